@@ -5,6 +5,7 @@ import (
 
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // ReadNBDVdisksConfig returns the requested NBDVdisksConfig
@@ -145,12 +146,19 @@ func ReadConfig(source Source, id string, keyType KeyType) ([]byte, error) {
 		source.MarkInvalidKey(configKey, "")
 	} else if errors.Cause(err) == ErrSourceUnavailable {
 		log.Errorf("couldn't fetch config %v: %v", configKey, err)
-		if source.Type() == "etcd" {
+		switch source.Type() {
+		case "etcd":
 			log.Broadcast(
 				log.StatusClusterTimeout,
 				log.SubjectETCD,
 				source.SourceConfig(),
 			)
+		case "redis":
+			log.Broadcast(
+				log.StatusClusterTimeout,
+				log.SubjectRedis,
+				source.SourceConfig(),
+			)
 		}
 	} else if errors.Cause(err) == ErrInvalidConfig {
 		source.MarkInvalidKey(configKey, "")
@@ -159,6 +167,76 @@ func ReadConfig(source Source, id string, keyType KeyType) ([]byte, error) {
 	return nil, err // config couldn't be read due to an error
 }
 
+// WriteVdiskStaticConfig writes the given VdiskStaticConfig
+// to a given config source, which has to be a SourceWriter.
+func WriteVdiskStaticConfig(source Source, vdiskID string, cfg VdiskStaticConfig) error {
+	return WriteConfig(source, vdiskID, KeyVdiskStatic, &cfg)
+}
+
+// WriteVdiskNBDConfig writes the given VdiskNBDConfig
+// to a given config source, which has to be a SourceWriter.
+func WriteVdiskNBDConfig(source Source, vdiskID string, cfg VdiskNBDConfig) error {
+	return WriteConfig(source, vdiskID, KeyVdiskNBD, &cfg)
+}
+
+// WriteConfig validates the given config,
+// and writes it in the YAML format to the given source,
+// which has to implement the SourceWriter interface.
+func WriteConfig(source Source, id string, keyType KeyType, cfg FormatValidator) error {
+	if source == nil {
+		return ErrNilSource
+	}
+	if id == "" {
+		return ErrNilID
+	}
+	writer, ok := source.(SourceWriter)
+	if !ok {
+		return errors.Wrapf(ErrSourceReadOnly,
+			"can't write config to %s source", source.Type())
+	}
+
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+	bytes, err := yaml.Marshal(cfg)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't serialize %s config %s", keyType, id)
+	}
+
+	return writer.Set(Key{ID: id, Type: keyType}, bytes)
+}
+
+// ParseConfig creates a new config of the given key type
+// from a given YAML slice, and validates it.
+func ParseConfig(keyType KeyType, data []byte) (FormatValidator, error) {
+	var cfg FormatValidator
+	var err error
+	switch keyType {
+	case KeyVdiskStatic:
+		cfg, err = NewVdiskStaticConfig(data)
+	case KeyVdiskNBD:
+		cfg, err = NewVdiskNBDConfig(data)
+	case KeyVdiskTlog:
+		cfg, err = NewVdiskTlogConfig(data)
+	case KeyClusterStorage:
+		cfg, err = NewStorageClusterConfig(data)
+	case KeyClusterZeroStor:
+		cfg, err = NewZeroStorClusterConfig(data)
+	case KeyClusterTlog:
+		cfg, err = NewTlogClusterConfig(data)
+	case KeyNBDServerVdisks:
+		cfg, err = NewNBDVdisksConfig(data)
+	default:
+		return nil, errors.Wrapf(ErrInvalidKey, "%v is not a supported key type", keyType)
+	}
+	if err != nil {
+		// ensure we don't return a non-nil interface containing a nil config
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // WatchNBDVdisksConfig watches a given source for NBDVdisksConfig updates.
 // Sends the initial config to the channel when created,
 // as well as any future updated versions of that config,
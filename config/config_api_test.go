@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-Disk/errors"
 )
 
 func TestReadNBDVdisksConfig(t *testing.T) {
@@ -626,3 +627,55 @@ func TestWatchNBDVdisksConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := VdiskStaticConfig{BlockSize: 4096, Size: 2, Type: VdiskTypeBoot, TemplateVdiskID: "t"}
+
+	err := WriteVdiskStaticConfig(nil, "a", cfg)
+	assert.Error(err, "should trigger error due to nil-source")
+
+	// once sources are read-only
+	source := NewStubSource()
+	source.SetVdiskConfig("b", &VdiskStaticConfig{BlockSize: 4096, Size: 1, Type: VdiskTypeDB})
+	err = WriteVdiskStaticConfig(NewOnceSource(source), "a", cfg)
+	assert.Equal(ErrSourceReadOnly, errors.Cause(err))
+
+	err = WriteVdiskStaticConfig(source, "a", VdiskStaticConfig{})
+	assert.Error(err, "should trigger error due to invalid config")
+
+	if assert.NoError(WriteVdiskStaticConfig(source, "a", cfg)) {
+		staticCfg, err := ReadVdiskStaticConfig(source, "a")
+		if assert.NoError(err) {
+			assert.Equal(cfg, *staticCfg)
+		}
+	}
+
+	nbdCfg := VdiskNBDConfig{StorageClusterID: "primary", TemplateStorageClusterID: "template"}
+	if assert.NoError(WriteVdiskNBDConfig(source, "a", nbdCfg)) {
+		cfg, err := ReadVdiskNBDConfig(source, "a")
+		if assert.NoError(err) {
+			assert.Equal(nbdCfg, *cfg)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := ParseConfig(KeyVdiskStatic, []byte("blockSize: 4096\nsize: 2\ntype: db\n"))
+	if assert.NoError(err) {
+		assert.Equal(&VdiskStaticConfig{BlockSize: 4096, Size: 2, Type: VdiskTypeDB}, cfg)
+	}
+
+	// invalid config
+	cfg, err = ParseConfig(KeyVdiskStatic, []byte("blockSize: 4096\n"))
+	assert.Error(err)
+	assert.Nil(cfg)
+
+	// unsupported key type
+	cfg, err = ParseConfig(KeyType(255), []byte("foo: bar\n"))
+	assert.Equal(ErrInvalidKey, errors.Cause(err))
+	assert.Nil(cfg)
+}
@@ -27,18 +27,24 @@ for each etcd key and sends the updated config key value to the channel returned
 The file source (intended for development) allows for getting the config from a YAML file.
 A file source needs a file path to where the config file can be found.
 How the YAML file should be formatted can be found in the file section of the 0-Disk config documentation: https://github.com/zero-os/0-Disk/blob/master/docs/config.md#file.
-For updates of the config, the file source will listen for a SIGHUP signal,
-each config key being watched by the Watch function will then read the config file and
-send the current subconfig in the file to the channel returned by the Watch function.
+For updates of the config, the file source watches the directory of the config file (using inotify),
+such that each config key being watched by the Watch function is reloaded as soon as the file is modified
+(or atomically replaced), sending the updated subconfig to the channel returned by the Watch function.
+A SIGHUP signal can still be used to force the reloading of all watched config keys.
+
+The redis source allows for getting the config from a Redis-compatible server (e.g. ARDB),
+using the same keys as the etcd source. For updates of the config, the redis source subscribes
+to notifications of each watched key, and polls it periodically as a fallback.
 
 The stub source (intended for testing) allows for tests to use a stubbed config where user has control
 of the data in the source, the stub source can be written to using the setters
-while other sources are not writable from 0-Disk, these setters also trigger a reload,
+while other sources can only be written to using the SourceWriter interface, these setters also trigger a reload,
 sending the newly set values to the config keys that are being watched using the Watch function.
 
 SourceConfig is a wrapper source type that switches between a file source or etcd source
 based on the provided input string. If it is a dailstring or list of dailstrings,
 it will assume that the source should be an etcd source.
+If it is a redis URL (redis://<address>[/<database>]), it will create a redis source.
 If it is an empty string, it will use the default file resource (config.yml) to create a file source.
 If it was none of the above it will asume the string is a path to a YAML file for a file source.
 
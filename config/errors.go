@@ -40,6 +40,14 @@ var (
 	// ErrInvalidConfig is returned when the given config was invalid
 	ErrInvalidConfig = errors.New("config is invalid")
 
+	// ErrSourceReadOnly is returned when a config is written
+	// to a source which doesn't implement the SourceWriter interface.
+	ErrSourceReadOnly = errors.New("config source is read-only")
+
+	// ErrConfigModified is returned when a config couldn't be swapped,
+	// as its current value isn't equal to the expected (old) value.
+	ErrConfigModified = errors.New("config was modified in source")
+
 	// ErrNilStorage is returned when a storage was nil while being required
 	ErrNilStorage = errors.New("storage is nil while it is required")
 )
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	valid "github.com/asaskevich/govalidator"
 	"github.com/zero-os/0-Disk/errors"
@@ -43,6 +44,11 @@ type NBDVdisksConfig struct {
 	Vdisks []string `yaml:"vdisks" valid:"required"`
 }
 
+// VdiskSnapshotSeparator separates the identifier of a vdisk
+// from the identifier of one of its snapshots,
+// and can therefore not be used within a vdisk identifier.
+const VdiskSnapshotSeparator = "@"
+
 // NewVdiskStaticConfig creates a new VdiskStaticConfig from a given YAML slice.
 func NewVdiskStaticConfig(data []byte) (*VdiskStaticConfig, error) {
 	staticfg := new(VdiskStaticConfig)
@@ -71,6 +77,14 @@ func (cfg *NBDVdisksConfig) Validate() error {
 			errors.Wrap(err, "invalid NBDVdisksConfig"))
 	}
 
+	for _, vdiskID := range cfg.Vdisks {
+		if strings.Contains(vdiskID, VdiskSnapshotSeparator) {
+			return errors.WrapError(ErrInvalidConfig, errors.Newf(
+				"invalid NBDVdisksConfig: vdisk ID %q contains reserved character %q",
+				vdiskID, VdiskSnapshotSeparator))
+		}
+	}
+
 	return nil
 }
 
@@ -431,6 +445,14 @@ func NewTlogClusterConfig(data []byte) (*TlogClusterConfig, error) {
 // A Tlog Server cluster is composed out of one or more Tlog servers.
 type TlogClusterConfig struct {
 	Servers []string `yaml:"servers" valid:"required"`
+	// DualWrite enables streaming all transactions
+	// to both servers at once, instead of only to the first server,
+	// and requires exactly two servers.
+	DualWrite bool `yaml:"dualWrite" valid:"optional"`
+	// WriteQuorum is the amount of servers which have to flush a transaction,
+	// before it is considered flushed, only used in DualWrite mode.
+	// When 0, a single server suffices.
+	WriteQuorum int `yaml:"writeQuorum" valid:"optional"`
 }
 
 // Validate implements FormatValidator.Validate.
@@ -451,13 +473,32 @@ func (cfg *TlogClusterConfig) Validate() error {
 		return err
 	}
 
+	if cfg.DualWrite {
+		if len(cfg.Servers) != 2 {
+			return errors.Newf(
+				"TlogClusterConfig requires exactly 2 servers in dualWrite mode, has %d",
+				len(cfg.Servers))
+		}
+		if cfg.WriteQuorum < 0 || cfg.WriteQuorum > 2 {
+			return errors.Newf(
+				"TlogClusterConfig has invalid writeQuorum %d, has to be 1 or 2 in dualWrite mode",
+				cfg.WriteQuorum)
+		}
+	}
+
 	return nil
 }
 
 // Clone implements Cloner.Clone
 func (cfg *TlogClusterConfig) Clone() TlogClusterConfig {
 	var clone TlogClusterConfig
-	if cfg == nil || cfg.Servers == nil {
+	if cfg == nil {
+		return clone
+	}
+
+	clone.DualWrite = cfg.DualWrite
+	clone.WriteQuorum = cfg.WriteQuorum
+	if cfg.Servers == nil {
 		return clone
 	}
 
@@ -21,6 +21,11 @@ foo: bar
 	`
 foo:
   - bar
+`,
+	`
+vdisks:
+  - foo
+  - foo@bar
 `,
 }
 
@@ -508,3 +513,64 @@ dataShards: 1
 parityShards: -1
 `,
 }
+
+var validTlogClusterConfigYAML = []string{
+	// minimal example
+	`
+servers:
+  - 1.1.1.1:11
+`, // failover example
+	`
+servers:
+  - 1.1.1.1:11
+  - 2.2.2.2:22
+  - 3.3.3.3:33
+`, // dual write example
+	`
+servers:
+  - 1.1.1.1:11
+  - 2.2.2.2:22
+dualWrite: true
+`, // dual write example with a write quorum of 2
+	`
+servers:
+  - 1.1.1.1:11
+  - 2.2.2.2:22
+dualWrite: true
+writeQuorum: 2
+`,
+}
+
+var invalidTlogClusterConfigYAML = []string{
+	``, // missing servers
+	`
+servers:
+  - foo
+`, // invalid server address
+	`
+servers:
+  - 1.1.1.1:11
+dualWrite: true
+`, // dual write requires exactly 2 servers
+	`
+servers:
+  - 1.1.1.1:11
+  - 2.2.2.2:22
+  - 3.3.3.3:33
+dualWrite: true
+`, // dual write requires exactly 2 servers
+	`
+servers:
+  - 1.1.1.1:11
+  - 2.2.2.2:22
+dualWrite: true
+writeQuorum: 3
+`, // write quorum too big
+	`
+servers:
+  - 1.1.1.1:11
+  - 2.2.2.2:22
+dualWrite: true
+writeQuorum: -1
+`, // negative write quorum
+}
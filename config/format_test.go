@@ -227,6 +227,25 @@ func TestStorageServerConfigYamlUnmarshalAndValidate(t *testing.T) {
 	}
 }
 
+func TestNewTlogClusterConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, validCase := range validTlogClusterConfigYAML {
+		cfg, err := NewTlogClusterConfig([]byte(validCase))
+		if assert.NoError(err, validCase) {
+			assert.NotNil(cfg, validCase)
+		}
+	}
+
+	for _, invalidCase := range invalidTlogClusterConfigYAML {
+		cfg, err := NewTlogClusterConfig([]byte(invalidCase))
+		if assert.Error(err, invalidCase) {
+			t.Logf("NewTlogClusterConfig error: %v", err)
+			assert.Nil(cfg, invalidCase)
+		}
+	}
+}
+
 func TestTlogClusterConfigClone(t *testing.T) {
 	assert := assert.New(t)
 
@@ -248,6 +267,11 @@ func TestTlogClusterConfigClone(t *testing.T) {
 	assert.NotEqual(a.Servers, b.Servers, "one server isn't equal any longer")
 	a.Servers[0] = "localhost:200"
 	assert.Equal(a.Servers, b.Servers, "should be equal")
+
+	a.DualWrite = true
+	a.WriteQuorum = 2
+	b = a.Clone()
+	assert.Equal(a, b, "should be equal")
 }
 
 func TestNewZeroStorClusterConfig(t *testing.T) {
@@ -10,12 +10,21 @@ import (
 // NewSource creates a new Source based on the given configuration.
 // Make sure to close the returned Source to avoid any leaks.
 func NewSource(config SourceConfig) (SourceCloser, error) {
-	if config.SourceType == FileSourceType {
+	switch config.SourceType {
+	case FileSourceType:
 		path, err := fileResource(config.Resource)
 		if err != nil {
 			return nil, errors.Wrap(err, "can't create source")
 		}
 		return FileSource(path)
+
+	case RedisSourceType:
+		resource, ok := config.Resource.(string)
+		if !ok {
+			return nil, errors.Newf(
+				"can't create source: '%v' is not a valid redis resource", config.Resource)
+		}
+		return RedisSource(resource)
 	}
 
 	endpoints, err := etcdResource(config.Resource)
@@ -49,6 +58,19 @@ type Source interface {
 	Type() string
 }
 
+// SourceWriter defines an optional API,
+// implemented by a Source which also allows configs to be written.
+type SourceWriter interface {
+	// Set a content value as a YAML byte slice,
+	// using a given Key, overwriting any existing value.
+	Set(key Key, value []byte) error
+	// CompareAndSwap sets a content value as a YAML byte slice,
+	// using a given Key, only if the current value equals the old value.
+	// A nil old value means that no value may exist yet for that Key.
+	// ErrConfigModified is returned in case the current value is different.
+	CompareAndSwap(key Key, old, new []byte) error
+}
+
 // SourceCloser defines a Source which
 // can and has to be closed by the user.
 type SourceCloser interface {
@@ -90,6 +112,7 @@ const (
 	KeyVdiskNBDStr        = "VdiskNBD"
 	KeyVdiskTlogStr       = "VdiskTlog"
 	KeyClusterStorageStr  = "ClusterStorage"
+	KeyClusterZeroStorStr = "ClusterZeroStor"
 	KeyClusterTlogStr     = "ClusterTlog"
 	KeyNBDServerVdisksStr = "NBDServerVdisks"
 )
@@ -104,6 +127,8 @@ func (kt KeyType) String() string {
 		return KeyVdiskTlogStr
 	case KeyClusterStorage:
 		return KeyClusterStorageStr
+	case KeyClusterZeroStor:
+		return KeyClusterZeroStorStr
 	case KeyClusterTlog:
 		return KeyClusterTlogStr
 	case KeyNBDServerVdisks:
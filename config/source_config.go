@@ -10,6 +10,16 @@ import (
 // implicitly infering the source type based on the given data,
 // and based on it use the data as the config's resource.
 func NewSourceConfig(data string) (SourceConfig, error) {
+	if strings.HasPrefix(strings.TrimSpace(data), redisResourceScheme+"://") {
+		if _, _, err := redisResource(data); err != nil {
+			return SourceConfig{}, err
+		}
+		return SourceConfig{
+			Resource:   strings.TrimSpace(data),
+			SourceType: RedisSourceType,
+		}, nil
+	}
+
 	if endpoints, err := etcdResourceFromString(data); err == nil {
 		return SourceConfig{
 			Resource:   endpoints,
@@ -36,6 +46,7 @@ type SourceConfig struct {
 	// The resource used to identify the specific config origins.
 	// Type = file -> Resource defines file path.
 	// Type = etcd -> Resource defines etcd endpoints.
+	// Type = redis -> Resource defines a redis URL (redis://<address>[/<database>]).
 	Resource interface{}
 	// Defines the type of source to be read from,
 	// and thus also what type of Resource this is.
@@ -57,9 +68,13 @@ func (cfg *SourceConfig) String() string {
 		return defaultFileResource
 	}
 
-	if cfg.SourceType == FileSourceType {
+	switch cfg.SourceType {
+	case FileSourceType:
 		str, _ := fileResource(cfg.Resource)
 		return str
+	case RedisSourceType:
+		str, _ := cfg.Resource.(string)
+		return str
 	}
 
 	// for all other resource type value we'll assume it's the etcd source
@@ -90,17 +105,23 @@ const (
 	FileSourceType SourceType = 0
 	// ETCDSourceType defines the etcd config resource
 	ETCDSourceType SourceType = 1
+	// RedisSourceType defines the redis config resource
+	RedisSourceType SourceType = 2
 )
 
 const (
-	fileSourceTypeString = "file"
-	etcdSourceTypeString = "etcd"
+	fileSourceTypeString  = "file"
+	etcdSourceTypeString  = "etcd"
+	redisSourceTypeString = "redis"
 )
 
 // String returns the name of the Config Source Type
 func (st SourceType) String() string {
-	if st == FileSourceType {
+	switch st {
+	case FileSourceType:
 		return fileSourceTypeString
+	case RedisSourceType:
+		return redisSourceTypeString
 	}
 
 	// default to etcd
@@ -108,7 +129,7 @@ func (st SourceType) String() string {
 }
 
 // Set allows you to set this Config Source Type
-// using a raw string. Options: {etcd, file}
+// using a raw string. Options: {etcd, file, redis}
 func (st *SourceType) Set(str string) error {
 	if str == "" {
 		return errors.New("no string was given")
@@ -119,6 +140,8 @@ func (st *SourceType) Set(str string) error {
 		*st = FileSourceType
 	case etcdSourceTypeString:
 		*st = ETCDSourceType
+	case redisSourceTypeString:
+		*st = RedisSourceType
 	default:
 		return errors.New(str + " is not a valid config source type")
 	}
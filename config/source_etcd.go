@@ -68,6 +68,66 @@ func (s *etcdv3Source) Get(key Key) ([]byte, error) {
 	return resp.Kvs[0].Value, nil
 }
 
+// Set implements SourceWriter.Set
+func (s *etcdv3Source) Set(key Key, value []byte) error {
+	// create ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// convert our internal key type to an etcd key
+	keyString, err := ETCDKey(key.ID, key.Type)
+	if err != nil {
+		log.Errorf("invalid config key: %v", err)
+		return ErrInvalidKey
+	}
+
+	// set value
+	_, err = s.client.Put(ctx, keyString, string(value))
+	if err != nil {
+		log.Errorf("could not set key '%s' in ETCD: %v", keyString, err)
+		return ErrSourceUnavailable
+	}
+
+	return nil
+}
+
+// CompareAndSwap implements SourceWriter.CompareAndSwap
+func (s *etcdv3Source) CompareAndSwap(key Key, old, new []byte) error {
+	// create ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// convert our internal key type to an etcd key
+	keyString, err := ETCDKey(key.ID, key.Type)
+	if err != nil {
+		log.Errorf("invalid config key: %v", err)
+		return ErrInvalidKey
+	}
+
+	// a nil old value means that the key may not exist yet
+	var cmp clientv3.Cmp
+	if old == nil {
+		cmp = clientv3.Compare(clientv3.CreateRevision(keyString), "=", 0)
+	} else {
+		cmp = clientv3.Compare(clientv3.Value(keyString), "=", string(old))
+	}
+
+	// set value, only if the comparison succeeds
+	resp, err := s.client.Txn(ctx).
+		If(cmp).
+		Then(clientv3.OpPut(keyString, string(new))).
+		Commit()
+	if err != nil {
+		log.Errorf("could not set key '%s' in ETCD: %v", keyString, err)
+		return ErrSourceUnavailable
+	}
+	if !resp.Succeeded {
+		return errors.Wrapf(ErrConfigModified, "key '%s' was modified", keyString)
+	}
+
+	return nil
+}
+
 // Watch implements Source.Watch
 func (s *etcdv3Source) Watch(ctx context.Context, key Key) (<-chan []byte, error) {
 	// convert our internal key type to an etcd key
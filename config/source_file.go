@@ -1,10 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"syscall"
 
 	"github.com/zero-os/0-Disk/errors"
@@ -20,12 +23,22 @@ func FileSource(path string) (SourceCloser, error) {
 	return &fileSource{
 		path:   path,
 		reader: ioutil.ReadFile,
+		writer: writeFileAtomic,
 	}, nil
 }
 
 type fileSource struct {
 	path   string
 	reader func(string) ([]byte, error)
+	writer func(string, []byte) error
+
+	// serializes all writes within this process
+	writeMux sync.Mutex
+
+	// watches the file for changes,
+	// only active as long as at least one key is watched
+	watchMux sync.Mutex
+	watcher  *fileWatcher
 }
 
 // Get implements Source.Get
@@ -75,41 +88,125 @@ func (s *fileSource) Get(key Key) ([]byte, error) {
 	}
 }
 
+// Set implements SourceWriter.Set
+// NOTE: any comments or custom formatting are lost when writing to the file.
+func (s *fileSource) Set(key Key, value []byte) error {
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+
+	return s.set(key, value)
+}
+
+// CompareAndSwap implements SourceWriter.CompareAndSwap
+// NOTE: the comparison is only atomic for writes made within this process.
+func (s *fileSource) CompareAndSwap(key Key, old, new []byte) error {
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+
+	current, err := s.Get(key)
+	if err != nil {
+		if cause := errors.Cause(err); cause != ErrConfigUnavailable && cause != ErrInvalidConfig {
+			return err
+		}
+		current = nil // config doesn't exist (yet)
+	}
+	if (old == nil) != (current == nil) || !bytes.Equal(old, current) {
+		return errors.Wrapf(ErrConfigModified, "%v was modified in '%s'", key, s.path)
+	}
+
+	return s.set(key, new)
+}
+
+// set the given value, embedding it in the entire config stored in the file
+func (s *fileSource) set(key Key, value []byte) error {
+	cfg, err := s.readFullFile()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.setConfig(key, value)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return errors.Wrap(err, "couldn't serialize file config")
+	}
+
+	err = s.writer(s.path, data)
+	if err != nil {
+		log.Errorf("couldn't write file config: %v", err)
+		return ErrSourceUnavailable
+	}
+	return nil
+}
+
 // Watch implements Source.Watch
+// NOTE: a key is reloaded when the file is modified, or when a SIGHUP signal is received.
 func (s *fileSource) Watch(ctx context.Context, key Key) (<-chan []byte, error) {
 	// setup SIGHUP
 	sighup := make(chan os.Signal, 1)
 	signal.Notify(sighup, syscall.SIGHUP)
 
+	// get notified of file changes,
+	// falling back to SIGHUP only in case the file can't be watched
+	changed, err := s.subscribe()
+	if err != nil {
+		log.Errorf(
+			"couldn't watch config file '%s', reload it using SIGHUP instead: %v", s.path, err)
+	}
+
+	// get the current value, such that only updates are sent on file changes
+	last, _ := s.Get(key)
+
 	ch := make(chan []byte)
 
 	go func() {
-		log.Debugf("Started (SIGHUP) watch goroutine for: %v", key)
+		log.Debugf("Started watch goroutine for: %v", key)
 		defer signal.Stop(sighup)
+		defer s.unsubscribe(changed)
 		defer close(ch)
-		defer log.Debugf("Closing (SIGHUP) watch goroutine for %v", key)
+		defer log.Debugf("Closing watch goroutine for %v", key)
 
 		for {
+			var forced bool
 			select {
 			case <-ctx.Done():
 				return
 			case <-sighup:
 				log.Debug("Received SIGHUP for: ", s.path)
-				// read, deserialize and serialize sub config
-				output, err := s.Get(key)
-				if errors.Cause(err) == ErrSourceUnavailable {
-					log.Errorf(
-						"getting key %v failed, due to the source being unavailable",
-						key)
+				forced = true
+			case <-changed:
+				log.Debugf("Config file '%s' was modified, reloading: %v", s.path, key)
+			}
+
+			// read, deserialize and serialize sub config
+			output, err := s.Get(key)
+			if errors.Cause(err) == ErrSourceUnavailable {
+				log.Errorf(
+					"getting key %v failed, due to the source being unavailable",
+					key)
+				if forced {
 					return
 				}
+				// the file might be in the middle of being replaced,
+				// in which case another change will follow soon
+				continue
+			}
+			// only send changed keys, unless explicitly asked to reload
+			if !forced && bytes.Equal(last, output) && (last == nil) == (output == nil) {
+				continue
+			}
+			last = output
 
-				select {
-				case ch <- output:
-				case <-ctx.Done():
-					log.Errorf(
-						"timed out while attempting to send updated config (%d)", key.Type)
-				}
+			// an invalid value (nil) is sent as well,
+			// such that the receiver can keep its last valid config and mark the key as invalid
+			select {
+			case ch <- output:
+			case <-ctx.Done():
+				log.Errorf(
+					"timed out while attempting to send updated config (%d)", key.Type)
 			}
 		}
 	}()
@@ -117,15 +214,59 @@ func (s *fileSource) Watch(ctx context.Context, key Key) (<-chan []byte, error)
 	return ch, nil
 }
 
+// subscribe to changes of the config file,
+// starting to watch the file in case it wasn't watched yet.
+func (s *fileSource) subscribe() (chan struct{}, error) {
+	s.watchMux.Lock()
+	defer s.watchMux.Unlock()
+
+	if s.watcher == nil {
+		watcher, err := newFileWatcher(s.path)
+		if err != nil {
+			return nil, err
+		}
+		s.watcher = watcher
+	}
+	return s.watcher.subscribe(), nil
+}
+
+// unsubscribe from changes of the config file,
+// no longer watching the file in case it was the last subscriber.
+func (s *fileSource) unsubscribe(ch chan struct{}) {
+	if ch == nil {
+		return
+	}
+
+	s.watchMux.Lock()
+	defer s.watchMux.Unlock()
+
+	if s.watcher == nil {
+		return
+	}
+	if s.watcher.unsubscribe(ch) == 0 {
+		s.watcher.close()
+		s.watcher = nil
+	}
+}
+
 // MarkInvalidKey implements Source.MarkInvalidKey
 func (s *fileSource) MarkInvalidKey(key Key, vdiskID string) {
 	if vdiskID == "" {
 		log.Errorf("%v in '%s' is invalid", key, s.path)
-		return
+	} else {
+		log.Errorf("%v in '%s' is invalid when used for vdisk %s",
+			key, s.path, vdiskID)
 	}
 
-	log.Errorf("%v in '%s' is invalid when used for vdisk %s",
-		key, s.path, vdiskID)
+	log.Broadcast(
+		log.StatusInvalidConfig,
+		log.SubjectFile,
+		log.InvalidConfigBody{
+			Endpoints: []string{s.path},
+			Key:       key.String(),
+			VdiskID:   vdiskID,
+		},
+	)
 }
 
 // SourceConfig implements Source.SourceConfig
@@ -140,6 +281,13 @@ func (s *fileSource) Type() string {
 
 // Close implements Source.Close
 func (s *fileSource) Close() error {
+	s.watchMux.Lock()
+	defer s.watchMux.Unlock()
+
+	if s.watcher != nil {
+		s.watcher.close()
+		s.watcher = nil
+	}
 	return nil
 }
 
@@ -279,6 +427,95 @@ func (cfg *FileFormatCompleteConfig) TlogClusterConfig(id string) (*TlogClusterC
 	return &tlogClusterConfig, nil
 }
 
+// setConfig parses the given YAML value as the config of the given key,
+// and embeds it in the YAML zerodisk config file,
+// overwriting any config which existed already for that key.
+func (cfg *FileFormatCompleteConfig) setConfig(key Key, value []byte) error {
+	switch key.Type {
+	case KeyVdiskStatic:
+		static, err := NewVdiskStaticConfig(value)
+		if err != nil {
+			return err
+		}
+		if cfg.Vdisks == nil {
+			cfg.Vdisks = make(map[string]FileFormatVdiskConfig)
+		}
+		vdiskCfg := cfg.Vdisks[key.ID]
+		vdiskCfg.BlockSize = static.BlockSize
+		vdiskCfg.ReadOnly = static.ReadOnly
+		vdiskCfg.Size = static.Size
+		vdiskCfg.VdiskType = static.Type
+		vdiskCfg.TemplateVdiskID = static.TemplateVdiskID
+		cfg.Vdisks[key.ID] = vdiskCfg
+
+	case KeyVdiskNBD:
+		nbd, err := NewVdiskNBDConfig(value)
+		if err != nil {
+			return err
+		}
+		vdiskCfg, ok := cfg.Vdisks[key.ID]
+		if !ok {
+			return errors.Wrapf(ErrConfigUnavailable,
+				"file config has no vdisk config under the id %s", key.ID)
+		}
+		vdiskCfg.NBD = nbd
+		cfg.Vdisks[key.ID] = vdiskCfg
+
+	case KeyVdiskTlog:
+		tlog, err := NewVdiskTlogConfig(value)
+		if err != nil {
+			return err
+		}
+		vdiskCfg, ok := cfg.Vdisks[key.ID]
+		if !ok {
+			return errors.Wrapf(ErrConfigUnavailable,
+				"file config has no vdisk config under the id %s", key.ID)
+		}
+		vdiskCfg.Tlog = tlog
+		cfg.Vdisks[key.ID] = vdiskCfg
+
+	case KeyClusterStorage:
+		storageCluster, err := NewStorageClusterConfig(value)
+		if err != nil {
+			return err
+		}
+		if cfg.StorageClusters == nil {
+			cfg.StorageClusters = make(map[string]StorageClusterConfig)
+		}
+		cfg.StorageClusters[key.ID] = *storageCluster
+
+	case KeyClusterZeroStor:
+		zeroStorCluster, err := NewZeroStorClusterConfig(value)
+		if err != nil {
+			return err
+		}
+		if cfg.ZeroStorClusters == nil {
+			cfg.ZeroStorClusters = make(map[string]ZeroStorClusterConfig)
+		}
+		cfg.ZeroStorClusters[key.ID] = *zeroStorCluster
+
+	case KeyClusterTlog:
+		tlogCluster, err := NewTlogClusterConfig(value)
+		if err != nil {
+			return err
+		}
+		if cfg.TlogClusters == nil {
+			cfg.TlogClusters = make(map[string]TlogClusterConfig)
+		}
+		cfg.TlogClusters[key.ID] = *tlogCluster
+
+	default:
+		// the NBD vdisks config is derived from the vdisk configs
+		return errors.Wrapf(
+			ErrInvalidKey,
+			"%v is not a supported key type to write to the file config",
+			key.Type,
+		)
+	}
+
+	return nil
+}
+
 // FileFormatVdiskConfig is the YAML format struct
 // used for all vdisk file-originated configurations.
 type FileFormatVdiskConfig struct {
@@ -324,6 +561,38 @@ func (cfg *FileFormatVdiskConfig) TlogConfig() (*VdiskTlogConfig, error) {
 	return cfg.Tlog, nil
 }
 
+// writeFileAtomic writes the given data to a temporary file first,
+// and renames it to the given path afterwards,
+// such that readers never read a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // no-op once renamed
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// preserve the permissions of the original file, if it exists
+	if info, err := os.Stat(path); err == nil {
+		err = os.Chmod(file.Name(), info.Mode())
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
 // if no error is given, we serialize the given value (unless it's nil)
 // into the YAML format, and return it (or an error if that didn't go well either).
 func serializeConfigReply(value interface{}, err error) ([]byte, error) {
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/errors"
+)
+
+func TestFileSourceSet(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "zerodisk-config")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	err = ioutil.WriteFile(path, []byte(`
+storageClusters:
+  mycluster:
+    servers:
+      - address: 127.0.0.1:16379
+vdisks:
+  myvdisk:
+    blockSize: 4096
+    readOnly: false
+    size: 10
+    type: boot
+    nbd:
+      storageClusterID: mycluster
+`), 0600)
+	require.NoError(err)
+
+	source, err := FileSource(path)
+	require.NoError(err)
+	defer source.Close()
+	writer, ok := source.(SourceWriter)
+	require.True(ok, "file source should be writable")
+
+	clusterKey := Key{ID: "mycluster", Type: KeyClusterStorage}
+	oldCluster, err := source.Get(clusterKey)
+	require.NoError(err)
+
+	// overwrite an existing cluster config
+	newCluster := []byte("servers:\n- address: 127.0.0.1:16380\n")
+	err = writer.Set(clusterKey, newCluster)
+	require.NoError(err)
+	clusterCfg, err := ReadStorageClusterConfig(source, "mycluster")
+	require.NoError(err)
+	assert.Equal("127.0.0.1:16380", clusterCfg.Servers[0].Address)
+
+	// swapping using an outdated value should fail
+	err = writer.CompareAndSwap(clusterKey, oldCluster, oldCluster)
+	assert.Equal(ErrConfigModified, errors.Cause(err))
+
+	// swapping using the current value should succeed
+	current, err := source.Get(clusterKey)
+	require.NoError(err)
+	err = writer.CompareAndSwap(clusterKey, current, oldCluster)
+	require.NoError(err)
+	clusterCfg, err = ReadStorageClusterConfig(source, "mycluster")
+	require.NoError(err)
+	assert.Equal("127.0.0.1:16379", clusterCfg.Servers[0].Address)
+
+	// a nil old value only succeeds for a new config
+	err = writer.CompareAndSwap(clusterKey, nil, newCluster)
+	assert.Equal(ErrConfigModified, errors.Cause(err))
+	err = writer.CompareAndSwap(Key{ID: "newcluster", Type: KeyClusterStorage}, nil, newCluster)
+	require.NoError(err)
+	_, err = ReadStorageClusterConfig(source, "newcluster")
+	assert.NoError(err)
+
+	// vdisk (static) configs can be created, other vdisk configs can only be updated
+	err = writer.Set(Key{ID: "newvdisk", Type: KeyVdiskNBD}, []byte("storageClusterID: mycluster\n"))
+	assert.Equal(ErrConfigUnavailable, errors.Cause(err))
+	err = writer.Set(Key{ID: "newvdisk", Type: KeyVdiskStatic}, []byte("blockSize: 4096\nsize: 2\ntype: db\n"))
+	require.NoError(err)
+	err = writer.Set(Key{ID: "newvdisk", Type: KeyVdiskNBD}, []byte("storageClusterID: newcluster\n"))
+	require.NoError(err)
+	nbdCfg, err := ReadVdiskNBDConfig(source, "newvdisk")
+	require.NoError(err)
+	assert.Equal("newcluster", nbdCfg.StorageClusterID)
+
+	// existing configs should be preserved
+	staticCfg, err := ReadVdiskStaticConfig(source, "myvdisk")
+	require.NoError(err)
+	assert.Equal(VdiskTypeBoot, staticCfg.Type)
+
+	// invalid configs can't be written
+	err = writer.Set(clusterKey, []byte("servers: []\n"))
+	assert.Error(err)
+
+	// the file permissions should be preserved
+	info, err := os.Stat(path)
+	require.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestFileSourceWatch(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "zerodisk-config")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	const configFormat = `
+storageClusters:
+  mycluster:
+    servers:
+      - address: %s
+  othercluster:
+    servers:
+      - address: %s
+vdisks:
+  myvdisk:
+    blockSize: 4096
+    size: 10
+    type: boot
+    nbd:
+      storageClusterID: mycluster
+`
+	path := filepath.Join(dir, "config.yml")
+	writeConfig := func(address, otherAddress string) {
+		err := ioutil.WriteFile(path, []byte(fmt.Sprintf(configFormat, address, otherAddress)), 0600)
+		require.NoError(err)
+	}
+	writeConfig("127.0.0.1:16379", "127.0.0.1:16380")
+
+	source, err := FileSource(path)
+	require.NoError(err)
+	defer source.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := source.Watch(ctx, Key{ID: "mycluster", Type: KeyClusterStorage})
+	require.NoError(err)
+
+	expectUpdate := func(expectedAddress string) {
+		select {
+		case data := <-ch:
+			if expectedAddress == "" {
+				require.Nil(data, "invalid config should be sent as nil")
+				return
+			}
+			cfg, err := NewStorageClusterConfig(data)
+			require.NoError(err)
+			require.Equal(expectedAddress, cfg.Servers[0].Address)
+		case <-time.After(time.Second * 5):
+			require.FailNow("timed out while waiting for update", expectedAddress)
+		}
+	}
+	expectNoUpdate := func() {
+		select {
+		case data := <-ch:
+			require.FailNow("unexpected update", "%s", data)
+		case <-time.After(fileWatchDebounce * 3):
+		}
+	}
+
+	// modifying the file in place
+	writeConfig("127.0.0.1:2000", "127.0.0.1:16380")
+	expectUpdate("127.0.0.1:2000")
+
+	// modifying another config in the file
+	writeConfig("127.0.0.1:2000", "127.0.0.1:3000")
+	expectNoUpdate()
+
+	// replacing the file atomically
+	err = writeFileAtomic(path, []byte(fmt.Sprintf(configFormat, "127.0.0.1:4000", "127.0.0.1:3000")))
+	require.NoError(err)
+	expectUpdate("127.0.0.1:4000")
+
+	// writing an invalid config
+	err = ioutil.WriteFile(path, []byte("storageClusters: {"), 0600)
+	require.NoError(err)
+	expectUpdate("")
+
+	// fixing the config
+	writeConfig("127.0.0.1:5000", "127.0.0.1:3000")
+	expectUpdate("127.0.0.1:5000")
+
+	// the channel is closed once the context is cancelled
+	cancel()
+	select {
+	case _, open := <-ch:
+		require.False(open, "channel should be closed")
+	case <-time.After(time.Second):
+		require.FailNow("timed out while waiting for channel to close")
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/zero-os/0-Disk/log"
+)
+
+// newFileWatcher creates a new watcher for the given config file.
+// The directory of the file is watched, rather than the file itself,
+// such that the file is still watched after it's atomically replaced (renamed),
+// which is how most editors (and the file source itself) write a file.
+func newFileWatcher(path string) (*fileWatcher, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	w := &fileWatcher{
+		path:        path,
+		watcher:     watcher,
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+	go w.listen()
+	return w, nil
+}
+
+// fileWatcher notifies all its subscribers when a config file is modified.
+// Bursts of file events are debounced, such that subscribers are only notified
+// once a file has been written completely.
+type fileWatcher struct {
+	path    string
+	watcher *fsnotify.Watcher
+
+	mux         sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+// subscribe to changes of the config file.
+func (w *fileWatcher) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	w.mux.Lock()
+	w.subscribers[ch] = struct{}{}
+	w.mux.Unlock()
+
+	return ch
+}
+
+// unsubscribe from changes of the config file,
+// returning the amount of subscribers left.
+func (w *fileWatcher) unsubscribe(ch chan struct{}) int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	delete(w.subscribers, ch)
+	return len(w.subscribers)
+}
+
+// close the watcher, no longer notifying any subscribers.
+func (w *fileWatcher) close() {
+	err := w.watcher.Close()
+	if err != nil {
+		log.Errorf("couldn't close watcher of config file '%s': %v", w.path, err)
+	}
+}
+
+// listen to file events, until the watcher is closed
+func (w *fileWatcher) listen() {
+	log.Debugf("started watching config file '%s'", w.path)
+	defer log.Debugf("stopped watching config file '%s'", w.path)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != w.path || event.Op&fileWatchOps == 0 {
+				continue
+			}
+			// (re)start the debounce period
+			debounce = time.After(fileWatchDebounce)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("error while watching config file '%s': %v", w.path, err)
+
+		case <-debounce:
+			debounce = nil
+			w.notify()
+		}
+	}
+}
+
+// notify all subscribers that the file was modified
+func (w *fileWatcher) notify() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	for ch := range w.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default: // a notification is already pending
+		}
+	}
+}
+
+// all file events which (might) modify the content of a file
+const fileWatchOps = fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove
+
+var (
+	// time to wait after the last file event, before notifying subscribers
+	fileWatchDebounce = 100 * time.Millisecond
+)
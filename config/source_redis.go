@@ -0,0 +1,389 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+)
+
+// RedisSource creates a config source,
+// where the configurations originate from a Redis-compatible server (e.g. ARDB).
+// The resource has the format `redis://<address>[/<database>]`.
+//
+// Configs are stored as string values, using the same keys as the etcd source.
+// Watched keys are updated as soon as a notification is received,
+// which is published by this source for every config it writes,
+// as well as by the server itself in case keyspace notifications are enabled.
+// Watched keys are also polled periodically, such that updates are never missed,
+// even when the server doesn't support pub/sub at all.
+func RedisSource(resource string) (SourceCloser, error) {
+	address, db, err := redisResource(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &redisSource{
+		resource:     resource,
+		address:      address,
+		db:           db,
+		pollInterval: redisPollInterval,
+	}
+	source.pool = &redis.Pool{
+		MaxIdle:     redisMaxIdleConnections,
+		IdleTimeout: redisIdleTimeout,
+		Dial:        source.dial,
+	}
+
+	// ensure the server is available
+	conn := source.pool.Get()
+	defer conn.Close()
+	_, err = conn.Do("PING")
+	if err != nil {
+		source.pool.Close()
+		log.Errorf("RedisSource requires an available redis server: %v", err)
+		log.Broadcast(
+			log.StatusClusterTimeout,
+			log.SubjectRedis,
+			resource,
+		)
+		return nil, ErrSourceUnavailable
+	}
+
+	return source, nil
+}
+
+type redisSource struct {
+	resource     string
+	address      string
+	db           int
+	pool         *redis.Pool
+	pollInterval time.Duration
+}
+
+// Get implements Source.Get
+func (s *redisSource) Get(key Key) ([]byte, error) {
+	keyString, err := ETCDKey(key.ID, key.Type)
+	if err != nil {
+		log.Errorf("invalid config key: %v", err)
+		return nil, ErrInvalidKey
+	}
+
+	value, err := s.get(keyString)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		log.Errorf("key '%s' was not found on the redis server", keyString)
+		return nil, ErrConfigUnavailable
+	}
+	return value, nil
+}
+
+// get the value of a given key, returning nil if it doesn't exist
+func (s *redisSource) get(keyString string) ([]byte, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	value, err := redis.Bytes(conn.Do("GET", keyString))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		log.Errorf("could not get key '%s' from redis: %v", keyString, err)
+		return nil, ErrSourceUnavailable
+	}
+	return value, nil
+}
+
+// Set implements SourceWriter.Set
+func (s *redisSource) Set(key Key, value []byte) error {
+	keyString, err := ETCDKey(key.ID, key.Type)
+	if err != nil {
+		log.Errorf("invalid config key: %v", err)
+		return ErrInvalidKey
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("SET", keyString, value)
+	if err != nil {
+		log.Errorf("could not set key '%s' in redis: %v", keyString, err)
+		return ErrSourceUnavailable
+	}
+
+	s.notify(conn, keyString)
+	return nil
+}
+
+// CompareAndSwap implements SourceWriter.CompareAndSwap
+func (s *redisSource) CompareAndSwap(key Key, old, new []byte) error {
+	keyString, err := ETCDKey(key.ID, key.Type)
+	if err != nil {
+		log.Errorf("invalid config key: %v", err)
+		return ErrInvalidKey
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	// a nil old value means that the key may not exist yet
+	mustNotExist := 0
+	if old == nil {
+		mustNotExist = 1
+	}
+	swapped, err := redis.Bool(redisCompareAndSwapScript.Do(
+		conn, keyString, mustNotExist, old, new))
+	if err != nil {
+		log.Errorf("could not set key '%s' in redis: %v", keyString, err)
+		return ErrSourceUnavailable
+	}
+	if !swapped {
+		return errors.Wrapf(ErrConfigModified, "key '%s' was modified", keyString)
+	}
+
+	s.notify(conn, keyString)
+	return nil
+}
+
+// notify all watchers of a given key, that its value was updated.
+// Failing to notify isn't an error, as watched keys are polled as well.
+func (s *redisSource) notify(conn redis.Conn, keyString string) {
+	_, err := conn.Do("PUBLISH", redisNotifyChannel(keyString), "set")
+	if err != nil {
+		log.Debugf("couldn't publish update of key '%s' to redis: %v", keyString, err)
+	}
+}
+
+// Watch implements Source.Watch
+func (s *redisSource) Watch(ctx context.Context, key Key) (<-chan []byte, error) {
+	keyString, err := ETCDKey(key.ID, key.Type)
+	if err != nil {
+		log.Errorf("invalid config key: %v", err)
+		return nil, ErrInvalidKey
+	}
+
+	// get the current value, such that only updates are sent
+	last, err := s.get(keyString)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	notifyCh := s.subscribe(ctx, keyString)
+
+	ch := make(chan []byte, 1)
+
+	go func() {
+		log.Debugf("watch goroutine for redis key '%s' started", keyString)
+		defer cancel()
+		defer log.Debugf("watch goroutine for redis key '%s' closed", keyString)
+		defer close(ch)
+
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-notifyCh:
+			case <-ticker.C:
+			}
+
+			value, err := s.get(keyString)
+			if err != nil {
+				continue // error is already logged, try again later
+			}
+			if bytes.Equal(last, value) && (last == nil) == (value == nil) {
+				continue // value wasn't updated
+			}
+			last = value
+
+			log.Debugf("value for %s received an update", keyString)
+
+			// send (updated) value
+			select {
+			case ch <- value:
+			case <-ctx.Done():
+				log.Errorf(
+					"timed out while attempting to send updated config (%s)", keyString)
+				return
+			}
+		}
+	}()
+
+	// watch function active
+	return ch, nil
+}
+
+// subscribe to all update notifications of a given key,
+// returning a nil channel in case the server doesn't support pub/sub.
+func (s *redisSource) subscribe(ctx context.Context, keyString string) <-chan struct{} {
+	conn, err := s.dial()
+	if err != nil {
+		log.Errorf("couldn't subscribe to updates of key '%s': %v", keyString, err)
+		return nil
+	}
+	psc := redis.PubSubConn{Conn: conn}
+
+	err = psc.Subscribe(redisNotifyChannel(keyString), redisKeyspaceChannel(s.db, keyString))
+	if err == nil {
+		if reply, ok := psc.Receive().(error); ok {
+			err = reply
+		}
+	}
+	if err != nil {
+		log.Debugf(
+			"couldn't subscribe to updates of key '%s', polling it instead: %v", keyString, err)
+		conn.Close()
+		return nil
+	}
+
+	ch := make(chan struct{}, 1)
+
+	// closing the connection stops the receive loop
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		for {
+			switch reply := psc.Receive().(type) {
+			case redis.Message:
+				select {
+				case ch <- struct{}{}:
+				default: // an update is already pending
+				}
+			case error:
+				if ctx.Err() == nil {
+					log.Errorf(
+						"subscription to updates of key '%s' stopped, polling it instead: %v",
+						keyString, reply)
+				}
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// MarkInvalidKey implements Source.MarkInvalidKey
+func (s *redisSource) MarkInvalidKey(key Key, vdiskID string) {
+	keyStr, err := ETCDKey(key.ID, key.Type)
+	if err != nil {
+		panic(err) // should never happen
+	}
+
+	log.Errorf(
+		"received invalid redis config '%s' (vdisk:'%s')", keyStr, vdiskID)
+
+	log.Broadcast(
+		log.StatusInvalidConfig,
+		log.SubjectRedis,
+		log.InvalidConfigBody{
+			Endpoints: []string{s.resource},
+			Key:       keyStr,
+			VdiskID:   vdiskID,
+		},
+	)
+}
+
+// SourceConfig implements Source.SourceConfig
+func (s *redisSource) SourceConfig() interface{} {
+	return s.resource
+}
+
+// Type implements Source.Type
+func (s *redisSource) Type() string {
+	return "redis"
+}
+
+// Close implements Source.Close
+func (s *redisSource) Close() error {
+	return s.pool.Close()
+}
+
+// dial a new connection to the redis server of this source
+func (s *redisSource) dial() (redis.Conn, error) {
+	return redis.Dial(
+		"tcp", s.address,
+		redis.DialDatabase(s.db),
+		redis.DialConnectTimeout(redisDialTimeout))
+}
+
+// redisResource parses a redis resource
+// into the address and database of the redis server.
+func redisResource(resource string) (string, int, error) {
+	u, err := url.Parse(strings.TrimSpace(resource))
+	if err != nil || u.Scheme != redisResourceScheme {
+		return "", 0, errors.Newf(
+			"redis config info: '%s' is not a valid redis resource", resource)
+	}
+	if !IsServiceAddress(u.Host) {
+		return "", 0, errors.Newf(
+			"redis config info: '%s' is not a valid address", u.Host)
+	}
+
+	var db int
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		db, err = strconv.Atoi(path)
+		if err != nil || db < 0 {
+			return "", 0, errors.Newf(
+				"redis config info: '%s' is not a valid database", path)
+		}
+	}
+
+	return u.Host, db, nil
+}
+
+// the channel this source publishes to, when it updates a key
+func redisNotifyChannel(keyString string) string {
+	return keyString
+}
+
+// the channel the server publishes to, when a key is updated
+// and keyspace notifications are enabled (e.g. `notify-keyspace-events K$`)
+func redisKeyspaceChannel(db int, keyString string) string {
+	return fmt.Sprintf("__keyspace@%d__:%s", db, keyString)
+}
+
+// sets KEYS[1] to ARGV[3], only if its current value equals ARGV[2],
+// or if it doesn't exist yet, in case ARGV[1] equals "1"
+var redisCompareAndSwapScript = redis.NewScript(1, `
+local current = redis.call("GET", KEYS[1])
+
+if ARGV[1] == "1" then
+	if current then
+		return 0
+	end
+elseif current ~= ARGV[2] then
+	return 0
+end
+
+redis.call("SET", KEYS[1], ARGV[3])
+return 1
+`)
+
+const (
+	redisResourceScheme     = "redis"
+	redisDialTimeout        = 5 * time.Second
+	redisIdleTimeout        = 5 * time.Minute
+	redisMaxIdleConnections = 2
+)
+
+var (
+	// polling interval of watched keys,
+	// as a fallback for missed or unsupported notifications
+	redisPollInterval = 10 * time.Second
+)
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/redisstub/ledisdb"
+)
+
+func TestRedisResource(t *testing.T) {
+	assert := assert.New(t)
+
+	validCases := []struct {
+		Resource string
+		Address  string
+		Database int
+	}{
+		{"redis://localhost:6379", "localhost:6379", 0},
+		{"redis://localhost:6379/", "localhost:6379", 0},
+		{"redis://127.0.0.1:16379/2", "127.0.0.1:16379", 2},
+		{" redis://[2001:db8::ff00:42:8329]:33/42 ", "[2001:db8::ff00:42:8329]:33", 42},
+	}
+	for _, validCase := range validCases {
+		address, db, err := redisResource(validCase.Resource)
+		if assert.NoError(err, validCase.Resource) {
+			assert.Equal(validCase.Address, address)
+			assert.Equal(validCase.Database, db)
+		}
+
+		cfg, err := NewSourceConfig(validCase.Resource)
+		if assert.NoError(err, validCase.Resource) {
+			assert.Equal(RedisSourceType, cfg.SourceType)
+			assert.Equal(cfg.Resource, cfg.String())
+		}
+	}
+
+	invalidCases := []string{
+		"",
+		"localhost:6379",
+		"etcd://localhost:6379",
+		"redis://localhost",
+		"redis://localhost:6379/foo",
+		"redis://localhost:6379/-1",
+	}
+	for _, invalidCase := range invalidCases {
+		_, _, err := redisResource(invalidCase)
+		assert.Error(err, invalidCase)
+	}
+}
+
+func TestRedisSource(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	server := ledisdb.NewServer()
+	defer server.Close()
+
+	source, err := RedisSource("redis://" + server.Address())
+	require.NoError(err)
+	defer source.Close()
+	assert.Equal("redis", source.Type())
+	writer, ok := source.(SourceWriter)
+	require.True(ok, "redis source should be writable")
+
+	key := Key{ID: "mycluster", Type: KeyClusterStorage}
+
+	// config doesn't exist yet
+	_, err = source.Get(key)
+	assert.Equal(ErrConfigUnavailable, errors.Cause(err))
+
+	// set and get a config
+	oldCluster := []byte("servers:\n- address: 127.0.0.1:16379\n")
+	require.NoError(writer.Set(key, oldCluster))
+	cfg, err := ReadStorageClusterConfig(source, key.ID)
+	require.NoError(err)
+	assert.Equal("127.0.0.1:16379", cfg.Servers[0].Address)
+
+	// a nil old value only succeeds for a new config
+	newCluster := []byte("servers:\n- address: 127.0.0.1:16380\n")
+	err = writer.CompareAndSwap(key, nil, newCluster)
+	assert.Equal(ErrConfigModified, errors.Cause(err))
+	err = writer.CompareAndSwap(Key{ID: "newcluster", Type: KeyClusterStorage}, nil, newCluster)
+	require.NoError(err)
+
+	// swapping using an outdated value should fail
+	err = writer.CompareAndSwap(key, newCluster, newCluster)
+	assert.Equal(ErrConfigModified, errors.Cause(err))
+
+	// swapping using the current value should succeed
+	require.NoError(writer.CompareAndSwap(key, oldCluster, newCluster))
+	value, err := source.Get(key)
+	require.NoError(err)
+	assert.Equal(newCluster, value)
+}
+
+func TestRedisSourceWatch(t *testing.T) {
+	require := require.New(t)
+
+	server := ledisdb.NewServer()
+	defer server.Close()
+
+	source, err := RedisSource("redis://" + server.Address())
+	require.NoError(err)
+	defer source.Close()
+	writer := source.(SourceWriter)
+
+	// the redis stub doesn't support pub/sub,
+	// so updates can only be received by polling
+	source.(*redisSource).pollInterval = time.Millisecond * 10
+
+	key := Key{ID: "myvdisk", Type: KeyVdiskStatic}
+	require.NoError(writer.Set(key, []byte("blockSize: 4096\nsize: 1\ntype: db\n")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := source.Watch(ctx, key)
+	require.NoError(err)
+
+	// the current value should never be sent
+	select {
+	case value := <-ch:
+		require.FailNow("unexpected update", "%s", value)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	newValue := []byte("blockSize: 4096\nsize: 2\ntype: db\n")
+	require.NoError(writer.Set(key, newValue))
+	select {
+	case value := <-ch:
+		require.Equal(newValue, value)
+	case <-time.After(time.Second):
+		require.FailNow("timed out while waiting for update")
+	}
+
+	// the channel is closed once the context is cancelled
+	cancel()
+	select {
+	case _, open := <-ch:
+		require.False(open, "channel should be closed")
+	case <-time.After(time.Second):
+		require.FailNow("timed out while waiting for channel to close")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // NewStubSource create a new stub source, for testing purposes
@@ -14,6 +15,7 @@ func NewStubSource() *StubSource {
 	source := new(StubSource)
 	source.fileSource.path = "/tests/in/memory"
 	source.fileSource.reader = source.readConfig
+	source.fileSource.writer = source.writeConfig
 	source.subscribers = make(map[chan []byte]Key)
 
 	return source
@@ -50,6 +52,76 @@ func (s *StubSource) Watch(ctx context.Context, key Key) (<-chan []byte, error)
 	return output, nil
 }
 
+// Set implements SourceWriter.Set
+func (s *StubSource) Set(key Key, value []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	defer s.triggerReload()
+
+	switch key.Type {
+	case KeyVdiskStatic:
+		cfg, err := NewVdiskStaticConfig(value)
+		if err != nil {
+			return err
+		}
+		vdiskCfg := s.getVdiskCfg(key.ID)
+		vdiskCfg.BlockSize = cfg.BlockSize
+		vdiskCfg.Size = cfg.Size
+		vdiskCfg.VdiskType = cfg.Type
+		vdiskCfg.ReadOnly = cfg.ReadOnly
+		vdiskCfg.TemplateVdiskID = cfg.TemplateVdiskID
+		s.cfg.Vdisks[key.ID] = vdiskCfg
+
+	case KeyVdiskNBD:
+		cfg, err := NewVdiskNBDConfig(value)
+		if err != nil {
+			return err
+		}
+		vdiskCfg := s.getVdiskCfg(key.ID)
+		vdiskCfg.NBD = cfg
+		s.cfg.Vdisks[key.ID] = vdiskCfg
+
+	case KeyVdiskTlog:
+		cfg, err := NewVdiskTlogConfig(value)
+		if err != nil {
+			return err
+		}
+		vdiskCfg := s.getVdiskCfg(key.ID)
+		vdiskCfg.Tlog = cfg
+		s.cfg.Vdisks[key.ID] = vdiskCfg
+
+	case KeyClusterStorage:
+		cfg, err := NewStorageClusterConfig(value)
+		if err != nil {
+			return err
+		}
+		s.setStorageCluster(key.ID, cfg)
+
+	case KeyClusterZeroStor:
+		cfg, err := NewZeroStorClusterConfig(value)
+		if err != nil {
+			return err
+		}
+		s.setZeroStorCluster(key.ID, cfg)
+
+	case KeyClusterTlog:
+		cfg, err := NewTlogClusterConfig(value)
+		if err != nil {
+			return err
+		}
+		s.setTlogCluster(key.ID, cfg)
+
+	default:
+		return errors.Wrapf(
+			ErrInvalidKey,
+			"%v is not a supported key type by the stub config",
+			key.Type,
+		)
+	}
+
+	return nil
+}
+
 // Close implements SourceCloser.Close
 func (s *StubSource) Close() error {
 	if s.invalidConfigSender != nil {
@@ -365,3 +437,20 @@ func (s *StubSource) readConfig(string) ([]byte, error) {
 
 	return serializeConfigReply(s.cfg, nil)
 }
+
+// writeConfig replaces the in-memory config with the given (complete) YAML config,
+// and notifies all subscribers of the (possibly) changed config.
+func (s *StubSource) writeConfig(_ string, bytes []byte) error {
+	cfg := new(FileFormatCompleteConfig)
+	err := yaml.Unmarshal(bytes, cfg)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	s.cfg = cfg
+	s.mux.Unlock()
+
+	s.triggerReload()
+	return nil
+}
@@ -39,6 +39,10 @@ func (s MessageSubject) String() string {
 		return subjectTlogStr
 	case SubjectZeroStor:
 		return subjectZeroStorStr
+	case SubjectRedis:
+		return subjectRedisStr
+	case SubjectFile:
+		return subjectFileStr
 	default:
 		return subjectNilStr
 	}
@@ -64,6 +68,10 @@ const (
 	SubjectTlog
 	// SubjectZeroStor identifies the messages has to do with zerostor
 	SubjectZeroStor
+	// SubjectRedis identifies the messages has to do with a redis config source
+	SubjectRedis
+	// SubjectFile identifies the messages has to do with a file config source
+	SubjectFile
 )
 
 // subjects
@@ -72,6 +80,8 @@ const (
 	subjectETCDStr     = "etcd"
 	subjectTlogStr     = "tlog"
 	subjectZeroStorStr = "zerostor"
+	subjectRedisStr    = "redis"
+	subjectFileStr     = "file"
 	subjectNilStr      = ""
 )
 
@@ -87,6 +97,7 @@ const (
 	StatusServerTimeout    MessageStatus = 421
 	StatusServerDisconnect MessageStatus = 422
 	StatusServerTempError  MessageStatus = 423
+	StatusDataCorrupted    MessageStatus = 424
 )
 
 // InvalidConfigBody is the data given for a StatusInvalidConfig message.
@@ -108,6 +119,15 @@ type ARDBServerTimeoutBody struct {
 	VdiskID  string         `json:"vdiskID"`
 }
 
+// ARDBDataCorruptedBody is the data given
+// for a ARDB StatusDataCorrupted message.
+type ARDBDataCorruptedBody struct {
+	VdiskID    string `json:"vdiskID"`
+	BlockIndex int64  `json:"blockIndex"`
+	Hash       string `json:"hash"`
+	Repaired   bool   `json:"repaired"`
+}
+
 // ARDBServerType defines the type of ARDB Server,
 // for any broadcast purposes.
 type ARDBServerType uint8
@@ -17,6 +17,8 @@ func TestMessageSubjectMarshal(t *testing.T) {
 		{SubjectETCD, subjectETCDStr},
 		{SubjectStorage, subjectStorageStr},
 		{SubjectTlog, subjectTlogStr},
+		{SubjectRedis, subjectRedisStr},
+		{SubjectFile, subjectFileStr},
 	}
 
 	for _, testCase := range testCases {
@@ -0,0 +1,201 @@
+package backup
+
+import (
+	"context"
+	"io"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+)
+
+// ConversionRequired returns true in case the given source vdisk
+// can only be copied to the given target vdisk using ConvertVdisk,
+// as their storage types and/or block sizes are different.
+func ConversionRequired(source, target storage.CopyVdiskConfig) bool {
+	return source.Type.StorageType() != target.Type.StorageType() ||
+		source.BlockSize != target.BlockSize
+}
+
+// ConvertVdisk copies a source vdisk to a target vdisk,
+// which can have a different storage type and/or block size.
+// All blocks are streamed from the source's BlockStorage to the target's BlockStorage,
+// inflating or deflating them in case the block sizes are different.
+// The content of a cloned vdisk is read through from its parent(s),
+// meaning that the target is never a clone, even if the source is.
+//
+// The source template cluster is optional,
+// and is used to read the content of the source which isn't available in the source cluster.
+// The target cluster is optional as well, and defaults to the source cluster.
+//
+// Note that contrary to storage.CopyVdisk, no tlog metadata is copied,
+// as the tlog data has to be regenerated for the converted target vdisk.
+func ConvertVdisk(ctx context.Context, source, target storage.CopyVdiskConfig, sourceCluster, sourceTemplateCluster, targetCluster ardb.StorageCluster) error {
+	if target.BlockSize <= 0 || source.BlockSize <= 0 {
+		return errors.New("source and target vdisks require a positive block size")
+	}
+	if source.BlockSize%target.BlockSize != 0 && target.BlockSize%source.BlockSize != 0 {
+		return errors.Newf(
+			"block size of vdisk %s (%d) isn't a multiple or factor of the block size of vdisk %s (%d)",
+			target.VdiskID, target.BlockSize, source.VdiskID, source.BlockSize)
+	}
+	if targetCluster == nil {
+		if source.VdiskID == target.VdiskID {
+			return errors.Newf("vdisk %s cannot be converted into itself", source.VdiskID)
+		}
+		targetCluster = sourceCluster
+	}
+
+	lineage, err := storage.LoadVdiskLineage(source.VdiskID, sourceCluster)
+	if err != nil {
+		return err
+	}
+	indices, err := storage.ListBlockIndicesInCluster(source.VdiskID, source.Type, sourceCluster)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't list block indices of vdisk %s", source.VdiskID)
+	}
+	// the blocks of a non-deduped vdisk which aren't available in the source cluster,
+	// are read from its template cluster, and thus have to be listed from it as well
+	if sourceTemplateCluster != nil && source.Type.StorageType() == config.StorageNonDeduped {
+		templateIndices, err := storage.ListBlockIndicesInCluster(
+			source.VdiskID, source.Type, sourceTemplateCluster)
+		if err != nil {
+			return errors.Wrapf(err,
+				"couldn't list block indices of vdisk %s in its template cluster", source.VdiskID)
+		}
+		indices = mergeIndices(indices, templateIndices)
+	}
+
+	sourceStorage, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:       source.VdiskID,
+		VdiskType:     source.Type,
+		BlockSize:     source.BlockSize,
+		LBACacheLimit: ardb.DefaultLBACacheLimit,
+		Lineage:       lineage,
+	}, sourceCluster, sourceTemplateCluster)
+	if err != nil {
+		return err
+	}
+	defer sourceStorage.Close()
+
+	targetStorage, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:       target.VdiskID,
+		VdiskType:     target.Type,
+		BlockSize:     target.BlockSize,
+		LBACacheLimit: ardb.DefaultLBACacheLimit,
+	}, targetCluster, nil)
+	if err != nil {
+		return err
+	}
+	defer targetStorage.Close()
+
+	log.Infof(
+		"converting %d blocks of %s vdisk %s (%d bytes/block) into %s vdisk %s (%d bytes/block)...",
+		len(indices), source.Type.StorageType(), source.VdiskID, source.BlockSize,
+		target.Type.StorageType(), target.VdiskID, target.BlockSize)
+
+	fetcher := sizedBlockFetcher(&storageBlockFetcher{
+		storage:   sourceStorage,
+		indices:   indices,
+		blockSize: source.BlockSize,
+	}, source.BlockSize, target.BlockSize)
+
+	var pair *blockIndexPair
+	var blockCount int64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		pair, err = fetcher.FetchBlock()
+		if err != nil {
+			if errors.Cause(err) == io.EOF {
+				break
+			}
+			return err
+		}
+
+		err = targetStorage.SetBlock(pair.Index, pair.Block)
+		if err != nil {
+			return errors.Wrapf(err,
+				"couldn't store block %d of vdisk %s", pair.Index, target.VdiskID)
+		}
+		blockCount++
+	}
+
+	err = targetStorage.Flush()
+	if err != nil {
+		return err
+	}
+	err = storage.RegisterVdisk(target.VdiskID, target.Type, targetCluster)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("converted vdisk %s into vdisk %s (%d blocks stored)",
+		source.VdiskID, target.VdiskID, blockCount)
+	return nil
+}
+
+// mergeIndices merges two sorted slices of indices,
+// into a single sorted slice without duplicates.
+func mergeIndices(a, b []int64) []int64 {
+	merged := make([]int64, 0, len(a)+len(b))
+	var i, j int
+	for i < len(a) || j < len(b) {
+		var index int64
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			index = a[i]
+			i++
+		case i == len(a) || b[j] < a[i]:
+			index = b[j]
+			j++
+		default:
+			index = a[i]
+			i++
+			j++
+		}
+		merged = append(merged, index)
+	}
+	return merged
+}
+
+// storageBlockFetcher is a blockFetcher,
+// which fetches the blocks stored at the given (sorted) indices from a BlockStorage.
+type storageBlockFetcher struct {
+	storage   storage.BlockStorage
+	indices   []int64
+	blockSize int64
+	cursor    int
+}
+
+// FetchBlock implements blockFetcher.FetchBlock
+func (sbf *storageBlockFetcher) FetchBlock() (*blockIndexPair, error) {
+	for sbf.cursor < len(sbf.indices) {
+		index := sbf.indices[sbf.cursor]
+		sbf.cursor++
+
+		block, err := sbf.storage.GetBlock(index)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't fetch block %d", index)
+		}
+		if len(block) == 0 {
+			continue // block was deleted in the meantime
+		}
+		if int64(len(block)) < sbf.blockSize {
+			// ensure the sized block fetchers always receive full blocks
+			padded := make([]byte, sbf.blockSize)
+			copy(padded, block)
+			block = padded
+		}
+
+		return &blockIndexPair{Block: block, Index: index}, nil
+	}
+
+	return nil, io.EOF
+}
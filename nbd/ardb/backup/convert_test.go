@@ -0,0 +1,150 @@
+package backup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestConvertVdisk_NonDedupedToDeduped_Inflation(t *testing.T) {
+	testConvertVdisk(t,
+		storage.CopyVdiskConfig{VdiskID: "a", Type: config.VdiskTypeDB, BlockSize: 512},
+		storage.CopyVdiskConfig{VdiskID: "b", Type: config.VdiskTypeBoot, BlockSize: 2048})
+}
+
+func TestConvertVdisk_DedupedToNonDeduped_Deflation(t *testing.T) {
+	testConvertVdisk(t,
+		storage.CopyVdiskConfig{VdiskID: "a", Type: config.VdiskTypeBoot, BlockSize: 2048},
+		storage.CopyVdiskConfig{VdiskID: "b", Type: config.VdiskTypeDB, BlockSize: 512})
+}
+
+func TestConvertVdisk_DedupedToNonDeduped(t *testing.T) {
+	testConvertVdisk(t,
+		storage.CopyVdiskConfig{VdiskID: "a", Type: config.VdiskTypeBoot, BlockSize: 512},
+		storage.CopyVdiskConfig{VdiskID: "b", Type: config.VdiskTypeDB, BlockSize: 512})
+}
+
+func testConvertVdisk(t *testing.T, source, target storage.CopyVdiskConfig) {
+	require := require.New(t)
+
+	sourceCluster := redisstub.NewUniCluster(true)
+	defer sourceCluster.Close()
+	targetCluster := redisstub.NewUniCluster(true)
+	defer targetCluster.Close()
+
+	require.True(ConversionRequired(source, target))
+
+	newStorage := func(cfg storage.CopyVdiskConfig, cluster ardb.StorageCluster) storage.BlockStorage {
+		bs, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+			VdiskID:   cfg.VdiskID,
+			VdiskType: cfg.Type,
+			BlockSize: cfg.BlockSize,
+		}, cluster, nil)
+		require.NoError(err)
+		return bs
+	}
+
+	// write some (sparse) data into the source vdisk
+	const dataSize = 1024 * 16
+	data := make([]byte, dataSize)
+	for _, offset := range []int{0, 600, 4096, 4200, 12000} {
+		for i := offset; i < offset+300; i++ {
+			data[i] = byte(i%250) + 1
+		}
+	}
+	sourceStorage := newStorage(source, sourceCluster)
+	for offset := int64(0); offset < dataSize; offset += source.BlockSize {
+		require.NoError(sourceStorage.SetBlock(offset/source.BlockSize, data[offset:offset+source.BlockSize]))
+	}
+	require.NoError(sourceStorage.Flush())
+	sourceStorage.Close()
+
+	err := ConvertVdisk(context.Background(), source, target, sourceCluster, nil, targetCluster)
+	require.NoError(err)
+
+	// the target vdisk should contain the exact same data
+	targetStorage := newStorage(target, targetCluster)
+	defer targetStorage.Close()
+	for offset := int64(0); offset < dataSize; offset += target.BlockSize {
+		block, err := targetStorage.GetBlock(offset / target.BlockSize)
+		require.NoError(err)
+		expected := data[offset : offset+target.BlockSize]
+		if isNilBlock(expected) {
+			require.True(isNilBlock(block), "block at offset %d", offset)
+			continue
+		}
+		require.Equal(expected, block, "block at offset %d", offset)
+	}
+}
+
+// blocks of a non-deduped vdisk which are only available
+// in its template cluster are converted as well
+func TestConvertVdisk_NonDedupedTemplate(t *testing.T) {
+	const blockSize = 512
+
+	require := require.New(t)
+
+	sourceCluster := redisstub.NewUniCluster(true)
+	defer sourceCluster.Close()
+	templateCluster := redisstub.NewUniCluster(true)
+	defer templateCluster.Close()
+
+	source := storage.CopyVdiskConfig{VdiskID: "a", Type: config.VdiskTypeDB, BlockSize: blockSize}
+	target := storage.CopyVdiskConfig{VdiskID: "b", Type: config.VdiskTypeBoot, BlockSize: blockSize}
+
+	newContent := func(b byte) []byte {
+		content := make([]byte, blockSize)
+		for i := range content {
+			content[i] = b
+		}
+		return content
+	}
+	setBlocks := func(cluster ardb.StorageCluster, blocks map[int64][]byte) {
+		bs, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+			VdiskID:   source.VdiskID,
+			VdiskType: source.Type,
+			BlockSize: blockSize,
+		}, cluster, nil)
+		require.NoError(err)
+		defer bs.Close()
+		for index, content := range blocks {
+			require.NoError(bs.SetBlock(index, content))
+		}
+		require.NoError(bs.Flush())
+	}
+
+	setBlocks(templateCluster, map[int64][]byte{0: newContent(1), 1: newContent(2), 3: newContent(3)})
+	setBlocks(sourceCluster, map[int64][]byte{1: newContent(4), 2: newContent(5)})
+
+	err := ConvertVdisk(context.Background(), source, target, sourceCluster, templateCluster, sourceCluster)
+	require.NoError(err)
+
+	targetStorage, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:   target.VdiskID,
+		VdiskType: target.Type,
+		BlockSize: blockSize,
+	}, sourceCluster, nil)
+	require.NoError(err)
+	defer targetStorage.Close()
+
+	expected := map[int64][]byte{0: newContent(1), 1: newContent(4), 2: newContent(5), 3: newContent(3)}
+	for index, content := range expected {
+		block, err := targetStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(content, block, "block %d", index)
+	}
+}
+
+func TestMergeIndices(t *testing.T) {
+	require.Equal(t, []int64{}, mergeIndices(nil, nil))
+	require.Equal(t, []int64{1, 2}, mergeIndices([]int64{1, 2}, nil))
+	require.Equal(t, []int64{1, 2}, mergeIndices(nil, []int64{1, 2}))
+	require.Equal(t,
+		[]int64{0, 1, 2, 3, 5, 8},
+		mergeIndices([]int64{1, 2, 5}, []int64{0, 1, 3, 5, 8}))
+}
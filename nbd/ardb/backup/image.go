@@ -0,0 +1,391 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+)
+
+const (
+	// RawImage represents the raw (sparse) image format,
+	// and is also the Default (nil) value of the Image Format.
+	RawImage ImageFormat = iota
+	// QCOW2Image represents the QCOW2 image format, as used by QEMU.
+	// See https://github.com/qemu/qemu/blob/master/docs/interop/qcow2.txt
+	QCOW2Image
+)
+
+// ImageFormat defines the format of an image file.
+type ImageFormat uint8
+
+// String implements Stringer.String
+func (f *ImageFormat) String() string {
+	switch *f {
+	case RawImage:
+		return rawImageStr
+	case QCOW2Image:
+		return qcow2ImageStr
+	default:
+		return ""
+	}
+}
+
+// Set implements Flag.Set
+func (f *ImageFormat) Set(str string) error {
+	switch str {
+	case rawImageStr:
+		*f = RawImage
+	case qcow2ImageStr:
+		*f = QCOW2Image
+	default:
+		return errUnknownImageFormat
+	}
+
+	return nil
+}
+
+// Type implements PValue.Type
+func (f *ImageFormat) Type() string {
+	return "ImageFormat"
+}
+
+func (f ImageFormat) validate() error {
+	switch f {
+	case RawImage, QCOW2Image:
+		return nil
+	default:
+		return errUnknownImageFormat
+	}
+}
+
+const (
+	rawImageStr   = "raw"
+	qcow2ImageStr = "qcow2"
+)
+
+var (
+	errUnknownImageFormat = errors.New("unknown image format")
+)
+
+// ImageConfig used to import/export a vdisk from/to an image file.
+type ImageConfig struct {
+	// Required: VdiskID to export from or import into
+	VdiskID string
+	// Required: Path of the image file to import from or export to
+	Path string
+	// Optional: Format of the image file (raw by default)
+	Format ImageFormat
+
+	// Required: config Source to configure the storage with
+	ConfigSource config.Source
+
+	// Optional: Amount of jobs (goroutines) to run simultaneously
+	//           (to import/export in parallel)
+	//           By default it equals the amount of CPUs available.
+	JobCount int
+}
+
+// validate the image config,
+// and fill-in all the missing optional data.
+func (cfg *ImageConfig) validate() error {
+	if cfg.VdiskID == "" {
+		return errNilVdiskID
+	}
+	if cfg.Path == "" {
+		return errors.New("no image path given")
+	}
+	if cfg.JobCount <= 0 {
+		cfg.JobCount = runtime.NumCPU()
+	}
+	return cfg.Format.validate()
+}
+
+// ImportImage imports a vdisk from a raw or QCOW2 image file.
+// Blocks which only contain zeroes are not stored.
+func ImportImage(ctx context.Context, cfg ImageConfig) error {
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	staticConfig, err := config.ReadVdiskStaticConfig(cfg.ConfigSource, cfg.VdiskID)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(cfg.Path)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't open image %s", cfg.Path)
+	}
+	defer file.Close()
+
+	var image imageReader
+	switch cfg.Format {
+	case QCOW2Image:
+		image, err = newQCOW2ImageReader(file)
+	default:
+		image, err = newRawImageReader(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	vdiskSize := int64(staticConfig.Size) * ardb.GibibyteAsBytes
+	if image.Size() > vdiskSize {
+		return errors.Newf(
+			"image %s (%d bytes) doesn't fit in vdisk %s (%d bytes)",
+			cfg.Path, image.Size(), cfg.VdiskID, vdiskSize)
+	}
+
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+
+	blockStorage, err := storage.BlockStorageFromConfig(cfg.VdiskID, cfg.ConfigSource, pool)
+	if err != nil {
+		return err
+	}
+	defer blockStorage.Close()
+
+	blockSize := int64(staticConfig.BlockSize)
+	blockCount := (image.Size() + blockSize - 1) / blockSize
+	log.Infof("importing %d bytes from %s image %s into vdisk %s...",
+		image.Size(), cfg.Format.String(), cfg.Path, cfg.VdiskID)
+
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	// launch input goroutine
+	indexCh := make(chan int64, cfg.JobCount)
+	group.Go(func() error {
+		defer close(indexCh)
+		for index := int64(0); index < blockCount; index++ {
+			select {
+			case indexCh <- index:
+			case <-groupCtx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+
+	// launch worker goroutines
+	for i := 0; i < cfg.JobCount; i++ {
+		group.Go(func() error {
+			block := make([]byte, blockSize)
+			for index := range indexCh {
+				n, err := image.ReadAt(block, index*blockSize)
+				if err != nil && err != io.EOF {
+					return errors.Wrapf(err, "couldn't read block %d from image", index)
+				}
+				// the last block can be smaller than the block size
+				for i := n; i < len(block); i++ {
+					block[i] = 0
+				}
+				if isNilBlock(block) {
+					continue // no need to store zero blocks
+				}
+				err = blockStorage.SetBlock(index, block)
+				if err != nil {
+					return errors.Wrapf(err, "couldn't store block %d", index)
+				}
+				select {
+				case <-groupCtx.Done():
+					return nil
+				default:
+				}
+			}
+			return nil
+		})
+	}
+
+	err = group.Wait()
+	if err != nil {
+		return err
+	}
+	// the vdisk is only partially imported/exported, if the given context was cancelled
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+	err = blockStorage.Flush()
+	if err != nil {
+		return err
+	}
+
+	return registerImportedVdisk(cfg.VdiskID, staticConfig.Type, cfg.ConfigSource, pool)
+}
+
+// ExportImage exports a vdisk to a raw (sparse) or QCOW2 image file,
+// overwriting the file if it already exists.
+// Blocks which aren't stored by the vdisk remain holes in the image.
+func ExportImage(ctx context.Context, cfg ImageConfig) error {
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	staticConfig, err := config.ReadVdiskStaticConfig(cfg.ConfigSource, cfg.VdiskID)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("collecting all stored block indices for vdisk %s, this might take a while...", cfg.VdiskID)
+	indices, err := storage.ListBlockIndices(cfg.VdiskID, cfg.ConfigSource)
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't list block (storage) indices (does vdisk '%s' exist?)",
+			cfg.VdiskID)
+	}
+
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+
+	blockStorage, err := storage.BlockStorageFromConfig(cfg.VdiskID, cfg.ConfigSource, pool)
+	if err != nil {
+		return err
+	}
+	defer blockStorage.Close()
+
+	file, err := os.Create(cfg.Path)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't create image %s", cfg.Path)
+	}
+	defer file.Close()
+
+	vdiskSize := int64(staticConfig.Size) * ardb.GibibyteAsBytes
+	var image imageWriter
+	switch cfg.Format {
+	case QCOW2Image:
+		image, err = newQCOW2ImageWriter(file, vdiskSize)
+	default:
+		image, err = newRawImageWriter(file, vdiskSize)
+	}
+	if err != nil {
+		return err
+	}
+
+	blockSize := int64(staticConfig.BlockSize)
+	log.Infof("exporting %d blocks of vdisk %s into %s image %s...",
+		len(indices), cfg.VdiskID, cfg.Format.String(), cfg.Path)
+
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	// launch input goroutine
+	indexCh := make(chan int64, cfg.JobCount)
+	group.Go(func() error {
+		defer close(indexCh)
+		for _, index := range indices {
+			select {
+			case indexCh <- index:
+			case <-groupCtx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+
+	// launch worker goroutines
+	for i := 0; i < cfg.JobCount; i++ {
+		group.Go(func() error {
+			for index := range indexCh {
+				block, err := blockStorage.GetBlock(index)
+				if err != nil {
+					return errors.Wrapf(err, "couldn't fetch block %d", index)
+				}
+				if isNilBlock(block) {
+					continue // keep the hole
+				}
+				_, err = image.WriteAt(block, index*blockSize)
+				if err != nil {
+					return errors.Wrapf(err, "couldn't write block %d to image", index)
+				}
+				select {
+				case <-groupCtx.Done():
+					return nil
+				default:
+				}
+			}
+			return nil
+		})
+	}
+
+	err = group.Wait()
+	if err != nil {
+		return err
+	}
+	// the vdisk is only partially imported/exported, if the given context was cancelled
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+	return image.Close()
+}
+
+// imageReader defines the API to read the virtual disk stored in an image,
+// holes are read as zeroes.
+type imageReader interface {
+	io.ReaderAt
+	// Size returns the (virtual) size of the image in bytes.
+	Size() int64
+}
+
+// imageWriter defines the API to write a virtual disk as an image,
+// the image is only complete once the writer has been closed.
+type imageWriter interface {
+	io.WriterAt
+	io.Closer
+}
+
+// newRawImageReader creates an imageReader for a raw image file.
+func newRawImageReader(file *os.File) (*rawImageReader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't stat raw image")
+	}
+	return &rawImageReader{File: file, size: info.Size()}, nil
+}
+
+// rawImageReader is an imageReader for raw image files.
+type rawImageReader struct {
+	*os.File
+	size int64
+}
+
+// Size implements imageReader.Size
+func (r *rawImageReader) Size() int64 {
+	return r.size
+}
+
+// newRawImageWriter creates an imageWriter for a raw image file,
+// truncating the file to the given size, turning it into a sparse file
+// (on file systems which support it) in which all unwritten parts are holes.
+func newRawImageWriter(file *os.File, size int64) (*rawImageWriter, error) {
+	err := file.Truncate(size)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't truncate raw image")
+	}
+	return &rawImageWriter{file: file}, nil
+}
+
+// rawImageWriter is an imageWriter for raw image files.
+type rawImageWriter struct {
+	file *os.File
+}
+
+// WriteAt implements imageWriter.WriteAt
+func (w *rawImageWriter) WriteAt(p []byte, off int64) (int, error) {
+	return w.file.WriteAt(p, off)
+}
+
+// Close implements imageWriter.Close
+func (w *rawImageWriter) Close() error {
+	return w.file.Sync()
+}
@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestImageFormat(t *testing.T) {
+	require := require.New(t)
+
+	var format ImageFormat
+	require.Equal(RawImage, format)
+	require.Equal(rawImageStr, format.String())
+
+	require.NoError(format.Set(qcow2ImageStr))
+	require.Equal(QCOW2Image, format)
+	require.Equal(qcow2ImageStr, format.String())
+
+	require.Error(format.Set("vmdk"))
+	require.Error(ImageFormat(42).validate())
+}
+
+func TestQCOW2ImageCommute(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "zerodisk-qcow2")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// virtual size which requires multiple L2 tables, and isn't cluster aligned
+	const size = 1024*1024*1024 + 4096
+	writes := map[int64][]byte{
+		0:                    newTestImageData(4096, 1),
+		65536 - 100:          newTestImageData(200, 2),   // crosses a cluster boundary
+		512*1024*1024 + 1024: newTestImageData(70000, 3), // second L2 table
+		size - 4096:          newTestImageData(4096, 4),
+	}
+
+	file, err := os.Create(path.Join(dir, "image.qcow2"))
+	require.NoError(err)
+	defer file.Close()
+
+	writer, err := newQCOW2ImageWriter(file, size)
+	require.NoError(err)
+	for offset, data := range writes {
+		_, err = writer.WriteAt(data, offset)
+		require.NoError(err)
+	}
+	require.NoError(writer.Close())
+	_, err = writer.WriteAt([]byte{1}, size)
+	require.Error(err, "writing beyond the virtual size should fail")
+
+	reader, err := newQCOW2ImageReader(file)
+	require.NoError(err)
+	require.Equal(int64(size), reader.Size())
+
+	for offset, data := range writes {
+		buf := make([]byte, len(data))
+		_, err = reader.ReadAt(buf, offset)
+		require.NoError(err)
+		require.Equal(data, buf, "data at offset %d", offset)
+	}
+
+	// holes are read as zeroes
+	buf := make([]byte, 8192)
+	_, err = reader.ReadAt(buf, 256*1024*1024)
+	require.NoError(err)
+	require.True(isNilBlock(buf))
+}
+
+func TestQCOW2ImageInvalidL1Size(t *testing.T) {
+	require := require.New(t)
+
+	file, err := ioutil.TempFile("", "zerodisk-qcow2")
+	require.NoError(err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	const size = 1024 * 1024 * 1024
+	writer, err := newQCOW2ImageWriter(file, size)
+	require.NoError(err)
+	require.NoError(writer.Close())
+
+	buf := make([]byte, qcow2HeaderSize)
+	_, err = file.ReadAt(buf, 0)
+	require.NoError(err)
+	var header qcow2Header
+	header.decode(buf)
+
+	for _, l1Size := range []uint32{header.L1Size - 1, qcow2MaxL1Size + 1, 0xffffffff} {
+		invalidHeader := header
+		invalidHeader.L1Size = l1Size
+		invalidHeader.encode(buf)
+		_, err = file.WriteAt(buf, 0)
+		require.NoError(err)
+		_, err = newQCOW2ImageReader(file)
+		require.Error(err, "L1 size %d", l1Size)
+	}
+
+	invalidHeader := header
+	invalidHeader.Size = 1 << 63
+	invalidHeader.encode(buf)
+	_, err = file.WriteAt(buf, 0)
+	require.NoError(err)
+	_, err = newQCOW2ImageReader(file)
+	require.Error(err)
+}
+
+func TestImportExportImageDeduped(t *testing.T) {
+	testImportExportImage(t, config.VdiskTypeBoot)
+}
+
+func TestImportExportImageNonDeduped(t *testing.T) {
+	testImportExportImage(t, config.VdiskTypeDB)
+}
+
+func testImportExportImage(t *testing.T, vdiskType config.VdiskType) {
+	const (
+		blockSize = 4096
+		dataSize  = blockSize*16 + 100 // not block aligned
+	)
+
+	require := require.New(t)
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "zerodisk-image")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	slice := redisstub.NewMemoryRedisSlice(2)
+	defer slice.Close()
+	clusterConfig := slice.StorageClusterConfig()
+
+	source := config.NewStubSource()
+	for _, vdiskID := range []string{"a", "b"} {
+		source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+			BlockSize: blockSize,
+			Size:      1,
+			Type:      vdiskType,
+		})
+		source.SetPrimaryStorageCluster(vdiskID, "cluster", &clusterConfig)
+	}
+
+	// create a raw image, which contains some data and a hole
+	data := make([]byte, dataSize)
+	copy(data, newTestImageData(blockSize*4, 1))
+	copy(data[blockSize*10:], newTestImageData(blockSize*6+100, 2))
+	rawPath := path.Join(dir, "image.raw")
+	require.NoError(ioutil.WriteFile(rawPath, data, 0644))
+
+	// raw image -> vdisk a -> qcow2 image -> vdisk b -> raw image
+	qcow2Path := path.Join(dir, "image.qcow2")
+	exportedPath := path.Join(dir, "exported.raw")
+	require.NoError(ImportImage(ctx, ImageConfig{
+		VdiskID: "a", Path: rawPath, ConfigSource: source, JobCount: 4}))
+	require.NoError(ExportImage(ctx, ImageConfig{
+		VdiskID: "a", Path: qcow2Path, Format: QCOW2Image, ConfigSource: source, JobCount: 4}))
+	require.NoError(ImportImage(ctx, ImageConfig{
+		VdiskID: "b", Path: qcow2Path, Format: QCOW2Image, ConfigSource: source, JobCount: 4}))
+	require.NoError(ExportImage(ctx, ImageConfig{
+		VdiskID: "b", Path: exportedPath, ConfigSource: source, JobCount: 4}))
+
+	// zero blocks shouldn't have been stored
+	indices, err := storage.ListBlockIndices("b", source)
+	require.NoError(err)
+	require.Len(indices, 4+7)
+
+	// both vdisks should be registered
+	cluster, err := ardb.NewCluster(clusterConfig, nil)
+	require.NoError(err)
+	ids, err := storage.ListVdisks(cluster, nil)
+	require.NoError(err)
+	require.Equal([]string{"a", "b"}, ids)
+
+	// the exported image should have the size of the vdisk,
+	// and contain the original data followed by zeroes
+	file, err := os.Open(exportedPath)
+	require.NoError(err)
+	defer file.Close()
+	info, err := file.Stat()
+	require.NoError(err)
+	require.Equal(ardb.GibibyteAsBytes, info.Size())
+	exported := make([]byte, blockSize*32)
+	_, err = file.ReadAt(exported, 0)
+	require.NoError(err)
+	require.Equal(data, exported[:dataSize])
+	require.True(isNilBlock(exported[dataSize:]))
+}
+
+func newTestImageData(size int, seed byte) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i%251) + seed
+	}
+	return data
+}
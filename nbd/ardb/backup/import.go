@@ -57,7 +57,30 @@ func Import(ctx context.Context, cfg Config) error {
 		SnapshotID:      cfg.SnapshotID,
 	}
 
-	return importBS(ctx, storageDriver, blockStorage, importConfig)
+	err = importBS(ctx, storageDriver, blockStorage, importConfig)
+	if err != nil {
+		return err
+	}
+
+	return registerImportedVdisk(cfg.VdiskID, staticConfig.Type, cfg.ConfigSource, pool)
+}
+
+// registerImportedVdisk registers an imported vdisk
+// in the vdisk registry of its primary cluster.
+func registerImportedVdisk(vdiskID string, t config.VdiskType, configSource config.Source, pool *ardb.Pool) error {
+	nbdConfig, err := config.ReadVdiskNBDConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	clusterConfig, err := config.ReadStorageClusterConfig(configSource, nbdConfig.StorageClusterID)
+	if err != nil {
+		return err
+	}
+	cluster, err := ardb.NewCluster(*clusterConfig, pool)
+	if err != nil {
+		return err
+	}
+	return storage.RegisterVdisk(vdiskID, t, cluster)
 }
 
 func importBS(ctx context.Context, src StorageDriver, dst storage.BlockStorage, cfg importConfig) error {
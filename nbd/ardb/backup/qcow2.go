@@ -0,0 +1,447 @@
+package backup
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/zero-os/0-Disk/errors"
+)
+
+// newQCOW2ImageReader creates an imageReader,
+// which reads the virtual disk stored within a QCOW2 image file.
+// Only unencrypted images without a backing file are supported,
+// and compressed clusters are not supported either.
+// Unallocated (and zero) clusters are read as zeroes.
+func newQCOW2ImageReader(file *os.File) (*qcow2ImageReader, error) {
+	buf := make([]byte, qcow2HeaderSize)
+	_, err := file.ReadAt(buf, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read QCOW2 header")
+	}
+
+	var header qcow2Header
+	header.decode(buf)
+	if header.Magic != qcow2Magic {
+		return nil, errors.Wrap(errInvalidQCOW2Image, "invalid magic")
+	}
+	if header.Version != 2 && header.Version != 3 {
+		return nil, errors.Wrapf(errInvalidQCOW2Image, "unsupported version %d", header.Version)
+	}
+	if header.Version == 3 {
+		var features [8]byte
+		_, err = file.ReadAt(features[:], qcow2HeaderSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't read QCOW2 (v3) header")
+		}
+		if incompatible := binary.BigEndian.Uint64(features[:]); incompatible != 0 {
+			return nil, errors.Wrapf(errInvalidQCOW2Image,
+				"unsupported incompatible features (0x%x)", incompatible)
+		}
+	}
+	if header.BackingFileOffset != 0 {
+		return nil, errors.Wrap(errInvalidQCOW2Image, "images with a backing file are not supported")
+	}
+	if header.CryptMethod != 0 {
+		return nil, errors.Wrap(errInvalidQCOW2Image, "encrypted images are not supported")
+	}
+	if header.ClusterBits < qcow2MinClusterBits || header.ClusterBits > qcow2MaxClusterBits {
+		return nil, errors.Wrapf(errInvalidQCOW2Image, "invalid cluster bits %d", header.ClusterBits)
+	}
+	if header.Size > qcow2MaxSize {
+		return nil, errors.Wrapf(errInvalidQCOW2Image, "unsupported virtual size %d", header.Size)
+	}
+
+	// the L1 table has to cover the entire virtual size,
+	// and is limited in size, just like qemu does, as it is loaded in memory
+	l1Coverage := uint64(1) << (2*header.ClusterBits - 3)
+	requiredL1Size := (header.Size + l1Coverage - 1) / l1Coverage
+	if uint64(header.L1Size) < requiredL1Size {
+		return nil, errors.Wrapf(errInvalidQCOW2Image,
+			"L1 table (%d entries) too small for virtual size %d", header.L1Size, header.Size)
+	}
+	if header.L1Size > qcow2MaxL1Size {
+		return nil, errors.Wrapf(errInvalidQCOW2Image,
+			"L1 table (%d entries) too big", header.L1Size)
+	}
+
+	reader := &qcow2ImageReader{
+		file:        file,
+		size:        int64(header.Size),
+		clusterBits: header.ClusterBits,
+		clusterSize: int64(1) << header.ClusterBits,
+		l2Bits:      header.ClusterBits - 3,
+		version:     header.Version,
+	}
+
+	// load the entire L1 table in memory, as it is small
+	l1Buf := make([]byte, int64(header.L1Size)*8)
+	_, err = file.ReadAt(l1Buf, int64(header.L1TableOffset))
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read QCOW2 L1 table")
+	}
+	reader.l1Table = make([]uint64, header.L1Size)
+	for i := range reader.l1Table {
+		reader.l1Table[i] = binary.BigEndian.Uint64(l1Buf[i*8:])
+	}
+
+	return reader, nil
+}
+
+// qcow2ImageReader is an imageReader for QCOW2 images,
+// which can be used from multiple goroutines at once.
+type qcow2ImageReader struct {
+	file        *os.File
+	size        int64
+	clusterBits uint32
+	clusterSize int64
+	l2Bits      uint32
+	version     uint32
+	l1Table     []uint64
+}
+
+// Size implements imageReader.Size
+func (r *qcow2ImageReader) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements imageReader.ReadAt
+func (r *qcow2ImageReader) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if off >= r.size {
+			return n, io.EOF
+		}
+
+		offsetInCluster := off & (r.clusterSize - 1)
+		length := r.clusterSize - offsetInCluster
+		if length > int64(len(p)) {
+			length = int64(len(p))
+		}
+		if off+length > r.size {
+			length = r.size - off
+		}
+
+		clusterOffset, err := r.clusterOffset(off)
+		if err != nil {
+			return n, err
+		}
+		if clusterOffset == 0 {
+			// unallocated or zero cluster
+			for i := int64(0); i < length; i++ {
+				p[i] = 0
+			}
+		} else {
+			_, err = r.file.ReadAt(p[:length], clusterOffset+offsetInCluster)
+			if err != nil && err != io.EOF {
+				return n, err
+			}
+		}
+
+		n += int(length)
+		off += length
+		p = p[length:]
+	}
+	return n, nil
+}
+
+// clusterOffset returns the (host) offset of the cluster
+// which contains the given (virtual) offset,
+// 0 is returned in case the cluster is unallocated or zero.
+func (r *qcow2ImageReader) clusterOffset(off int64) (int64, error) {
+	l1Index := off >> (r.clusterBits + r.l2Bits)
+	if l1Index >= int64(len(r.l1Table)) {
+		return 0, errors.Wrapf(errInvalidQCOW2Image, "offset %d isn't covered by the L1 table", off)
+	}
+	l2TableOffset := int64(r.l1Table[l1Index] & qcow2OffsetMask)
+	if l2TableOffset == 0 {
+		return 0, nil
+	}
+
+	l2Index := (off >> r.clusterBits) & ((1 << r.l2Bits) - 1)
+	var buf [8]byte
+	_, err := r.file.ReadAt(buf[:], l2TableOffset+l2Index*8)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't read QCOW2 L2 table entry")
+	}
+	entry := binary.BigEndian.Uint64(buf[:])
+	if entry&qcow2CompressedFlag != 0 {
+		return 0, errors.Wrap(errInvalidQCOW2Image, "compressed clusters are not supported")
+	}
+	if r.version >= 3 && entry&qcow2ZeroFlag != 0 {
+		return 0, nil
+	}
+	return int64(entry & qcow2OffsetMask), nil
+}
+
+// newQCOW2ImageWriter creates an imageWriter,
+// which writes a virtual disk of the given size as a (version 2) QCOW2 image file.
+// Clusters are only allocated for the data written,
+// such that all holes of the virtual disk remain unallocated.
+// The image is only valid once the writer has been closed.
+func newQCOW2ImageWriter(file *os.File, size int64) (*qcow2ImageWriter, error) {
+	if size <= 0 {
+		return nil, errors.New("QCOW2 image requires a positive virtual size")
+	}
+
+	const clusterBits = qcow2DefaultClusterBits
+	clusterSize := int64(1) << clusterBits
+	l2Bits := uint32(clusterBits - 3)
+	l1Size := (size + (clusterSize << l2Bits) - 1) / (clusterSize << l2Bits)
+
+	writer := &qcow2ImageWriter{
+		file:        file,
+		size:        size,
+		clusterBits: clusterBits,
+		clusterSize: clusterSize,
+		l2Bits:      l2Bits,
+		l1Table:     make([]uint64, l1Size),
+		l2Tables:    make(map[int64][]uint64),
+	}
+	// the first cluster contains the header, followed by the L1 table
+	writer.l1TableOffset = clusterSize
+	writer.nextOffset = clusterSize + writer.alignToCluster(l1Size*8)
+
+	return writer, nil
+}
+
+// qcow2ImageWriter is an imageWriter for QCOW2 images,
+// which can be used from multiple goroutines at once.
+type qcow2ImageWriter struct {
+	file        *os.File
+	size        int64
+	clusterBits uint32
+	clusterSize int64
+	l2Bits      uint32
+
+	// protects the allocation of clusters and the in-memory tables
+	mux           sync.Mutex
+	nextOffset    int64
+	l1TableOffset int64
+	l1Table       []uint64
+	l2Tables      map[int64][]uint64
+}
+
+// WriteAt implements imageWriter.WriteAt
+func (w *qcow2ImageWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > w.size {
+		return 0, errors.Newf(
+			"cannot write %d bytes at offset %d, as it exceeds the image size (%d)",
+			len(p), off, w.size)
+	}
+
+	var n int
+	for len(p) > 0 {
+		offsetInCluster := off & (w.clusterSize - 1)
+		length := w.clusterSize - offsetInCluster
+		if length > int64(len(p)) {
+			length = int64(len(p))
+		}
+
+		clusterOffset := w.allocateCluster(off)
+		_, err := w.file.WriteAt(p[:length], clusterOffset+offsetInCluster)
+		if err != nil {
+			return n, err
+		}
+
+		n += int(length)
+		off += length
+		p = p[length:]
+	}
+	return n, nil
+}
+
+// allocateCluster returns the (host) offset of the cluster
+// which contains the given (virtual) offset, allocating it if needed.
+// Newly allocated clusters are placed at the end of the file,
+// and thus read as zeroes until written.
+func (w *qcow2ImageWriter) allocateCluster(off int64) int64 {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	l1Index := off >> (w.clusterBits + w.l2Bits)
+	l2Table, ok := w.l2Tables[l1Index]
+	if !ok {
+		l2Table = make([]uint64, 1<<w.l2Bits)
+		w.l2Tables[l1Index] = l2Table
+		w.l1Table[l1Index] = uint64(w.nextOffset) | qcow2CopiedFlag
+		w.nextOffset += w.clusterSize
+	}
+
+	l2Index := (off >> w.clusterBits) & ((1 << w.l2Bits) - 1)
+	if l2Table[l2Index] == 0 {
+		l2Table[l2Index] = uint64(w.nextOffset) | qcow2CopiedFlag
+		w.nextOffset += w.clusterSize
+	}
+	return int64(l2Table[l2Index] & qcow2OffsetMask)
+}
+
+// Close implements imageWriter.Close,
+// writing all metadata (tables and header) of the image.
+func (w *qcow2ImageWriter) Close() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	// write all L2 tables
+	for l1Index, l2Table := range w.l2Tables {
+		offset := int64(w.l1Table[l1Index] & qcow2OffsetMask)
+		err := w.writeTable(l2Table, offset)
+		if err != nil {
+			return errors.Wrap(err, "couldn't write QCOW2 L2 table")
+		}
+	}
+
+	// write the L1 table
+	err := w.writeTable(w.l1Table, w.l1TableOffset)
+	if err != nil {
+		return errors.Wrap(err, "couldn't write QCOW2 L1 table")
+	}
+
+	// place the refcount table and blocks at the end of the image,
+	// such that they cover all clusters, including themselves
+	refcountsPerBlock := w.clusterSize / 2 // 16-bit refcounts
+	refcountTableOffset := w.nextOffset
+	var refcountTableClusters, refcountBlockCount int64
+	for {
+		clusterCount := refcountTableOffset/w.clusterSize + refcountTableClusters + refcountBlockCount
+		requiredBlockCount := (clusterCount + refcountsPerBlock - 1) / refcountsPerBlock
+		requiredTableClusters := w.alignToCluster(requiredBlockCount*8) / w.clusterSize
+		if requiredBlockCount == refcountBlockCount && requiredTableClusters == refcountTableClusters {
+			break
+		}
+		refcountBlockCount, refcountTableClusters = requiredBlockCount, requiredTableClusters
+	}
+	clusterCount := refcountTableOffset/w.clusterSize + refcountTableClusters + refcountBlockCount
+	refcountBlocksOffset := refcountTableOffset + refcountTableClusters*w.clusterSize
+
+	// all clusters are in use exactly once (we never have holes in the host file)
+	refcountTable := make([]uint64, refcountTableClusters*w.clusterSize/8)
+	refcountBlock := make([]byte, w.clusterSize)
+	for i := int64(0); i < refcountBlockCount; i++ {
+		offset := refcountBlocksOffset + i*w.clusterSize
+		refcountTable[i] = uint64(offset)
+
+		for j := int64(0); j < refcountsPerBlock; j++ {
+			var refcount uint16
+			if i*refcountsPerBlock+j < clusterCount {
+				refcount = 1
+			}
+			binary.BigEndian.PutUint16(refcountBlock[j*2:], refcount)
+		}
+		_, err = w.file.WriteAt(refcountBlock, offset)
+		if err != nil {
+			return errors.Wrap(err, "couldn't write QCOW2 refcount block")
+		}
+	}
+	err = w.writeTable(refcountTable, refcountTableOffset)
+	if err != nil {
+		return errors.Wrap(err, "couldn't write QCOW2 refcount table")
+	}
+
+	// write the header last, such that the image is only valid when complete
+	header := qcow2Header{
+		Magic:                 qcow2Magic,
+		Version:               2,
+		ClusterBits:           w.clusterBits,
+		Size:                  uint64(w.size),
+		L1Size:                uint32(len(w.l1Table)),
+		L1TableOffset:         uint64(w.l1TableOffset),
+		RefcountTableOffset:   uint64(refcountTableOffset),
+		RefcountTableClusters: uint32(refcountTableClusters),
+	}
+	buf := make([]byte, qcow2HeaderSize)
+	header.encode(buf)
+	_, err = w.file.WriteAt(buf, 0)
+	if err != nil {
+		return errors.Wrap(err, "couldn't write QCOW2 header")
+	}
+	return nil
+}
+
+// writeTable writes a table of (big endian) 64-bit entries at the given offset.
+func (w *qcow2ImageWriter) writeTable(table []uint64, offset int64) error {
+	buf := make([]byte, len(table)*8)
+	for i, entry := range table {
+		binary.BigEndian.PutUint64(buf[i*8:], entry)
+	}
+	_, err := w.file.WriteAt(buf, offset)
+	return err
+}
+
+// alignToCluster rounds up the given size to a multiple of the cluster size.
+func (w *qcow2ImageWriter) alignToCluster(size int64) int64 {
+	return (size + w.clusterSize - 1) &^ (w.clusterSize - 1)
+}
+
+// qcow2Header is the (version 2) header of a QCOW2 image,
+// which is also the first part of a version 3 header.
+// See https://github.com/qemu/qemu/blob/master/docs/interop/qcow2.txt
+type qcow2Header struct {
+	Magic                 uint32
+	Version               uint32
+	BackingFileOffset     uint64
+	BackingFileSize       uint32
+	ClusterBits           uint32
+	Size                  uint64
+	CryptMethod           uint32
+	L1Size                uint32
+	L1TableOffset         uint64
+	RefcountTableOffset   uint64
+	RefcountTableClusters uint32
+	NbSnapshots           uint32
+	SnapshotsOffset       uint64
+}
+
+func (h *qcow2Header) decode(buf []byte) {
+	h.Magic = binary.BigEndian.Uint32(buf[0:])
+	h.Version = binary.BigEndian.Uint32(buf[4:])
+	h.BackingFileOffset = binary.BigEndian.Uint64(buf[8:])
+	h.BackingFileSize = binary.BigEndian.Uint32(buf[16:])
+	h.ClusterBits = binary.BigEndian.Uint32(buf[20:])
+	h.Size = binary.BigEndian.Uint64(buf[24:])
+	h.CryptMethod = binary.BigEndian.Uint32(buf[32:])
+	h.L1Size = binary.BigEndian.Uint32(buf[36:])
+	h.L1TableOffset = binary.BigEndian.Uint64(buf[40:])
+	h.RefcountTableOffset = binary.BigEndian.Uint64(buf[48:])
+	h.RefcountTableClusters = binary.BigEndian.Uint32(buf[56:])
+	h.NbSnapshots = binary.BigEndian.Uint32(buf[60:])
+	h.SnapshotsOffset = binary.BigEndian.Uint64(buf[64:])
+}
+
+func (h *qcow2Header) encode(buf []byte) {
+	binary.BigEndian.PutUint32(buf[0:], h.Magic)
+	binary.BigEndian.PutUint32(buf[4:], h.Version)
+	binary.BigEndian.PutUint64(buf[8:], h.BackingFileOffset)
+	binary.BigEndian.PutUint32(buf[16:], h.BackingFileSize)
+	binary.BigEndian.PutUint32(buf[20:], h.ClusterBits)
+	binary.BigEndian.PutUint64(buf[24:], h.Size)
+	binary.BigEndian.PutUint32(buf[32:], h.CryptMethod)
+	binary.BigEndian.PutUint32(buf[36:], h.L1Size)
+	binary.BigEndian.PutUint64(buf[40:], h.L1TableOffset)
+	binary.BigEndian.PutUint64(buf[48:], h.RefcountTableOffset)
+	binary.BigEndian.PutUint32(buf[56:], h.RefcountTableClusters)
+	binary.BigEndian.PutUint32(buf[60:], h.NbSnapshots)
+	binary.BigEndian.PutUint64(buf[64:], h.SnapshotsOffset)
+}
+
+const (
+	qcow2Magic      = 0x514649fb // "QFI\xfb"
+	qcow2HeaderSize = 72
+
+	qcow2MinClusterBits     = 9
+	qcow2MaxClusterBits     = 21
+	qcow2DefaultClusterBits = 16 // 64 KiB, same as qemu-img
+
+	qcow2MaxSize   = 1 << 62              // max virtual size, such that it fits an int64
+	qcow2MaxL1Size = 32 * 1024 * 1024 / 8 // max L1 table entries (32 MiB), same as qemu
+
+	qcow2OffsetMask     = 0x00fffffffffffe00
+	qcow2CopiedFlag     = 1 << 63
+	qcow2CompressedFlag = 1 << 62
+	qcow2ZeroFlag       = 1
+)
+
+var (
+	errInvalidQCOW2Image = errors.New("invalid or unsupported QCOW2 image")
+)
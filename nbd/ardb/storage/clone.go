@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage/lba"
+)
+
+// CloneVdisk creates an (instant) copy-on-write clone of a parent vdisk.
+// The clone reads through to its parent until a block is written in the clone,
+// hence no data is copied at all when creating a clone.
+// The parent and clone vdisks have to have the same storage type and block size,
+// and are stored within the same cluster.
+//
+// Note that the parent shouldn't be modified for as long as it has clones,
+// as these modifications would be visible in those clones.
+// Semi-deduped vdisks cannot be cloned.
+func CloneVdisk(parent, clone CopyVdiskConfig, cluster ardb.StorageCluster) error {
+	parentStorageType := parent.Type.StorageType()
+	cloneStorageType := clone.Type.StorageType()
+	if parentStorageType != cloneStorageType {
+		return errors.Newf(
+			"parent vdisk %s and clone vdisk %s have different storageTypes (%s != %s)",
+			parent.VdiskID, clone.VdiskID, parentStorageType, cloneStorageType)
+	}
+	if parentStorageType == config.StorageSemiDeduped {
+		return errors.Wrapf(ErrCloneNotSupported,
+			"cannot clone vdisk %s", parent.VdiskID)
+	}
+	if parent.BlockSize != clone.BlockSize {
+		return errors.Newf(
+			"vdisks %s and %s have non matching block sizes (%d != %d)",
+			parent.VdiskID, clone.VdiskID, parent.BlockSize, clone.BlockSize)
+	}
+	if parent.VdiskID == clone.VdiskID {
+		return errors.Newf("vdisk %s cannot be cloned into itself", parent.VdiskID)
+	}
+
+	log.Infof("creating vdisk %s as a clone of vdisk %s", clone.VdiskID, parent.VdiskID)
+	err := registerVdiskClone(parent.VdiskID, clone.VdiskID, cluster)
+	if err != nil {
+		return err
+	}
+	return RegisterVdisk(clone.VdiskID, clone.Type, cluster)
+}
+
+// LoadVdiskParent loads the ID of the vdisk the given vdisk is a clone of.
+// An empty string is returned in case the given vdisk isn't a clone.
+func LoadVdiskParent(vdiskID string, cluster ardb.StorageCluster) (string, error) {
+	if isInterfaceValueNil(cluster) {
+		return "", ErrClusterNotDefined
+	}
+	parentID, err := ardb.String(cluster.Do(
+		ardb.Command(command.Get, vdiskParentKey(vdiskID))))
+	if err == ardb.ErrNil {
+		return "", nil
+	}
+	return parentID, err
+}
+
+// LoadVdiskLineage loads all ancestors of the given vdisk,
+// starting with its direct parent, and ending with the (original) vdisk
+// it was (indirectly) cloned from. Nil is returned in case the vdisk isn't a clone.
+func LoadVdiskLineage(vdiskID string, cluster ardb.StorageCluster) ([]string, error) {
+	var lineage []string
+	visited := map[string]struct{}{vdiskID: {}}
+	for {
+		parentID, err := LoadVdiskParent(vdiskID, cluster)
+		if err != nil || parentID == "" {
+			return lineage, err
+		}
+		if _, ok := visited[parentID]; ok {
+			return nil, errors.Newf(
+				"lineage of vdisk %s contains a cycle at vdisk %s", vdiskID, parentID)
+		}
+		visited[parentID] = struct{}{}
+		lineage = append(lineage, parentID)
+		vdiskID = parentID
+	}
+}
+
+// ListVdiskClones lists the IDs of all (direct) clones of the given vdisk.
+func ListVdiskClones(vdiskID string, cluster ardb.StorageCluster) ([]string, error) {
+	if isInterfaceValueNil(cluster) {
+		return nil, ErrClusterNotDefined
+	}
+	clones, err := ardb.OptStrings(cluster.Do(
+		ardb.Command(command.SetMembers, vdiskClonesKey(vdiskID))))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(clones)
+	return clones, nil
+}
+
+// registerVdiskClone stores the lineage link between a parent and its clone.
+func registerVdiskClone(parentID, cloneID string, cluster ardb.StorageCluster) error {
+	return ardb.Error(cluster.Do(ardb.Commands(
+		ardb.Command(command.Set, vdiskParentKey(cloneID), parentID),
+		ardb.Command(command.SetAdd, vdiskClonesKey(parentID), cloneID),
+	)))
+}
+
+// unregisterVdiskClone removes the lineage link between a clone and its parent (if any).
+func unregisterVdiskClone(cloneID string, cluster ardb.StorageCluster) (bool, error) {
+	parentID, err := LoadVdiskParent(cloneID, cluster)
+	if err != nil || parentID == "" {
+		return false, err
+	}
+	log.Infof("unlinking vdisk %s from its parent vdisk %s", cloneID, parentID)
+	err = ardb.Error(cluster.Do(ardb.Commands(
+		ardb.Command(command.SetRemove, vdiskClonesKey(parentID), cloneID),
+		ardb.Command(command.Delete, vdiskParentKey(cloneID)),
+	)))
+	return err == nil, err
+}
+
+// listMaskingIndicesInCluster lists the indices stored by a vdisk itself,
+// which mask the blocks of its parent(s), including those stored as deleted.
+// These are LBA sector indices for deduped vdisks, and block indices for non-deduped vdisks.
+func listMaskingIndicesInCluster(vdiskID string, t config.VdiskType, cluster ardb.StorageCluster) ([]int64, error) {
+	var key string
+	switch st := t.StorageType(); st {
+	case config.StorageDeduped:
+		key = lbaStorageKey(vdiskID)
+	case config.StorageNonDeduped:
+		key = nonDedupedStorageKey(vdiskID)
+	default:
+		return nil, errors.Wrapf(ErrCloneNotSupported,
+			"cannot list masking indices of vdisk %s of storage type %s", vdiskID, st)
+	}
+
+	var mux sync.Mutex
+	var indices []int64
+	err := forAllServers(cluster, func(server ardb.StorageServer) error {
+		serverIndices, err := ardb.Int64s(server.Do(ardb.Command(command.HashKeys, key)))
+		if err != nil {
+			if err == ardb.ErrNil {
+				return nil
+			}
+			return err
+		}
+		mux.Lock()
+		indices = append(indices, serverIndices...)
+		mux.Unlock()
+		return nil
+	})
+	return indices, err
+}
+
+// maskingIndex returns the index which masks the given block index of a parent vdisk,
+// which is the index of the LBA sector that contains it for deduped vdisks,
+// as a clone stores (and thus masks) entire LBA sectors.
+func maskingIndex(blockIndex int64, t config.VdiskType) int64 {
+	if t.StorageType() == config.StorageDeduped {
+		return blockIndex / lba.NumberOfRecordsPerLBASector
+	}
+	return blockIndex
+}
+
+// vdiskParentKey returns the key which stores the parent of a cloned vdisk.
+func vdiskParentKey(vdiskID string) string {
+	return vdiskParentKeyPrefix + vdiskID
+}
+
+// vdiskClonesKey returns the key of the set which stores all clones of a vdisk.
+func vdiskClonesKey(vdiskID string) string {
+	return vdiskClonesKeyPrefix + vdiskID
+}
+
+var (
+	// ErrCloneNotSupported is an error returned
+	// when trying to clone a vdisk whose storage type doesn't support clones.
+	ErrCloneNotSupported = errors.New("storage type doesn't support clones")
+	// ErrVdiskHasClones is an error returned
+	// when trying to delete a vdisk which still has clones.
+	ErrVdiskHasClones = errors.New("vdisk has clones")
+)
+
+const (
+	vdiskParentKeyPrefix = "parent:"
+	vdiskClonesKeyPrefix = "clones:"
+)
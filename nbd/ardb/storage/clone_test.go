@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestCloneVdiskDeduped(t *testing.T) {
+	testCloneVdisk(t, config.VdiskTypeBoot)
+}
+
+func TestCloneVdiskNonDeduped(t *testing.T) {
+	testCloneVdisk(t, config.VdiskTypeDB)
+}
+
+func testCloneVdisk(t *testing.T, vdiskType config.VdiskType) {
+	const blockSize = 512
+
+	require := require.New(t)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	newStorage := func(vdiskID string) BlockStorage {
+		lineage, err := LoadVdiskLineage(vdiskID, cluster)
+		require.NoError(err)
+		storage, err := NewBlockStorage(BlockStorageConfig{
+			VdiskID:   vdiskID,
+			VdiskType: vdiskType,
+			BlockSize: blockSize,
+			Lineage:   lineage,
+		}, cluster, nil)
+		require.NoError(err)
+		return storage
+	}
+	newContent := func(b byte) []byte {
+		content := make([]byte, blockSize)
+		for i := range content {
+			content[i] = b
+		}
+		return content
+	}
+
+	// create a parent vdisk with some blocks
+	parentStorage := newStorage("parent")
+	require.NoError(parentStorage.SetBlock(0, newContent(1)))
+	require.NoError(parentStorage.SetBlock(1, newContent(2)))
+	require.NoError(parentStorage.SetBlock(2, newContent(3)))
+	require.NoError(parentStorage.Flush())
+
+	// a vdisk cannot be cloned into a vdisk with a different block size
+	err := CloneVdisk(
+		CopyVdiskConfig{VdiskID: "parent", Type: vdiskType, BlockSize: blockSize},
+		CopyVdiskConfig{VdiskID: "foo", Type: vdiskType, BlockSize: blockSize * 2},
+		cluster)
+	require.Error(err)
+
+	// clone the parent, and clone that clone again
+	require.NoError(CloneVdisk(
+		CopyVdiskConfig{VdiskID: "parent", Type: vdiskType, BlockSize: blockSize},
+		CopyVdiskConfig{VdiskID: "clone", Type: vdiskType, BlockSize: blockSize},
+		cluster))
+	require.NoError(CloneVdisk(
+		CopyVdiskConfig{VdiskID: "clone", Type: vdiskType, BlockSize: blockSize},
+		CopyVdiskConfig{VdiskID: "grandclone", Type: vdiskType, BlockSize: blockSize},
+		cluster))
+
+	lineage, err := LoadVdiskLineage("grandclone", cluster)
+	require.NoError(err)
+	require.Equal([]string{"clone", "parent"}, lineage)
+	exists, err := VdiskExistsInCluster("grandclone", vdiskType, cluster)
+	require.NoError(err)
+	require.True(exists)
+
+	// a clone reads through to its parent
+	cloneStorage := newStorage("clone")
+	content, err := cloneStorage.GetBlock(1)
+	require.NoError(err)
+	require.Equal(newContent(2), content)
+
+	// writing and deleting blocks in a clone, doesn't modify its parent
+	require.NoError(cloneStorage.SetBlock(1, newContent(4)))
+	require.NoError(cloneStorage.DeleteBlock(2))
+	require.NoError(cloneStorage.SetBlock(3, newContent(5)))
+	require.NoError(cloneStorage.Flush())
+
+	for index, expected := range map[int64][]byte{1: newContent(4), 2: nil, 3: newContent(5)} {
+		content, err = cloneStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(expected, content, "clone block %d", index)
+	}
+	for index, expected := range map[int64][]byte{1: newContent(2), 2: newContent(3), 3: nil} {
+		content, err = parentStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(expected, content, "parent block %d", index)
+	}
+
+	// a clone of a clone reads through the entire lineage
+	grandCloneStorage := newStorage("grandclone")
+	for index, expected := range map[int64][]byte{0: newContent(1), 1: newContent(4), 2: nil, 3: newContent(5)} {
+		content, err = grandCloneStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(expected, content, "grandclone block %d", index)
+	}
+
+	indices, err := ListBlockIndicesInCluster("grandclone", vdiskType, cluster)
+	require.NoError(err)
+	require.Equal([]int64{0, 1, 3}, indices, "deleted block 2 is masked")
+
+	// a clone without any data of its own is listed as well
+	vdiskID, ok := filterListedVdiskID(vdiskParentKey("grandclone"))
+	require.True(ok)
+	require.Equal("grandclone", vdiskID)
+
+	// a vdisk with clones cannot be deleted
+	clones, err := ListVdiskClones("parent", cluster)
+	require.NoError(err)
+	require.Equal([]string{"clone"}, clones)
+	_, err = DeleteVdiskInCluster("clone", vdiskType, cluster)
+	require.Equal(ErrVdiskHasClones, errors.Cause(err))
+
+	// deleting the clones, unprotects the parent
+	deleted, err := DeleteVdiskInCluster("grandclone", vdiskType, cluster)
+	require.NoError(err)
+	require.True(deleted)
+	deleted, err = DeleteVdiskInCluster("clone", vdiskType, cluster)
+	require.NoError(err)
+	require.True(deleted)
+	clones, err = ListVdiskClones("parent", cluster)
+	require.NoError(err)
+	require.Empty(clones)
+	deleted, err = DeleteVdiskInCluster("parent", vdiskType, cluster)
+	require.NoError(err)
+	require.True(deleted)
+}
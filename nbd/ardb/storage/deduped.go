@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/zero-os/0-Disk"
 	"github.com/zero-os/0-Disk/errors"
@@ -14,6 +16,12 @@ import (
 
 // Deduped returns a deduped BlockStorage
 func Deduped(vdiskID string, blockSize, lbaCacheLimit int64, cluster, templateCluster ardb.StorageCluster) (BlockStorage, error) {
+	return newDedupedStorage(vdiskID, nil, blockSize, lbaCacheLimit, cluster, templateCluster)
+}
+
+// newDedupedStorage creates a deduped BlockStorage,
+// which reads through to the given lineage, in case the vdisk is a clone.
+func newDedupedStorage(vdiskID string, lineage []string, blockSize, lbaCacheLimit int64, cluster, templateCluster ardb.StorageCluster) (BlockStorage, error) {
 	// define the LBA cache limit
 	cacheLimit := lbaCacheLimit
 	if cacheLimit < lba.BytesPerSector {
@@ -24,7 +32,7 @@ func Deduped(vdiskID string, blockSize, lbaCacheLimit int64, cluster, templateCl
 	}
 
 	// create the LBA (used to store deduped metadata)
-	lbaStorage := newLBASectorStorage(vdiskID, cluster)
+	lbaStorage := newLBASectorStorage(vdiskID, lineage, cluster)
 	vlba, err := lba.NewLBA(cacheLimit, lbaStorage)
 	if err != nil {
 		log.Errorf("couldn't create the LBA: %s", err.Error())
@@ -119,6 +127,18 @@ func (ds *dedupedStorage) Flush() (err error) {
 	return
 }
 
+// CachedIndices implements CacheWarmer.CachedIndices
+// returning the indices of all LBA sectors currently cached.
+func (ds *dedupedStorage) CachedIndices() []int64 {
+	return ds.lba.CachedSectorIndices()
+}
+
+// WarmCache implements CacheWarmer.WarmCache
+// prefetching the LBA sectors at the given indices.
+func (ds *dedupedStorage) WarmCache(indices []int64) error {
+	return ds.lba.Prefetch(indices)
+}
+
 // getPrimaryContent gets content from the primary storage.
 // Assigned to (*dedupedStorage).getContent in case this storage has no template support.
 func (ds *dedupedStorage) getPrimaryContent(hash zerodisk.Hash) (content []byte, err error) {
@@ -309,6 +329,23 @@ func listDedupedBlockIndices(vdiskID string, cluster ardb.StorageCluster) ([]int
 	return indices, nil
 }
 
+// copyDeduped copies a deduped storage from a sourceID to a targetID,
+// within the same cluster or between different clusters.
+// When copying between different clusters, the content referenced
+// by the metadata is copied as well, such that the target vdisk
+// doesn't depend on the source cluster, nor on its (optional) template cluster.
+func copyDeduped(sourceID, targetID string, sourceBS, targetBS int64, sourceCluster, sourceTemplateCluster, targetCluster ardb.StorageCluster) error {
+	err := copyDedupedMetadata(sourceID, targetID, sourceBS, targetBS, sourceCluster, targetCluster)
+	if err != nil || isInterfaceValueNil(targetCluster) {
+		return err
+	}
+
+	log.Infof(
+		"copying deduped content from vdisk %s to vdisk %s between clusters...",
+		sourceID, targetID)
+	return copyDedupedContent(sourceID, sourceCluster, sourceTemplateCluster, targetCluster)
+}
+
 // copyDedupedMetadata copies all metadata of a deduped storage
 // from a sourceID to a targetID, within the same cluster or between different clusters.
 func copyDedupedMetadata(sourceID, targetID string, sourceBS, targetBS int64, sourceCluster, targetCluster ardb.StorageCluster) error {
@@ -338,6 +375,268 @@ func copyDedupedMetadata(sourceID, targetID string, sourceBS, targetBS int64, so
 	return copyDedupedDifferentServerCount(sourceID, targetID, sourceCluster, targetCluster)
 }
 
+// copyDedupedContent copies all content referenced by a deduped vdisk
+// from a source cluster to a target cluster.
+// Only the content missing on the target cluster is copied,
+// which is checked in batches, one batch per target server at a time.
+// Content which is missing on the source cluster is fetched
+// from the (optional) template cluster of the source vdisk instead.
+func copyDedupedContent(vdiskID string, sourceCluster, sourceTemplateCluster, targetCluster ardb.StorageCluster) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	copier := &dedupedContentCopier{
+		vdiskID:         vdiskID,
+		sourceCluster:   sourceCluster,
+		templateCluster: sourceTemplateCluster,
+		targetCluster:   targetCluster,
+	}
+
+	err := copier.addVdisk(ctx, vdiskID, sourceCluster)
+	if err != nil {
+		return err
+	}
+	err = copier.flushAll()
+	if err != nil {
+		return err
+	}
+
+	log.Infof(
+		"copied %d of %d unique content blocks of deduped vdisk %s (%d already available, %d missing)",
+		copier.copied, copier.scanned, vdiskID, copier.available, copier.missing)
+	return nil
+}
+
+// dedupedContentCopier is used to copy
+// the content of a single deduped vdisk between clusters.
+// Content is batched per object index (the first byte of its hash),
+// as all content of the same object index is stored on the same server.
+type dedupedContentCopier struct {
+	vdiskID       string
+	sourceCluster ardb.StorageCluster
+	targetCluster ardb.StorageCluster
+	pending       [256][]zerodisk.Hash
+
+	// content shared by multiple blocks is only copied once,
+	// as long as it is seen within maxDedupedContentSeen unique content blocks
+	seen    map[string]struct{}
+	scanned int64
+
+	// optional: content missing on the source cluster is fetched from this cluster
+	templateCluster ardb.StorageCluster
+
+	// optional: limits the rate at which content is added (nil if unlimited)
+	throttle <-chan time.Time
+	// optional: full batches are sent to this channel rather than copied directly,
+	// such that they can be copied in parallel using copyBatch
+	batchCh chan<- dedupedContentBatch
+	// required when a throttle or batch channel is defined
+	ctx context.Context
+
+	// counters are updated atomically, as batches can be copied in parallel
+	copied, available, missing int64
+}
+
+// dedupedContentBatch is a batch of content hashes of the same object index.
+type dedupedContentBatch struct {
+	objectIndex int64
+	hashes      []zerodisk.Hash
+}
+
+// addVdisk adds the content of all blocks referenced
+// by the LBA of the given vdisk, stored in the given cluster.
+func (c *dedupedContentCopier) addVdisk(ctx context.Context, vdiskID string, cluster ardb.StorageCluster) error {
+	serverCh, err := cluster.ServerIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	storageKey := lbaStorageKey(vdiskID)
+	for server := range serverCh {
+		for input := range dedupMetadataFetcher(ctx, storageKey, server) {
+			if input.Error != nil {
+				return errors.Wrapf(input.Error,
+					"couldn't fetch LBA sectors of vdisk %s", vdiskID)
+			}
+			for sectorIndex, bytes := range input.Data {
+				sector, err := lba.SectorFromBytes(bytes)
+				if err != nil {
+					return errors.Wrapf(err,
+						"invalid raw sector bytes at sector index %d", sectorIndex)
+				}
+				err = c.addSector(sector)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addSector adds the content of all blocks referenced by the given sector,
+// copying a batch of content as soon as it is full.
+func (c *dedupedContentCopier) addSector(sector *lba.Sector) error {
+	for hashIndex := int64(0); hashIndex < lba.NumberOfRecordsPerLBASector; hashIndex++ {
+		hash := sector.Get(hashIndex)
+		if hash == nil {
+			continue // no content stored for this block
+		}
+		if _, ok := c.seen[string(hash)]; ok {
+			continue
+		}
+		if c.seen == nil || len(c.seen) >= maxDedupedContentSeen {
+			// bound the memory used to remember seen content,
+			// content which is seen again is found to be available on the target cluster
+			c.seen = make(map[string]struct{})
+		}
+		c.seen[string(hash)] = struct{}{}
+		c.scanned++
+
+		if c.throttle != nil {
+			select {
+			case <-c.throttle:
+			case <-c.ctx.Done():
+				return c.ctx.Err()
+			}
+		}
+
+		objectIndex := hash[0]
+		c.pending[objectIndex] = append(c.pending[objectIndex], hash)
+		if len(c.pending[objectIndex]) >= dedupedContentCopyBatchSize {
+			err := c.flush(int64(objectIndex))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush copies the pending content of the given object index,
+// or sends it as a batch to the batch channel, if one is defined.
+func (c *dedupedContentCopier) flush(objectIndex int64) error {
+	hashes := c.pending[objectIndex]
+	if len(hashes) == 0 {
+		return nil
+	}
+	c.pending[objectIndex] = nil
+
+	if c.batchCh == nil {
+		return c.copyBatch(objectIndex, hashes)
+	}
+	select {
+	case c.batchCh <- dedupedContentBatch{objectIndex: objectIndex, hashes: hashes}:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+// flushAll copies (or sends) all remaining pending content.
+func (c *dedupedContentCopier) flushAll() error {
+	for objectIndex := range c.pending {
+		err := c.flush(int64(objectIndex))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBatch copies the given content of the given object index,
+// which isn't available on the target cluster yet.
+func (c *dedupedContentCopier) copyBatch(objectIndex int64, hashes []zerodisk.Hash) error {
+	// check which content already exists on the target cluster
+	cmds := make([]ardb.StorageAction, len(hashes))
+	for i, hash := range hashes {
+		cmds[i] = ardb.Command(command.Exists, hash.Bytes())
+	}
+	exists, err := ardb.Bools(c.targetCluster.DoFor(objectIndex, ardb.Commands(cmds...)))
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't check existence of content of vdisk %s on target cluster", c.vdiskID)
+	}
+	var missing []zerodisk.Hash
+	for i, hash := range hashes {
+		if exists[i] {
+			atomic.AddInt64(&c.available, 1)
+			continue
+		}
+		missing = append(missing, hash)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// fetch the missing content from the source cluster
+	cmds = make([]ardb.StorageAction, len(missing))
+	for i, hash := range missing {
+		cmds[i] = ardb.Command(command.Get, hash.Bytes())
+	}
+	contents, err := ardb.Values(c.sourceCluster.DoFor(objectIndex, ardb.Commands(cmds...)))
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't fetch content of vdisk %s from source cluster", c.vdiskID)
+	}
+
+	// content missing on the source cluster might be available in the template cluster
+	var templateIndices []int
+	for i := range missing {
+		if contents[i] == nil {
+			templateIndices = append(templateIndices, i)
+		}
+	}
+	if len(templateIndices) > 0 && !isInterfaceValueNil(c.templateCluster) {
+		cmds = make([]ardb.StorageAction, len(templateIndices))
+		for i, index := range templateIndices {
+			cmds[i] = ardb.Command(command.Get, missing[index].Bytes())
+		}
+		templateContents, err := ardb.Values(c.templateCluster.DoFor(objectIndex, ardb.Commands(cmds...)))
+		if err != nil {
+			return errors.Wrapf(err,
+				"couldn't fetch content of vdisk %s from template cluster", c.vdiskID)
+		}
+		for i, index := range templateIndices {
+			contents[index] = templateContents[i]
+		}
+	}
+
+	// store the missing content on the target cluster
+	cmds = cmds[:0]
+	for i, hash := range missing {
+		content, err := ardb.OptBytes(contents[i], nil)
+		if err != nil {
+			return errors.Wrapf(err,
+				"invalid content of vdisk %s fetched from source cluster", c.vdiskID)
+		}
+		if content == nil {
+			log.Debugf(
+				"content %x of vdisk %s isn't available on source cluster, it isn't copied",
+				hash, c.vdiskID)
+			atomic.AddInt64(&c.missing, 1)
+			continue
+		}
+		cmds = append(cmds, ardb.Command(command.Set, hash.Bytes(), content))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	err = ardb.Error(c.targetCluster.DoFor(objectIndex, ardb.Commands(cmds...)))
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't store content of vdisk %s on target cluster", c.vdiskID)
+	}
+	atomic.AddInt64(&c.copied, int64(len(cmds)))
+	return nil
+}
+
+// amount of content blocks checked and copied at once per target server
+const dedupedContentCopyBatchSize = 128
+
+// max amount of unique content blocks remembered while copying content
+const maxDedupedContentSeen = 1024 * 64
+
 func copyDedupedSameCluster(sourceID, targetID string, cluster ardb.StorageCluster) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -497,7 +796,7 @@ func copyDedupedDifferentServerCount(sourceID, targetID string, sourceCluster, t
 	}
 
 	sourceKey := lbaStorageKey(sourceID)
-	targetStorage := newLBASectorStorage(targetID, targetCluster)
+	targetStorage := newLBASectorStorage(targetID, nil, targetCluster)
 
 	type copyResult struct {
 		Count int64
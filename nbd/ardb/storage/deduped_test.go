@@ -9,8 +9,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/zero-os/0-Disk"
-	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/log"
 	"github.com/zero-os/0-Disk/nbd/ardb"
 	"github.com/zero-os/0-Disk/nbd/ardb/command"
@@ -18,26 +18,11 @@ import (
 	"github.com/zero-os/0-Disk/redisstub"
 )
 
-// simplified algorithm based on `zeroctl/cmd/copyvdisk/vdisk.go`
+// copies only the (LBA) metadata, and not the content, from vdisk A to vdisk B,
+// such that the content has to be fetched from the template cluster
 func copyTestMetaData(t *testing.T, vdiskIDA, vdiskIDB string, clusterA, clusterB ardb.StorageCluster) {
-	const (
-		vtype     = config.VdiskTypeBoot
-		blockSize = int64(4096)
-	)
-	err := CopyVdisk(
-		CopyVdiskConfig{
-			VdiskID:   vdiskIDA,
-			Type:      vtype,
-			BlockSize: blockSize,
-		},
-		CopyVdiskConfig{
-			VdiskID:   vdiskIDB,
-			Type:      vtype,
-			BlockSize: blockSize,
-		},
-		clusterA,
-		clusterB,
-	)
+	const blockSize = int64(4096)
+	err := copyDedupedMetadata(vdiskIDA, vdiskIDB, blockSize, blockSize, clusterA, clusterB)
 	if err != nil {
 		debug.PrintStack()
 		t.Fatal(err)
@@ -575,7 +560,7 @@ func TestCopyDedupedDifferentServerCount(t *testing.T) {
 	sourceCluster := redisstub.NewCluster(4, true)
 	defer sourceCluster.Close()
 
-	sourceSectorStorage := newLBASectorStorage("source", sourceCluster)
+	sourceSectorStorage := newLBASectorStorage("source", nil, sourceCluster)
 
 	// create random source sectors
 	var indices []int64
@@ -621,7 +606,7 @@ func testCopyDedupedDifferentServerCount(assert *assert.Assertions, indices []in
 
 	// now validate all sectors are correctly copied
 
-	targetSectorStorage := newLBASectorStorage(targetID, targetCluster)
+	targetSectorStorage := newLBASectorStorage(targetID, nil, targetCluster)
 	for _, index := range indices {
 		sourceSector, err := sourceSectorStorage.GetSector(index)
 		if !assert.NoError(err) {
@@ -638,6 +623,107 @@ func testCopyDedupedDifferentServerCount(assert *assert.Assertions, indices []in
 	}
 }
 
+func TestCopyDedupedContent(t *testing.T) {
+	// same amount of servers
+	testCopyDedupedContent(t, 4, 4)
+	// different amount of servers
+	testCopyDedupedContent(t, 4, 3)
+}
+
+func testCopyDedupedContent(t *testing.T, sourceServerCount, targetServerCount int) {
+	const (
+		blockSize  = 512
+		blockCount = 64
+	)
+
+	require := require.New(t)
+
+	sourceCluster := redisstub.NewCluster(sourceServerCount, true)
+	defer sourceCluster.Close()
+	targetCluster := redisstub.NewCluster(targetServerCount, true)
+	defer targetCluster.Close()
+
+	// store some blocks, sharing some of the content
+	var contents [][]byte
+	source, err := Deduped("source", blockSize, ardb.DefaultLBACacheLimit, sourceCluster, nil)
+	require.NoError(err)
+	for index := int64(0); index < blockCount; index++ {
+		content := make([]byte, blockSize)
+		content[0] = byte(index%(blockCount/2)) + 1
+		contents = append(contents, content)
+		require.NoError(source.SetBlock(index, content))
+	}
+	require.NoError(source.Flush())
+	source.Close()
+
+	// some of the content is already available on the target cluster
+	target, err := Deduped("other", blockSize, ardb.DefaultLBACacheLimit, targetCluster, nil)
+	require.NoError(err)
+	require.NoError(target.SetBlock(0, contents[0]))
+	require.NoError(target.Flush())
+	target.Close()
+
+	err = copyDeduped("source", "target", blockSize, blockSize, sourceCluster, nil, targetCluster)
+	require.NoError(err)
+
+	// all content should be readable from the target cluster alone
+	target, err = Deduped("target", blockSize, ardb.DefaultLBACacheLimit, targetCluster, nil)
+	require.NoError(err)
+	defer target.Close()
+	for index, content := range contents {
+		block, err := target.GetBlock(int64(index))
+		require.NoError(err)
+		require.Equal(content, block, "block %d", index)
+		testDedupContentExists(t, targetCluster, content)
+	}
+}
+
+// content only available in the template cluster of the source vdisk
+// is copied from that template cluster
+func TestCopyDedupedContentFromTemplate(t *testing.T) {
+	const (
+		blockSize  = 512
+		blockCount = 16
+	)
+
+	require := require.New(t)
+
+	sourceCluster := redisstub.NewCluster(2, true)
+	defer sourceCluster.Close()
+	templateCluster := redisstub.NewCluster(3, true)
+	defer templateCluster.Close()
+	targetCluster := redisstub.NewCluster(4, true)
+	defer targetCluster.Close()
+
+	var contents [][]byte
+	source, err := Deduped("source", blockSize, ardb.DefaultLBACacheLimit, sourceCluster, nil)
+	require.NoError(err)
+	for index := int64(0); index < blockCount; index++ {
+		content := make([]byte, blockSize)
+		content[0] = byte(index) + 1
+		contents = append(contents, content)
+		require.NoError(source.SetBlock(index, content))
+	}
+	require.NoError(source.Flush())
+	source.Close()
+
+	// move half of the content from the source cluster to the template cluster
+	for index := 0; index < blockCount; index += 2 {
+		hash := zerodisk.HashBytes(contents[index])
+		require.NoError(ardb.Error(sourceCluster.DoFor(int64(hash[0]),
+			ardb.Command(command.Delete, hash.Bytes()))))
+		require.NoError(ardb.Error(templateCluster.DoFor(int64(hash[0]),
+			ardb.Command(command.Set, hash.Bytes(), contents[index]))))
+	}
+
+	err = copyDeduped("source", "target", blockSize, blockSize, sourceCluster, templateCluster, targetCluster)
+	require.NoError(err)
+
+	for _, content := range contents {
+		testDedupContentExists(t, targetCluster, content)
+	}
+}
+
 func init() {
 	log.SetLevel(log.DebugLevel)
 }
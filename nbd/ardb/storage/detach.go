@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+)
+
+// DetachConfig defines the configuration used to detach a vdisk from its template.
+type DetachConfig struct {
+	// VdiskID of the vdisk to detach
+	VdiskID string
+	// ConfigSource of the vdisk, which has to implement config.SourceWriter,
+	// as the template configuration of the vdisk is cleared through it
+	ConfigSource config.Source
+	// Rate defines the maximum amount of content blocks
+	// to check per second, a rate of 0 means unlimited.
+	Rate int64
+	// JobCount defines the amount of jobs (goroutines) fetching content in parallel,
+	// by default it equals the amount of CPUs available.
+	JobCount int
+}
+
+// DetachVdisk detaches a vdisk from its template,
+// such that its template cluster can be retired.
+// All content referenced by the vdisk which isn't available
+// in the primary cluster yet, is copied from the template cluster first.
+// Only once all content is available in the primary cluster,
+// the template storage cluster and vdisk are cleared from the vdisk's configuration.
+//
+// A vdisk can't be detached while it is in use (owned by an nbdserver),
+// nor if some of its content isn't available in the template cluster either.
+func DetachVdisk(ctx context.Context, cfg DetachConfig) (*WarmResult, error) {
+	if cfg.ConfigSource == nil {
+		return nil, config.ErrNilSource
+	}
+	if _, ok := cfg.ConfigSource.(config.SourceWriter); !ok {
+		return nil, errors.Wrapf(config.ErrSourceReadOnly,
+			"can't detach vdisk %s using a %s config source", cfg.VdiskID, cfg.ConfigSource.Type())
+	}
+
+	// read the configs of the vdisk
+	staticConfig, err := config.ReadVdiskStaticConfig(cfg.ConfigSource, cfg.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+	nbdConfig, err := config.ReadVdiskNBDConfig(cfg.ConfigSource, cfg.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+	if nbdConfig.TemplateStorageClusterID == "" {
+		return nil, errors.Wrapf(ErrVdiskNotAttached,
+			"vdisk %s has no template cluster configured", cfg.VdiskID)
+	}
+
+	// create the primary cluster
+	clusterConfig, err := config.ReadStorageClusterConfig(cfg.ConfigSource, nbdConfig.StorageClusterID)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := ardb.NewCluster(*clusterConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkVdiskNotInUse(cfg.VdiskID, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// copy all content missing in the primary cluster,
+	// in case the vdisk type supports templates at all
+	result := new(WarmResult)
+	if staticConfig.Type.TemplateSupport() {
+		templateClusterConfig, err := config.ReadStorageClusterConfig(
+			cfg.ConfigSource, nbdConfig.TemplateStorageClusterID)
+		if err != nil {
+			return nil, err
+		}
+		templateCluster, err := ardb.NewCluster(*templateClusterConfig, nil)
+		if err != nil {
+			return nil, err
+		}
+		lineage, err := LoadVdiskLineage(cfg.VdiskID, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = WarmVdisk(ctx, WarmConfig{
+			VdiskID:         cfg.VdiskID,
+			TemplateVdiskID: staticConfig.TemplateVdiskID,
+			Type:            staticConfig.Type,
+			Lineage:         lineage,
+			Rate:            cfg.Rate,
+			JobCount:        cfg.JobCount,
+		}, cluster, templateCluster)
+		if err != nil {
+			return nil, err
+		}
+		if result.Missing > 0 {
+			return nil, errors.Newf(
+				"can't detach vdisk %s, as %d of its content blocks aren't available in the primary nor template cluster",
+				cfg.VdiskID, result.Missing)
+		}
+	}
+
+	// the vdisk might have been mounted in the meantime
+	err = checkVdiskNotInUse(cfg.VdiskID, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// clear the template configuration of the vdisk,
+	// the NBD config first, as it defines whether the template cluster is used at all
+	nbdConfig.TemplateStorageClusterID = ""
+	err = config.WriteVdiskNBDConfig(cfg.ConfigSource, cfg.VdiskID, *nbdConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't clear template cluster of vdisk %s", cfg.VdiskID)
+	}
+	if staticConfig.TemplateVdiskID != "" {
+		staticConfig.TemplateVdiskID = ""
+		err = config.WriteVdiskStaticConfig(cfg.ConfigSource, cfg.VdiskID, *staticConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't clear template vdisk of vdisk %s", cfg.VdiskID)
+		}
+	}
+
+	log.Infof("detached vdisk %s from its template", cfg.VdiskID)
+	return result, nil
+}
+
+// checkVdiskNotInUse returns ErrVdiskInUse
+// in case the given vdisk is owned by an nbdserver, which didn't release it.
+func checkVdiskNotInUse(vdiskID string, cluster ardb.StorageCluster) error {
+	ownership, err := LoadVdiskOwnership(vdiskID, cluster)
+	if err != nil {
+		return err
+	}
+	if ownership.Owner != "" && !ownership.Released {
+		return errors.Wrapf(ErrVdiskInUse,
+			"vdisk %s is in use by nbdserver %q", vdiskID, ownership.Owner)
+	}
+	return nil
+}
+
+var (
+	// ErrVdiskInUse is an error returned
+	// when a vdisk is modified in a way that requires it not to be used (by an nbdserver).
+	ErrVdiskInUse = errors.New("vdisk is in use")
+
+	// ErrVdiskNotAttached is an error returned
+	// when a vdisk is detached from a template it isn't attached to.
+	ErrVdiskNotAttached = errors.New("vdisk isn't attached to a template")
+)
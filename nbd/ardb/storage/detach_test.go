@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestDetachVdisk(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	require := require.New(t)
+
+	primary := redisstub.NewMemoryRedisSlice(2)
+	defer primary.Close()
+	primaryConfig := primary.StorageClusterConfig()
+	template := redisstub.NewMemoryRedisSlice(2)
+	defer template.Close()
+	templateConfig := template.StorageClusterConfig()
+
+	source := config.NewStubSource()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize:       blockSize,
+		Size:            1,
+		Type:            config.VdiskTypeBoot,
+		TemplateVdiskID: "template",
+	})
+	source.SetPrimaryStorageCluster(vdiskID, "primary", &primaryConfig)
+	source.SetTemplateStorageCluster(vdiskID, "template", &templateConfig)
+
+	cluster, err := ardb.NewCluster(primaryConfig, nil)
+	require.NoError(err)
+	templateCluster, err := ardb.NewCluster(templateConfig, nil)
+	require.NoError(err)
+
+	storage, err := Deduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	require.NoError(err)
+	defer storage.Close()
+	contents := [][]byte{newWarmTestContent(blockSize, 1), newWarmTestContent(blockSize, 2)}
+	for index, content := range contents {
+		require.NoError(storage.SetBlock(int64(index), content))
+	}
+	require.NoError(storage.Flush())
+
+	// the content of block 1 is only available in the template cluster
+	hash := zerodisk.HashBytes(contents[1])
+	require.NoError(ardb.Error(cluster.DoFor(int64(hash[0]),
+		ardb.Command(command.Delete, hash.Bytes()))))
+
+	detach := func() (*WarmResult, error) {
+		return DetachVdisk(context.Background(), DetachConfig{VdiskID: vdiskID, ConfigSource: source})
+	}
+
+	// the content isn't available anywhere, so the vdisk can't be detached
+	_, err = detach()
+	require.Error(err)
+
+	// a vdisk in use can't be detached
+	require.NoError(ardb.Error(templateCluster.DoFor(int64(hash[0]),
+		ardb.Command(command.Set, hash.Bytes(), contents[1]))))
+	require.NoError(ClaimVdisk(vdiskID, "server", cluster))
+	_, err = detach()
+	require.Equal(ErrVdiskInUse, errors.Cause(err))
+
+	// once released, it can be detached
+	require.NoError(ReleaseVdisk(vdiskID, "server", nil, cluster))
+	result, err := detach()
+	require.NoError(err)
+	require.Equal(WarmResult{Scanned: 2, Warmed: 1, Available: 1}, *result)
+
+	content, err := storage.GetBlock(1)
+	require.NoError(err)
+	require.Equal(contents[1], content)
+
+	// the template configuration has been cleared
+	staticConfig, err := config.ReadVdiskStaticConfig(source, vdiskID)
+	require.NoError(err)
+	require.Empty(staticConfig.TemplateVdiskID)
+	nbdConfig, err := config.ReadVdiskNBDConfig(source, vdiskID)
+	require.NoError(err)
+	require.Empty(nbdConfig.TemplateStorageClusterID)
+	require.Equal("primary", nbdConfig.StorageClusterID)
+
+	// a detached vdisk can't be detached again
+	_, err = detach()
+	require.Equal(ErrVdiskNotAttached, errors.Cause(err))
+}
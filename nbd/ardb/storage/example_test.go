@@ -122,6 +122,7 @@ func copyVdisk(vdiskIDA, vdiskIDB string, clusterA, clusterB ardb.StorageCluster
 			BlockSize: blockSize,
 		},
 		clusterA,
+		nil,
 		clusterB,
 	)
 	panicOnError(err)
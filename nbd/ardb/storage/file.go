@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"os"
+	"sync"
+
+	"github.com/zero-os/0-Disk/errors"
+)
+
+// NewFileStorage returns a BlockStorage implementation,
+// which stores all blocks in a sparse (raw) local file of the given size,
+// such that the file can be inspected (e.g. loop-mounted) afterwards.
+// The file is created if it doesn't exist yet, and any existing content is discarded,
+// such that blocks which were never written to remain holes in the file.
+func NewFileStorage(vdiskID, path string, blockSize, size int64) (BlockStorage, error) {
+	if blockSize <= 0 {
+		return nil, errors.Newf("invalid block size %d", blockSize)
+	}
+	if size <= 0 || size%blockSize != 0 {
+		return nil, errors.Newf(
+			"invalid size %d, has to be a multiple of the block size %d", size, blockSize)
+	}
+
+	// any existing content is discarded, as all blocks start out as holes
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't open file storage %s", path)
+	}
+	err = file.Truncate(size)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrapf(err, "couldn't truncate file storage %s", path)
+	}
+
+	return &fileStorage{
+		blockSize:  blockSize,
+		blockCount: size / blockSize,
+		vdiskID:    vdiskID,
+		file:       file,
+		written:    make(map[int64]struct{}),
+	}, nil
+}
+
+// fileStorage is a BlockStorage implementation,
+// that stores each block at its offset in a sparse local file,
+// only meant for offline inspection of a vdisk, no ARDB cluster is used.
+// It is safe for concurrent use.
+type fileStorage struct {
+	blockSize  int64
+	blockCount int64
+	vdiskID    string
+	file       *os.File
+	// indices of the blocks written by this storage,
+	// all other blocks are holes which don't have to be deleted
+	written map[int64]struct{}
+	mux     sync.Mutex
+}
+
+// SetBlock implements BlockStorage.SetBlock
+func (fs *fileStorage) SetBlock(blockIndex int64, content []byte) error {
+	err := fs.checkBlockIndex(blockIndex)
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) > fs.blockSize {
+		return errors.Newf(
+			"block %d is too big (%d > %d)", blockIndex, len(content), fs.blockSize)
+	}
+
+	// zero blocks are stored as deleted blocks,
+	// such that holes are kept where possible
+	if isZeroContent(content) {
+		return fs.DeleteBlock(blockIndex)
+	}
+
+	block := content
+	if int64(len(block)) < fs.blockSize {
+		block = make([]byte, fs.blockSize)
+		copy(block, content)
+	}
+
+	_, err = fs.file.WriteAt(block, blockIndex*fs.blockSize)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't write block %d", blockIndex)
+	}
+
+	fs.mux.Lock()
+	fs.written[blockIndex] = struct{}{}
+	fs.mux.Unlock()
+	return nil
+}
+
+// GetBlock implements BlockStorage.GetBlock
+func (fs *fileStorage) GetBlock(blockIndex int64) ([]byte, error) {
+	err := fs.checkBlockIndex(blockIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	content := make([]byte, fs.blockSize)
+	_, err = fs.file.ReadAt(content, blockIndex*fs.blockSize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read block %d", blockIndex)
+	}
+	if isZeroContent(content) {
+		return nil, nil
+	}
+	return content, nil
+}
+
+// DeleteBlock implements BlockStorage.DeleteBlock
+func (fs *fileStorage) DeleteBlock(blockIndex int64) error {
+	err := fs.checkBlockIndex(blockIndex)
+	if err != nil {
+		return err
+	}
+
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	// a block which was never written is a hole,
+	// and thus already deleted
+	if _, ok := fs.written[blockIndex]; !ok {
+		return nil
+	}
+
+	_, err = fs.file.WriteAt(make([]byte, fs.blockSize), blockIndex*fs.blockSize)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't delete block %d", blockIndex)
+	}
+	delete(fs.written, blockIndex)
+	return nil
+}
+
+// Flush implements BlockStorage.Flush
+func (fs *fileStorage) Flush() error {
+	return fs.file.Sync()
+}
+
+// Close implements BlockStorage.Close
+func (fs *fileStorage) Close() error {
+	return fs.file.Close()
+}
+
+// checkBlockIndex returns an error if the given block index is out of range
+func (fs *fileStorage) checkBlockIndex(blockIndex int64) error {
+	if blockIndex < 0 || blockIndex >= fs.blockCount {
+		return errors.Newf(
+			"block index %d out of range [0, %d) for vdisk %s",
+			blockIndex, fs.blockCount, fs.vdiskID)
+	}
+	return nil
+}
+
+// isZeroContent detects if a given content buffer is completely filled with 0s
+func isZeroContent(content []byte) bool {
+	for _, c := range content {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
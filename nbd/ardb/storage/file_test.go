@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 8
+	)
+
+	blockStorage, path := newTestFileStorage(t, vdiskID, blockSize, blockSize*4)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	testBlockStorage(t, blockStorage)
+}
+
+func TestFileStorageForceFlush(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 8
+	)
+
+	blockStorage, path := newTestFileStorage(t, vdiskID, blockSize, blockSize*4)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	testBlockStorageForceFlush(t, blockStorage)
+}
+
+func TestFileStorageContent(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 4
+	)
+
+	blockStorage, path := newTestFileStorage(t, vdiskID, blockSize, blockSize*4)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	require.NoError(t, blockStorage.SetBlock(1, []byte{1, 2, 3, 4}))
+	require.NoError(t, blockStorage.SetBlock(3, []byte{5, 6}))
+	require.NoError(t, blockStorage.SetBlock(2, []byte{7, 8, 9, 10}))
+	require.NoError(t, blockStorage.DeleteBlock(2))
+
+	// out of range blocks are refused
+	assert.Error(t, blockStorage.SetBlock(4, []byte{1}))
+	assert.Error(t, blockStorage.SetBlock(-1, []byte{1}))
+	_, err := blockStorage.GetBlock(4)
+	assert.Error(t, err)
+	// as are blocks which are too big
+	assert.Error(t, blockStorage.SetBlock(0, []byte{1, 2, 3, 4, 5}))
+
+	require.NoError(t, blockStorage.Flush())
+	require.NoError(t, blockStorage.Close())
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0, 0, 0, 0,
+		1, 2, 3, 4,
+		0, 0, 0, 0,
+		5, 6, 0, 0,
+	}, content)
+}
+
+// the existing content of a file is discarded,
+// as the blocks which aren't written to are expected to be holes
+func TestFileStorageExistingFile(t *testing.T) {
+	const blockSize = 4
+
+	dir, err := ioutil.TempDir("", "filestorage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vdisk.img")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0644))
+
+	blockStorage, err := NewFileStorage("a", path, blockSize, blockSize*2)
+	require.NoError(t, err)
+	require.NoError(t, blockStorage.SetBlock(1, []byte{11, 12}))
+	content, err := blockStorage.GetBlock(0)
+	require.NoError(t, err)
+	assert.Nil(t, content)
+	require.NoError(t, blockStorage.Close())
+
+	content, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 11, 12, 0, 0}, content)
+}
+
+func TestNewFileStorageInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestorage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vdisk.img")
+
+	_, err = NewFileStorage("a", path, 0, 8)
+	assert.Error(t, err)
+	_, err = NewFileStorage("a", path, 8, 0)
+	assert.Error(t, err)
+	_, err = NewFileStorage("a", path, 8, 12)
+	assert.Error(t, err)
+}
+
+func newTestFileStorage(t *testing.T, vdiskID string, blockSize, size int64) (BlockStorage, string) {
+	dir, err := ioutil.TempDir("", "filestorage")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "vdisk.img")
+
+	blockStorage, err := NewFileStorage(vdiskID, path, blockSize, size)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return blockStorage, path
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+)
+
+// VdiskOwnership defines which nbdserver owns (serves) a vdisk,
+// and is used to hand off a vdisk from one nbdserver to another,
+// without the guest (using that vdisk) noticing.
+type VdiskOwnership struct {
+	// ID of the nbdserver which owns the vdisk,
+	// empty in case the vdisk was never claimed
+	Owner string
+	// true in case the owner has released the vdisk,
+	// meaning it flushed all its data and stopped serving it
+	Released bool
+	// the (LBA sector) indices which were cached by the owner
+	// at the moment it released the vdisk
+	CachedIndices []int64
+}
+
+// LoadVdiskOwnership loads a given vdisk's ownership from the given ARDB storage cluster.
+func LoadVdiskOwnership(vdiskID string, cluster ardb.StorageCluster) (VdiskOwnership, error) {
+	var ownership VdiskOwnership
+	if cluster == nil {
+		return ownership, ErrClusterNotDefined
+	}
+
+	key := vdiskOwnershipKey(vdiskID)
+	reply, err := cluster.Do(ardb.Command(command.HashGetAll, key))
+	if err != nil {
+		return ownership, err
+	}
+	fields, err := ardb.OptStrings(reply, nil)
+	if err != nil {
+		return ownership, err
+	}
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch value := fields[i+1]; fields[i] {
+		case vdiskOwnershipOwnerField:
+			ownership.Owner = value
+		case vdiskOwnershipReleasedField:
+			ownership.Released = value == "1"
+		case vdiskOwnershipCachedIndicesField:
+			ownership.CachedIndices = deserializeInt64s([]byte(value))
+		}
+	}
+
+	return ownership, nil
+}
+
+// ClaimVdisk claims the ownership of a vdisk for the given (nbd) server,
+// overwriting any ownership stored previously.
+// The previous ownership is overwritten atomically,
+// such that the vdisk is never seen as released by the new owner.
+func ClaimVdisk(vdiskID, serverID string, cluster ardb.StorageCluster) error {
+	key := vdiskOwnershipKey(vdiskID)
+	return ardb.Error(cluster.Do(ardb.Script(0, claimVdiskScriptSource, []string{key},
+		key, serverID,
+		vdiskOwnershipOwnerField, vdiskOwnershipReleasedField, vdiskOwnershipCachedIndicesField)))
+}
+
+// ReleaseVdisk releases the ownership of a vdisk for the given (nbd) server,
+// storing the indices of the cache entries it had, such that the next owner can pre-warm its cache.
+// An error is returned in case the vdisk isn't owned by the given server.
+// The ownership is checked and released atomically,
+// such that a vdisk claimed in the meantime by another server is never released.
+func ReleaseVdisk(vdiskID, serverID string, cachedIndices []int64, cluster ardb.StorageCluster) error {
+	key := vdiskOwnershipKey(vdiskID)
+	owner, err := ardb.OptString(cluster.Do(ardb.Script(0, releaseVdiskScriptSource, []string{key},
+		key, serverID, serializeInt64s(cachedIndices),
+		vdiskOwnershipOwnerField, vdiskOwnershipReleasedField, vdiskOwnershipCachedIndicesField)))
+	if err != nil {
+		return err
+	}
+	if owner != serverID {
+		return errors.Wrapf(ErrNotOwner,
+			"vdisk %s is owned by %q, not %q", vdiskID, owner, serverID)
+	}
+	return nil
+}
+
+// WaitForVdiskRelease waits until the given vdisk is released,
+// by the server which owns it, if it isn't owned by the given (nbd) server itself.
+// ErrVdiskNotReleased is returned in case the owner
+// didn't release it before the given timeout.
+func WaitForVdiskRelease(ctx context.Context, vdiskID, serverID string, timeout time.Duration, cluster ardb.StorageCluster) (VdiskOwnership, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(vdiskReleasePollInterval)
+	defer ticker.Stop()
+
+	for {
+		ownership, err := LoadVdiskOwnership(vdiskID, cluster)
+		if err != nil {
+			return ownership, err
+		}
+		if ownership.Owner == "" || ownership.Owner == serverID || ownership.Released {
+			return ownership, nil
+		}
+
+		log.Debugf("waiting for vdisk %s to be released by %q", vdiskID, ownership.Owner)
+		select {
+		case <-ctx.Done():
+			return ownership, errors.Wrapf(ErrVdiskNotReleased,
+				"vdisk %s is still owned by %q after %v", vdiskID, ownership.Owner, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// vdiskOwnershipKey returns the key of the ARDB hashmap,
+// which contains the ownership info stored for a vdisk.
+func vdiskOwnershipKey(vdiskID string) string {
+	return vdiskOwnershipKeyPrefix + vdiskID
+}
+
+// serializeInt64s serializes a slice of int64s into a compact binary form.
+func serializeInt64s(s []int64) []byte {
+	buf := make([]byte, len(s)*binary.MaxVarintLen64)
+	var n int
+	for _, i := range s {
+		n += binary.PutVarint(buf[n:], i)
+	}
+	return buf[:n]
+}
+
+// deserializeInt64s deserializes a slice of int64s,
+// previously serialized using serializeInt64s.
+func deserializeInt64s(buf []byte) []int64 {
+	var s []int64
+	for len(buf) > 0 {
+		i, n := binary.Varint(buf)
+		if n <= 0 {
+			break
+		}
+		s = append(s, i)
+		buf = buf[n:]
+	}
+	return s
+}
+
+var (
+	// ErrNotOwner is an error returned
+	// when a server tries to release a vdisk it doesn't own.
+	ErrNotOwner = errors.New("vdisk not owned by server")
+	// ErrVdiskNotReleased is an error returned
+	// when a vdisk wasn't released by its owner in time.
+	ErrVdiskNotReleased = errors.New("vdisk not released by its owner")
+)
+
+const (
+	vdiskReleasePollInterval = time.Millisecond * 100
+)
+
+// claimVdiskScriptSource claims the ownership of a vdisk for the given server,
+// deleting any ownership info stored by a previous owner.
+const claimVdiskScriptSource = `
+local key = ARGV[1]
+local serverID = ARGV[2]
+local ownerField = ARGV[3]
+local releasedField = ARGV[4]
+local cachedIndicesField = ARGV[5]
+
+redis.call("HDEL", key, releasedField, cachedIndicesField)
+return redis.call("HSET", key, ownerField, serverID)
+`
+
+// releaseVdiskScriptSource releases the ownership of a vdisk,
+// only if it is owned by the given server, returning the (original) owner.
+const releaseVdiskScriptSource = `
+local key = ARGV[1]
+local serverID = ARGV[2]
+local cachedIndices = ARGV[3]
+local ownerField = ARGV[4]
+local releasedField = ARGV[5]
+local cachedIndicesField = ARGV[6]
+
+local owner = redis.call("HGET", key, ownerField)
+if not owner then
+	return ""
+end
+if owner ~= serverID then
+	return owner
+end
+
+redis.call("HSET", key, releasedField, "1")
+redis.call("HSET", key, cachedIndicesField, cachedIndices)
+return owner
+`
+
+const (
+	vdiskOwnershipKeyPrefix          = "owner:"
+	vdiskOwnershipOwnerField         = "owner"
+	vdiskOwnershipReleasedField      = "released"
+	vdiskOwnershipCachedIndicesField = "cache"
+)
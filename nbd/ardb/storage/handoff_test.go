@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestVdiskHandoff(t *testing.T) {
+	const vdiskID = "a"
+
+	require := require.New(t)
+
+	cluster := redisstub.NewUniCluster(true)
+	require.NotNil(cluster)
+	defer cluster.Close()
+
+	// a vdisk which was never claimed, has no owner
+	ownership, err := LoadVdiskOwnership(vdiskID, cluster)
+	require.NoError(err)
+	require.Empty(ownership.Owner)
+
+	require.NoError(ClaimVdisk(vdiskID, "source", cluster))
+	ownership, err = LoadVdiskOwnership(vdiskID, cluster)
+	require.NoError(err)
+	require.Equal("source", ownership.Owner)
+	require.False(ownership.Released)
+
+	// only the owner can release a vdisk
+	err = ReleaseVdisk(vdiskID, "target", nil, cluster)
+	require.Equal(ErrNotOwner, errors.Cause(err))
+
+	// a vdisk which isn't released yet, can only be waited on until the timeout
+	ownership, err = WaitForVdiskRelease(context.Background(), vdiskID, "target", time.Millisecond*200, cluster)
+	require.Equal(ErrVdiskNotReleased, errors.Cause(err))
+	require.False(ownership.Released)
+
+	// a vdisk owned by the server itself, doesn't have to be waited on
+	ownership, err = WaitForVdiskRelease(context.Background(), vdiskID, "source", time.Millisecond*200, cluster)
+	require.NoError(err)
+	require.Equal("source", ownership.Owner)
+
+	// release the vdisk in the background, while we are waiting for it
+	indices := []int64{0, 1, 42, 1 << 40}
+	go func() {
+		time.Sleep(time.Millisecond * 200)
+		ReleaseVdisk(vdiskID, "source", indices, cluster)
+	}()
+	ownership, err = WaitForVdiskRelease(context.Background(), vdiskID, "target", time.Second*5, cluster)
+	require.NoError(err)
+	require.True(ownership.Released)
+	require.Equal("source", ownership.Owner)
+	require.Equal(indices, ownership.CachedIndices)
+
+	// claiming a vdisk again resets the ownership
+	require.NoError(ClaimVdisk(vdiskID, "target", cluster))
+	ownership, err = LoadVdiskOwnership(vdiskID, cluster)
+	require.NoError(err)
+	require.Equal(VdiskOwnership{Owner: "target"}, ownership)
+
+	// the previous owner can no longer release the vdisk, once it was claimed by another server
+	err = ReleaseVdisk(vdiskID, "source", indices, cluster)
+	require.Equal(ErrNotOwner, errors.Cause(err))
+	ownership, err = LoadVdiskOwnership(vdiskID, cluster)
+	require.NoError(err)
+	require.Equal(VdiskOwnership{Owner: "target"}, ownership)
+
+	// a vdisk which was never claimed, can't be released
+	err = ReleaseVdisk("b", "source", nil, cluster)
+	require.Equal(ErrNotOwner, errors.Cause(err))
+}
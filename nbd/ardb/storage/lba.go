@@ -7,13 +7,18 @@ import (
 )
 
 // newLBASectorStorage creates a new LBA sector storage
-// which writes/reads to/from an ARDB Cluster
-func newLBASectorStorage(vdiskID string, cluster ardb.StorageCluster) *lbaSectorStorage {
-	return &lbaSectorStorage{
+// which writes/reads to/from an ARDB Cluster.
+// The lineage is optional, and only defined for cloned vdisks.
+func newLBASectorStorage(vdiskID string, lineage []string, cluster ardb.StorageCluster) *lbaSectorStorage {
+	s := &lbaSectorStorage{
 		cluster: cluster,
 		vdiskID: vdiskID,
 		key:     lbaStorageKey(vdiskID),
 	}
+	for _, parentID := range lineage {
+		s.parentKeys = append(s.parentKeys, lbaStorageKey(parentID))
+	}
+	return s
 }
 
 // lbaSectorStorage is the sector storage implementation,
@@ -21,12 +26,21 @@ func newLBASectorStorage(vdiskID string, cluster ardb.StorageCluster) *lbaSector
 type lbaSectorStorage struct {
 	cluster      ardb.StorageCluster
 	vdiskID, key string
+	// keys of the parent vdisks, nearest parent first,
+	// only defined in case the vdisk is a clone
+	parentKeys []string
 }
 
 // GetSector implements sectorStorage.GetSector
 func (s *lbaSectorStorage) GetSector(index int64) (*lba.Sector, error) {
 	reply, err := s.cluster.DoFor(index, ardb.Command(command.HashGet, s.key, index))
-	if reply == nil {
+	// a sector which isn't available in a clone,
+	// is read from the nearest parent which does have it,
+	// it will only be copied up once the sector is modified.
+	for i := 0; reply == nil && err == nil && i < len(s.parentKeys); i++ {
+		reply, err = s.cluster.DoFor(index, ardb.Command(command.HashGet, s.parentKeys[i], index))
+	}
+	if reply == nil && err == nil {
 		return lba.NewSector(), nil
 	}
 
@@ -42,7 +56,13 @@ func (s *lbaSectorStorage) GetSector(index int64) (*lba.Sector, error) {
 func (s *lbaSectorStorage) SetSector(index int64, sector *lba.Sector) error {
 	var cmd *ardb.StorageCommand
 	if data := sector.Bytes(); data == nil {
-		cmd = ardb.Command(command.HashDelete, s.key, index)
+		if len(s.parentKeys) > 0 {
+			// a clone stores a nil sector explicitly,
+			// such that it masks the sector of its parent(s)
+			cmd = ardb.Command(command.HashSet, s.key, index, make([]byte, lba.BytesPerSector))
+		} else {
+			cmd = ardb.Command(command.HashDelete, s.key, index)
+		}
 	} else {
 		cmd = ardb.Command(command.HashSet, s.key, index, data)
 	}
@@ -74,6 +74,29 @@ func (bucket *sectorBucket) GetHash(index int64) (zerodisk.Hash, error) {
 	return hash, nil
 }
 
+// CachedSectorIndices returns the indices of all sectors cached in this bucket,
+// ordered from most to least recently used.
+func (bucket *sectorBucket) CachedSectorIndices() []int64 {
+	bucket.mux.Lock()
+	defer bucket.mux.Unlock()
+
+	indices := make([]int64, 0, bucket.evictList.Len())
+	for elem := bucket.evictList.Front(); elem != nil; elem = elem.Next() {
+		indices = append(indices, elem.Value.(*cacheEntry).sectorIndex)
+	}
+	return indices
+}
+
+// FetchSector ensures the sector at the given (sector) index is cached,
+// fetching it from the persistent storage if needed.
+func (bucket *sectorBucket) FetchSector(index int64) error {
+	bucket.mux.Lock()
+	defer bucket.mux.Unlock()
+
+	_, err := bucket.getSector(index)
+	return err
+}
+
 // Flush all sectors from this bucket to persistent storage,
 // after which its bucket will be empty.
 func (bucket *sectorBucket) Flush() error {
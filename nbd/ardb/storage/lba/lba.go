@@ -96,6 +96,48 @@ func (lba *LBA) Flush() error {
 	return errs.AsError()
 }
 
+// CachedSectorIndices returns the indices of all sectors currently cached.
+// NOTE: the cache is cleared when flushing,
+// so this should be called prior to flushing, if it is required.
+func (lba *LBA) CachedSectorIndices() []int64 {
+	var indices []int64
+	for _, bucket := range lba.buckets {
+		indices = append(indices, bucket.CachedSectorIndices()...)
+	}
+	return indices
+}
+
+// Prefetch fetches the sectors at the given (sector) indices
+// from the external metadataserver, and stores them in the cache,
+// such that future lookups of blocks within those sectors won't have to wait on I/O.
+// Sectors which are already cached are left untouched.
+func (lba *LBA) Prefetch(sectorIndices []int64) error {
+	var wg sync.WaitGroup
+	errs := errors.NewErrorSlice()
+
+	// group sectors by bucket,
+	// such that each bucket can be filled in parallel
+	groups := make(map[int][]int64)
+	for _, sectorIndex := range sectorIndices {
+		index := bucketIndex(sectorIndex*NumberOfRecordsPerLBASector, lba.bucketCount)
+		groups[index] = append(groups[index], sectorIndex)
+	}
+
+	for index, group := range groups {
+		wg.Add(1)
+		bucket, group := lba.buckets[index], group
+		go func() {
+			defer wg.Done()
+			for _, sectorIndex := range group {
+				errs.Add(bucket.FetchSector(sectorIndex))
+			}
+		}()
+	}
+
+	wg.Wait()
+	return errs.AsError()
+}
+
 func (lba *LBA) getBucket(blockIndex int64) *sectorBucket {
 	bucketIndex := bucketIndex(blockIndex, lba.bucketCount)
 	return lba.buckets[bucketIndex]
@@ -157,6 +157,43 @@ func testLBAWithEmptyStorage(t *testing.T, sectors, buckets int64, lba *LBA) {
 	}
 }
 
+func TestLBAPrefetch(t *testing.T) {
+	const (
+		bucketCount   = 4
+		lbaCacheLimit = MinimumBucketSizeLimit * bucketCount
+	)
+
+	require := require.New(t)
+
+	storage := newStubSectorStorage()
+	lba, err := NewLBA(lbaCacheLimit, storage)
+	require.NoError(err)
+
+	// store some hashes, and flush them, which clears the cache
+	hash := zerodisk.HashBytes([]byte("foo"))
+	for _, blockIndex := range []int64{0, NumberOfRecordsPerLBASector * 3, NumberOfRecordsPerLBASector * 7} {
+		require.NoError(lba.Set(blockIndex, hash))
+	}
+	require.Len(lba.CachedSectorIndices(), 3)
+	require.NoError(lba.Flush())
+	require.Empty(lba.CachedSectorIndices())
+
+	// prefetch the sectors, filling the cache once again
+	require.NoError(lba.Prefetch([]int64{0, 3, 7}))
+	indices := lba.CachedSectorIndices()
+	require.Len(indices, 3)
+	require.Subset(indices, []int64{0, 3, 7})
+
+	// prefetching an already cached sector is a no-op
+	require.NoError(lba.Prefetch([]int64{3}))
+	require.Len(lba.CachedSectorIndices(), 3)
+
+	// the prefetched content should be available
+	h, err := lba.Get(NumberOfRecordsPerLBASector * 7)
+	require.NoError(err)
+	require.Equal(hash, h)
+}
+
 func TestBucketIndex_1_Bucket(t *testing.T) {
 	testBucketIndex(t, 1)
 }
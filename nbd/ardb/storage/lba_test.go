@@ -23,7 +23,7 @@ func TestLBAWithEmptyLBASectorStorage(t *testing.T) {
 	require.NotNil(cluster)
 	defer cluster.Close()
 
-	storage := newLBASectorStorage("foo", cluster)
+	storage := newLBASectorStorage("foo", nil, cluster)
 	require.NotNil(storage)
 
 	lba, err := lba.NewLBA(lbaCacheLimit, storage)
@@ -11,10 +11,17 @@ import (
 
 // NonDeduped returns a non deduped BlockStorage
 func NonDeduped(vdiskID, templateVdiskID string, blockSize int64, cluster, templateCluster ardb.StorageCluster) (BlockStorage, error) {
+	return newNonDedupedStorage(vdiskID, templateVdiskID, nil, blockSize, cluster, templateCluster)
+}
+
+// newNonDedupedStorage creates a non deduped BlockStorage,
+// which reads through to the given lineage, in case the vdisk is a clone.
+func newNonDedupedStorage(vdiskID, templateVdiskID string, lineage []string, blockSize int64, cluster, templateCluster ardb.StorageCluster) (BlockStorage, error) {
 	// create the nondeduped storage with the info we know for sure
 	nondeduped := &nonDedupedStorage{
 		blockSize:       blockSize,
 		storageKey:      nonDedupedStorageKey(vdiskID),
+		snapshotsKey:    vdiskSnapshotsKey(vdiskID),
 		vdiskID:         vdiskID,
 		templateVdiskID: templateVdiskID,
 		cluster:         cluster,
@@ -32,6 +39,16 @@ func NonDeduped(vdiskID, templateVdiskID string, blockSize int64, cluster, templ
 		nondeduped.templateCluster = templateCluster
 	}
 
+	// clones read through to their parent(s)
+	if len(lineage) > 0 {
+		for _, parentID := range lineage {
+			nondeduped.parentStorageKeys = append(
+				nondeduped.parentStorageKeys, nonDedupedStorageKey(parentID))
+		}
+		nondeduped.getOwnContent = nondeduped.getContent
+		nondeduped.getContent = nondeduped.getCloneContent
+	}
+
 	return nondeduped, nil
 }
 
@@ -41,12 +58,15 @@ func NonDeduped(vdiskID, templateVdiskID string, blockSize int64, cluster, templ
 type nonDedupedStorage struct {
 	blockSize          int64                   // blocksize in bytes
 	storageKey         string                  // Storage Key based on vdiskID
+	snapshotsKey       string                  // Key of the set of snapshots of this vdisk
 	templateStorageKey string                  // Storage Key based on templateVdiskID
 	vdiskID            string                  // ID for the vdisk
 	templateVdiskID    string                  // used in case template is supposed (same value as vdiskID if not defined)
 	cluster            ardb.StorageCluster     // used to interact with the ARDB (StorageEngine) Cluster
 	templateCluster    ardb.StorageCluster     // used to interact with the ARDB (StorageEngine) Template Cluster
 	getContent         nondedupedContentGetter // getter depends on whether there is template support or not
+	parentStorageKeys  []string                // Storage Keys of the parent vdisks (nearest first), in case this is a clone
+	getOwnContent      nondedupedContentGetter // original getter, only used in case this is a clone
 }
 
 // used to provide different content getters based on the vdisk properties
@@ -55,18 +75,14 @@ type nondedupedContentGetter func(blockIndex int64) (content []byte, err error)
 
 // Set implements BlockStorage.Set
 func (ss *nonDedupedStorage) SetBlock(blockIndex int64, content []byte) error {
-	var cmd *ardb.StorageCommand
-
 	// don't store zero blocks,
 	// and delete existing ones if they already existed
 	if ss.isZeroContent(content) {
-		cmd = ardb.Command(command.HashDelete, ss.storageKey, blockIndex)
-	} else {
-		// content is not zero, so let's (over)write it
-		cmd = ardb.Command(command.HashSet, ss.storageKey, blockIndex, content)
+		return ss.DeleteBlock(blockIndex)
 	}
 
-	return ardb.Error(ss.cluster.DoFor(blockIndex, cmd))
+	// content is not zero, so let's (over)write it
+	return ss.writeBlock(blockIndex, content)
 }
 
 // Get implements BlockStorage.Get
@@ -77,9 +93,34 @@ func (ss *nonDedupedStorage) GetBlock(blockIndex int64) (content []byte, err err
 
 // Delete implements BlockStorage.Delete
 func (ss *nonDedupedStorage) DeleteBlock(blockIndex int64) error {
-	cmd := ardb.Command(command.HashDelete, ss.storageKey, blockIndex)
+	if len(ss.parentStorageKeys) > 0 {
+		// a clone stores an empty block explicitly,
+		// such that it masks the block of its parent(s)
+		return ss.writeBlock(blockIndex, []byte{})
+	}
 	// delete the block defined for the block index (if it previously existed at all)
-	return ardb.Error(ss.cluster.DoFor(blockIndex, cmd))
+	return ss.writeBlock(blockIndex, nil)
+}
+
+// writeBlock sets the given block, or deletes it in case the content is nil.
+// The original block is copied to all snapshots of this vdisk first,
+// for those snapshots that didn't copy that block yet (copy-on-write).
+// The snapshots are checked within the same script as the write itself,
+// such that copy-on-write applies as soon as a snapshot is registered.
+func (ss *nonDedupedStorage) writeBlock(blockIndex int64, content []byte) error {
+	op := "HSET"
+	if content == nil {
+		op = "HDEL"
+	}
+
+	keysAndArgs := make([]interface{}, 0, 5+len(ss.parentStorageKeys))
+	keysAndArgs = append(keysAndArgs, ss.storageKey, ss.snapshotsKey, blockIndex, op, content)
+	for _, key := range ss.parentStorageKeys {
+		keysAndArgs = append(keysAndArgs, key)
+	}
+
+	script := ardb.Script(0, writeNonDedupedBlockScriptSource, []string{ss.storageKey}, keysAndArgs...)
+	return ardb.Error(ss.cluster.DoFor(blockIndex, script))
 }
 
 // Flush implements BlockStorage.Flush
@@ -104,7 +145,7 @@ func (ss *nonDedupedStorage) getPrimaryOrTemplateContent(blockIndex int64) (cont
 		return // critical err, or content is found
 	}
 
-	cmd := ardb.Command(command.HashGet, ss.storageKey, blockIndex)
+	cmd := ardb.Command(command.HashGet, ss.templateStorageKey, blockIndex)
 	content, err = ardb.OptBytes(ss.templateCluster.DoFor(blockIndex, cmd))
 	if err != nil {
 		// this error is returned, in case the cluster is simply not defined,
@@ -141,6 +182,31 @@ func (ss *nonDedupedStorage) getPrimaryOrTemplateContent(blockIndex int64) (cont
 	return
 }
 
+// (*nonDedupedStorage).getContent in case storage is a clone
+func (ss *nonDedupedStorage) getCloneContent(blockIndex int64) (content []byte, err error) {
+	cmd := ardb.Command(command.HashGet, ss.storageKey, blockIndex)
+	content, err = ardb.OptBytes(ss.cluster.DoFor(blockIndex, cmd))
+	for i := 0; content == nil && err == nil && i < len(ss.parentStorageKeys); i++ {
+		cmd = ardb.Command(command.HashGet, ss.parentStorageKeys[i], blockIndex)
+		content, err = ardb.OptBytes(ss.cluster.DoFor(blockIndex, cmd))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		if ss.templateCluster == nil {
+			return nil, nil
+		}
+		// not available in the clone nor its parents,
+		// try the template storage
+		return ss.getOwnContent(blockIndex)
+	}
+	if len(content) == 0 {
+		return nil, nil // deleted block in clone
+	}
+	return content, nil
+}
+
 // isZeroContent detects if a given content buffer is completely filled with 0s
 func (ss *nonDedupedStorage) isZeroContent(content []byte) bool {
 	for _, c := range content {
@@ -238,7 +304,7 @@ func listNonDedupedBlockIndices(vdiskID string, cluster ardb.StorageCluster) ([]
 	resultCh := make(chan serverResult)
 
 	var serverCount int
-	action := ardb.Command(command.HashKeys, nonDedupedStorageKey(vdiskID))
+	action := ardb.Script(0, listNonDedupedBlockIndicesScriptSource, nil, nonDedupedStorageKey(vdiskID))
 	for server := range serverCh {
 		server := server
 		go func() {
@@ -725,3 +791,57 @@ redis.call("RESTORE", destination, 0, redis.call("DUMP", source))
 
 return redis.call("HLEN", destination)
 `
+
+// writeNonDedupedBlockScriptSource writes (or deletes) a non-deduped block,
+// copying the original block to all snapshots of the vdisk which don't have it yet.
+// Blocks not available in the vdisk itself, are looked up in the (optional) parent keys,
+// and are stored as empty blocks in the snapshots in case they can't be found at all.
+const writeNonDedupedBlockScriptSource = `
+local key = ARGV[1]
+local snapshots = ARGV[2]
+local index = ARGV[3]
+local op = ARGV[4]
+local content = ARGV[5]
+
+if redis.call("SCARD", snapshots) > 0 then
+	local original = redis.call("HGET", key, index)
+	local parent = 6
+	while not original and ARGV[parent] do
+		original = redis.call("HGET", ARGV[parent], index)
+		parent = parent + 1
+	end
+	if not original then
+		original = ""
+	end
+
+	local ids = redis.call("SMEMBERS", snapshots)
+	for i = 1, #ids do
+		local snapshotKey = key .. "@" .. ids[i]
+		if redis.call("HEXISTS", snapshotKey, index) == 0 then
+			redis.call("HSET", snapshotKey, index, original)
+		end
+	end
+end
+
+if op == "HDEL" then
+	return redis.call("HDEL", key, index)
+end
+return redis.call("HSET", key, index, content)
+`
+
+// listNonDedupedBlockIndicesScriptSource lists the indices of all blocks of a non-deduped vdisk,
+// skipping the empty blocks stored by clones and snapshots to mask deleted blocks.
+const listNonDedupedBlockIndicesScriptSource = `
+local key = ARGV[1]
+local keys = redis.call("HKEYS", key)
+
+local indices = {}
+for i = 1, #keys do
+	local block = redis.call("HGET", key, keys[i])
+	if block and #block > 0 then
+		indices[#indices+1] = tonumber(keys[i])
+	end
+end
+
+return indices
+`
@@ -225,6 +225,50 @@ func TestGetNondedupedTemplateContent(t *testing.T) {
 	testNondedupContentExists(t, clusterA, vdiskID, testBlockIndex, testContent)
 }
 
+// content is fetched from the template cluster,
+// using the identifier of the template vdisk, rather than the one of the vdisk itself
+func TestGetNondedupedTemplateContentTemplateVdiskID(t *testing.T) {
+	const (
+		vdiskID         = "a"
+		templateVdiskID = "template"
+	)
+
+	templateCluster := redisstub.NewUniCluster(false)
+	defer templateCluster.Close()
+	cluster := redisstub.NewUniCluster(false)
+	defer cluster.Close()
+
+	templateStorage, err := NonDeduped(templateVdiskID, "", 8, templateCluster, nil)
+	if err != nil {
+		t.Fatalf("template storage could not be created: %v", err)
+	}
+	storage, err := NonDeduped(vdiskID, templateVdiskID, 8, cluster, templateCluster)
+	if err != nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	testContent := []byte{4, 2}
+	var testBlockIndex int64 // 0
+
+	err = templateStorage.SetBlock(testBlockIndex, testContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := storage.GetBlock(testBlockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(testContent, content) != 0 {
+		t.Fatalf("content (%v) should equal the template content (%v)", content, testContent)
+	}
+
+	// wait until the Get method saves the content async
+	time.Sleep(time.Millisecond * 200)
+	testNondedupContentExists(t, cluster, vdiskID, testBlockIndex, testContent)
+	testNondedupContentDoesNotExist(t, templateCluster, vdiskID, testBlockIndex, testContent)
+}
+
 // test feature implemented for
 // https://github.com/zero-os/0-Disk/issues/369
 func TestNonDedupedStorageTemplateServerDown(t *testing.T) {
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+)
+
+// VdiskRegistryEntry is a vdisk as registered in the vdisk registry of a cluster.
+type VdiskRegistryEntry struct {
+	VdiskID string
+	// Type of the vdisk, 0 in case it is unknown,
+	// which is the case for vdisks registered by rebuilding the registry.
+	Type config.VdiskType
+	// Time the vdisk was registered, zero in case it is unknown,
+	// which is the case for vdisks registered by rebuilding the registry.
+	Created time.Time
+}
+
+// RegisterVdisk registers a vdisk in the vdisk registry of the given cluster,
+// such that it can be listed without having to scan the entire cluster.
+// Registering a vdisk which is already registered is a no-op,
+// such that its creation time is preserved.
+func RegisterVdisk(vdiskID string, t config.VdiskType, cluster ardb.StorageCluster) error {
+	if isInterfaceValueNil(cluster) {
+		return ErrClusterNotDefined
+	}
+	entry := VdiskRegistryEntry{VdiskID: vdiskID, Type: t, Created: time.Now()}
+	// the registry is stored on all servers,
+	// such that it remains available as long as one server is available
+	return doForAllServers(cluster, registerVdiskAction(vdiskID, encodeVdiskRegistryEntry(entry)))
+}
+
+// UnregisterVdisk removes a vdisk from the vdisk registry of the given cluster.
+func UnregisterVdisk(vdiskID string, cluster ardb.StorageCluster) error {
+	if isInterfaceValueNil(cluster) {
+		return ErrClusterNotDefined
+	}
+	return doForAllServers(cluster, ardb.Command(command.HashDelete, vdiskRegistryKey, vdiskID))
+}
+
+// ListVdiskRegistry lists all vdisks registered in the vdisk registry of the given cluster,
+// sorted by their identifiers.
+func ListVdiskRegistry(cluster ardb.StorageCluster) ([]VdiskRegistryEntry, error) {
+	if isInterfaceValueNil(cluster) {
+		return nil, ErrClusterNotDefined
+	}
+	// the reply contains the field (vdiskID) and value of each entry, one after another
+	values, err := ardb.OptStrings(cluster.Do(
+		ardb.Command(command.HashGetAll, vdiskRegistryKey)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]VdiskRegistryEntry, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		entry, err := decodeVdiskRegistryEntry(values[i], values[i+1])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].VdiskID < entries[j].VdiskID
+	})
+	return entries, nil
+}
+
+// RebuildVdiskRegistry rebuilds the vdisk registry of the given cluster,
+// by scanning the entire cluster for vdisks (see: ScanVdisks).
+// Vdisks which are already registered keep their type and creation time,
+// while registered vdisks which can no longer be found are removed from the registry.
+// Snapshots (see: SnapshotVdiskID) are never registered, as they are listed per vdisk.
+// The identifiers of all vdisks found are returned.
+// NOTE: this function is very slow, and puts a lot of pressure on the ARDB cluster.
+func RebuildVdiskRegistry(cluster ardb.StorageCluster) ([]string, error) {
+	ids, err := ScanVdisks(cluster, func(vdiskID string) bool {
+		return !strings.Contains(vdiskID, snapshotVdiskIDSeparator)
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ListVdiskRegistry(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		found[id] = struct{}{}
+	}
+
+	var cmds []ardb.StorageAction
+	for _, entry := range entries {
+		if _, ok := found[entry.VdiskID]; ok {
+			delete(found, entry.VdiskID)
+			continue
+		}
+		log.Infof("removing vdisk %s from the vdisk registry, as it no longer exists", entry.VdiskID)
+		cmds = append(cmds, ardb.Command(command.HashDelete, vdiskRegistryKey, entry.VdiskID))
+	}
+	for id := range found {
+		log.Infof("adding vdisk %s to the vdisk registry", id)
+		cmds = append(cmds, registerVdiskAction(id, encodeVdiskRegistryEntry(VdiskRegistryEntry{VdiskID: id})))
+	}
+	if len(cmds) == 0 {
+		return ids, nil
+	}
+
+	err = doForAllServers(cluster, ardb.Commands(cmds...))
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// registerVdiskAction creates the action which registers a vdisk
+// using the given encoded entry, only if it isn't registered yet.
+func registerVdiskAction(vdiskID, value string) ardb.StorageAction {
+	return ardb.Script(0, registerVdiskScriptSource,
+		[]string{vdiskRegistryKey}, vdiskRegistryKey, vdiskID, value)
+}
+
+// encodeVdiskRegistryEntry encodes an entry
+// as the "type:created" value stored in the vdisk registry.
+func encodeVdiskRegistryEntry(entry VdiskRegistryEntry) string {
+	var created int64
+	if !entry.Created.IsZero() {
+		created = entry.Created.Unix()
+	}
+	return fmt.Sprintf("%s:%d", entry.Type, created)
+}
+
+// decodeVdiskRegistryEntry decodes a "type:created" value stored in the vdisk registry.
+func decodeVdiskRegistryEntry(vdiskID, value string) (VdiskRegistryEntry, error) {
+	entry := VdiskRegistryEntry{VdiskID: vdiskID}
+
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return entry, errors.Newf("invalid vdisk registry entry %q for vdisk %s", value, vdiskID)
+	}
+	if parts[0] != "" {
+		err := entry.Type.SetString(parts[0])
+		if err != nil {
+			return entry, errors.Wrapf(err, "invalid vdisk registry entry for vdisk %s", vdiskID)
+		}
+	}
+	created, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return entry, errors.Wrapf(err, "invalid vdisk registry entry for vdisk %s", vdiskID)
+	}
+	if created != 0 {
+		entry.Created = time.Unix(created, 0)
+	}
+	return entry, nil
+}
+
+// vdiskRegistryKey is the key of the hashmap which stores the vdisk registry,
+// mapping the identifier of each registered vdisk to its "type:created" value.
+const vdiskRegistryKey = "vdisk:registry"
+
+const registerVdiskScriptSource = `
+local key = ARGV[1]
+local vdiskID = ARGV[2]
+local value = ARGV[3]
+
+if redis.call("HEXISTS", key, vdiskID) == 1 then
+	return 0
+end
+
+redis.call("HSET", key, vdiskID, value)
+return 1
+`
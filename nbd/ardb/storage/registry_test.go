@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestVdiskRegistry(t *testing.T) {
+	require := require.New(t)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	// an empty registry lists no vdisks
+	ids, err := ListVdisks(cluster, nil)
+	require.NoError(err)
+	require.Empty(ids)
+
+	start := time.Now().Add(-time.Second)
+	require.NoError(RegisterVdisk("b", config.VdiskTypeDB, cluster))
+	require.NoError(RegisterVdisk("a", config.VdiskTypeBoot, cluster))
+	require.NoError(RegisterVdisk("c", config.VdiskTypeCache, cluster))
+
+	entries, err := ListVdiskRegistry(cluster)
+	require.NoError(err)
+	require.Len(entries, 3)
+	for i, expected := range []VdiskRegistryEntry{
+		{VdiskID: "a", Type: config.VdiskTypeBoot},
+		{VdiskID: "b", Type: config.VdiskTypeDB},
+		{VdiskID: "c", Type: config.VdiskTypeCache},
+	} {
+		require.Equal(expected.VdiskID, entries[i].VdiskID)
+		require.Equal(expected.Type, entries[i].Type)
+		require.True(entries[i].Created.After(start))
+	}
+
+	// registering an already registered vdisk preserves its original entry
+	require.NoError(RegisterVdisk("a", config.VdiskTypeDB, cluster))
+	entries, err = ListVdiskRegistry(cluster)
+	require.NoError(err)
+	require.Len(entries, 3)
+	require.Equal(config.VdiskTypeBoot, entries[0].Type)
+
+	ids, err = ListVdisks(cluster, func(vdiskID string) bool { return vdiskID != "b" })
+	require.NoError(err)
+	require.Equal([]string{"a", "c"}, ids)
+
+	require.NoError(UnregisterVdisk("a", cluster))
+	ids, err = ListVdisks(cluster, nil)
+	require.NoError(err)
+	require.Equal([]string{"b", "c"}, ids)
+}
+
+func TestVdiskRegistryCopyAndDeleteVdisk(t *testing.T) {
+	require := require.New(t)
+
+	sourceCluster := redisstub.NewUniCluster(true)
+	defer sourceCluster.Close()
+	targetCluster := redisstub.NewUniCluster(true)
+	defer targetCluster.Close()
+
+	storage, err := NewBlockStorage(BlockStorageConfig{
+		VdiskID:   "a",
+		VdiskType: config.VdiskTypeBoot,
+		BlockSize: 512,
+	}, sourceCluster, nil)
+	require.NoError(err)
+	content := make([]byte, 512)
+	content[0] = 1
+	require.NoError(storage.SetBlock(0, content))
+	require.NoError(storage.Flush())
+	storage.Close()
+	require.NoError(RegisterVdisk("a", config.VdiskTypeBoot, sourceCluster))
+
+	// copying a vdisk registers the target vdisk in the target cluster
+	require.NoError(CopyVdisk(
+		CopyVdiskConfig{VdiskID: "a", Type: config.VdiskTypeBoot, BlockSize: 512},
+		CopyVdiskConfig{VdiskID: "b", Type: config.VdiskTypeBoot, BlockSize: 512},
+		sourceCluster, nil, targetCluster))
+	ids, err := ListVdisks(targetCluster, nil)
+	require.NoError(err)
+	require.Equal([]string{"b"}, ids)
+
+	// deleting a vdisk unregisters it
+	_, err = DeleteVdiskInCluster("a", config.VdiskTypeBoot, sourceCluster)
+	require.NoError(err)
+	ids, err = ListVdisks(sourceCluster, nil)
+	require.NoError(err)
+	require.Empty(ids)
+}
+
+func TestVdiskRegistryEntryEncoding(t *testing.T) {
+	require := require.New(t)
+
+	created := time.Unix(time.Now().Unix(), 0)
+	for _, entry := range []VdiskRegistryEntry{
+		{VdiskID: "a", Type: config.VdiskTypeBoot, Created: created},
+		{VdiskID: "b", Type: config.VdiskTypeTmp, Created: created},
+		{VdiskID: "c"}, // unknown type and creation time
+	} {
+		decoded, err := decodeVdiskRegistryEntry(entry.VdiskID, encodeVdiskRegistryEntry(entry))
+		require.NoError(err)
+		require.Equal(entry, decoded)
+	}
+
+	for _, value := range []string{"", "boot", "foo:0", "boot:bar"} {
+		_, err := decodeVdiskRegistryEntry("a", value)
+		require.Error(err, value)
+	}
+}
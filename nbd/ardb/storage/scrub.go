@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage/lba"
+)
+
+// ScrubConfig defines the configuration used to scrub a vdisk.
+type ScrubConfig struct {
+	// VdiskID of the (deduped) vdisk to scrub
+	VdiskID string
+	// Rate defines the maximum amount of content blocks
+	// to verify per second, a rate of 0 means unlimited.
+	Rate int64
+}
+
+// ScrubResult is the result of scrubbing a vdisk.
+type ScrubResult struct {
+	// amount of unique content blocks verified
+	Scanned int64
+	// amount of content blocks which were missing or didn't match their hash
+	Corrupted int64
+	// amount of corrupted content blocks which could be repaired
+	Repaired int64
+}
+
+// ScrubDedupedVdisk walks through the LBA of a deduped vdisk,
+// and verifies the content referenced by it, by rehashing that content.
+// Content which is missing or doesn't match its hash, is repaired
+// using the slave or template cluster, if any of them has a valid copy of it.
+// All corrupted content is broadcasted using the StatusDataCorrupted status.
+//
+// Only the LBA of the vdisk itself is walked, meaning that content
+// referenced only by the parent of a cloned vdisk, is scrubbed as part of that parent.
+func ScrubDedupedVdisk(ctx context.Context, cfg ScrubConfig, cluster, slaveCluster, templateCluster ardb.StorageCluster) (*ScrubResult, error) {
+	if isInterfaceValueNil(cluster) {
+		return nil, ErrClusterNotDefined
+	}
+
+	scrubber := &dedupedScrubber{
+		vdiskID:         cfg.VdiskID,
+		cluster:         cluster,
+		slaveCluster:    slaveCluster,
+		templateCluster: templateCluster,
+	}
+	if cfg.Rate > 0 {
+		if interval := time.Second / time.Duration(cfg.Rate); interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			scrubber.throttle = ticker.C
+		}
+	}
+
+	serverCh, err := cluster.ServerIterator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("scrubbing content of deduped vdisk %s", cfg.VdiskID)
+	storageKey := lbaStorageKey(cfg.VdiskID)
+	for server := range serverCh {
+		// the content verified while walking the sectors of a server,
+		// is forgotten once all sectors of that server are walked
+		scrubber.resetVerified()
+		for input := range dedupMetadataFetcher(ctx, storageKey, server) {
+			if input.Error != nil {
+				return nil, errors.Wrapf(input.Error,
+					"couldn't fetch LBA sectors of vdisk %s", cfg.VdiskID)
+			}
+			for sectorIndex, bytes := range input.Data {
+				sector, err := lba.SectorFromBytes(bytes)
+				if err != nil {
+					return nil, errors.Wrapf(err,
+						"invalid raw sector bytes at sector index %d", sectorIndex)
+				}
+				err = scrubber.scrubSector(ctx, sectorIndex, sector)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	log.Infof(
+		"scrubbed deduped vdisk %s: %d content blocks verified, %d corrupted, %d repaired",
+		cfg.VdiskID, scrubber.result.Scanned, scrubber.result.Corrupted, scrubber.result.Repaired)
+	return &scrubber.result, nil
+}
+
+// dedupedScrubber is used to scrub the content of a single deduped vdisk.
+type dedupedScrubber struct {
+	vdiskID         string
+	cluster         ardb.StorageCluster
+	slaveCluster    ardb.StorageCluster
+	templateCluster ardb.StorageCluster
+	throttle        <-chan time.Time    // nil if unlimited
+	verified        map[string]struct{} // content shared by multiple blocks is only verified once
+	result          ScrubResult
+}
+
+// resetVerified forgets all content verified so far.
+func (s *dedupedScrubber) resetVerified() {
+	s.verified = make(map[string]struct{})
+}
+
+// scrubSector verifies the content of all blocks referenced by the given sector.
+func (s *dedupedScrubber) scrubSector(ctx context.Context, sectorIndex int64, sector *lba.Sector) error {
+	for hashIndex := int64(0); hashIndex < lba.NumberOfRecordsPerLBASector; hashIndex++ {
+		hash := sector.Get(hashIndex)
+		if hash == nil {
+			continue // no content stored for this block
+		}
+		if _, ok := s.verified[string(hash)]; ok {
+			continue
+		}
+
+		if s.throttle != nil {
+			select {
+			case <-s.throttle:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		blockIndex := sectorIndex*lba.NumberOfRecordsPerLBASector + hashIndex
+		err := s.scrubContent(blockIndex, hash)
+		if err != nil {
+			return err
+		}
+		if len(s.verified) >= maxScrubVerifiedHashes {
+			// bound the memory used to remember verified content,
+			// at the cost of verifying shared content more than once
+			s.resetVerified()
+		}
+		s.verified[string(hash)] = struct{}{}
+	}
+	return nil
+}
+
+// scrubContent verifies the content of the given block,
+// repairing and broadcasting it in case it is corrupted.
+func (s *dedupedScrubber) scrubContent(blockIndex int64, hash zerodisk.Hash) error {
+	s.result.Scanned++
+
+	content, err := getDedupedContent(hash, s.cluster)
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't fetch content of block %d of vdisk %s", blockIndex, s.vdiskID)
+	}
+	if content == nil {
+		// content is fetched lazily from the template cluster,
+		// and is thus only missing if the template cluster doesn't have it either
+		if content, _ = s.getValidContent(hash, s.templateCluster); content != nil {
+			return nil
+		}
+		log.Errorf("content of block %d of vdisk %s (%x) is missing", blockIndex, s.vdiskID, hash)
+	} else if zerodisk.HashBytes(content).Equals(hash) {
+		return nil
+	} else {
+		log.Errorf("content of block %d of vdisk %s (%x) is corrupted", blockIndex, s.vdiskID, hash)
+	}
+
+	s.result.Corrupted++
+	repaired := s.repairContent(blockIndex, hash)
+	if repaired {
+		s.result.Repaired++
+	}
+
+	log.Broadcast(
+		log.StatusDataCorrupted,
+		log.SubjectStorage,
+		log.ARDBDataCorruptedBody{
+			VdiskID:    s.vdiskID,
+			BlockIndex: blockIndex,
+			Hash:       hex.EncodeToString(hash),
+			Repaired:   repaired,
+		},
+	)
+	return nil
+}
+
+// repairContent tries to repair the content of the given block,
+// using a valid copy from the slave cluster, or else the template cluster.
+func (s *dedupedScrubber) repairContent(blockIndex int64, hash zerodisk.Hash) bool {
+	for _, source := range []struct {
+		cluster ardb.StorageCluster
+		name    string
+	}{
+		{s.slaveCluster, "slave"},
+		{s.templateCluster, "template"},
+	} {
+		content, err := s.getValidContent(hash, source.cluster)
+		if err != nil {
+			log.Errorf(
+				"couldn't fetch content of block %d of vdisk %s from %s cluster: %v",
+				blockIndex, s.vdiskID, source.name, err)
+			continue
+		}
+		if content == nil {
+			continue
+		}
+
+		err = ardb.Error(s.cluster.DoFor(int64(hash[0]),
+			ardb.Command(command.Set, hash.Bytes(), content)))
+		if err != nil {
+			log.Errorf(
+				"couldn't repair content of block %d of vdisk %s: %v",
+				blockIndex, s.vdiskID, err)
+			return false
+		}
+		log.Infof(
+			"repaired content of block %d of vdisk %s using the %s cluster",
+			blockIndex, s.vdiskID, source.name)
+		return true
+	}
+
+	log.Errorf(
+		"couldn't repair content of block %d of vdisk %s, no valid copy available",
+		blockIndex, s.vdiskID)
+	return false
+}
+
+// getValidContent fetches the content for the given hash from the given cluster,
+// returning nil in case that cluster isn't defined, or has no valid copy of that content.
+func (s *dedupedScrubber) getValidContent(hash zerodisk.Hash, cluster ardb.StorageCluster) ([]byte, error) {
+	if isInterfaceValueNil(cluster) {
+		return nil, nil
+	}
+	content, err := getDedupedContent(hash, cluster)
+	if err != nil {
+		if errors.Cause(err) == ErrClusterNotDefined {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if content == nil || !zerodisk.HashBytes(content).Equals(hash) {
+		return nil, nil
+	}
+	return content, nil
+}
+
+// getDedupedContent fetches the content for the given hash from the given cluster.
+func getDedupedContent(hash zerodisk.Hash, cluster ardb.StorageCluster) ([]byte, error) {
+	return ardb.OptBytes(cluster.DoFor(int64(hash[0]),
+		ardb.Command(command.Get, hash.Bytes())))
+}
+
+// maxScrubVerifiedHashes defines the max amount of hashes
+// remembered as verified while scrubbing a vdisk.
+const maxScrubVerifiedHashes = 1024 * 64
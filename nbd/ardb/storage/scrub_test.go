@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestScrubDedupedVdisk(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	require := require.New(t)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+	slaveCluster := redisstub.NewUniCluster(true)
+	defer slaveCluster.Close()
+	templateCluster := redisstub.NewUniCluster(true)
+	defer templateCluster.Close()
+
+	newContent := func(b byte) []byte {
+		content := make([]byte, blockSize)
+		for i := range content {
+			content[i] = b
+		}
+		return content
+	}
+	setContent := func(cluster ardb.StorageCluster, hash zerodisk.Hash, content []byte) {
+		require.NoError(ardb.Error(cluster.DoFor(int64(hash[0]),
+			ardb.Command(command.Set, hash.Bytes(), content))))
+	}
+	scrub := func() *ScrubResult {
+		result, err := ScrubDedupedVdisk(context.Background(),
+			ScrubConfig{VdiskID: vdiskID, Rate: 1000},
+			cluster, slaveCluster, templateCluster)
+		require.NoError(err)
+		return result
+	}
+
+	storage, err := Deduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	require.NoError(err)
+	defer storage.Close()
+
+	// blocks 0 and 300 share the same content
+	contents := map[int64][]byte{0: newContent(1), 1: newContent(2), 2: newContent(3), 300: newContent(1)}
+	for index, content := range contents {
+		require.NoError(storage.SetBlock(index, content))
+	}
+	require.NoError(storage.Flush())
+
+	// all content is valid
+	result := scrub()
+	require.Equal(ScrubResult{Scanned: 3}, *result)
+
+	// corrupt content which the slave has a valid copy of
+	hashA := zerodisk.HashBytes(contents[1])
+	setContent(cluster, hashA, newContent(4))
+	setContent(slaveCluster, hashA, contents[1])
+	// corrupt content which only the template has a valid copy of
+	hashB := zerodisk.HashBytes(contents[2])
+	setContent(cluster, hashB, newContent(5))
+	setContent(slaveCluster, hashB, newContent(5))
+	setContent(templateCluster, hashB, contents[2])
+
+	result = scrub()
+	require.Equal(ScrubResult{Scanned: 3, Corrupted: 2, Repaired: 2}, *result)
+	for index := int64(1); index <= 2; index++ {
+		content, err := storage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(contents[index], content)
+	}
+
+	// content which is missing, but available in the template cluster, isn't corrupted
+	hashC := zerodisk.HashBytes(contents[0])
+	require.NoError(ardb.Error(cluster.DoFor(int64(hashC[0]),
+		ardb.Command(command.Delete, hashC.Bytes()))))
+	setContent(templateCluster, hashC, contents[0])
+	result = scrub()
+	require.Equal(ScrubResult{Scanned: 3}, *result)
+
+	// corrupted content without a valid copy cannot be repaired
+	setContent(cluster, hashA, newContent(6))
+	require.NoError(ardb.Error(slaveCluster.DoFor(int64(hashA[0]),
+		ardb.Command(command.Delete, hashA.Bytes()))))
+	result = scrub()
+	require.Equal(ScrubResult{Scanned: 3, Corrupted: 1}, *result)
+}
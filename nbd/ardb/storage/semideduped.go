@@ -143,6 +143,22 @@ func (sds *semiDedupedStorage) Close() error {
 	return errs.AsError()
 }
 
+// CachedIndices implements CacheWarmer.CachedIndices
+func (sds *semiDedupedStorage) CachedIndices() []int64 {
+	if cw, ok := sds.templateStorage.(CacheWarmer); ok {
+		return cw.CachedIndices()
+	}
+	return nil
+}
+
+// WarmCache implements CacheWarmer.WarmCache
+func (sds *semiDedupedStorage) WarmCache(indices []int64) error {
+	if cw, ok := sds.templateStorage.(CacheWarmer); ok {
+		return cw.WarmCache(indices)
+	}
+	return nil
+}
+
 // readBitMap reads and decompresses (gzip) the bitmap from the ardb
 func (sds *semiDedupedStorage) readBitMap() error {
 	cmd := ardb.Command(command.Get, semiDedupBitMapKey(sds.vdiskID))
@@ -236,8 +252,8 @@ func listSemiDedupedBlockIndices(vdiskID string, cluster ardb.StorageCluster) ([
 
 // copySemiDeduped copies a semi deduped storage
 // within the same or between different storage clusters.
-func copySemiDeduped(sourceID, targetID string, sourceBS, targetBS int64, sourceCluster, targetCluster ardb.StorageCluster) error {
-	err := copyDedupedMetadata(sourceID, targetID, sourceBS, targetBS, sourceCluster, targetCluster)
+func copySemiDeduped(sourceID, targetID string, sourceBS, targetBS int64, sourceCluster, sourceTemplateCluster, targetCluster ardb.StorageCluster) error {
+	err := copyDeduped(sourceID, targetID, sourceBS, targetBS, sourceCluster, sourceTemplateCluster, targetCluster)
 	if err != nil {
 		return err
 	}
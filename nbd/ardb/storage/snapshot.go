@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+)
+
+// SnapshotVdiskID returns the identifier of a vdisk snapshot,
+// which can be used to mount that snapshot (read-only) as an NBD export.
+func SnapshotVdiskID(vdiskID, snapshotID string) string {
+	return vdiskID + snapshotVdiskIDSeparator + snapshotID
+}
+
+// ParseSnapshotVdiskID parses the identifier of a vdisk snapshot,
+// as created by SnapshotVdiskID, returning the vdisk and snapshot identifiers.
+// False is returned in case the given identifier doesn't identify a snapshot.
+func ParseSnapshotVdiskID(id string) (vdiskID, snapshotID string, ok bool) {
+	index := strings.LastIndex(id, snapshotVdiskIDSeparator)
+	if index <= 0 || index == len(id)-1 {
+		return "", "", false
+	}
+	return id[:index], id[index+1:], true
+}
+
+// CreateSnapshot creates a named read-only (point-in-time) snapshot of a vdisk,
+// stored within the same cluster as the vdisk itself.
+//
+// The LBA sectors of a deduped vdisk are frozen by copying them under the snapshot's key.
+// Blocks of a non-deduped vdisk are copied to the snapshot,
+// only when they are modified for the first time after the snapshot was created (copy-on-write).
+//
+// The snapshot is only registered (and thus readable) once it is complete.
+//
+// Note that only data which is flushed to the cluster is part of the snapshot,
+// and that the LBA sectors of a deduped vdisk are frozen in batches,
+// while copy-on-write is enabled for each server of a non-deduped vdisk in turn,
+// such that the vdisk shouldn't be written to while the snapshot is created.
+// Semi-deduped vdisks don't support snapshots.
+func CreateSnapshot(vdiskID, snapshotID string, t config.VdiskType, cluster ardb.StorageCluster) error {
+	if snapshotID == "" || strings.Contains(snapshotID, snapshotVdiskIDSeparator) {
+		return errors.Newf("invalid snapshot identifier %q", snapshotID)
+	}
+	if _, _, ok := ParseSnapshotVdiskID(vdiskID); ok {
+		return errors.Newf("cannot snapshot %s as it is a snapshot itself", vdiskID)
+	}
+
+	exists, err := SnapshotExists(vdiskID, snapshotID, cluster)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.Newf("snapshot %s of vdisk %s already exists", snapshotID, vdiskID)
+	}
+
+	snapshotVdiskID := SnapshotVdiskID(vdiskID, snapshotID)
+	// the snapshot is registered on all servers,
+	// as the copy-on-write logic of non-deduped storage requires it
+	registerSnapshot := func() error {
+		return doForAllServers(cluster,
+			ardb.Command(command.SetAdd, vdiskSnapshotsKey(vdiskID), snapshotID))
+	}
+
+	switch st := t.StorageType(); st {
+	case config.StorageDeduped:
+		log.Infof("freezing LBA sectors of vdisk %s as snapshot %s", vdiskID, snapshotID)
+		err = freezeDedupedMetadata(vdiskID, snapshotVdiskID, cluster)
+		if err != nil {
+			return err
+		}
+		// a snapshot of a cloned vdisk, still reads through to the parent(s) of that vdisk
+		parentID, err := LoadVdiskParent(vdiskID, cluster)
+		if err != nil {
+			return err
+		}
+		if parentID != "" {
+			err = registerVdiskClone(parentID, snapshotVdiskID, cluster)
+			if err != nil {
+				return err
+			}
+		}
+		return registerSnapshot()
+
+	case config.StorageNonDeduped:
+		log.Infof("enabling copy-on-write of vdisk %s for snapshot %s", vdiskID, snapshotID)
+		// the snapshot reads through to the vdisk, for all blocks which weren't modified yet
+		err = registerVdiskClone(vdiskID, snapshotVdiskID, cluster)
+		if err != nil {
+			return err
+		}
+		// copy-on-write starts as soon as the snapshot is registered,
+		// as it is checked by every block write of the vdisk
+		return registerSnapshot()
+
+	default:
+		return errors.Wrapf(ErrSnapshotNotSupported,
+			"cannot snapshot vdisk %s of storage type %s", vdiskID, st)
+	}
+}
+
+// ListSnapshots lists the identifiers of all snapshots of the given vdisk.
+func ListSnapshots(vdiskID string, cluster ardb.StorageCluster) ([]string, error) {
+	if isInterfaceValueNil(cluster) {
+		return nil, ErrClusterNotDefined
+	}
+	snapshots, err := ardb.OptStrings(cluster.Do(
+		ardb.Command(command.SetMembers, vdiskSnapshotsKey(vdiskID))))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// SnapshotExists returns true in case the given snapshot of a vdisk exists.
+func SnapshotExists(vdiskID, snapshotID string, cluster ardb.StorageCluster) (bool, error) {
+	if isInterfaceValueNil(cluster) {
+		return false, ErrClusterNotDefined
+	}
+	return ardb.Bool(cluster.Do(
+		ardb.Command(command.SetIsMember, vdiskSnapshotsKey(vdiskID), snapshotID)))
+}
+
+// DeleteSnapshot deletes a snapshot of the given vdisk,
+// returning true in case the snapshot existed and was deleted.
+func DeleteSnapshot(vdiskID, snapshotID string, t config.VdiskType, cluster ardb.StorageCluster) (bool, error) {
+	exists, err := SnapshotExists(vdiskID, snapshotID, cluster)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	// unregister the snapshot first, such that copy-on-write stops immediately
+	err = doForAllServers(cluster,
+		ardb.Command(command.SetRemove, vdiskSnapshotsKey(vdiskID), snapshotID))
+	if err != nil {
+		return false, err
+	}
+
+	snapshotVdiskID := SnapshotVdiskID(vdiskID, snapshotID)
+	_, err = unregisterVdiskClone(snapshotVdiskID, cluster)
+	if err != nil {
+		return false, err
+	}
+
+	switch st := t.StorageType(); st {
+	case config.StorageDeduped:
+		_, err = deleteDedupedData(snapshotVdiskID, cluster)
+	case config.StorageNonDeduped:
+		_, err = deleteNonDedupedData(snapshotVdiskID, cluster)
+	default:
+		err = errors.Newf("%v is not a supported storage type", st)
+	}
+	return err == nil, err
+}
+
+// freezeDedupedMetadata copies all LBA sectors of a deduped vdisk
+// to the given target within the same cluster.
+// The sectors are copied in batches, such that the servers aren't blocked
+// for the duration of the copy, in case of a big vdisk.
+func freezeDedupedMetadata(sourceID, targetID string, cluster ardb.StorageCluster) error {
+	sourceKey, targetKey := lbaStorageKey(sourceID), lbaStorageKey(targetID)
+	return forAllServers(cluster, func(server ardb.StorageServer) error {
+		_, err := copyDedupedBetweenServers(sourceKey, targetKey, server, server)
+		return err
+	})
+}
+
+// doForAllServers applies the given action on all servers of the given cluster.
+func doForAllServers(cluster ardb.StorageCluster, action ardb.StorageAction) error {
+	return forAllServers(cluster, func(server ardb.StorageServer) error {
+		_, err := server.Do(action)
+		return err
+	})
+}
+
+// forAllServers calls the given function for all servers of the given cluster, in parallel.
+func forAllServers(cluster ardb.StorageCluster, fn func(server ardb.StorageServer) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serverCh, err := cluster.ServerIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error)
+	var serverCount int
+	for server := range serverCh {
+		server := server
+		go func() {
+			err := fn(server)
+			select {
+			case errCh <- err:
+			case <-ctx.Done():
+			}
+		}()
+		serverCount++
+	}
+
+	for i := 0; i < serverCount; i++ {
+		if err = <-errCh; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vdiskSnapshotsKey returns the key of the set which stores all snapshots of a vdisk.
+func vdiskSnapshotsKey(vdiskID string) string {
+	return vdiskSnapshotsKeyPrefix + vdiskID
+}
+
+var (
+	// ErrSnapshotNotSupported is an error returned
+	// when trying to snapshot a vdisk whose storage type doesn't support snapshots.
+	ErrSnapshotNotSupported = errors.New("storage type doesn't support snapshots")
+	// ErrVdiskHasSnapshots is an error returned
+	// when trying to delete a vdisk which still has snapshots.
+	ErrVdiskHasSnapshots = errors.New("vdisk has snapshots")
+)
+
+const (
+	snapshotVdiskIDSeparator = config.VdiskSnapshotSeparator
+	vdiskSnapshotsKeyPrefix  = "snapshots:"
+)
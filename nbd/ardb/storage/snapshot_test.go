@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestParseSnapshotVdiskID(t *testing.T) {
+	vdiskID, snapshotID, ok := ParseSnapshotVdiskID(SnapshotVdiskID("a", "b"))
+	if assert.True(t, ok) {
+		assert.Equal(t, "a", vdiskID)
+		assert.Equal(t, "b", snapshotID)
+	}
+
+	for _, id := range []string{"a", "a@", "@b", ""} {
+		_, _, ok = ParseSnapshotVdiskID(id)
+		assert.False(t, ok, id)
+	}
+}
+
+func TestSnapshotDeduped(t *testing.T) {
+	testSnapshot(t, config.VdiskTypeBoot)
+}
+
+func TestSnapshotNonDeduped(t *testing.T) {
+	testSnapshot(t, config.VdiskTypeDB)
+}
+
+func testSnapshot(t *testing.T, vdiskType config.VdiskType) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	require := require.New(t)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	newStorage := func(vdiskID string) BlockStorage {
+		lineage, err := LoadVdiskLineage(vdiskID, cluster)
+		require.NoError(err)
+		storage, err := NewBlockStorage(BlockStorageConfig{
+			VdiskID:   vdiskID,
+			VdiskType: vdiskType,
+			BlockSize: blockSize,
+			Lineage:   lineage,
+		}, cluster, nil)
+		require.NoError(err)
+		return storage
+	}
+	newContent := func(b byte) []byte {
+		content := make([]byte, blockSize)
+		for i := range content {
+			content[i] = b
+		}
+		return content
+	}
+
+	vdiskStorage := newStorage(vdiskID)
+	require.NoError(vdiskStorage.SetBlock(0, newContent(1)))
+	require.NoError(vdiskStorage.SetBlock(1, newContent(2)))
+	require.NoError(vdiskStorage.Flush())
+
+	require.NoError(CreateSnapshot(vdiskID, "snap", vdiskType, cluster))
+	require.Error(CreateSnapshot(vdiskID, "snap", vdiskType, cluster), "snapshot already exists")
+	snapshots, err := ListSnapshots(vdiskID, cluster)
+	require.NoError(err)
+	require.Equal([]string{"snap"}, snapshots)
+
+	// modifying the vdisk, doesn't modify the snapshot
+	require.NoError(vdiskStorage.SetBlock(0, newContent(3)))
+	require.NoError(vdiskStorage.DeleteBlock(1))
+	require.NoError(vdiskStorage.SetBlock(2, newContent(4)))
+	require.NoError(vdiskStorage.Flush())
+	// modifying the same block twice, only copies it once
+	require.NoError(vdiskStorage.SetBlock(0, newContent(5)))
+	require.NoError(vdiskStorage.Flush())
+
+	snapshotStorage := newStorage(SnapshotVdiskID(vdiskID, "snap"))
+	for index, expected := range map[int64][]byte{0: newContent(1), 1: newContent(2), 2: nil} {
+		content, err := snapshotStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(expected, content, "snapshot block %d", index)
+	}
+	for index, expected := range map[int64][]byte{0: newContent(5), 1: nil, 2: newContent(4)} {
+		content, err := vdiskStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(expected, content, "vdisk block %d", index)
+	}
+
+	// a vdisk cannot be deleted as long as it has snapshots
+	_, err = DeleteVdiskInCluster(vdiskID, vdiskType, cluster)
+	require.Equal(ErrVdiskHasSnapshots, errors.Cause(err))
+
+	deleted, err := DeleteSnapshot(vdiskID, "snap", vdiskType, cluster)
+	require.NoError(err)
+	require.True(deleted)
+	deleted, err = DeleteSnapshot(vdiskID, "snap", vdiskType, cluster)
+	require.NoError(err)
+	require.False(deleted)
+
+	deleted, err = DeleteVdiskInCluster(vdiskID, vdiskType, cluster)
+	require.NoError(err)
+	require.True(deleted)
+}
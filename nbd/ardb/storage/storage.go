@@ -28,6 +28,16 @@ type BlockStorage interface {
 	Close() (err error)
 }
 
+// CacheWarmer is an optional interface which can be implemented by a BlockStorage,
+// in case it caches metadata which can be exported and (pre-)fetched.
+// It is used to hand off a warm cache, when a vdisk is migrated from one nbdserver to another.
+type CacheWarmer interface {
+	// CachedIndices returns the indices of all cached entries.
+	CachedIndices() []int64
+	// WarmCache fetches the entries at the given indices into the cache.
+	WarmCache(indices []int64) error
+}
+
 // BlockStorageConfig is used when creating a block storage using the
 // NewBlockStorage helper constructor.
 type BlockStorageConfig struct {
@@ -44,6 +54,10 @@ type BlockStorageConfig struct {
 
 	// optional: used by (semi)deduped storage
 	LBACacheLimit int64
+
+	// optional: IDs of the parent vdisks (nearest parent first),
+	// only defined in case the vdisk is a clone, see `LoadVdiskLineage`
+	Lineage []string
 }
 
 // Validate this BlockStorageConfig.
@@ -94,6 +108,12 @@ func BlockStorageFromConfig(vdiskID string, cs config.Source, dialer ardb.Connec
 		}
 	}
 
+	// load the lineage, in case the vdisk is a clone
+	lineage, err := LoadVdiskLineage(vdiskID, cluster)
+	if err != nil {
+		return nil, err
+	}
+
 	// create block storage config
 	cfg := BlockStorageConfig{
 		VdiskID:         vdiskID,
@@ -101,6 +121,7 @@ func BlockStorageFromConfig(vdiskID string, cs config.Source, dialer ardb.Connec
 		VdiskType:       vdiskConfig.Type,
 		BlockSize:       int64(vdiskConfig.BlockSize),
 		LBACacheLimit:   ardb.DefaultLBACacheLimit,
+		Lineage:         lineage,
 	}
 
 	// try to create actual block storage
@@ -124,22 +145,28 @@ func NewBlockStorage(cfg BlockStorageConfig, cluster, templateCluster ardb.Stora
 
 	switch storageType := vdiskType.StorageType(); storageType {
 	case config.StorageDeduped:
-		return Deduped(
+		return newDedupedStorage(
 			cfg.VdiskID,
+			cfg.Lineage,
 			cfg.BlockSize,
 			cfg.LBACacheLimit,
 			cluster,
 			templateCluster)
 
 	case config.StorageNonDeduped:
-		return NonDeduped(
+		return newNonDedupedStorage(
 			cfg.VdiskID,
 			cfg.TemplateVdiskID,
+			cfg.Lineage,
 			cfg.BlockSize,
 			cluster,
 			templateCluster)
 
 	case config.StorageSemiDeduped:
+		if len(cfg.Lineage) > 0 {
+			return nil, errors.Wrapf(ErrCloneNotSupported,
+				"cannot create storage for cloned vdisk %s", cfg.VdiskID)
+		}
 		return SemiDeduped(
 			cfg.VdiskID,
 			cfg.BlockSize,
@@ -188,14 +215,17 @@ func VdiskExists(vdiskID string, source config.Source) (bool, error) {
 }
 
 // VdiskExistsInCluster returns true if the vdisk in question exists in the given ARDB storage cluster.
+// A cloned vdisk exists, even if no data was written to it yet.
 // An error is returned in case this couldn't be verified for whatever reason.
 func VdiskExistsInCluster(vdiskID string, t config.VdiskType, cluster ardb.StorageCluster) (bool, error) {
+	var exists bool
+	var err error
 	switch st := t.StorageType(); st {
 	case config.StorageDeduped:
-		return dedupedVdiskExists(vdiskID, cluster)
+		exists, err = dedupedVdiskExists(vdiskID, cluster)
 
 	case config.StorageNonDeduped:
-		return nonDedupedVdiskExists(vdiskID, cluster)
+		exists, err = nonDedupedVdiskExists(vdiskID, cluster)
 
 	case config.StorageSemiDeduped:
 		return semiDedupedVdiskExists(vdiskID, cluster)
@@ -203,6 +233,12 @@ func VdiskExistsInCluster(vdiskID string, t config.VdiskType, cluster ardb.Stora
 	default:
 		return false, errors.Newf("%v is not a supported storage type", st)
 	}
+	if err != nil || exists {
+		return exists, err
+	}
+
+	parentID, err := LoadVdiskParent(vdiskID, cluster)
+	return parentID != "", err
 }
 
 // CopyVdiskConfig is the config for a vdisk
@@ -215,8 +251,13 @@ type CopyVdiskConfig struct {
 
 // CopyVdisk allows you to copy a vdisk from a source to a target vdisk.
 // The source and target vdisks have to have the same storage type and block size.
-// They can be stored on the same or different clusters.
-func CopyVdisk(source, target CopyVdiskConfig, sourceCluster, targetCluster ardb.StorageCluster) error {
+// They can be stored on the same or different clusters,
+// except for cloned source vdisks, which can only be copied within the same cluster,
+// in which case the target becomes a clone of the same parent.
+// The (optional) template cluster of the source vdisk is used
+// to copy the deduped content which isn't available in the source cluster,
+// when copying a (semi) deduped vdisk between different clusters.
+func CopyVdisk(source, target CopyVdiskConfig, sourceCluster, sourceTemplateCluster, targetCluster ardb.StorageCluster) error {
 	sourceStorageType := source.Type.StorageType()
 	targetStorageType := target.Type.StorageType()
 	if sourceStorageType != targetStorageType {
@@ -225,12 +266,23 @@ func CopyVdisk(source, target CopyVdiskConfig, sourceCluster, targetCluster ardb
 			source.VdiskID, target.VdiskID, sourceStorageType, targetStorageType)
 	}
 
-	var err error
+	parentID, err := LoadVdiskParent(source.VdiskID, sourceCluster)
+	if err != nil {
+		return err
+	}
+	if parentID != "" {
+		if !isInterfaceValueNil(targetCluster) {
+			return errors.Newf(
+				"cannot copy vdisk %s to a different cluster, as it is a clone of vdisk %s",
+				source.VdiskID, parentID)
+		}
+	}
+
 	switch sourceStorageType {
 	case config.StorageDeduped:
-		err = copyDedupedMetadata(
+		err = copyDeduped(
 			source.VdiskID, target.VdiskID, source.BlockSize, target.BlockSize,
-			sourceCluster, targetCluster)
+			sourceCluster, sourceTemplateCluster, targetCluster)
 
 	case config.StorageNonDeduped:
 		err = copyNonDedupedData(
@@ -240,13 +292,24 @@ func CopyVdisk(source, target CopyVdiskConfig, sourceCluster, targetCluster ardb
 	case config.StorageSemiDeduped:
 		err = copySemiDeduped(
 			source.VdiskID, target.VdiskID, source.BlockSize, target.BlockSize,
-			sourceCluster, targetCluster)
+			sourceCluster, sourceTemplateCluster, targetCluster)
 
 	default:
 		err = errors.Newf(
 			"%v is not a supported storage type", sourceStorageType)
 	}
 
+	if err == nil && parentID != "" {
+		err = registerVdiskClone(parentID, target.VdiskID, sourceCluster)
+	}
+	if err == nil {
+		registryCluster := targetCluster
+		if isInterfaceValueNil(registryCluster) {
+			registryCluster = sourceCluster
+		}
+		err = RegisterVdisk(target.VdiskID, target.Type, registryCluster)
+	}
+
 	if err != nil || !source.Type.TlogSupport() || !target.Type.TlogSupport() {
 		return err
 	}
@@ -298,7 +361,30 @@ func DeleteVdisk(vdiskID string, configSource config.Source) (bool, error) {
 // Note that for deduped storage the actual block data isn't deleted or dereferenced.
 // See https://github.com/zero-os/0-Disk/issues/147
 func DeleteVdiskInCluster(vdiskID string, t config.VdiskType, cluster ardb.StorageCluster) (bool, error) {
-	var err error
+	// a vdisk cannot be deleted as long as it still has snapshots or clones
+	snapshots, err := ListSnapshots(vdiskID, cluster)
+	if err != nil {
+		return false, err
+	}
+	if len(snapshots) > 0 {
+		return false, errors.Wrapf(ErrVdiskHasSnapshots,
+			"cannot delete vdisk %s (snapshots: %v)", vdiskID, snapshots)
+	}
+	clones, err := ListVdiskClones(vdiskID, cluster)
+	if err != nil {
+		return false, err
+	}
+	if len(clones) > 0 {
+		return false, errors.Wrapf(ErrVdiskHasClones,
+			"cannot delete vdisk %s (clones: %v)", vdiskID, clones)
+	}
+
+	// unlink a cloned vdisk from its parent
+	unlinkedClone, err := unregisterVdiskClone(vdiskID, cluster)
+	if err != nil {
+		return false, err
+	}
+
 	var deletedTlogMetadata bool
 	if t.TlogSupport() {
 		command := ardb.Command(command.Delete, tlogMetadataKey(vdiskID))
@@ -323,16 +409,40 @@ func DeleteVdiskInCluster(vdiskID string, t config.VdiskType, cluster ardb.Stora
 		err = errors.Newf("%v is not a supported storage type", st)
 	}
 
-	return deletedTlogMetadata || deletedStorage, err
+	if err == nil {
+		err = UnregisterVdisk(vdiskID, cluster)
+	}
+
+	return unlinkedClone || deletedTlogMetadata || deletedStorage, err
+}
+
+// ListVdisks lists the vdisks registered in the vdisk registry
+// of a given storage cluster, and returns their ids.
+// Optionally a predicate can be given to
+// filter specific vdisks based on their identifiers.
+// Vdisks which were created before the registry existed
+// are only listed once the registry has been rebuilt (see: RebuildVdiskRegistry).
+func ListVdisks(cluster ardb.StorageCluster, pred func(vdiskID string) bool) ([]string, error) {
+	entries, err := ListVdiskRegistry(cluster)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if pred == nil || pred(entry.VdiskID) {
+			ids = append(ids, entry.VdiskID)
+		}
+	}
+	return ids, nil
 }
 
-// ListVdisks scans a given storage cluster
+// ScanVdisks scans a given storage cluster
 // for available vdisks, and returns their ids.
 // Optionally a predicate can be given to
 // filter specific vdisks based on their identifiers.
 // NOTE: this function is very slow,
 //       and puts a lot of pressure on the ARDB cluster.
-func ListVdisks(cluster ardb.StorageCluster, pred func(vdiskID string) bool) ([]string, error) {
+func ScanVdisks(cluster ardb.StorageCluster, pred func(vdiskID string) bool) ([]string, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -497,8 +607,85 @@ func ListBlockIndices(vdiskID string, source config.Source) ([]int64, error) {
 }
 
 // ListBlockIndicesInCluster returns all indices stored for the given vdisk from cluster configs.
+// For a cloned vdisk, the indices of its parent(s) are included as well,
+// except for those of blocks that were deleted in the clone (or a nearer parent).
 // This function returns either an error OR indices.
 func ListBlockIndicesInCluster(id string, t config.VdiskType, cluster ardb.StorageCluster) ([]int64, error) {
+	indices, err := listBlockIndicesInCluster(id, t, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	lineage, err := LoadVdiskLineage(id, cluster)
+	if err != nil || len(lineage) == 0 {
+		return indices, err
+	}
+
+	// a block is defined by the nearest vdisk in the lineage which stores it,
+	// even in case it is stored as a (masking) deleted block
+	masked := make(map[int64]struct{})
+	maskedBy := id
+	for _, parentID := range lineage {
+		maskingIndices, err := listMaskingIndicesInCluster(maskedBy, t, cluster)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range maskingIndices {
+			masked[index] = struct{}{}
+		}
+		parentIndices, err := listBlockIndicesInCluster(parentID, t, cluster)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range parentIndices {
+			if _, ok := masked[maskingIndex(index, t)]; !ok {
+				indices = append(indices, index)
+			}
+		}
+		maskedBy = parentID
+	}
+	sortInt64s(indices)
+	return dedupInt64s(indices), nil
+}
+
+// ServerBlockCount defines the amount of blocks
+// a vdisk has stored on a single storage server.
+type ServerBlockCount struct {
+	Server     config.StorageServerConfig
+	BlockCount int64
+}
+
+// CountBlocksPerServer counts for each server of the given cluster,
+// the amount of blocks the given vdisk stores on that server.
+// For deduped vdisks this is the amount of blocks whose metadata is stored on that server.
+// Servers which aren't online are returned with a block count of 0.
+//
+// Only the blocks of the vdisk itself are counted,
+// meaning that blocks read through from the parent(s) of a cloned vdisk are not.
+func CountBlocksPerServer(id string, t config.VdiskType, cfg config.StorageClusterConfig) ([]ServerBlockCount, error) {
+	counts := make([]ServerBlockCount, len(cfg.Servers))
+	for index, serverCfg := range cfg.Servers {
+		counts[index].Server = serverCfg
+		if serverCfg.State != config.StorageServerStateOnline {
+			continue
+		}
+
+		cluster, err := ardb.NewUniCluster(serverCfg, nil)
+		if err != nil {
+			return nil, err
+		}
+		indices, err := listBlockIndicesInCluster(id, t, cluster)
+		if err != nil {
+			return nil, errors.Wrapf(err,
+				"couldn't list block indices of vdisk %s on %s", id, &serverCfg)
+		}
+		counts[index].BlockCount = int64(len(indices))
+	}
+	return counts, nil
+}
+
+// listBlockIndicesInCluster returns all indices stored for the given vdisk itself.
+func listBlockIndicesInCluster(id string, t config.VdiskType, cluster ardb.StorageCluster) ([]int64, error) {
 	switch st := t.StorageType(); st {
 	case config.StorageDeduped:
 		return listDedupedBlockIndices(id, cluster)
@@ -533,6 +720,7 @@ var listStorageKeyPrefixRex = regexp.MustCompile("^(" +
 var listStorageKeyPrefixes = []string{
 	lbaStorageKeyPrefix,
 	nonDedupedStorageKeyPrefix,
+	vdiskParentKeyPrefix,
 }
 
 // sortInt64s sorts a slice of int64s
@@ -7,7 +7,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/redisstub"
 )
 
 // shared test function to test all types of BlockStorage equally,
@@ -341,6 +344,37 @@ func testBlockStorageDeadlock(t *testing.T, blockSize, blockCount int64, storage
 	wg.Wait()
 }
 
+func TestCountBlocksPerServer(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 8
+	)
+
+	require := require.New(t)
+
+	slice := redisstub.NewMemoryRedisSlice(2)
+	defer slice.Close()
+	clusterConfig := slice.StorageClusterConfig()
+	cluster, err := ardb.NewCluster(clusterConfig, nil)
+	require.NoError(err)
+
+	storage, err := NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	require.NoError(err)
+	defer storage.Close()
+	for blockIndex := int64(0); blockIndex < 5; blockIndex++ {
+		require.NoError(storage.SetBlock(blockIndex, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	}
+	require.NoError(storage.Flush())
+
+	counts, err := CountBlocksPerServer(vdiskID, config.VdiskTypeDB, clusterConfig)
+	require.NoError(err)
+	require.Len(counts, 2)
+	require.Equal(clusterConfig.Servers[0], counts[0].Server)
+	require.Equal(int64(3), counts[0].BlockCount)
+	require.Equal(clusterConfig.Servers[1], counts[1].Server)
+	require.Equal(int64(2), counts[1].BlockCount)
+}
+
 func TestSortInt64s(t *testing.T) {
 	require := require.New(t)
 	testCases := []struct {
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+)
+
+// WarmConfig defines the configuration used to warm up a vdisk.
+type WarmConfig struct {
+	// VdiskID of the vdisk to warm up
+	VdiskID string
+	// TemplateVdiskID of the vdisk the content is fetched from,
+	// only used for nondeduped vdisks, and equal to VdiskID if not defined
+	TemplateVdiskID string
+	// Type of the vdisk to warm up
+	Type config.VdiskType
+	// Lineage of the vdisk (nearest parent first),
+	// only defined in case the vdisk is a clone, see `LoadVdiskLineage`
+	Lineage []string
+	// Rate defines the maximum amount of content blocks
+	// to check per second, a rate of 0 means unlimited.
+	Rate int64
+	// JobCount defines the amount of jobs (goroutines) fetching content in parallel,
+	// by default it equals the amount of CPUs available.
+	JobCount int
+}
+
+// WarmResult is the result of warming up a vdisk.
+type WarmResult struct {
+	// amount of (unique) content blocks checked
+	Scanned int64
+	// amount of content blocks fetched from the template cluster
+	Warmed int64
+	// amount of content blocks which were already available in the primary cluster
+	Available int64
+	// amount of content blocks which aren't available in the template cluster either
+	Missing int64
+}
+
+// WarmVdisk fetches all content of a vdisk, which isn't available yet
+// in the primary cluster, from the template cluster.
+// Normally such content is only fetched lazily, when it is read for the first time,
+// warming up a vdisk ensures that it no longer depends on the template cluster.
+//
+// For (semi) deduped vdisks the LBA of the vdisk (and its lineage) is walked,
+// while for nondeduped vdisks the blocks of the template vdisk are listed.
+// Content is never overwritten, such that blocks written in the meantime are preserved.
+func WarmVdisk(ctx context.Context, cfg WarmConfig, cluster, templateCluster ardb.StorageCluster) (*WarmResult, error) {
+	if isInterfaceValueNil(cluster) || isInterfaceValueNil(templateCluster) {
+		return nil, ErrClusterNotDefined
+	}
+	if !cfg.Type.TemplateSupport() {
+		return nil, errors.Newf(
+			"cannot warm up vdisk %s, as its type %s has no template support",
+			cfg.VdiskID, cfg.Type)
+	}
+	if cfg.JobCount <= 0 {
+		cfg.JobCount = runtime.NumCPU()
+	}
+
+	var throttle <-chan time.Time
+	if cfg.Rate > 0 {
+		if interval := time.Second / time.Duration(cfg.Rate); interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			throttle = ticker.C
+		}
+	}
+
+	var result *WarmResult
+	var err error
+	switch storageType := cfg.Type.StorageType(); storageType {
+	case config.StorageDeduped, config.StorageSemiDeduped:
+		log.Infof("warming up deduped content of vdisk %s", cfg.VdiskID)
+		result, err = warmDedupedVdisk(ctx, cfg, throttle, cluster, templateCluster)
+	case config.StorageNonDeduped:
+		log.Infof("warming up non-deduped content of vdisk %s", cfg.VdiskID)
+		result, err = warmNonDedupedVdisk(ctx, cfg, throttle, cluster, templateCluster)
+	default:
+		return nil, errors.Newf(
+			"cannot warm up vdisk %s, as its storage type %s isn't supported",
+			cfg.VdiskID, storageType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof(
+		"warmed up vdisk %s: %d content blocks checked, %d fetched from template cluster, %d already available, %d missing",
+		cfg.VdiskID, result.Scanned, result.Warmed, result.Available, result.Missing)
+	return result, nil
+}
+
+// warmDedupedVdisk walks the LBA of a (semi) deduped vdisk and its lineage,
+// copying all referenced content missing in the primary cluster from the template cluster.
+// Content is checked and copied in batches, using multiple jobs in parallel.
+func warmDedupedVdisk(ctx context.Context, cfg WarmConfig, throttle <-chan time.Time, cluster, templateCluster ardb.StorageCluster) (*WarmResult, error) {
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	batchCh := make(chan dedupedContentBatch, cfg.JobCount)
+	copier := &dedupedContentCopier{
+		vdiskID:       cfg.VdiskID,
+		sourceCluster: templateCluster,
+		targetCluster: cluster,
+		throttle:      throttle,
+		batchCh:       batchCh,
+		ctx:           groupCtx,
+	}
+
+	// launch input goroutine, which walks the LBA of the vdisk and its lineage
+	group.Go(func() error {
+		defer close(batchCh)
+		vdiskIDs := append([]string{cfg.VdiskID}, cfg.Lineage...)
+		for _, vdiskID := range vdiskIDs {
+			err := copier.addVdisk(groupCtx, vdiskID, cluster)
+			if err != nil {
+				return err
+			}
+		}
+		return copier.flushAll()
+	})
+
+	// launch worker goroutines
+	for i := 0; i < cfg.JobCount; i++ {
+		group.Go(func() error {
+			for batch := range batchCh {
+				err := copier.copyBatch(batch.objectIndex, batch.hashes)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, err
+	}
+	// the vdisk is only partially warmed up, if the given context was cancelled
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return &WarmResult{
+		Scanned:   copier.scanned,
+		Warmed:    copier.copied,
+		Available: copier.available,
+		Missing:   copier.missing,
+	}, nil
+}
+
+// warmNonDedupedVdisk lists all blocks of the template vdisk of a nondeduped vdisk,
+// copying each block which isn't available in the primary cluster yet,
+// neither for the vdisk itself nor for any of its parents.
+// Blocks are checked and copied one by one, using multiple jobs in parallel.
+func warmNonDedupedVdisk(ctx context.Context, cfg WarmConfig, throttle <-chan time.Time, cluster, templateCluster ardb.StorageCluster) (*WarmResult, error) {
+	templateVdiskID := cfg.TemplateVdiskID
+	if templateVdiskID == "" {
+		templateVdiskID = cfg.VdiskID
+	}
+	indices, err := listNonDedupedBlockIndices(templateVdiskID, templateCluster)
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"couldn't list blocks of template vdisk %s", templateVdiskID)
+	}
+
+	warmer := &nonDedupedWarmer{
+		storageKeys:        []string{nonDedupedStorageKey(cfg.VdiskID)},
+		templateStorageKey: nonDedupedStorageKey(templateVdiskID),
+		cluster:            cluster,
+		templateCluster:    templateCluster,
+	}
+	for _, parentID := range cfg.Lineage {
+		warmer.storageKeys = append(warmer.storageKeys, nonDedupedStorageKey(parentID))
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	// launch input goroutine
+	indexCh := make(chan int64, cfg.JobCount)
+	group.Go(func() error {
+		defer close(indexCh)
+		for _, index := range indices {
+			if throttle != nil {
+				select {
+				case <-throttle:
+				case <-groupCtx.Done():
+					return nil
+				}
+			}
+			select {
+			case indexCh <- index:
+			case <-groupCtx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+
+	// launch worker goroutines
+	for i := 0; i < cfg.JobCount; i++ {
+		group.Go(func() error {
+			for index := range indexCh {
+				err := warmer.warmBlock(index)
+				if err != nil {
+					return errors.Wrapf(err,
+						"couldn't warm up block %d of vdisk %s", index, cfg.VdiskID)
+				}
+			}
+			return nil
+		})
+	}
+
+	err = group.Wait()
+	if err != nil {
+		return nil, err
+	}
+	// the vdisk is only partially warmed up, if the given context was cancelled
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	warmer.result.Scanned = int64(len(indices))
+	return &warmer.result, nil
+}
+
+// nonDedupedWarmer is used to warm up
+// the blocks of a single nondeduped vdisk.
+type nonDedupedWarmer struct {
+	storageKeys        []string // storage keys of the vdisk, followed by those of its parents
+	templateStorageKey string
+	cluster            ardb.StorageCluster
+	templateCluster    ardb.StorageCluster
+
+	// counters are updated atomically, as blocks are warmed up in parallel
+	result WarmResult
+}
+
+// warmBlock copies the given block from the template cluster,
+// in case it isn't available in the primary cluster yet.
+func (w *nonDedupedWarmer) warmBlock(blockIndex int64) error {
+	// check if the block (or a masking empty block) is already available
+	cmds := make([]ardb.StorageAction, len(w.storageKeys))
+	for i, key := range w.storageKeys {
+		cmds[i] = ardb.Command(command.HashExists, key, blockIndex)
+	}
+	exists, err := ardb.Bools(w.cluster.DoFor(blockIndex, ardb.Commands(cmds...)))
+	if err != nil {
+		return err
+	}
+	for _, ok := range exists {
+		if ok {
+			atomic.AddInt64(&w.result.Available, 1)
+			return nil
+		}
+	}
+
+	content, err := ardb.OptBytes(w.templateCluster.DoFor(blockIndex,
+		ardb.Command(command.HashGet, w.templateStorageKey, blockIndex)))
+	if err != nil {
+		return err
+	}
+	if content == nil {
+		atomic.AddInt64(&w.result.Missing, 1)
+		return nil
+	}
+
+	// only store the block if it still isn't available,
+	// such that blocks written in the meantime are never overwritten
+	keysAndArgs := make([]interface{}, 0, 2+len(w.storageKeys))
+	keysAndArgs = append(keysAndArgs, blockIndex, content)
+	for _, key := range w.storageKeys {
+		keysAndArgs = append(keysAndArgs, key)
+	}
+	script := ardb.Script(0, warmNonDedupedBlockScriptSource, []string{w.storageKeys[0]}, keysAndArgs...)
+	stored, err := ardb.Bool(w.cluster.DoFor(blockIndex, script))
+	if err != nil {
+		return err
+	}
+	if stored {
+		atomic.AddInt64(&w.result.Warmed, 1)
+	} else {
+		atomic.AddInt64(&w.result.Available, 1)
+	}
+	return nil
+}
+
+// stores a block in the storage key of a nondeduped vdisk (ARGV[3]),
+// only if it isn't available for that vdisk or any of its parents (ARGV[3:])
+const warmNonDedupedBlockScriptSource = `
+local index = ARGV[1]
+local content = ARGV[2]
+
+for i = 3, #ARGV do
+	if redis.call("HEXISTS", ARGV[i], index) == 1 then
+		return 0
+	end
+end
+
+redis.call("HSET", ARGV[3], index, content)
+return 1
+`
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestWarmDedupedVdisk(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	require := require.New(t)
+
+	cluster := redisstub.NewCluster(4, true)
+	defer cluster.Close()
+	templateCluster := redisstub.NewCluster(3, true)
+	defer templateCluster.Close()
+
+	storage, err := Deduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	require.NoError(err)
+	defer storage.Close()
+
+	// blocks 0 and 300 share the same content
+	contents := map[int64][]byte{
+		0: newWarmTestContent(blockSize, 1), 1: newWarmTestContent(blockSize, 2),
+		2: newWarmTestContent(blockSize, 3), 3: newWarmTestContent(blockSize, 4),
+		300: newWarmTestContent(blockSize, 1),
+	}
+	for index, content := range contents {
+		require.NoError(storage.SetBlock(index, content))
+	}
+	require.NoError(storage.Flush())
+
+	// move content of blocks 1 and 2 to the template cluster,
+	// and delete the content of block 3 entirely
+	for index := int64(1); index <= 3; index++ {
+		hash := zerodisk.HashBytes(contents[index])
+		if index < 3 {
+			require.NoError(ardb.Error(templateCluster.DoFor(int64(hash[0]),
+				ardb.Command(command.Set, hash.Bytes(), contents[index]))))
+		}
+		require.NoError(ardb.Error(cluster.DoFor(int64(hash[0]),
+			ardb.Command(command.Delete, hash.Bytes()))))
+	}
+
+	result, err := WarmVdisk(context.Background(), WarmConfig{
+		VdiskID:  vdiskID,
+		Type:     config.VdiskTypeBoot,
+		Rate:     1000,
+		JobCount: 2,
+	}, cluster, templateCluster)
+	require.NoError(err)
+	require.Equal(WarmResult{Scanned: 4, Warmed: 2, Available: 1, Missing: 1}, *result)
+
+	// the warmed up content is available without template cluster
+	for index := int64(0); index <= 2; index++ {
+		content, err := getDedupedContent(zerodisk.HashBytes(contents[index]), cluster)
+		require.NoError(err)
+		require.Equal(contents[index], content)
+	}
+
+	// warming up an already warmed up vdisk doesn't fetch anything
+	result, err = WarmVdisk(context.Background(), WarmConfig{
+		VdiskID: vdiskID,
+		Type:    config.VdiskTypeBoot,
+	}, cluster, templateCluster)
+	require.NoError(err)
+	require.Equal(WarmResult{Scanned: 4, Available: 3, Missing: 1}, *result)
+}
+
+func TestWarmNonDedupedVdisk(t *testing.T) {
+	const (
+		vdiskID         = "a"
+		parentID        = "p"
+		templateVdiskID = "template"
+		blockSize       = 512
+	)
+
+	require := require.New(t)
+
+	cluster := redisstub.NewCluster(4, true)
+	defer cluster.Close()
+	templateCluster := redisstub.NewCluster(3, true)
+	defer templateCluster.Close()
+
+	templateStorage, err := NonDeduped(templateVdiskID, "", blockSize, templateCluster, nil)
+	require.NoError(err)
+	for index := int64(0); index < 4; index++ {
+		require.NoError(templateStorage.SetBlock(index, newWarmTestContent(blockSize, byte(index+1))))
+	}
+
+	// block 1 was already written, and block 2 is available in the parent of the vdisk
+	storage, err := NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	require.NoError(err)
+	written := newWarmTestContent(blockSize, 42)
+	require.NoError(storage.SetBlock(1, written))
+	parentStorage, err := NonDeduped(parentID, "", blockSize, cluster, nil)
+	require.NoError(err)
+	require.NoError(parentStorage.SetBlock(2, newWarmTestContent(blockSize, 43)))
+
+	result, err := WarmVdisk(context.Background(), WarmConfig{
+		VdiskID:         vdiskID,
+		TemplateVdiskID: templateVdiskID,
+		Type:            config.VdiskTypeDB,
+		Lineage:         []string{parentID},
+		Rate:            1000,
+		JobCount:        2,
+	}, cluster, templateCluster)
+	require.NoError(err)
+	require.Equal(WarmResult{Scanned: 4, Warmed: 2, Available: 2}, *result)
+
+	// written blocks are never overwritten
+	for index, expected := range map[int64][]byte{
+		0: newWarmTestContent(blockSize, 1),
+		1: written,
+		3: newWarmTestContent(blockSize, 4),
+	} {
+		content, err := storage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(expected, content)
+	}
+	content, err := storage.GetBlock(2)
+	require.NoError(err)
+	require.Nil(content)
+}
+
+func TestWarmVdiskNoTemplateSupport(t *testing.T) {
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	_, err := WarmVdisk(context.Background(), WarmConfig{
+		VdiskID: "a",
+		Type:    config.VdiskTypeCache,
+	}, cluster, cluster)
+	require.Error(t, err)
+
+	_, err = WarmVdisk(context.Background(), WarmConfig{
+		VdiskID: "a",
+		Type:    config.VdiskTypeBoot,
+	}, cluster, nil)
+	require.Error(t, err)
+}
+
+func newWarmTestContent(size int, seed byte) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i%251) + seed
+	}
+	return content
+}
@@ -73,9 +73,19 @@ type Backend interface {
 	Geometry(ctx context.Context) (Geometry, error)                         // size, minimum BS, preferred BS, maximum BS
 	HasFua(ctx context.Context) bool                                        // does the driver support FUA?
 	HasFlush(ctx context.Context) bool                                      // does the driver support flush?
+	HasMultiConn(ctx context.Context) bool                                  // can the driver be used by multiple connections?
 	GoBackground(ctx context.Context)                                       // optional background thread
 }
 
+// ReleasableBackend is an optional interface which can be implemented by a Backend,
+// in case the backend can be released (e.g. handed off to another server),
+// while connections are still using it.
+// Once the returned channel is closed, the connection is closed,
+// without replying to any requests still in flight.
+type ReleasableBackend interface {
+	Released() <-chan struct{}
+}
+
 // BackendGenerator is a generator function type that generates a backend
 type BackendGenerator func(ctx context.Context, e *ExportConfig) (Backend, error)
 
@@ -627,13 +637,22 @@ func (c *Connection) Serve(parentCtx context.Context) {
 	go c.receive(ctx)
 	go c.reply(ctx)
 
-	// Wait until either we are explicitly killed or one of our
-	// workers dies
+	// a nil channel blocks forever,
+	// which is what we want for a backend that can't be released
+	var releasedCh <-chan struct{}
+	if rb, ok := c.backend.(ReleasableBackend); ok {
+		releasedCh = rb.Released()
+	}
+
+	// Wait until either we are explicitly killed, one of our
+	// workers dies or the backend has been released
 	select {
 	case <-c.killCh:
 		c.logger.Infof("Worker forced close for %s", c.name)
 	case <-ctx.Done():
 		c.logger.Infof("Parent forced close for %s", c.name)
+	case <-releasedCh:
+		c.logger.Infof("Backend released for %s", c.name)
 	}
 }
 
@@ -1111,13 +1130,19 @@ func (c *Connection) connectExport(ctx context.Context, ec *ExportConfig) (*Expo
 		gem.MaximumBlockSize = gem.PreferredBlockSize
 	}
 
-	flags := uint16(NBD_FLAG_HAS_FLAGS | NBD_FLAG_SEND_WRITE_ZEROES | NBD_FLAG_SEND_CLOSE)
+	// NOTE: NBD_FLAG_SEND_CLOSE is no longer sent,
+	// as it was never part of the official protocol,
+	// and it shares its bit with NBD_FLAG_CAN_MULTI_CONN.
+	flags := uint16(NBD_FLAG_HAS_FLAGS | NBD_FLAG_SEND_WRITE_ZEROES)
 	if backend.HasFua(ctx) || forceFua {
 		flags |= NBD_FLAG_SEND_FUA
 	}
 	if backend.HasFlush(ctx) || forceFlush {
 		flags |= NBD_FLAG_SEND_FLUSH
 	}
+	if backend.HasMultiConn(ctx) {
+		flags |= NBD_FLAG_CAN_MULTI_CONN
+	}
 
 	c.logger.Debugf("generating backend %s, using %d flags, for %s", driver, flags, c.name)
 
@@ -69,6 +69,11 @@ func (fb *FileBackend) HasFlush(ctx context.Context) bool {
 	return true
 }
 
+// HasMultiConn implements Backend.HasMultiConn
+func (fb *FileBackend) HasMultiConn(ctx context.Context) bool {
+	return false
+}
+
 // GoBackground implements Backend.GoBackground
 func (fb *FileBackend) GoBackground(ctx context.Context) {
 	// No background thread needed
@@ -34,7 +34,8 @@ const (
 	NBD_FLAG_SEND_TRIM         = uint16(1 << 5)
 	NBD_FLAG_SEND_WRITE_ZEROES = uint16(1 << 6)
 	NBD_FLAG_SEND_DF           = uint16(1 << 7)
-	NBD_FLAG_SEND_CLOSE        = uint16(1 << 8)
+	NBD_FLAG_SEND_CLOSE        = uint16(1 << 8) // experimental, never merged upstream
+	NBD_FLAG_CAN_MULTI_CONN    = uint16(1 << 8)
 )
 
 // NBD magic numbers
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/zero-os/0-Disk/errors"
@@ -35,6 +36,89 @@ type backend struct {
 	closer           Closer
 	vComp            *vdiskCompletion
 	vdiskStatsLogger statistics.VdiskLogger
+
+	// optional callback, called once the vdisk is written to for the first time
+	onFirstWrite   func()
+	firstWriteOnce sync.Once
+
+	// used to serialize the read-modify-write cycle of a block,
+	// as non-overlapping partial writes to the same block
+	// can arrive concurrently via different connections
+	mergeLocks [mergeLockCount]sync.Mutex
+	// used to serialize flushes, coming from any connection
+	flushMux sync.Mutex
+
+	// used to drain all in-flight requests,
+	// when handing off this backend's vdisk to another nbdserver
+	drainMux sync.RWMutex
+	drained  bool
+}
+
+// mergeLockCount defines the amount of locks
+// used to protect the merging of partial block writes.
+const mergeLockCount = 64
+
+// acquire a drain (read) lock for the duration of a request.
+// If the backend has been drained, the request is held back until
+// the given (connection) context is done, and an error is returned.
+// Holding back the request ensures no error is ever replied to the client,
+// as the connection is closed prior to the cancellation of its context.
+func (ab *backend) acquire(ctx context.Context) error {
+	ab.drainMux.RLock()
+	if !ab.drained {
+		return nil
+	}
+	ab.drainMux.RUnlock()
+
+	<-ctx.Done()
+	return errBackendDrained
+}
+
+// release the drain (read) lock acquired for a request.
+func (ab *backend) release() {
+	ab.drainMux.RUnlock()
+}
+
+// drain waits for all in-flight requests to finish,
+// after which all new requests are held back and all data is flushed.
+// The cached indices of the storage (if any) are returned,
+// and are collected prior to flushing, as flushing might clear the cache.
+// In case the flush failed, the backend is undrained.
+func (ab *backend) drain() ([]int64, error) {
+	ab.drainMux.Lock()
+	ab.drained = true
+	ab.drainMux.Unlock()
+
+	var indices []int64
+	if cw, ok := ab.storage.(storage.CacheWarmer); ok {
+		indices = cw.CachedIndices()
+	}
+
+	err := ab.flush()
+	if err != nil {
+		ab.drainMux.Lock()
+		ab.drained = false
+		ab.drainMux.Unlock()
+		return nil, err
+	}
+
+	return indices, nil
+}
+
+// lockBlock locks the merge lock for the given block index,
+// returning the function that has to be called to unlock it again.
+func (ab *backend) lockBlock(blockIndex int64) func() {
+	mux := &ab.mergeLocks[uint64(blockIndex)%mergeLockCount]
+	mux.Lock()
+	return mux.Unlock
+}
+
+// written is called after each successful write,
+// calling the onFirstWrite callback (if any) only after the first one.
+func (ab *backend) written() {
+	if ab.onFirstWrite != nil {
+		ab.firstWriteOnce.Do(ab.onFirstWrite)
+	}
 }
 
 // Closer defines a type which can be closed.
@@ -44,6 +128,11 @@ type Closer interface {
 
 // WriteAt implements nbd.Backend.WriteAt
 func (ab *backend) WriteAt(ctx context.Context, b []byte, offset int64) (bytesWritten int64, err error) {
+	if err = ab.acquire(ctx); err != nil {
+		return
+	}
+	defer ab.release()
+
 	blockIndex := offset / ab.blockSize
 	offsetInsideBlock := offset % ab.blockSize
 
@@ -70,11 +159,17 @@ func (ab *backend) WriteAt(ctx context.Context, b []byte, offset int64) (bytesWr
 
 	bytesWritten = int64(len(b))
 	ab.vdiskStatsLogger.LogWriteOperation(bytesWritten)
+	ab.written()
 	return
 }
 
 // WriteZeroesAt implements nbd.Backend.WriteZeroesAt
 func (ab *backend) WriteZeroesAt(ctx context.Context, offset, length int64) (bytesWritten int64, err error) {
+	if err = ab.acquire(ctx); err != nil {
+		return
+	}
+	defer ab.release()
+
 	blockIndex := offset / ab.blockSize
 	offsetInsideBlock := offset % ab.blockSize
 
@@ -100,6 +195,7 @@ func (ab *backend) WriteZeroesAt(ctx context.Context, offset, length int64) (byt
 
 	bytesWritten = length
 	ab.vdiskStatsLogger.LogWriteOperation(bytesWritten)
+	ab.written()
 	return
 }
 
@@ -107,6 +203,8 @@ func (ab *backend) WriteZeroesAt(ctx context.Context, offset, length int64) (byt
 // or does nothing in case the block does not exist yet.
 // The length + offset should not exceed the blocksize.
 func (ab *backend) mergeZeroes(blockIndex, offset, length int64) error {
+	defer ab.lockBlock(blockIndex)()
+
 	mergedContent, err := ab.storage.GetBlock(blockIndex)
 	if err != nil {
 		return err
@@ -127,6 +225,8 @@ func (ab *backend) mergeZeroes(blockIndex, offset, length int64) error {
 
 // merge a block into an existing block....
 func (ab *backend) merge(blockIndex, offset int64, content []byte) error {
+	defer ab.lockBlock(blockIndex)()
+
 	mergedContent, _ := ab.storage.GetBlock(blockIndex)
 
 	// create old content from scratch or expand it to the blocksize,
@@ -148,6 +248,11 @@ func (ab *backend) merge(blockIndex, offset int64, content []byte) error {
 
 // ReadAt implements nbd.Backend.ReadAt
 func (ab *backend) ReadAt(ctx context.Context, offset, length int64) (payload []byte, err error) {
+	if err = ab.acquire(ctx); err != nil {
+		return
+	}
+	defer ab.release()
+
 	blockIndex := offset / ab.blockSize
 
 	// try to read the payload
@@ -187,11 +292,25 @@ func (ab *backend) TrimAt(ctx context.Context, offset, length int64) (int64, err
 }
 
 // Flush implements nbd.Backend.Flush
+// As the backend is shared between all connections of a vdisk,
+// a flush covers all writes completed by any of those connections.
 func (ab *backend) Flush(ctx context.Context) (err error) {
-	err = ab.storage.Flush()
+	if err = ab.acquire(ctx); err != nil {
+		return
+	}
+	defer ab.release()
+
+	err = ab.flush()
 	return
 }
 
+// flush the storage, serializing it with any other flush.
+func (ab *backend) flush() error {
+	ab.flushMux.Lock()
+	defer ab.flushMux.Unlock()
+	return ab.storage.Flush()
+}
+
 // Close implements nbd.Backend.Close
 func (ab *backend) Close(ctx context.Context) (err error) {
 	ab.vdiskStatsLogger.Close()
@@ -229,6 +348,13 @@ func (ab *backend) HasFlush(ctx context.Context) bool {
 	return true
 }
 
+// HasMultiConn implements nbd.Backend.HasMultiConn
+// Yes, we support multiple connections,
+// as all connections of a vdisk share the same backend.
+func (ab *backend) HasMultiConn(ctx context.Context) bool {
+	return true
+}
+
 // GoBackground implements Backend.GoBackground
 // ensuring that a backend gracefully exists when a SIGTERM signal is received.
 func (ab *backend) GoBackground(ctx context.Context) {
@@ -250,7 +376,7 @@ func (ab *backend) GoBackground(ctx context.Context) {
 		// execute flush
 		done := make(chan error, 1)
 		go func() {
-			done <- ab.storage.Flush()
+			done <- ab.flush()
 		}()
 
 		var err error
@@ -275,3 +401,8 @@ func (ab *backend) GoBackground(ctx context.Context) {
 		log.Debugf("exit from SIGTERM handler for vdisk %s", ab.vdiskID)
 	}
 }
+
+var (
+	// returned for requests which are held back by a drained backend
+	errBackendDrained = errors.New("backend is drained")
+)
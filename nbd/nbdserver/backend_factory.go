@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
@@ -18,6 +21,10 @@ type backendFactoryConfig struct {
 	LBACacheLimit int64         // min-capped to LBA.BytesPerSector
 	ConfigSource  config.Source // config source
 	TlogPrivKey   string        // tlog private key
+	ServerID      string        // ID of this nbdserver, used to claim vdisks
+	ScrubRate     int64         // max content blocks verified per second, 0 disables scrubbing
+	ScrubInterval time.Duration // interval in between scrubbing a deduped vdisk
+	WarmRate      int64         // max content blocks warmed up per second, 0 disables warming up
 }
 
 // Validate all the parameters of this BackendFactoryConfig,
@@ -44,17 +51,30 @@ func newBackendFactory(cfg backendFactoryConfig) (*backendFactory, error) {
 		configSource:  cfg.ConfigSource,
 		vdiskComp:     newVdiskCompletion(),
 		tlogPrivKey:   cfg.TlogPrivKey,
+		serverID:      cfg.ServerID,
+		scrubRate:     cfg.ScrubRate,
+		scrubInterval: cfg.ScrubInterval,
+		warmRate:      cfg.WarmRate,
+		backends:      make(map[string]*sharedBackend),
 	}, nil
 }
 
 // backendFactory holds some variables
 // that can not be passed in the exportconfig like the config source.
 // Its NewBackend method is used as the ardb backend generator.
+// All NBD connections of a vdisk share a single (reference counted) backend.
 type backendFactory struct {
 	lbaCacheLimit int64
 	configSource  config.Source
 	vdiskComp     *vdiskCompletion
 	tlogPrivKey   string
+	serverID      string
+	scrubRate     int64
+	scrubInterval time.Duration
+	warmRate      int64
+
+	backends    map[string]*sharedBackend
+	backendsMux sync.Mutex
 }
 
 type closers []Closer
@@ -71,10 +91,154 @@ func (cs closers) Close() error {
 	return nil
 }
 
-// NewBackend generates a new ardb backend
-func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (backend nbd.Backend, err error) {
+// NewBackend returns the ardb backend for the given export,
+// creating it only if no connection is using that vdisk's backend already.
+// The backend is created without holding the lock of the factory,
+// such that creating (or waiting for the handoff of) one vdisk
+// doesn't stall the connections of other vdisks.
+func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (nbd.Backend, error) {
 	vdiskID := ec.Name
 
+	f.backendsMux.Lock()
+	if sb, ok := f.backends[vdiskID]; ok {
+		sb.refCount++
+		f.backendsMux.Unlock()
+		return f.waitForBackend(ctx, sb)
+	}
+
+	// register the backend prior to creating it,
+	// such that other connections of this vdisk wait for it, rather than creating their own
+	sb := &sharedBackend{
+		vdiskID:  vdiskID,
+		factory:  f,
+		refCount: 1,
+		created:  make(chan struct{}),
+		released: make(chan struct{}),
+	}
+	f.backends[vdiskID] = sb
+	f.backendsMux.Unlock()
+
+	// the context of the shared backend is not bound
+	// to the connection which happens to create it,
+	// as the backend has to live as long as any connection is using it
+	backendCtx, cancel := context.WithCancel(context.Background())
+	var backend *backend
+	var cluster *storage.Cluster
+	var err error
+	if parentID, snapshotID, ok := storage.ParseSnapshotVdiskID(vdiskID); ok {
+		backend, err = f.newSnapshotBackend(backendCtx, parentID, snapshotID)
+	} else {
+		backend, cluster, err = f.newBackend(backendCtx, vdiskID)
+	}
+	if err != nil {
+		cancel()
+		f.backendsMux.Lock()
+		if f.backends[vdiskID] == sb {
+			delete(f.backends, vdiskID)
+		}
+		f.backendsMux.Unlock()
+		sb.createErr = err
+		close(sb.created)
+		return nil, err
+	}
+
+	sb.backend = backend
+	sb.cluster = cluster
+	sb.ctx, sb.cancel = backendCtx, cancel
+	close(sb.created)
+
+	// the background thread is run only once per vdisk
+	go backend.GoBackground(backendCtx)
+
+	return sb, nil
+}
+
+// waitForBackend waits until the given shared backend,
+// to which a reference was already added, is created by another connection.
+func (f *backendFactory) waitForBackend(ctx context.Context, sb *sharedBackend) (nbd.Backend, error) {
+	select {
+	case <-sb.created:
+	case <-ctx.Done():
+		f.backendsMux.Lock()
+		sb.refCount--
+		f.backendsMux.Unlock()
+		return nil, ctx.Err()
+	}
+	if sb.createErr != nil {
+		return nil, sb.createErr
+	}
+
+	f.backendsMux.Lock()
+	refCount := sb.refCount
+	f.backendsMux.Unlock()
+	log.Infof("sharing existing backend for vdisk `%v` (%d connections)", sb.vdiskID, refCount)
+	return sb, nil
+}
+
+// release a reference to the given shared backend,
+// returning true in case it was the last reference.
+func (f *backendFactory) release(sb *sharedBackend) bool {
+	f.backendsMux.Lock()
+	defer f.backendsMux.Unlock()
+
+	sb.refCount--
+	if sb.refCount > 0 {
+		log.Infof("released backend for vdisk `%v` (%d connections left)", sb.vdiskID, sb.refCount)
+		return false
+	}
+
+	// the backend might no longer be registered, in case it was handed off
+	if f.backends[sb.vdiskID] == sb {
+		delete(f.backends, sb.vdiskID)
+	}
+	return true
+}
+
+// Handoff hands off the given vdisk, such that another nbdserver can take over serving it.
+// All in-flight requests are drained, all data is flushed (including the tlog),
+// after which the ownership of the vdisk is released,
+// and all connections of that vdisk are closed.
+// NBD clients are expected to reconnect to the nbdserver taking over.
+func (f *backendFactory) Handoff(vdiskID string) error {
+	f.backendsMux.Lock()
+	sb, ok := f.backends[vdiskID]
+	if ok {
+		// ensure no new connection can start using this backend
+		delete(f.backends, vdiskID)
+	}
+	f.backendsMux.Unlock()
+
+	if !ok {
+		log.Debugf("no backend to hand off for vdisk `%v`", vdiskID)
+		return nil
+	}
+
+	// the backend might still be in the process of being created
+	<-sb.created
+	if sb.createErr != nil {
+		log.Debugf("no backend to hand off for vdisk `%v`, as it couldn't be created", vdiskID)
+		return nil
+	}
+
+	log.Infof("handing off vdisk `%v`", vdiskID)
+	err := sb.handoff()
+	if err != nil {
+		// put the backend back in place, as it can still be used
+		f.backendsMux.Lock()
+		if _, ok := f.backends[vdiskID]; !ok {
+			f.backends[vdiskID] = sb
+		}
+		f.backendsMux.Unlock()
+		return errors.Wrapf(err, "couldn't hand off vdisk %s", vdiskID)
+	}
+
+	log.Infof("handed off vdisk `%v`", vdiskID)
+	return nil
+}
+
+// newBackend creates a new ardb backend for the given vdisk,
+// returning it together with the primary cluster used by that backend.
+func (f *backendFactory) newBackend(ctx context.Context, vdiskID string) (b *backend, primaryCluster *storage.Cluster, err error) {
 	log.Infof("creating new backend for vdisk `%v`", vdiskID)
 
 	// fetch static config
@@ -89,13 +253,23 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 	var resourceCloser closers
 
 	// create primary cluster
-	primaryCluster, err := storage.NewPrimaryCluster(ctx, vdiskID, f.configSource)
+	primaryCluster, err = storage.NewPrimaryCluster(ctx, vdiskID, f.configSource)
 	if err != nil {
 		log.Error(err)
 		return
 	}
 	resourceCloser = append(resourceCloser, primaryCluster)
 
+	// wait until the vdisk is released by the nbdserver which owned it (if any),
+	// such that we don't start serving it while that server might still be flushing
+	ownership, err := storage.WaitForVdiskRelease(
+		ctx, vdiskID, f.serverID, vdiskHandoffTimeout, primaryCluster)
+	if err != nil {
+		resourceCloser.Close()
+		log.Error(err)
+		return
+	}
+
 	// create template cluster if supported by vdisk
 	// NOTE: internal template cluster may be nil, this is OK
 	var templateCluster *storage.Cluster
@@ -109,6 +283,14 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 		resourceCloser = append(resourceCloser, templateCluster)
 	}
 
+	// load the parent vdisks, in case the vdisk is a clone
+	lineage, err := storage.LoadVdiskLineage(vdiskID, primaryCluster)
+	if err != nil {
+		resourceCloser.Close()
+		log.Error(err)
+		return
+	}
+
 	blockStorage, err := storage.NewBlockStorage(
 		storage.BlockStorageConfig{
 			VdiskID:         vdiskID,
@@ -116,6 +298,7 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 			VdiskType:       staticConfig.Type,
 			BlockSize:       blockSize,
 			LBACacheLimit:   f.lbaCacheLimit,
+			Lineage:         lineage,
 		}, primaryCluster, templateCluster)
 	if err != nil {
 		resourceCloser.Close()
@@ -134,7 +317,7 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 			blockStorage.Close()
 			resourceCloser.Close()
 			log.Infof("couldn't vdisk %s's NBD config: %s", vdiskID, err.Error())
-			return nil, err
+			return nil, nil, err
 		}
 		if vdiskNBDConfig.TlogServerClusterID != "" {
 			log.Infof("creating tlogStorage for backend %v (%v)", vdiskID, staticConfig.Type)
@@ -145,23 +328,70 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 				blockStorage.Close()
 				resourceCloser.Close()
 				log.Infof("couldn't create tlog storage: %s", err.Error())
-				return nil, err
+				return nil, nil, err
 			}
 			blockStorage = tlogBlockStorage
 		}
 	}
 
+	// pre-warm the cache, using the indices that were cached by the previous owner
+	if ownership.Released && len(ownership.CachedIndices) > 0 {
+		if cw, ok := blockStorage.(storage.CacheWarmer); ok {
+			log.Infof("pre-warming cache of vdisk `%v` with %d entries", vdiskID, len(ownership.CachedIndices))
+			err = cw.WarmCache(ownership.CachedIndices)
+			if err != nil {
+				// not critical, as the cache will warm up once used
+				log.Errorf("couldn't pre-warm cache of vdisk `%v`: %v", vdiskID, err)
+			}
+		}
+	}
+
+	// claim the vdisk, as we're about to start serving it
+	err = storage.ClaimVdisk(vdiskID, f.serverID, primaryCluster)
+	if err != nil {
+		blockStorage.Close()
+		resourceCloser.Close()
+		log.Infof("couldn't claim vdisk %s: %s", vdiskID, err.Error())
+		return nil, nil, err
+	}
+
 	// create statistics loggers
 	vdiskLogger, err := statistics.NewVdiskLogger(ctx, f.configSource, vdiskID)
 	if err != nil {
 		blockStorage.Close()
 		resourceCloser.Close()
 		log.Infof("couldn't create vdisk logger: %s", err.Error())
-		return nil, err
+		return nil, nil, err
+	}
+
+	// scrub the content of a deduped vdisk in the background, if enabled,
+	// using the slave and template cluster (if any) to repair corrupted content
+	if f.scrubRate > 0 && staticConfig.Type.StorageType() == config.StorageDeduped {
+		slaveCluster, err := storage.NewSlaveCluster(ctx, vdiskID, true, f.configSource)
+		if err != nil {
+			blockStorage.Close()
+			resourceCloser.Close()
+			log.Infof("couldn't create slave cluster: %s", err.Error())
+			return nil, nil, err
+		}
+		resourceCloser = append(resourceCloser, slaveCluster)
+		go f.scrubVdisk(ctx, vdiskID, primaryCluster, slaveCluster, templateCluster)
+	}
+
+	// fetch all content missing in the primary cluster from the template cluster
+	// in the background, if enabled, such that the vdisk no longer depends on it
+	if f.warmRate > 0 && templateCluster != nil {
+		go f.warmVdisk(ctx, storage.WarmConfig{
+			VdiskID:         vdiskID,
+			TemplateVdiskID: staticConfig.TemplateVdiskID,
+			Type:            staticConfig.Type,
+			Lineage:         lineage,
+			Rate:            f.warmRate,
+		}, primaryCluster, templateCluster)
 	}
 
 	// Create the actual ARDB backend
-	backend = newBackend(
+	b = newBackend(
 		vdiskID,
 		staticConfig.Size*uint64(ardb.GibibyteAsBytes),
 		blockSize,
@@ -171,15 +401,250 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 		vdiskLogger,
 	)
 
+	// register the vdisk in the vdisk registry of its primary cluster,
+	// once it is written to for the first time
+	b.onFirstWrite = func() {
+		err := storage.RegisterVdisk(vdiskID, staticConfig.Type, primaryCluster)
+		if err != nil {
+			// not critical, as the registry can always be rebuilt
+			log.Errorf("couldn't register vdisk `%v` in the vdisk registry: %v", vdiskID, err)
+		}
+	}
+
 	return
 }
 
+// newSnapshotBackend creates a new (read-only) ardb backend
+// for the given snapshot of a vdisk.
+// Snapshots have no owner, and are never stored in the tlog.
+func (f *backendFactory) newSnapshotBackend(ctx context.Context, vdiskID, snapshotID string) (*backend, error) {
+	log.Infof("creating new backend for snapshot `%v` of vdisk `%v`", snapshotID, vdiskID)
+
+	// fetch static config of the vdisk the snapshot was taken from
+	staticConfig, err := config.ReadVdiskStaticConfig(f.configSource, vdiskID)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	blockSize := int64(staticConfig.BlockSize)
+	snapshotVdiskID := storage.SnapshotVdiskID(vdiskID, snapshotID)
+
+	// create primary cluster
+	primaryCluster, err := storage.NewPrimaryCluster(ctx, vdiskID, f.configSource)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	exists, err := storage.SnapshotExists(vdiskID, snapshotID, primaryCluster)
+	if err != nil {
+		primaryCluster.Close()
+		log.Error(err)
+		return nil, err
+	}
+	if !exists {
+		primaryCluster.Close()
+		err = errors.Newf("snapshot %s of vdisk %s does not exist", snapshotID, vdiskID)
+		log.Error(err)
+		return nil, err
+	}
+
+	lineage, err := storage.LoadVdiskLineage(snapshotVdiskID, primaryCluster)
+	if err != nil {
+		primaryCluster.Close()
+		log.Error(err)
+		return nil, err
+	}
+
+	blockStorage, err := storage.NewBlockStorage(
+		storage.BlockStorageConfig{
+			VdiskID:       snapshotVdiskID,
+			VdiskType:     staticConfig.Type,
+			BlockSize:     blockSize,
+			LBACacheLimit: f.lbaCacheLimit,
+			Lineage:       lineage,
+		}, primaryCluster, nil)
+	if err != nil {
+		primaryCluster.Close()
+		log.Error(err)
+		return nil, err
+	}
+
+	// the statistics of a snapshot are logged as part of the vdisk
+	vdiskLogger, err := statistics.NewVdiskLogger(ctx, f.configSource, vdiskID)
+	if err != nil {
+		blockStorage.Close()
+		primaryCluster.Close()
+		log.Infof("couldn't create vdisk logger: %s", err.Error())
+		return nil, err
+	}
+
+	return newBackend(
+		snapshotVdiskID,
+		staticConfig.Size*uint64(ardb.GibibyteAsBytes),
+		blockSize,
+		blockStorage,
+		f.vdiskComp,
+		primaryCluster,
+		vdiskLogger,
+	), nil
+}
+
+// scrubVdisk periodically scrubs the content of a deduped vdisk,
+// until the given context is done. The first scrub starts shortly after mounting,
+// spread using a random jitter, such that vdisks mounted at the same time
+// aren't all scrubbed at once.
+func (f *backendFactory) scrubVdisk(ctx context.Context, vdiskID string, cluster, slaveCluster, templateCluster *storage.Cluster) {
+	interval := f.scrubInterval
+	if interval <= 0 {
+		interval = defaultScrubInterval
+	}
+	jitter := maxScrubStartJitter
+	if jitter > interval {
+		jitter = interval
+	}
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(jitter))))
+	defer timer.Stop()
+
+	cfg := storage.ScrubConfig{VdiskID: vdiskID, Rate: f.scrubRate}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			timer.Reset(interval)
+		}
+
+		_, err := storage.ScrubDedupedVdisk(ctx, cfg, cluster, slaveCluster, templateCluster)
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("couldn't scrub vdisk `%v`: %v", vdiskID, err)
+		}
+	}
+}
+
+// warmVdisk warms up a vdisk once, using its template cluster.
+func (f *backendFactory) warmVdisk(ctx context.Context, cfg storage.WarmConfig, cluster, templateCluster *storage.Cluster) {
+	_, err := storage.WarmVdisk(ctx, cfg, cluster, templateCluster)
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	if errors.Cause(err) == storage.ErrClusterNotDefined {
+		log.Debugf("couldn't warm up vdisk `%v`, as it has no template cluster", cfg.VdiskID)
+		return
+	}
+	log.Errorf("couldn't warm up vdisk `%v`: %v", cfg.VdiskID, err)
+}
+
+// sharedBackend is a backend shared between all NBD connections of a vdisk.
+// The backend is only closed once the last connection using it is closed.
+type sharedBackend struct {
+	*backend
+
+	vdiskID  string
+	factory  *backendFactory
+	cluster  *storage.Cluster // primary cluster, used to store the vdisk's ownership (nil for snapshots)
+	refCount int              // protected by factory.backendsMux
+
+	// closed once the backend is created,
+	// only after which the other properties can be used
+	created   chan struct{}
+	createErr error
+
+	released     chan struct{} // closed when the backend is handed off
+	releasedOnce sync.Once
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Close implements nbd.Backend.Close
+// Closing the last connection also releases the vdisk,
+// such that another nbdserver can take over without delay.
+func (sb *sharedBackend) Close(ctx context.Context) error {
+	if !sb.factory.release(sb) {
+		return nil
+	}
+
+	// the ownership is only released if all data could be flushed,
+	// as the primary cluster is closed together with the backend,
+	// this has to happen prior to closing the backend
+	var indices []int64
+	if cw, ok := sb.storage.(storage.CacheWarmer); ok {
+		indices = cw.CachedIndices()
+	}
+	if err := sb.backend.flush(); err != nil {
+		log.Errorf("couldn't flush vdisk `%v`, not releasing it: %v", sb.vdiskID, err)
+	} else {
+		sb.releaseOwnership(indices)
+	}
+
+	sb.cancel()
+	return sb.backend.Close(ctx)
+}
+
+// Released implements nbd.ReleasableBackend.Released
+func (sb *sharedBackend) Released() <-chan struct{} {
+	return sb.released
+}
+
+// handoff drains and flushes the backend,
+// after which it releases the vdisk's ownership,
+// and signals all connections to close.
+func (sb *sharedBackend) handoff() error {
+	indices, err := sb.backend.drain()
+	if err != nil {
+		return err
+	}
+
+	sb.releaseOwnership(indices)
+	close(sb.released)
+	return nil
+}
+
+// releaseOwnership releases the ownership of the vdisk, only once.
+func (sb *sharedBackend) releaseOwnership(indices []int64) {
+	if sb.cluster == nil {
+		return // snapshots are never owned
+	}
+	sb.releasedOnce.Do(func() {
+		err := storage.ReleaseVdisk(sb.vdiskID, sb.factory.serverID, indices, sb.cluster)
+		if err != nil {
+			log.Errorf("couldn't release vdisk `%v`: %v", sb.vdiskID, err)
+		}
+	})
+}
+
+// GoBackground implements nbd.Backend.GoBackground
+// The actual background thread is shared between all connections,
+// and is started when the backend is created,
+// so here we only wait until either the connection or the backend is done.
+func (sb *sharedBackend) GoBackground(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-sb.ctx.Done():
+	}
+}
+
 // StopAndWait stops all vdisk and waits for vdisks completion.
 // It only stop and wait for vdisk which has vdiskCompletion
 // attached.
 // It returns errors from vdisk that exited
 // because of context cancellation.
-func (f backendFactory) StopAndWait() []error {
+func (f *backendFactory) StopAndWait() []error {
 	f.vdiskComp.StopAll()
 	return f.vdiskComp.Wait()
 }
+
+const (
+	// the maximum time we wait for a vdisk to be released
+	// by the nbdserver which owns it, before taking it over
+	vdiskHandoffTimeout = time.Second * 30
+
+	// the default interval in between scrubbing a deduped vdisk
+	defaultScrubInterval = time.Hour * 24
+
+	// the maximum time we wait, after mounting a deduped vdisk,
+	// before scrubbing it for the first time
+	maxScrubStartJitter = time.Minute * 5
+)
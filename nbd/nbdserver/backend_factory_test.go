@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/nbd/gonbdserver/nbd"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestBackendFactorySharedBackend(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	mr := redisstub.NewMemoryRedisSlice(2)
+	defer mr.Close()
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeCache,
+	})
+	clusterCfg := mr.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, "mycluster", &clusterCfg)
+
+	factory, err := newBackendFactory(backendFactoryConfig{ConfigSource: source})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ec := &nbd.ExportConfig{Name: vdiskID}
+
+	backendA, err := factory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+	backendB, err := factory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+
+	// both connections should share the same backend
+	require.True(t, backendA == backendB)
+	assert.True(t, backendA.HasMultiConn(ctx))
+
+	// content written and flushed via one connection,
+	// should be visible via the other connection
+	content := make([]byte, blockSize)
+	for i := range content {
+		content[i] = byte(i % 255)
+	}
+	_, err = backendA.WriteAt(ctx, content[:blockSize/2], 0)
+	require.NoError(t, err)
+	_, err = backendB.WriteAt(ctx, content[blockSize/2:], blockSize/2)
+	require.NoError(t, err)
+	require.NoError(t, backendB.Flush(ctx))
+	payload, err := backendB.ReadAt(ctx, 0, blockSize)
+	require.NoError(t, err)
+	assert.Equal(t, content, payload)
+
+	// closing the first connection should keep the backend alive
+	require.NoError(t, backendA.Close(ctx))
+	payload, err = backendB.ReadAt(ctx, 0, blockSize)
+	require.NoError(t, err)
+	assert.Equal(t, content, payload)
+
+	// closing the last connection should release the backend
+	require.NoError(t, backendB.Close(ctx))
+	factory.backendsMux.Lock()
+	assert.Empty(t, factory.backends)
+	factory.backendsMux.Unlock()
+
+	// a new connection should create a new backend
+	backendC, err := factory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+	assert.False(t, backendA == backendC)
+	require.NoError(t, backendC.Close(ctx))
+}
+
+func TestBackendFactoryConcurrentConnections(t *testing.T) {
+	const (
+		vdiskID     = "a"
+		blockSize   = 512
+		connections = 8
+	)
+
+	mr := redisstub.NewMemoryRedisSlice(2)
+	defer mr.Close()
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeCache,
+	})
+	clusterCfg := mr.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, "mycluster", &clusterCfg)
+
+	factory, err := newBackendFactory(backendFactoryConfig{ConfigSource: source})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ec := &nbd.ExportConfig{Name: vdiskID}
+
+	// connections created at the same time,
+	// should all wait for and share the same backend
+	type result struct {
+		backend nbd.Backend
+		err     error
+	}
+	results := make(chan result, connections)
+	for i := 0; i < connections; i++ {
+		go func() {
+			backend, err := factory.NewBackend(ctx, ec)
+			results <- result{backend, err}
+		}()
+	}
+
+	var backends []nbd.Backend
+	for i := 0; i < connections; i++ {
+		res := <-results
+		require.NoError(t, res.err)
+		backends = append(backends, res.backend)
+	}
+	for _, backend := range backends[1:] {
+		require.True(t, backends[0] == backend)
+	}
+
+	factory.backendsMux.Lock()
+	require.Equal(t, connections, factory.backends[vdiskID].refCount)
+	factory.backendsMux.Unlock()
+
+	for _, backend := range backends {
+		require.NoError(t, backend.Close(ctx))
+	}
+	factory.backendsMux.Lock()
+	assert.Empty(t, factory.backends)
+	factory.backendsMux.Unlock()
+}
+
+func TestBackendFactoryHandoff(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	mr := redisstub.NewMemoryRedisSlice(2)
+	defer mr.Close()
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeBoot,
+	})
+	clusterCfg := mr.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, "mycluster", &clusterCfg)
+
+	sourceFactory, err := newBackendFactory(backendFactoryConfig{
+		ConfigSource: source,
+		ServerID:     "source",
+	})
+	require.NoError(t, err)
+	targetFactory, err := newBackendFactory(backendFactoryConfig{
+		ConfigSource: source,
+		ServerID:     "target",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ec := &nbd.ExportConfig{Name: vdiskID}
+
+	sourceBackend, err := sourceFactory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+
+	content := make([]byte, blockSize)
+	for i := range content {
+		content[i] = byte(i % 255)
+	}
+	_, err = sourceBackend.WriteAt(ctx, content, blockSize*3)
+	require.NoError(t, err)
+
+	// hand off the vdisk, without flushing it explicitly first
+	require.NoError(t, sourceFactory.Handoff(vdiskID))
+	select {
+	case <-sourceBackend.(nbd.ReleasableBackend).Released():
+	default:
+		t.Fatal("source backend wasn't released")
+	}
+
+	// the source backend should hold back any new requests
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = sourceBackend.ReadAt(cancelledCtx, 0, blockSize)
+	assert.Equal(t, errBackendDrained, err)
+
+	// the target should be able to take over immediately
+	targetBackend, err := targetFactory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+	payload, err := targetBackend.ReadAt(ctx, blockSize*3, blockSize)
+	require.NoError(t, err)
+	assert.Equal(t, content, payload)
+
+	// closing the source backend, shouldn't release the target's ownership
+	require.NoError(t, sourceBackend.Close(ctx))
+	ownership, err := storage.LoadVdiskOwnership(vdiskID, targetBackend.(*sharedBackend).cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "target", ownership.Owner)
+	assert.False(t, ownership.Released)
+
+	require.NoError(t, targetBackend.Close(ctx))
+}
+
+func TestBackendFactoryRegisterOnFirstWrite(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	mr := redisstub.NewMemoryRedisSlice(2)
+	defer mr.Close()
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeBoot,
+	})
+	clusterCfg := mr.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, "mycluster", &clusterCfg)
+
+	factory, err := newBackendFactory(backendFactoryConfig{ConfigSource: source})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	vdiskBackend, err := factory.NewBackend(ctx, &nbd.ExportConfig{Name: vdiskID})
+	require.NoError(t, err)
+	defer vdiskBackend.Close(ctx)
+	cluster := vdiskBackend.(*sharedBackend).cluster
+
+	// mounting (and reading) a vdisk doesn't register it
+	_, err = vdiskBackend.ReadAt(ctx, 0, blockSize)
+	require.NoError(t, err)
+	ids, err := storage.ListVdisks(cluster, nil)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	// writing to it for the first time does
+	_, err = vdiskBackend.WriteAt(ctx, make([]byte, blockSize), 0)
+	require.NoError(t, err)
+	ids, err = storage.ListVdisks(cluster, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{vdiskID}, ids)
+}
+
+func TestBackendFactorySnapshot(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	mr := redisstub.NewMemoryRedisSlice(2)
+	defer mr.Close()
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeBoot,
+	})
+	clusterCfg := mr.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, "mycluster", &clusterCfg)
+
+	factory, err := newBackendFactory(backendFactoryConfig{ConfigSource: source})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	vdiskBackend, err := factory.NewBackend(ctx, &nbd.ExportConfig{Name: vdiskID})
+	require.NoError(t, err)
+	defer vdiskBackend.Close(ctx)
+
+	original := make([]byte, blockSize)
+	for i := range original {
+		original[i] = byte(i % 255)
+	}
+	_, err = vdiskBackend.WriteAt(ctx, original, 0)
+	require.NoError(t, err)
+	require.NoError(t, vdiskBackend.Flush(ctx))
+
+	cluster := vdiskBackend.(*sharedBackend).cluster
+	require.NoError(t, storage.CreateSnapshot(vdiskID, "snap", config.VdiskTypeBoot, cluster))
+
+	// modify the vdisk after the snapshot was taken
+	_, err = vdiskBackend.WriteAt(ctx, make([]byte, blockSize/2), 0)
+	require.NoError(t, err)
+	require.NoError(t, vdiskBackend.Flush(ctx))
+
+	// a snapshot which doesn't exist cannot be mounted
+	_, err = factory.NewBackend(ctx, &nbd.ExportConfig{Name: storage.SnapshotVdiskID(vdiskID, "foo")})
+	require.Error(t, err)
+
+	snapshotBackend, err := factory.NewBackend(ctx, &nbd.ExportConfig{Name: storage.SnapshotVdiskID(vdiskID, "snap")})
+	require.NoError(t, err)
+	payload, err := snapshotBackend.ReadAt(ctx, 0, blockSize)
+	require.NoError(t, err)
+	assert.Equal(t, original, payload)
+	require.NoError(t, snapshotBackend.Close(ctx))
+}
@@ -6,14 +6,19 @@ import (
 
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
 	"github.com/zero-os/0-Disk/nbd/gonbdserver/nbd"
 )
 
 // NewExportController creates a new export config manager.
-func NewExportController(ctx context.Context, configSource config.Source, tlsOnly bool, serverID string) (*ExportController, error) {
+// The optional handoff function is called for each vdisk
+// which is removed from this server's vdisks config,
+// such that it can be handed off to the server it is migrated to.
+func NewExportController(ctx context.Context, configSource config.Source, tlsOnly bool, serverID string, handoff func(vdiskID string) error) (*ExportController, error) {
 	exportController := &ExportController{
 		configSource: configSource,
 		tlsOnly:      tlsOnly,
+		handoff:      handoff,
 		done:         make(chan struct{}),
 	}
 
@@ -35,6 +40,7 @@ type ExportController struct {
 	done         chan struct{}
 
 	tlsOnly bool
+	handoff func(vdiskID string) error
 }
 
 // ListConfigNames implements nbd.ExportConfigManager.ListConfigNames
@@ -48,19 +54,31 @@ func (c *ExportController) ListConfigNames() (exports []string) {
 }
 
 // GetConfig implements nbd.ExportConfigManager.GetConfig
+// A vdisk snapshot (`vdiskID@snapshotID`) is exported read-only,
+// using the config of the vdisk it was taken from.
 func (c *ExportController) GetConfig(name string) (*nbd.ExportConfig, error) {
 	log.Infof("Getting vdisk %q", name)
 
-	cfg, err := config.ReadVdiskStaticConfig(c.configSource, name)
+	vdiskID, _, isSnapshot := storage.ParseSnapshotVdiskID(name)
+	if !isSnapshot {
+		vdiskID = name
+	}
+
+	cfg, err := config.ReadVdiskStaticConfig(c.configSource, vdiskID)
 	if err != nil {
 		return nil, err
 	}
 
+	description := cfg.Type.String() + " vdisk"
+	if isSnapshot {
+		description += " snapshot"
+	}
+
 	return &nbd.ExportConfig{
 		Name:               name,
-		Description:        cfg.Type.String() + " vdisk",
+		Description:        description,
 		Driver:             "ardb",
-		ReadOnly:           cfg.ReadOnly,
+		ReadOnly:           cfg.ReadOnly || isSnapshot,
 		TLSOnly:            c.tlsOnly,
 		MinimumBlockSize:   0, // use size given by ArdbBackend.Geometry
 		PreferredBlockSize: 0, // use size given by ArdbBackend.Geometry
@@ -76,8 +94,41 @@ func (c *ExportController) Close() error {
 
 func (c *ExportController) reloadVdisksConfig(cfg config.NBDVdisksConfig) {
 	c.vdisksMux.Lock()
-	defer c.vdisksMux.Unlock()
+	removed := removedVdisks(c.vdisksConfig.Vdisks, cfg.Vdisks)
 	c.vdisksConfig = cfg
+	c.vdisksMux.Unlock()
+
+	if c.handoff == nil {
+		return
+	}
+
+	// hand off all vdisks which are no longer served by this server,
+	// in the background, as draining a vdisk can take a while
+	for _, vdiskID := range removed {
+		vdiskID := vdiskID
+		go func() {
+			err := c.handoff(vdiskID)
+			if err != nil {
+				log.Errorf("couldn't hand off vdisk %s: %v", vdiskID, err)
+			}
+		}()
+	}
+}
+
+// removedVdisks returns all vdisks which are listed in a, but not in b.
+func removedVdisks(a, b []string) []string {
+	set := make(map[string]struct{}, len(b))
+	for _, vdiskID := range b {
+		set[vdiskID] = struct{}{}
+	}
+
+	var removed []string
+	for _, vdiskID := range a {
+		if _, ok := set[vdiskID]; !ok {
+			removed = append(removed, vdiskID)
+		}
+	}
+	return removed
 }
 
 func (c *ExportController) spawnBackground(ctx context.Context, serverID string) error {
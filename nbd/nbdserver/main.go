@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	_ "net/http/pprof"
 
@@ -32,6 +33,9 @@ func main() {
 	var logPath string
 	var serverID string
 	var tlogPrivKey string
+	var scrubRate int64
+	var scrubInterval time.Duration
+	var warmRate int64
 
 	flag.BoolVar(&verbose, "v", false, "when false, only log warnings and errors")
 	flag.StringVar(&logPath, "logfile", "", "optionally log to the specified file, instead of the stderr")
@@ -42,9 +46,15 @@ func main() {
 	flag.Var(&sourceConfig, "config", "config resource: dialstrings (etcd cluster) or path (yaml file)")
 	flag.Int64Var(&lbacachelimit, "lbacachelimit", ardb.DefaultLBACacheLimit,
 		fmt.Sprintf("Cache limit of LBA in bytes, needs to be higher then %d (bytes in 1 sector)", lba.BytesPerSector))
-	flag.StringVar(&serverID, "id", "default", "The server ID (default: default)")
+	flag.StringVar(&serverID, "id", defaultServerID, "The server ID (default: default)")
 	flag.BoolVar(&version, "version", false, "prints build version and exits")
 	flag.StringVar(&tlogPrivKey, "tlog-priv-key", "", "32 bytes tlog private key")
+	flag.Int64Var(&scrubRate, "scrub-rate", 0,
+		"Max amount of content blocks of deduped vdisks to verify per second, 0 disables scrubbing")
+	flag.DurationVar(&scrubInterval, "scrub-interval", defaultScrubInterval,
+		"Interval in between scrubbing the content of a mounted deduped vdisk")
+	flag.Int64Var(&warmRate, "warm-rate", 0,
+		"Max amount of content blocks per second to check when warming up a mounted vdisk using its template cluster, 0 disables warming up")
 
 	flag.Parse()
 
@@ -72,7 +82,7 @@ func main() {
 
 	zerodisk.LogVersion()
 
-	log.Debugf("flags parsed: tlsonly=%t profileaddress=%q protocol=%q address=%q config=%q lbacachelimit=%d logfile=%q id=%q",
+	log.Debugf("flags parsed: tlsonly=%t profileaddress=%q protocol=%q address=%q config=%q lbacachelimit=%d logfile=%q id=%q scrubrate=%d scrubinterval=%v warmrate=%d",
 		tlsonly,
 		profileAddress,
 		protocol, address,
@@ -80,6 +90,9 @@ func main() {
 		lbacachelimit,
 		logPath,
 		serverID,
+		scrubRate,
+		scrubInterval,
+		warmRate,
 	)
 
 	// let's create the source and defer close it
@@ -125,6 +138,10 @@ func main() {
 		ConfigSource:  configSource,
 		LBACacheLimit: lbacachelimit,
 		TlogPrivKey:   tlogPrivKey,
+		ServerID:      vdiskOwnerID(serverID, protocol, address),
+		ScrubRate:     scrubRate,
+		ScrubInterval: scrubInterval,
+		WarmRate:      warmRate,
 	})
 	handleSigterm(backendFactory, cancelFunc)
 
@@ -145,6 +162,7 @@ func main() {
 		configSource,
 		tlsonly,
 		serverID,
+		backendFactory.Handoff,
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -207,3 +225,22 @@ func init() {
 		flag.PrintDefaults()
 	}
 }
+
+// vdiskOwnerID returns the ID used to claim the ownership of vdisks,
+// which is the server ID, unless the default server ID is used,
+// in which case the hostname and listen address are used instead,
+// as the default server ID is shared by all nbdservers which don't specify one.
+func vdiskOwnerID(serverID, protocol, address string) string {
+	if serverID != defaultServerID {
+		return serverID
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Errorf("couldn't get hostname to identify this nbdserver: %v", err)
+	}
+	ownerID := hostname + "/" + protocol + "://" + address
+	log.Infof("claiming vdisks as %q, as no server ID was specified", ownerID)
+	return ownerID
+}
+
+const defaultServerID = "default"
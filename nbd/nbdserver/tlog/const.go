@@ -9,4 +9,9 @@ const (
 	FlushWaitRetry = time.Minute
 	// FlushWaitRetryNum defines the number of flush retries
 	FlushWaitRetryNum = 4
+
+	// FlushMarkerName is the name of the tlog marker
+	// inserted at the latest sequence each time a tlog storage is flushed,
+	// such that a vdisk can be restored to the last state its user flushed.
+	FlushMarkerName = "flush"
 )
@@ -57,7 +57,7 @@ func Storage(ctx context.Context, vdiskID, tlogPrivKey string, configSource conf
 
 	if client == nil {
 		log.Infof("creating tlogclient for vdisk `%v`", vdiskID)
-		client, err = tlogclient.New(tlogClusterConfig.Servers, vdiskID)
+		client, err = newTlogClient(tlogClusterConfig, vdiskID)
 		if err != nil {
 			cancel()
 			return nil, errors.Wrap(err, "tlogStorage requires valid tlogclient")
@@ -79,6 +79,7 @@ func Storage(ctx context.Context, vdiskID, tlogPrivKey string, configSource conf
 	}
 
 	tlogStorage.sequence = client.LastFlushedSequence() + 1
+	tlogStorage.lastMarkedSequence = client.LastFlushedSequence()
 	tlogStorage.tlogReady = client.Ready()
 
 	err = tlogStorage.spawnBackgroundGoroutine(ctx)
@@ -146,6 +147,10 @@ type tlogStorage struct {
 	tlogReady        bool
 	tlogNotReadyBuff []writeOp
 	cancel           context.CancelFunc
+
+	// last sequence marked with the flush marker,
+	// sequences flushed before this storage was used aren't marked
+	lastMarkedSequence uint64
 }
 
 type transaction struct {
@@ -264,6 +269,28 @@ func (tls *tlogStorage) DeleteBlock(blockIndex int64) (err error) {
 	return
 }
 
+// CachedIndices implements storage.CacheWarmer.CachedIndices
+func (tls *tlogStorage) CachedIndices() []int64 {
+	tls.storageMux.Lock()
+	defer tls.storageMux.Unlock()
+
+	if cw, ok := tls.storage.(storage.CacheWarmer); ok {
+		return cw.CachedIndices()
+	}
+	return nil
+}
+
+// WarmCache implements storage.CacheWarmer.WarmCache
+func (tls *tlogStorage) WarmCache(indices []int64) error {
+	tls.storageMux.Lock()
+	defer tls.storageMux.Unlock()
+
+	if cw, ok := tls.storage.(storage.CacheWarmer); ok {
+		return cw.WarmCache(indices)
+	}
+	return nil
+}
+
 // Flush implements BlockStorage.Flush
 func (tls *tlogStorage) Flush() error {
 	tls.mux.Lock()
@@ -291,8 +318,9 @@ func (tls *tlogStorage) Flush() error {
 
 	defer tls.mux.Unlock()
 
-	// ForceFlush at the latest sequence
-	tls.tlog.ForceFlushAtSeq(tls.getLatestSequence())
+	// ForceFlush at the latest sequence,
+	// marking it as flushed by the user of this storage
+	tls.forceFlushAndMark(tls.getLatestSequence())
 
 	// wait until the cache is empty or timeout
 	doneCh := make(chan struct{})
@@ -387,6 +415,7 @@ func (tls *tlogStorage) waitTlogServerReady() {
 	tls.sequenceMux.Lock()
 	tls.sequence = tls.tlog.LastFlushedSequence() + 1
 	tls.sequenceMux.Unlock()
+	tls.lastMarkedSequence = tls.tlog.LastFlushedSequence()
 
 	// resume all helds IO
 	for _, op := range tls.tlogNotReadyBuff {
@@ -450,6 +479,9 @@ func (tls *tlogStorage) spawnBackgroundGoroutine(parentCtx context.Context) erro
 				case tlog.BlockStatusRecvOK:
 					// nothing to do, logging would be way too verbose
 
+				case tlog.BlockStatusRecvBusy:
+					// the tlog client resends the block, logging would be way too verbose
+
 				case tlog.BlockStatusForceFlushReceived:
 					log.Debugf("vdisk %s's tlog server has received force flush message", tls.vdiskID)
 
@@ -800,6 +832,25 @@ func (tls *tlogStorage) getNextSequenceIndex() (sequence uint64) {
 	return
 }
 
+// force flush at the given sequence, inserting the flush marker at that sequence,
+// unless that sequence is already marked or no sequence was written yet.
+// The mutex has to be locked by the caller.
+func (tls *tlogStorage) forceFlushAndMark(seq uint64) {
+	if seq <= tls.lastMarkedSequence {
+		tls.tlog.ForceFlushAtSeq(seq)
+		return
+	}
+
+	err := tls.tlog.InsertMarker(FlushMarkerName, seq)
+	if err != nil {
+		log.Errorf("couldn't insert flush marker at sequence %d for vdisk %s: %v",
+			seq, tls.vdiskID, err)
+		tls.tlog.ForceFlushAtSeq(seq)
+		return
+	}
+	tls.lastMarkedSequence = seq
+}
+
 // get the latest transaction sequence
 func (tls *tlogStorage) getLatestSequence() (sequence uint64) {
 	tls.sequenceMux.Lock()
@@ -807,14 +858,32 @@ func (tls *tlogStorage) getLatestSequence() (sequence uint64) {
 	return tls.sequence - 1
 }
 
+// newTlogClient creates a tlog client for the given tlog cluster,
+// streaming to both servers at once in dual-write mode.
+func newTlogClient(cfg *config.TlogClusterConfig, vdiskID string) (tlogClient, error) {
+	if !cfg.DualWrite {
+		return tlogclient.New(cfg.Servers, vdiskID)
+	}
+
+	quorum := cfg.WriteQuorum
+	if quorum == 0 {
+		quorum = 1
+	}
+	log.Infof("tlogclient for vdisk `%v` writes to %v with write quorum %d",
+		vdiskID, cfg.Servers, quorum)
+	return tlogclient.NewMulti(cfg.Servers, vdiskID, quorum)
+}
+
 // tlogClient represents the tlog client interface used
-// by the tlogStorage, usually filled by (*tlogclient.Client)
+// by the tlogStorage, usually filled by (*tlogclient.Client),
+// or by (*tlogclient.MultiClient) in dual-write mode
 //
 // using an interface makes it possible to use a dummy version
 // for testing purposes
 type tlogClient interface {
 	Send(op uint8, seq uint64, index int64, timestamp int64, data []byte) error
 	ForceFlushAtSeq(uint64) error
+	InsertMarker(name string, seq uint64) error
 	WaitNbdSlaveSync() error
 	ChangeServerAddresses([]string)
 	Recv() <-chan *tlogclient.Result
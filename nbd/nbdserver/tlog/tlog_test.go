@@ -1071,6 +1071,10 @@ func (stls *stubTlogClient) ForceFlushAtSeq(uint64) error {
 	return nil
 }
 
+func (stls *stubTlogClient) InsertMarker(name string, seq uint64) error {
+	return nil
+}
+
 func (stls *stubTlogClient) WaitNbdSlaveSync() error {
 	return nil
 }
@@ -1104,3 +1108,56 @@ func (stls *stubTlogClient) Ready() bool {
 
 func (stls *stubTlogClient) WaitReady() {
 }
+
+// Test that flushing a tlog storage marks the latest sequence,
+// such that the vdisk can be restored to the state flushed by its user.
+func TestTlogStorageFlushMarker(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 8
+	)
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	testConf := &server.Config{
+		ListenAddr: "",
+		FlushSize:  25,
+		FlushTime:  25,
+		PrivKey:    "12345678901234567890123456789012",
+	}
+	configSource, storConf, cleanup := newZeroStorConfig(t, vdiskID, testConf, 4, 2)
+	defer cleanup()
+
+	s, err := server.NewServer(testConf, configSource)
+	require.NoError(t, err)
+	go s.Listen(ctx)
+
+	source := config.NewStubSource()
+	source.SetPrimaryStorageCluster(vdiskID, "nbdCluster", nil)
+	source.SetTlogServerCluster(vdiskID, "tlogcluster", &config.TlogClusterConfig{
+		Servers: []string{s.ListenAddr()},
+	})
+	defer source.Close()
+
+	blockStorage, err := Storage(
+		ctx, vdiskID, "", source, blockSize,
+		storage.NewInMemoryStorage(vdiskID, blockSize), ardb.NopCluster{}, nil)
+	require.NoError(t, err)
+	defer blockStorage.Close()
+
+	for _, numBlocks := range []int64{3, 2} {
+		for i := int64(0); i < numBlocks; i++ {
+			require.NoError(t, blockStorage.SetBlock(i, []byte{4, 2}))
+		}
+		require.NoError(t, blockStorage.Flush())
+
+		// the marker is flushed together with its sequence
+		latestSeq := blockStorage.(*tlogStorage).getLatestSequence()
+		storCli, err := stor.NewClient(storConf)
+		require.NoError(t, err)
+		marker, err := storCli.FindMarker(FlushMarkerName)
+		storCli.Close()
+		require.NoError(t, err)
+		require.Equal(t, latestSeq, marker.Sequence)
+	}
+}
@@ -26,6 +26,10 @@ type Aggregation struct {
 	lastSequence uint64
 	size         int
 	maxBlockNum  int
+	markers      []Marker
+
+	// data encoding used when encoding this aggregation
+	dedup, delta bool
 }
 
 // NewAggregation creates an aggregation with given capnp buffer
@@ -62,6 +66,11 @@ func (a *Aggregation) Full() bool {
 	return a.size == a.maxBlockNum
 }
 
+// Size returns the number of blocks in this aggregation
+func (a *Aggregation) Size() int {
+	return a.size
+}
+
 // LastSequence returns last sequence in this aggregation
 func (a *Aggregation) LastSequence() uint64 {
 	return a.lastSequence
@@ -118,6 +127,32 @@ func (a *Aggregation) AddBlock(block *schema.TlogBlock) error {
 	return nil
 }
 
+// AddMarker adds a marker to this aggregation,
+// the marker is stored as part of this aggregation when it gets encoded.
+func (a *Aggregation) AddMarker(marker Marker) {
+	a.markers = append(a.markers, marker)
+}
+
+// SetMarkers replaces all markers of this aggregation,
+// the markers are stored as part of this aggregation when it gets encoded.
+func (a *Aggregation) SetMarkers(markers []Marker) {
+	a.markers = markers
+}
+
+// Markers returns all markers added to this aggregation
+func (a *Aggregation) Markers() []Marker {
+	return a.markers
+}
+
+// SetDataEncoding defines how the data of the blocks is stored,
+// when this aggregation gets encoded.
+// When dedup is true, data identical to the data of a previous block is only stored once.
+// When delta is true, data is stored as a delta against the previous version
+// of the same block, in case that delta is small enough.
+func (a *Aggregation) SetDataEncoding(dedup, delta bool) {
+	a.dedup, a.delta = dedup, delta
+}
+
 // IgnoreSeqBefore ignore all blocks with sequence before the given
 // sequence
 func (a *Aggregation) IgnoreSeqBefore(seq uint64) error {
@@ -155,10 +190,95 @@ func (a *Aggregation) SetTimestamp(timestamp int64) {
 // Encode encodes this aggregation to byte slice
 func (a *Aggregation) Encode() ([]byte, error) {
 	a.agg.SetSize(uint64(a.size))
+	if err := a.encodeMarkers(); err != nil {
+		return nil, err
+	}
+
+	msg := a.msg
+	if a.dedup || a.delta {
+		var err error
+		msg, err = a.encodeBlockData()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	buf := new(bytes.Buffer)
-	err := capnp.NewEncoder(buf).Encode(a.msg)
+	err := capnp.NewEncoder(buf).Encode(msg)
 	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
+
+// encodeBlockData creates a new capnp message containing this aggregation,
+// only containing its blocks, which data is deduplicated and/or delta-encoded.
+func (a *Aggregation) encodeBlockData() (*capnp.Message, error) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+	agg, err := schema.NewRootTlogAggregation(seg)
+	if err != nil {
+		return nil, err
+	}
+	err = copyAggregationHeader(&agg, &a.agg, a.size)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := agg.NewBlocks(int32(a.size))
+	if err != nil {
+		return nil, err
+	}
+	enc := newBlockDataEncoder(a.dedup, a.delta)
+	for i := 0; i < a.size; i++ {
+		src, dst := a.blockList.At(i), blocks.At(i)
+		err = copyBlockHeader(&dst, &src)
+		if err != nil {
+			return nil, err
+		}
+		err = enc.encode(&dst, &src)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
+
+// encodeMarkers sets the markers of this aggregation in its capnp message
+func (a *Aggregation) encodeMarkers() error {
+	if len(a.markers) == 0 {
+		return nil
+	}
+	list, err := a.agg.NewMarkers(int32(len(a.markers)))
+	if err != nil {
+		return err
+	}
+	for i, marker := range a.markers {
+		m := list.At(i)
+		if err := m.SetName(marker.Name); err != nil {
+			return err
+		}
+		m.SetSequence(marker.Sequence)
+		m.SetTimestamp(marker.Timestamp)
+	}
+	return nil
+}
+
+// AggregationBlocks returns the block list of the given aggregation,
+// and the amount of blocks it contains.
+// The block list can be bigger than the amount of blocks it contains,
+// in case the aggregation was flushed before it was full.
+func AggregationBlocks(agg *schema.TlogAggregation) (schema.TlogBlock_List, int, error) {
+	blocks, err := agg.Blocks()
+	if err != nil {
+		return blocks, 0, err
+	}
+	size := int(agg.Size())
+	if blocks.Len() < size {
+		size = blocks.Len()
+	}
+	return blocks, size, nil
+}
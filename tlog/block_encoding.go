@@ -0,0 +1,248 @@
+package tlog
+
+import (
+	"bytes"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"zombiezen.com/go/capnproto2"
+)
+
+// deltaMaxRatio defines the max size of delta-encoded data,
+// relative to the size of the data itself,
+// data which has a bigger delta is stored as is.
+const deltaMaxRatio = 0.5
+
+// blockDataEncoder encodes the data of the blocks of a single aggregation,
+// deduplicating identical data and/or delta-encoding data
+// against the previous version of the same block.
+type blockDataEncoder struct {
+	dedup, delta bool
+
+	// position of the first block with a given hash
+	hashes map[string]int
+	// position of the last set operation of a given block index
+	versions map[int64]int
+	// (original) data of all blocks encoded so far
+	data [][]byte
+}
+
+func newBlockDataEncoder(dedup, delta bool) *blockDataEncoder {
+	return &blockDataEncoder{
+		dedup:    dedup,
+		delta:    delta,
+		hashes:   make(map[string]int),
+		versions: make(map[int64]int),
+	}
+}
+
+// encode the data of the given block,
+// which is the next block of the aggregation, into dst.
+func (enc *blockDataEncoder) encode(dst, src *schema.TlogBlock) error {
+	data, err := src.Data()
+	if err != nil {
+		return err
+	}
+	pos := len(enc.data)
+	enc.data = append(enc.data, data)
+
+	if src.Operation() != schema.OpSet {
+		delete(enc.versions, src.Index())
+		dst.SetEncoding(schema.EncodingRaw)
+		return dst.SetData(data)
+	}
+
+	// the previous version of this block is replaced by this one
+	ref, hasVersion := enc.versions[src.Index()]
+	enc.versions[src.Index()] = pos
+
+	if enc.dedup {
+		hash, err := src.Hash()
+		if err != nil {
+			return err
+		}
+		if len(hash) > 0 {
+			if ref, ok := enc.hashes[string(hash)]; ok && bytes.Equal(enc.data[ref], data) {
+				dst.SetEncoding(schema.EncodingDedup)
+				dst.SetRef(uint32(ref))
+				return nil
+			}
+			enc.hashes[string(hash)] = pos
+		}
+	}
+
+	if enc.delta && hasVersion {
+		delta := encodeDelta(enc.data[ref], data)
+		if float64(len(delta)) < float64(len(data))*deltaMaxRatio {
+			dst.SetEncoding(schema.EncodingDelta)
+			dst.SetRef(uint32(ref))
+			return dst.SetData(delta)
+		}
+	}
+
+	dst.SetEncoding(schema.EncodingRaw)
+	return dst.SetData(data)
+}
+
+// blockDataDecoder decodes the data of the blocks of a single aggregation,
+// resolving deduplicated and delta-encoded data.
+type blockDataDecoder struct {
+	blocks schema.TlogBlock_List
+	// data of delta-encoded blocks decoded so far
+	decoded map[int][]byte
+}
+
+func newBlockDataDecoder(blocks schema.TlogBlock_List) *blockDataDecoder {
+	return &blockDataDecoder{
+		blocks:  blocks,
+		decoded: make(map[int][]byte),
+	}
+}
+
+// data returns the (decoded) data of the block at the given position.
+func (dec *blockDataDecoder) data(pos int) ([]byte, error) {
+	block := dec.blocks.At(pos)
+
+	encoding := block.Encoding()
+	if encoding == schema.EncodingRaw {
+		return block.Data()
+	}
+
+	// encoded data can only refer to a previous block
+	ref := int(block.Ref())
+	if ref >= pos {
+		return nil, errors.Newf(
+			"block %d of aggregation refers to block %d which isn't a previous block", pos, ref)
+	}
+
+	switch encoding {
+	case schema.EncodingDedup:
+		return dec.data(ref)
+
+	case schema.EncodingDelta:
+		if data, ok := dec.decoded[pos]; ok {
+			return data, nil
+		}
+		base, err := dec.data(ref)
+		if err != nil {
+			return nil, err
+		}
+		delta, err := block.Data()
+		if err != nil {
+			return nil, err
+		}
+		data, err := decodeDelta(base, delta)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't decode block %d of aggregation", pos)
+		}
+		dec.decoded[pos] = data
+		return data, nil
+
+	default:
+		return nil, errors.Newf("block %d of aggregation has unknown encoding %d", pos, encoding)
+	}
+}
+
+// DecodeAggregation returns the given aggregation,
+// with the data of all its blocks decoded, such that it can be used as is.
+// The given aggregation is returned in case none of its blocks is encoded,
+// otherwise a decoded copy of it is returned.
+func DecodeAggregation(agg *schema.TlogAggregation) (*schema.TlogAggregation, error) {
+	blocks, size, err := AggregationBlocks(agg)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := false
+	for i := 0; i < size; i++ {
+		if blocks.At(i).Encoding() != schema.EncodingRaw {
+			encoded = true
+			break
+		}
+	}
+	if !encoded {
+		return agg, nil
+	}
+
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+	out, err := schema.NewRootTlogAggregation(seg)
+	if err != nil {
+		return nil, err
+	}
+	err = copyAggregationHeader(&out, agg, size)
+	if err != nil {
+		return nil, err
+	}
+
+	outBlocks, err := out.NewBlocks(int32(size))
+	if err != nil {
+		return nil, err
+	}
+	dec := newBlockDataDecoder(blocks)
+	for i := 0; i < size; i++ {
+		src, dst := blocks.At(i), outBlocks.At(i)
+		err = copyBlockHeader(&dst, &src)
+		if err != nil {
+			return nil, err
+		}
+		data, err := dec.data(i)
+		if err != nil {
+			return nil, err
+		}
+		err = dst.SetData(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &out, nil
+}
+
+// copyAggregationHeader copies all properties except the blocks
+// of aggregation 'src' to 'dst', using the given size.
+func copyAggregationHeader(dst, src *schema.TlogAggregation, size int) error {
+	dst.SetSize(uint64(size))
+	dst.SetTimestamp(src.Timestamp())
+	if src.HasPrev() {
+		prev, err := src.Prev()
+		if err != nil {
+			return err
+		}
+		err = dst.SetPrev(prev)
+		if err != nil {
+			return err
+		}
+	}
+	if src.HasMarkers() {
+		markers, err := src.Markers()
+		if err != nil {
+			return err
+		}
+		err = dst.SetMarkers(markers)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBlockHeader copies all properties except the (encoded) data
+// of block 'src' to 'dst'.
+func copyBlockHeader(dst, src *schema.TlogBlock) error {
+	dst.SetSequence(src.Sequence())
+	dst.SetIndex(src.Index())
+	hash, err := src.Hash()
+	if err != nil {
+		return err
+	}
+	err = dst.SetHash(hash)
+	if err != nil {
+		return err
+	}
+	dst.SetTimestamp(src.Timestamp())
+	dst.SetOperation(src.Operation())
+	return nil
+}
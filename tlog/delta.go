@@ -0,0 +1,105 @@
+package tlog
+
+import (
+	"encoding/binary"
+
+	"github.com/zero-os/0-Disk/errors"
+)
+
+var (
+	// ErrInvalidDelta is returned in case delta-encoded data can't be decoded
+	ErrInvalidDelta = errors.New("invalid delta-encoded data")
+)
+
+// deltaMinGap is the minimum amount of unchanged bytes,
+// required to split a changed range in 2,
+// as each range has an overhead of (at least) 2 bytes.
+const deltaMinGap = 8
+
+// encodeDelta encodes data as a delta against the given base.
+// Bytes after the end of the base are compared against 0.
+//
+// The delta is encoded as the (uvarint) length of the data,
+// followed by all changed ranges, each encoded as the (uvarint) amount of
+// unchanged bytes since the previous range, the (uvarint) length of the range,
+// and the changed bytes.
+func encodeDelta(base, data []byte) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	delta := make([]byte, 0, len(data)/4)
+	putUvarint := func(v int) {
+		n := binary.PutUvarint(buf, uint64(v))
+		delta = append(delta, buf[:n]...)
+	}
+
+	putUvarint(len(data))
+
+	// end of the previous range
+	var prev int
+	for i := 0; i < len(data); {
+		if data[i] == byteAt(base, i) {
+			i++
+			continue
+		}
+
+		// extend the range as long as the gap
+		// between 2 changed bytes is small enough
+		start, end := i, i+1
+		for j := end; j < len(data) && j-end < deltaMinGap; j++ {
+			if data[j] != byteAt(base, j) {
+				end = j + 1
+			}
+		}
+
+		putUvarint(start - prev)
+		putUvarint(end - start)
+		delta = append(delta, data[start:end]...)
+		prev, i = end, end
+	}
+
+	return delta
+}
+
+// decodeDelta decodes data, delta-encoded against the given base.
+func decodeDelta(base, delta []byte) ([]byte, error) {
+	size, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, ErrInvalidDelta
+	}
+	delta = delta[n:]
+
+	data := make([]byte, size)
+	copy(data, base)
+
+	var pos uint64
+	for len(delta) > 0 {
+		skip, n := binary.Uvarint(delta)
+		if n <= 0 {
+			return nil, ErrInvalidDelta
+		}
+		delta = delta[n:]
+		length, n := binary.Uvarint(delta)
+		if n <= 0 {
+			return nil, ErrInvalidDelta
+		}
+		delta = delta[n:]
+
+		pos += skip
+		if pos+length > size || length > uint64(len(delta)) {
+			return nil, ErrInvalidDelta
+		}
+		copy(data[pos:], delta[:length])
+		delta = delta[length:]
+		pos += length
+	}
+
+	return data, nil
+}
+
+// byteAt returns the byte at the given index,
+// or 0 in case the index is out of range.
+func byteAt(data []byte, index int) byte {
+	if index < len(data) {
+		return data[index]
+	}
+	return 0
+}
@@ -21,6 +21,12 @@ type Flusher struct {
 	storCli   *stor.Client
 	curAgg    *tlog.Aggregation
 	capnpBuf  []byte
+
+	// markers which are not flushed yet
+	markers []tlog.Marker
+
+	// data encoding of the flushed aggregations
+	dedup, delta bool
 }
 
 // New creates a new flusher
@@ -58,6 +64,19 @@ func NewWithStorClient(storCli *stor.Client, flushSize, blockSize int) *Flusher
 	}
 }
 
+// SetDataEncoding defines how the data of the blocks is stored
+// in the flushed aggregations, see tlog.Aggregation.SetDataEncoding.
+// By default all data is stored as is, neither deduplicated nor delta-encoded.
+func (f *Flusher) SetDataEncoding(dedup, delta bool) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.dedup, f.delta = dedup, delta
+	if f.curAgg != nil {
+		f.curAgg.SetDataEncoding(dedup, delta)
+	}
+}
+
 // AddTransaction add transaction to this flusher
 func (f *Flusher) AddTransaction(t tlog.Transaction) error {
 	f.mux.Lock()
@@ -88,11 +107,40 @@ func (f *Flusher) AddBlock(block *schema.TlogBlock) error {
 	return f.curAgg.AddBlock(block)
 }
 
+// AddMarker adds a marker to this flusher,
+// it is flushed as part of the first aggregation
+// which contains the marker's sequence or any sequence after it,
+// or as part of an aggregation without blocks,
+// in case the marker's sequence was already flushed.
+func (f *Flusher) AddMarker(marker tlog.Marker) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.markers = append(f.markers, marker)
+}
+
+// HasFlushedMarkers returns true if the flusher has markers
+// which sequence was already flushed, such that they can be
+// flushed without any blocks.
+func (f *Flusher) HasFlushedMarkers() bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	lastSeq := f.storCli.LastSequence()
+	for _, marker := range f.markers {
+		if marker.Sequence <= lastSeq {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *Flusher) initAggregation() error {
 	agg, err := tlog.NewAggregation(f.capnpBuf, f.flushSize)
 	if err != nil {
 		return err
 	}
+	agg.SetDataEncoding(f.dedup, f.delta)
 	f.curAgg = agg
 	return nil
 }
@@ -113,6 +161,14 @@ func (f *Flusher) Empty() bool {
 	return f.curAgg.Empty()
 }
 
+// Size returns the number of blocks in the flusher's aggregation
+func (f *Flusher) Size() int {
+	if f.curAgg == nil {
+		return 0
+	}
+	return f.curAgg.Size()
+}
+
 // IgnoreSeqBefore ignores all blocks with sequence less than
 // the given sequence
 func (f *Flusher) IgnoreSeqBefore(seq uint64) error {
@@ -131,14 +187,44 @@ func (f *Flusher) Flush() ([]byte, []uint64, error) {
 }
 
 func (f *Flusher) flush() ([]byte, []uint64, error) {
+	// the last sequence covered by the flushed aggregation,
+	// which is the last flushed sequence for an empty aggregation
+	lastSeq := f.storCli.LastSequence()
+	if f.curAgg != nil && !f.curAgg.Empty() {
+		lastSeq = f.curAgg.LastSequence()
+	}
+
+	// add all markers which are covered by this aggregation
+	var covered, pending []tlog.Marker
+	for _, marker := range f.markers {
+		if marker.Sequence <= lastSeq {
+			covered = append(covered, marker)
+		} else {
+			pending = append(pending, marker)
+		}
+	}
+
 	if f.curAgg == nil || f.curAgg.Empty() {
-		return nil, nil, nil
+		// an aggregation without blocks is only flushed
+		// to persist the markers whose sequence was already flushed
+		if len(covered) == 0 {
+			return nil, nil, nil
+		}
+		if f.curAgg == nil {
+			if err := f.initAggregation(); err != nil {
+				return nil, nil, err
+			}
+		}
 	}
+	// the covered markers replace the ones set by a previous (failed) flush,
+	// as they are only removed from the pending markers once stored
+	f.curAgg.SetMarkers(covered)
 
 	data, err := f.storCli.ProcessStoreAgg(f.curAgg)
 	if err != nil {
 		return nil, nil, err
 	}
+	f.markers = pending
 
 	seqs := f.curAgg.Sequences()
 
@@ -0,0 +1,47 @@
+package tlog
+
+import (
+	"github.com/zero-os/0-Disk/tlog/schema"
+)
+
+// Marker defines a named tlog marker,
+// marking the state of a vdisk right after the given sequence,
+// e.g. an application-consistent state after a guest fsfreeze.
+type Marker struct {
+	Name      string
+	Sequence  uint64
+	Timestamp int64
+}
+
+// MarkerFromSchema creates a Marker from a capnp marker
+func MarkerFromSchema(marker schema.TlogMarker) (Marker, error) {
+	name, err := marker.Name()
+	if err != nil {
+		return Marker{}, err
+	}
+	return Marker{
+		Name:      name,
+		Sequence:  marker.Sequence(),
+		Timestamp: marker.Timestamp(),
+	}, nil
+}
+
+// AggregationMarkers returns all markers stored in the given aggregation
+func AggregationMarkers(agg *schema.TlogAggregation) ([]Marker, error) {
+	if !agg.HasMarkers() {
+		return nil, nil
+	}
+	list, err := agg.Markers()
+	if err != nil {
+		return nil, err
+	}
+
+	markers := make([]Marker, list.Len())
+	for i := range markers {
+		markers[i], err = MarkerFromSchema(list.At(i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return markers, nil
+}
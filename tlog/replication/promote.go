@@ -0,0 +1,126 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/player"
+)
+
+var (
+	// ErrNotStandby is returned when a vdisk is used as a standby vdisk,
+	// while it isn't configured as one (read-only).
+	ErrNotStandby = errors.New("vdisk is not a standby vdisk")
+)
+
+// CheckStandby checks whether the given vdisk is a standby vdisk,
+// meaning it is configured as read-only, such that it isn't written to
+// by anything else than a replicator. ErrNotStandby is returned if it isn't.
+func CheckStandby(source config.Source, vdiskID string) error {
+	staticConfig, err := config.ReadVdiskStaticConfig(source, vdiskID)
+	if err != nil {
+		return err
+	}
+	if !staticConfig.ReadOnly {
+		return errors.Wrapf(ErrNotStandby, "vdisk %s is writable", vdiskID)
+	}
+	return nil
+}
+
+// Promote turns the given standby vdisk into a writable vdisk.
+// All replicated sequences which weren't replayed yet,
+// are replayed into the storage of the vdisk, after which the vdisk
+// is no longer configured as read-only, requiring the config source to be writable.
+// It returns the last sequence of the promoted vdisk.
+//
+// Replication to the standby vdisk has to be stopped prior to promoting it.
+func Promote(ctx context.Context, source config.Source, vdiskID, privKey string) (uint64, error) {
+	staticConfig, err := config.ReadVdiskStaticConfig(source, vdiskID)
+	if err != nil {
+		return 0, err
+	}
+	if !staticConfig.ReadOnly {
+		return 0, errors.Wrapf(ErrNotStandby, "vdisk %s is writable", vdiskID)
+	}
+
+	metaCli, err := newMetaClient(source, vdiskID)
+	if err != nil {
+		return 0, err
+	}
+	defer metaCli.Close()
+	lastReplayedSeq, err := loadLastReplayedSeq(metaCli, vdiskID)
+	if err != nil {
+		return 0, err
+	}
+
+	player, err := player.NewPlayer(ctx, source, vdiskID, privKey)
+	if err != nil {
+		return 0, err
+	}
+	defer player.Close()
+
+	lastSeq, err := player.Replay(decoder.NewLimitBySequence(lastReplayedSeq+1, 0))
+	if err != nil {
+		return 0, errors.Wrapf(err, "couldn't replay the tlog of standby vdisk %s", vdiskID)
+	}
+	if lastSeq > lastReplayedSeq {
+		log.Infof("replayed sequences %d-%d of standby vdisk %s",
+			lastReplayedSeq+1, lastSeq, vdiskID)
+		err = saveLastReplayedSeq(metaCli, vdiskID, lastSeq)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		lastSeq = lastReplayedSeq
+	}
+
+	staticConfig.ReadOnly = false
+	err = config.WriteVdiskStaticConfig(source, vdiskID, *staticConfig)
+	if err != nil {
+		return 0, errors.Wrapf(err, "couldn't make standby vdisk %s writable", vdiskID)
+	}
+	return lastSeq, nil
+}
+
+// create a metadata client for the tlog of the given vdisk
+func newMetaClient(source config.Source, vdiskID string) (*stor.MetaClient, error) {
+	storConf, err := stor.ConfigFromConfigSource(source, vdiskID, "")
+	if err != nil {
+		return nil, err
+	}
+	return stor.NewMetaClient(storConf.MetaShards)
+}
+
+// load the last sequence replayed into the storage of the given standby vdisk,
+// 0 is returned in case no sequence was replayed yet.
+func loadLastReplayedSeq(metaCli *stor.MetaClient, vdiskID string) (uint64, error) {
+	b, err := metaCli.GetMeta(lastReplayedSeqKey(vdiskID))
+	if err != nil || len(b) == 0 {
+		return 0, err
+	}
+
+	var seq uint64
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&seq)
+	return seq, err
+}
+
+func saveLastReplayedSeq(metaCli *stor.MetaClient, vdiskID string, seq uint64) error {
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(seq)
+	if err != nil {
+		return err
+	}
+	return metaCli.SaveMeta(lastReplayedSeqKey(vdiskID), buf.Bytes())
+}
+
+// lastReplayedSeqKey returns the (metadata) key
+// of the last sequence replayed for the given standby vdisk.
+func lastReplayedSeqKey(vdiskID string) []byte {
+	return []byte("tlog:last_replicated_seq:" + vdiskID)
+}
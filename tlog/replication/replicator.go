@@ -0,0 +1,233 @@
+// Package replication provides asynchronous cross-site replication of a vdisk,
+// by shipping the flushed tlog aggregations of a primary vdisk
+// to a (remote) standby vdisk, which can be promoted when needed.
+package replication
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/subscriber"
+)
+
+const (
+	// DefaultMaxLag is the default amount of sequences
+	// a standby vdisk can lag behind, before it is reported as lagging.
+	DefaultMaxLag = 1024
+
+	// amount of aggregations received from the primary tlogserver,
+	// which can be buffered while the target is still applying earlier aggregations
+	aggBufferSize = 16
+)
+
+var (
+	// time to wait before replicating again, after replication failed
+	retryDelay = 5 * time.Second
+)
+
+// Config used to create a replicator.
+type Config struct {
+	// SourceAddress is the address of the primary tlogserver,
+	// on which it accepts subscribers.
+	SourceAddress string
+	// VdiskID of the primary vdisk.
+	VdiskID string
+	// MaxLag is the amount of sequences the standby vdisk can lag behind
+	// the primary vdisk, before it is reported as lagging.
+	// DefaultMaxLag is used when 0.
+	MaxLag uint64
+}
+
+// Status of a replicator.
+type Status struct {
+	// LastFlushedSequence is the last sequence flushed by the primary tlogserver,
+	// as far as known by the replicator.
+	LastFlushedSequence uint64
+	// LastAckedSequence is the last sequence acknowledged by the target.
+	LastAckedSequence uint64
+	// Lag is the amount of flushed sequences which aren't acknowledged yet.
+	Lag uint64
+	// Lagging is true when the lag exceeds the configured max lag.
+	Lagging bool
+}
+
+// Replicator ships the flushed tlog aggregations of a primary vdisk
+// to a target, which applies them to a standby vdisk.
+type Replicator struct {
+	cfg    Config
+	target Target
+
+	status Status
+	mux    sync.Mutex
+}
+
+// New creates a new replicator, replicating to the given target.
+func New(cfg Config, target Target) (*Replicator, error) {
+	if cfg.SourceAddress == "" {
+		return nil, errors.New("replicator requires a source address")
+	}
+	if cfg.VdiskID == "" {
+		return nil, errors.New("replicator requires a vdiskID")
+	}
+	if target == nil {
+		return nil, errors.New("replicator requires a non-nil target")
+	}
+	if cfg.MaxLag == 0 {
+		cfg.MaxLag = DefaultMaxLag
+	}
+
+	return &Replicator{
+		cfg:    cfg,
+		target: target,
+	}, nil
+}
+
+// Run the replicator until the given context is done.
+// In case replication fails, for example because the link to either site was lost,
+// it is resumed from the last sequence acknowledged by the target.
+func (r *Replicator) Run(ctx context.Context) {
+	log.Infof("replicator (%v): started", r.cfg.VdiskID)
+	defer log.Infof("replicator (%v): exited", r.cfg.VdiskID)
+
+	for {
+		err := r.replicate(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		log.Errorf("replicator (%v): replication failed, retrying in %v: %v",
+			r.cfg.VdiskID, retryDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// Status returns the current status of this replicator.
+func (r *Replicator) Status() Status {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.status
+}
+
+// replicate all flushed aggregations,
+// starting from the last sequence acknowledged by the target,
+// until the context is done or an error occurs.
+func (r *Replicator) replicate(ctx context.Context) error {
+	lastAckedSeq, err := r.target.LastSequence()
+	if err != nil {
+		return errors.Wrap(err, "couldn't load the last acknowledged sequence")
+	}
+	r.setAcked(lastAckedSeq)
+
+	sub, err := subscriber.New(r.cfg.SourceAddress, subscriber.Config{
+		VdiskID:       r.cfg.VdiskID,
+		StartSequence: lastAckedSeq + 1,
+		WithData:      true,
+	})
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	r.setFlushed(sub.LastFlushedSequence())
+
+	log.Infof("replicator (%v): replicating from sequence %d",
+		r.cfg.VdiskID, lastAckedSeq+1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// receive aggregations in the background,
+	// such that the primary's progress is known while the target applies
+	aggCh := make(chan *schema.TlogAggregation, aggBufferSize)
+	var recvErr error
+	go func() {
+		defer close(aggCh)
+		for {
+			agg, err := sub.Recv()
+			if err != nil {
+				recvErr = err
+				return
+			}
+			if size := agg.Size(); size > 0 {
+				blocks, err := agg.Blocks()
+				if err != nil {
+					recvErr = err
+					return
+				}
+				r.setFlushed(blocks.At(int(size - 1)).Sequence())
+			}
+
+			select {
+			case aggCh <- agg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case agg, ok := <-aggCh:
+			if !ok {
+				return recvErr
+			}
+			seq, err := r.target.Apply(agg)
+			if err != nil {
+				return errors.Wrap(err, "target couldn't apply aggregation")
+			}
+			r.setAcked(seq)
+		}
+	}
+}
+
+func (r *Replicator) setFlushed(seq uint64) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if seq > r.status.LastFlushedSequence {
+		r.status.LastFlushedSequence = seq
+		r.updateLag()
+	}
+}
+
+func (r *Replicator) setAcked(seq uint64) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.status.LastAckedSequence = seq
+	r.updateLag()
+}
+
+// update the lag, and report when the standby vdisk
+// starts lagging or has caught up again.
+// the mutex has to be locked when calling this method.
+func (r *Replicator) updateLag() {
+	r.status.Lag = 0
+	if r.status.LastFlushedSequence > r.status.LastAckedSequence {
+		r.status.Lag = r.status.LastFlushedSequence - r.status.LastAckedSequence
+	}
+
+	lagging := r.status.Lag > r.cfg.MaxLag
+	if lagging == r.status.Lagging {
+		return
+	}
+	r.status.Lagging = lagging
+
+	if lagging {
+		log.Errorf("replicator (%v): standby vdisk lags %d sequences behind (max %d)",
+			r.cfg.VdiskID, r.status.Lag, r.cfg.MaxLag)
+	} else {
+		log.Infof("replicator (%v): standby vdisk caught up, lagging %d sequences behind",
+			r.cfg.VdiskID, r.status.Lag)
+	}
+}
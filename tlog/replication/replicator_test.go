@@ -0,0 +1,253 @@
+package replication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-Disk/tlog/tlogclient"
+	"github.com/zero-os/0-Disk/tlog/tlogserver/server"
+)
+
+const (
+	primaryVdiskID = "primary"
+	standbyVdiskID = "standby"
+	privKey        = "12345678901234567890123456789012"
+	blockSize      = 4096
+)
+
+func TestReplicatePlayerTarget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	primary := newTestSite(t, primaryVdiskID, false)
+	defer primary.Close()
+	primaryServer := primary.newTlogServer(ctx, t)
+
+	standby := newTestSite(t, standbyVdiskID, true)
+	defer standby.Close()
+
+	// 1. write to the primary vdisk, and replicate it
+	writer := newTestWriter(t, primaryServer, primaryVdiskID)
+	defer writer.Close()
+	writer.Write(t, 1, 40)
+
+	target, err := NewPlayerTarget(ctx, standby.source, standbyVdiskID, privKey)
+	require.NoError(t, err)
+	replicator := testReplicate(ctx, t, primaryServer, target, 40)
+	assert.Equal(t, uint64(0), replicator.Status().Lag)
+	require.NoError(t, target.Close())
+	standby.assertContent(t, 1, 40)
+
+	// 2. resume replication from the last sequence acknowledged,
+	//    once the link to the standby site is available again
+	writer.Write(t, 41, 100)
+
+	target, err = NewPlayerTarget(ctx, standby.source, standbyVdiskID, privKey)
+	require.NoError(t, err)
+	lastSeq, err := target.LastSequence()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(40), lastSeq)
+	testReplicate(ctx, t, primaryServer, target, 100)
+	require.NoError(t, target.Close())
+	standby.assertContent(t, 1, 100)
+
+	// 3. promote the standby vdisk
+	lastSeq, err = Promote(ctx, standby.source, standbyVdiskID, privKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), lastSeq)
+	assert.Error(t, CheckStandby(standby.source, standbyVdiskID))
+	standby.assertContent(t, 1, 100)
+
+	_, err = Promote(ctx, standby.source, standbyVdiskID, privKey)
+	assert.Equal(t, ErrNotStandby, errors.Cause(err))
+}
+
+func TestReplicateTlogServerTarget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	primary := newTestSite(t, primaryVdiskID, false)
+	defer primary.Close()
+	primaryServer := primary.newTlogServer(ctx, t)
+
+	standby := newTestSite(t, standbyVdiskID, true)
+	defer standby.Close()
+	standbyServer := standby.newTlogServer(ctx, t)
+	require.NoError(t, CheckStandby(standby.source, standbyVdiskID))
+
+	// replicate to the tlogserver of the standby site
+	writer := newTestWriter(t, primaryServer, primaryVdiskID)
+	defer writer.Close()
+	writer.Write(t, 1, 60)
+
+	target, err := NewTlogServerTarget([]string{standbyServer.ListenAddr()}, standbyVdiskID)
+	require.NoError(t, err)
+	testReplicate(ctx, t, primaryServer, target, 60)
+	require.NoError(t, target.Close())
+
+	// the replicated sequences are only replayed when promoting the standby vdisk
+	lastSeq, err := Promote(ctx, standby.source, standbyVdiskID, privKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(60), lastSeq)
+	standby.assertContent(t, 1, 60)
+}
+
+// replicate until the given sequence is acknowledged by the target
+func testReplicate(ctx context.Context, t *testing.T, s *server.Server, target Target, lastSeq uint64) *Replicator {
+	replicator, err := New(Config{
+		SourceAddress: s.SubscribeListenAddr(),
+		VdiskID:       primaryVdiskID,
+	}, target)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		replicator.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-doneCh
+	}()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for replicator.Status().LastAckedSequence < lastSeq {
+		require.True(t, time.Now().Before(deadline), "replication timed out: %+v", replicator.Status())
+		time.Sleep(50 * time.Millisecond)
+	}
+	status := replicator.Status()
+	assert.Equal(t, lastSeq, status.LastAckedSequence)
+	assert.Equal(t, lastSeq, status.LastFlushedSequence)
+	assert.False(t, status.Lagging)
+	return replicator
+}
+
+// a site containing a single vdisk
+type testSite struct {
+	vdiskID     string
+	storCluster *embeddedserver.ZeroStorCluster
+	mdServer    *embedserver.Server
+	cluster     *redisstub.UniCluster
+	source      *config.StubSource
+}
+
+func newTestSite(t *testing.T, vdiskID string, readOnly bool) *testSite {
+	storCluster, err := embeddedserver.NewZeroStorCluster(2)
+	require.NoError(t, err)
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+	cluster := redisstub.NewUniCluster(true)
+
+	source := config.NewStubSource()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		ReadOnly:  readOnly,
+		Size:      1,
+		Type:      config.VdiskTypeBoot,
+	})
+	source.SetPrimaryStorageCluster(vdiskID, "nbd_cluster_id", &config.StorageClusterConfig{
+		Servers: []config.StorageServerConfig{cluster.StorageServerConfig()},
+	})
+	var servers []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		servers = append(servers, config.ServerConfig{Address: addr})
+	}
+	source.SetTlogZeroStorCluster(vdiskID, "zero_stor_cluster_id", &config.ZeroStorClusterConfig{
+		IYO: config.IYOCredentials{
+			Org:       "testorg",
+			Namespace: vdiskID,
+		},
+		MetadataServers: []config.ServerConfig{
+			config.ServerConfig{Address: mdServer.ListenAddr()},
+		},
+		DataServers:  servers,
+		DataShards:   1,
+		ParityShards: 1,
+	})
+
+	return &testSite{
+		vdiskID:     vdiskID,
+		storCluster: storCluster,
+		mdServer:    mdServer,
+		cluster:     cluster,
+		source:      source,
+	}
+}
+
+func (site *testSite) newTlogServer(ctx context.Context, t *testing.T) *server.Server {
+	conf := server.DefaultConfig()
+	conf.ListenAddr = "127.0.0.1:0"
+	conf.SubscribeListenAddr = "127.0.0.1:0"
+	s, err := server.NewServer(conf, site.source)
+	require.NoError(t, err)
+	go s.Listen(ctx)
+	return s
+}
+
+// assert that the content of the given range of blocks,
+// matches the content written by the test writer
+func (site *testSite) assertContent(t *testing.T, start, end uint64) {
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+	blockStorage, err := storage.BlockStorageFromConfig(site.vdiskID, site.source, pool)
+	require.NoError(t, err)
+	defer blockStorage.Close()
+
+	for seq := start; seq <= end; seq++ {
+		content, err := blockStorage.GetBlock(int64(seq))
+		require.NoError(t, err)
+		if assert.Len(t, content, blockSize) {
+			assert.Equal(t, byte(seq), content[0])
+		}
+	}
+}
+
+func (site *testSite) Close() {
+	site.source.Close()
+	site.cluster.Close()
+	site.mdServer.Stop()
+	site.storCluster.Close()
+}
+
+// writes to a vdisk using a tlogclient, waiting until the writes are flushed
+type testWriter struct {
+	*tlogclient.Client
+}
+
+func newTestWriter(t *testing.T, s *server.Server, vdiskID string) *testWriter {
+	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID)
+	require.NoError(t, err)
+	return &testWriter{client}
+}
+
+// write the given sequences, where each sequence writes the block with the same index
+func (w *testWriter) Write(t *testing.T, start, end uint64) {
+	for seq := start; seq <= end; seq++ {
+		data := make([]byte, blockSize)
+		data[0] = byte(seq)
+		err := w.Send(schema.OpSet, seq, int64(seq), tlog.TimeNowTimestamp(), data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.ForceFlushAtSeq(end))
+
+	for result := range w.Recv() {
+		require.NoError(t, result.Err)
+		if result.Resp.Status == tlog.BlockStatusFlushOK && w.LastFlushedSequence() >= end {
+			return
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package replication
+
+import (
+	"context"
+	"time"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/tlog/tlogclient"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/player"
+)
+
+const (
+	// time to wait for a remote tlogserver
+	// to acknowledge the flush of an aggregation
+	ackTimeout = 30 * time.Second
+)
+
+// Target applies replicated aggregations to a standby vdisk.
+type Target interface {
+	// LastSequence returns the last sequence acknowledged by this target,
+	// from which replication is resumed. 0 is returned if no sequence was replicated yet.
+	LastSequence() (uint64, error)
+	// Apply the blocks of the given aggregation which weren't acknowledged yet,
+	// returning the last sequence acknowledged once they're applied.
+	Apply(agg *schema.TlogAggregation) (uint64, error)
+	// Close the target and release all its resources.
+	Close() error
+}
+
+// TlogServerTarget is a target which sends replicated aggregations
+// to the tlogserver of a remote site, which flushes them to the tlog of the standby vdisk.
+// The standby vdisk's storage is only updated once the standby vdisk is promoted.
+type TlogServerTarget struct {
+	client *tlogclient.Client
+}
+
+// NewTlogServerTarget creates a new target,
+// sending to the given tlogserver(s) for the given standby vdisk.
+func NewTlogServerTarget(servers []string, vdiskID string) (*TlogServerTarget, error) {
+	client, err := tlogclient.New(servers, vdiskID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't connect to the tlogserver of standby vdisk %s", vdiskID)
+	}
+	return &TlogServerTarget{client: client}, nil
+}
+
+// LastSequence implements Target.LastSequence
+func (t *TlogServerTarget) LastSequence() (uint64, error) {
+	t.client.WaitReady()
+	return t.client.LastFlushedSequence(), nil
+}
+
+// Apply implements Target.Apply
+func (t *TlogServerTarget) Apply(agg *schema.TlogAggregation) (uint64, error) {
+	lastFlushedSeq := t.client.LastFlushedSequence()
+
+	blocks, err := agg.Blocks()
+	if err != nil {
+		return lastFlushedSeq, err
+	}
+
+	var lastSeq uint64
+	for i := 0; i < blocks.Len(); i++ {
+		if seq := blocks.At(i).Sequence(); seq > lastFlushedSeq {
+			lastSeq = seq
+		}
+	}
+	if lastSeq == 0 {
+		return lastFlushedSeq, nil // all blocks were already acknowledged
+	}
+
+	// send blocks in the background, as the responses have to be received
+	// while sending, for the client not to block
+	sendErrCh := make(chan error, 1)
+	go func() {
+		for i := 0; i < blocks.Len(); i++ {
+			block := blocks.At(i)
+			if block.Sequence() <= lastFlushedSeq {
+				continue
+			}
+			data, err := block.Data()
+			if err != nil {
+				sendErrCh <- err
+				return
+			}
+			err = t.client.Send(block.Operation(), block.Sequence(),
+				block.Index(), block.Timestamp(), data)
+			if err != nil {
+				sendErrCh <- err
+				return
+			}
+		}
+		sendErrCh <- t.client.ForceFlushAtSeq(lastSeq)
+	}()
+
+	// wait until all blocks are flushed
+	timeout := time.After(ackTimeout)
+	for {
+		select {
+		case err := <-sendErrCh:
+			if err != nil {
+				return t.client.LastFlushedSequence(), err
+			}
+
+		case res := <-t.client.Recv():
+			if res.Err != nil {
+				return t.client.LastFlushedSequence(), res.Err
+			}
+			if res.Resp.Status == tlog.BlockStatusFlushFailed {
+				return t.client.LastFlushedSequence(), res.Resp.Status.Error()
+			}
+			if lastFlushedSeq = t.client.LastFlushedSequence(); lastFlushedSeq >= lastSeq {
+				return lastFlushedSeq, nil
+			}
+
+		case <-timeout:
+			return t.client.LastFlushedSequence(), errors.Newf(
+				"timed out waiting for sequence %d to be flushed", lastSeq)
+		}
+	}
+}
+
+// Close implements Target.Close
+func (t *TlogServerTarget) Close() error {
+	return t.client.Close()
+}
+
+// PlayerTarget is a target which stores replicated aggregations
+// in the tlog (0-stor cluster) of the standby vdisk,
+// and replays them into the storage of that standby vdisk.
+type PlayerTarget struct {
+	vdiskID         string
+	storClient      *stor.Client
+	player          *player.Player
+	metaCli         *stor.MetaClient
+	lastStoredSeq   uint64
+	lastReplayedSeq uint64
+}
+
+// NewPlayerTarget creates a new target, storing and replaying replicated aggregations
+// for the given standby vdisk, configured in the given (remote) config source.
+// Any sequences which were stored, but not yet replayed, are replayed first.
+func NewPlayerTarget(ctx context.Context, source config.Source, vdiskID, privKey string) (*PlayerTarget, error) {
+	storClient, err := stor.NewClientFromConfigSource(source, vdiskID, privKey)
+	if err != nil {
+		return nil, err
+	}
+	player, err := player.NewPlayer(ctx, source, vdiskID, privKey)
+	if err != nil {
+		storClient.Close()
+		return nil, err
+	}
+	metaCli, err := newMetaClient(source, vdiskID)
+	if err != nil {
+		player.Close()
+		storClient.Close()
+		return nil, err
+	}
+
+	t := &PlayerTarget{
+		vdiskID:    vdiskID,
+		storClient: storClient,
+		player:     player,
+		metaCli:    metaCli,
+	}
+	err = t.catchUp()
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// LastSequence implements Target.LastSequence
+func (t *PlayerTarget) LastSequence() (uint64, error) {
+	return t.lastStoredSeq, nil
+}
+
+// Apply implements Target.Apply
+func (t *PlayerTarget) Apply(agg *schema.TlogAggregation) (uint64, error) {
+	blocks, err := agg.Blocks()
+	if err != nil {
+		return t.lastStoredSeq, err
+	}
+
+	// store the blocks which weren't stored yet
+	storeAgg, err := tlog.NewAggregation(nil, blocks.Len())
+	if err != nil {
+		return t.lastStoredSeq, err
+	}
+	for i := 0; i < blocks.Len(); i++ {
+		block := blocks.At(i)
+		if block.Sequence() <= t.lastStoredSeq {
+			continue
+		}
+		err = storeAgg.AddBlock(&block)
+		if err != nil {
+			return t.lastStoredSeq, err
+		}
+	}
+	if storeAgg.Empty() {
+		return t.lastStoredSeq, nil
+	}
+	_, err = t.storClient.ProcessStoreAgg(storeAgg)
+	if err != nil {
+		return t.lastStoredSeq, errors.Wrap(err, "couldn't store aggregation")
+	}
+	t.lastStoredSeq = storeAgg.LastSequence()
+
+	// replay the stored blocks
+	_, err = t.player.ReplayAggregation(agg, decoder.NewLimitBySequence(t.lastReplayedSeq+1, 0))
+	if err != nil {
+		return t.lastStoredSeq, errors.Wrap(err, "couldn't replay aggregation")
+	}
+	err = t.setLastReplayedSeq(t.lastStoredSeq)
+	return t.lastStoredSeq, err
+}
+
+// Close implements Target.Close
+func (t *PlayerTarget) Close() error {
+	t.metaCli.Close()
+	t.storClient.Close()
+	return t.player.Close()
+}
+
+// replay all sequences which were stored,
+// but not replayed yet, into the storage of the standby vdisk
+func (t *PlayerTarget) catchUp() error {
+	var err error
+	t.lastStoredSeq, err = t.storClient.LoadLastSequence()
+	if err != nil && errors.Cause(err) != stor.ErrNoFlushedBlock {
+		return err
+	}
+	t.lastReplayedSeq, err = loadLastReplayedSeq(t.metaCli, t.vdiskID)
+	if err != nil || t.lastStoredSeq <= t.lastReplayedSeq {
+		return err
+	}
+
+	lastSeq, err := t.player.Replay(decoder.NewLimitBySequence(t.lastReplayedSeq+1, 0))
+	if err != nil {
+		return errors.Wrap(err, "couldn't replay stored sequences")
+	}
+	return t.setLastReplayedSeq(lastSeq)
+}
+
+func (t *PlayerTarget) setLastReplayedSeq(seq uint64) error {
+	err := saveLastReplayedSeq(t.metaCli, t.vdiskID, seq)
+	if err != nil {
+		return err
+	}
+	t.lastReplayedSeq = seq
+	return nil
+}
@@ -0,0 +1,13 @@
+package schema
+
+// Block data encoding
+const (
+	// the data of the block is stored as is
+	EncodingRaw = 0
+	// the data of the block is equal to the data
+	// of the block it refers to, and isn't stored
+	EncodingDedup = 1
+	// the data of the block is stored as a delta
+	// against the data of the block it refers to
+	EncodingDelta = 2
+)
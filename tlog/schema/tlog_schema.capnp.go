@@ -182,12 +182,12 @@ type TlogAggregation struct{ capnp.Struct }
 const TlogAggregation_TypeID = 0xe46ab5b4b619e094
 
 func NewTlogAggregation(s *capnp.Segment) (TlogAggregation, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 3})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4})
 	return TlogAggregation{st}, err
 }
 
 func NewRootTlogAggregation(s *capnp.Segment) (TlogAggregation, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 3})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4})
 	return TlogAggregation{st}, err
 }
 
@@ -275,12 +275,37 @@ func (s TlogAggregation) SetPrev(v []byte) error {
 	return s.Struct.SetData(2, v)
 }
 
+func (s TlogAggregation) Markers() (TlogMarker_List, error) {
+	p, err := s.Struct.Ptr(3)
+	return TlogMarker_List{List: p.List()}, err
+}
+
+func (s TlogAggregation) HasMarkers() bool {
+	p, err := s.Struct.Ptr(3)
+	return p.IsValid() || err != nil
+}
+
+func (s TlogAggregation) SetMarkers(v TlogMarker_List) error {
+	return s.Struct.SetPtr(3, v.List.ToPtr())
+}
+
+// NewMarkers sets the markers field to a newly
+// allocated TlogMarker_List, preferring placement in s's segment.
+func (s TlogAggregation) NewMarkers(n int32) (TlogMarker_List, error) {
+	l, err := NewTlogMarker_List(s.Struct.Segment(), n)
+	if err != nil {
+		return TlogMarker_List{}, err
+	}
+	err = s.Struct.SetPtr(3, l.List.ToPtr())
+	return l, err
+}
+
 // TlogAggregation_List is a list of TlogAggregation.
 type TlogAggregation_List struct{ capnp.List }
 
 // NewTlogAggregation creates a new list of TlogAggregation.
 func NewTlogAggregation_List(s *capnp.Segment, sz int32) (TlogAggregation_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 3}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4}, sz)
 	return TlogAggregation_List{l}, err
 }
 
@@ -311,10 +336,11 @@ const (
 	TlogClientMessage_Which_forceFlushAtSeq  TlogClientMessage_Which = 1
 	TlogClientMessage_Which_waitNBDSlaveSync TlogClientMessage_Which = 2
 	TlogClientMessage_Which_disconnect       TlogClientMessage_Which = 3
+	TlogClientMessage_Which_marker           TlogClientMessage_Which = 4
 )
 
 func (w TlogClientMessage_Which) String() string {
-	const s = "blockforceFlushAtSeqwaitNBDSlaveSyncdisconnect"
+	const s = "blockforceFlushAtSeqwaitNBDSlaveSyncdisconnectmarker"
 	switch w {
 	case TlogClientMessage_Which_block:
 		return s[0:5]
@@ -324,6 +350,8 @@ func (w TlogClientMessage_Which) String() string {
 		return s[20:36]
 	case TlogClientMessage_Which_disconnect:
 		return s[36:46]
+	case TlogClientMessage_Which_marker:
+		return s[46:52]
 
 	}
 	return "TlogClientMessage_Which(" + strconv.FormatUint(uint64(w), 10) + ")"
@@ -404,6 +432,36 @@ func (s TlogClientMessage) SetDisconnect() {
 
 }
 
+func (s TlogClientMessage) Marker() (TlogMarker, error) {
+	p, err := s.Struct.Ptr(0)
+	return TlogMarker{Struct: p.Struct()}, err
+}
+
+func (s TlogClientMessage) HasMarker() bool {
+	if s.Struct.Uint16(0) != 4 {
+		return false
+	}
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s TlogClientMessage) SetMarker(v TlogMarker) error {
+	s.Struct.SetUint16(0, 4)
+	return s.Struct.SetPtr(0, v.Struct.ToPtr())
+}
+
+// NewMarker sets the marker field to a newly
+// allocated TlogMarker struct, preferring placement in s's segment.
+func (s TlogClientMessage) NewMarker() (TlogMarker, error) {
+	s.Struct.SetUint16(0, 4)
+	ss, err := NewTlogMarker(s.Struct.Segment())
+	if err != nil {
+		return TlogMarker{}, err
+	}
+	err = s.Struct.SetPtr(0, ss.Struct.ToPtr())
+	return ss, err
+}
+
 // TlogClientMessage_List is a list of TlogClientMessage.
 type TlogClientMessage_List struct{ capnp.List }
 
@@ -438,6 +496,10 @@ func (p TlogClientMessage_Promise) Block() TlogBlock_Promise {
 	return TlogBlock_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
 
+func (p TlogClientMessage_Promise) Marker() TlogMarker_Promise {
+	return TlogMarker_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
+}
+
 type TlogBlock struct{ capnp.Struct }
 
 // TlogBlock_TypeID is the unique identifier for the type TlogBlock.
@@ -523,6 +585,22 @@ func (s TlogBlock) SetOperation(v uint8) {
 	s.Struct.SetUint8(24, v)
 }
 
+func (s TlogBlock) Encoding() uint8 {
+	return s.Struct.Uint8(25)
+}
+
+func (s TlogBlock) SetEncoding(v uint8) {
+	s.Struct.SetUint8(25, v)
+}
+
+func (s TlogBlock) Ref() uint32 {
+	return s.Struct.Uint32(28)
+}
+
+func (s TlogBlock) SetRef(v uint32) {
+	s.Struct.SetUint32(28, v)
+}
+
 // TlogBlock_List is a list of TlogBlock.
 type TlogBlock_List struct{ capnp.List }
 
@@ -770,71 +848,358 @@ func (p WaitTlogHandshakeResponse_Promise) Struct() (WaitTlogHandshakeResponse,
 	return WaitTlogHandshakeResponse{s}, err
 }
 
-const schema_f4533cbae6e08506 = "x\xda\x8cS]h\x1cU\x14>\xdf\xbd3\x9b\x14\xb2" +
-	"I\x86Y\xa1)\x94E,X\x83\xb5I\xd7\xa7PH" +
-	"\xb3\xc6R\x03\x95\xde]\xa1\x10\x14\x1dgow\xc7\xcc" +
-	"\xcel\xf7\xce\xa6\x1bm\xd1\x96\x06JQ\x14Q\xa9E" +
-	"\xc4\x84\x0a\x0a\x15\x14\x12\x11\x9f\xfa\xa6>I\xa1\x0aA" +
-	"\x90\x08\xfd\xc1\x07\x85\x82\x08\x82u\xe4\xeef\x7f\x9a\xac" +
-	"\xb6o3\x87\x8f\xef~?\xe7\x8c\xe5\xd9\x016n>" +
-	"l\x10\x8913\x11\x8f_;\xb3\xff\xe7\xfa\xed\xd7I" +
-	"\x8c\xc0\x88\x13\x8b\xeb7\xbf\xde\x9f\xff\x83L\xd6G\x94" +
-	"\xf9\x0b;`ok|\x9a\xec(\x08\xf1\xda\xae\xf3\xdf" +
-	"\xfc\xb2\xe3\xea\x05\x0dG\x17\x1c\x1a\xf3\x1c\xdf\x07\xbb\xcc" +
-	"\xfb\x88l\x8f\x9f \xc4\xd3\x87\xc7\x0b?\xdd\xd8\xbbJ" +
-	"\xd6\xc8\x16\xf0\x1a\x7f\x1b\xf6\xed\x06\xf87>I\x88\x97" +
-	"\xaf\xfey\xe7\xa1W\x0e|\xab\xa9Y\x07\xfd$\xfa\x0c" +
-	"\xa2L\xd2\x98\x85\xfd\xa0\xa1\xe1;\x8d[\x84\xf8\xca\xcc" +
-	"\xf2\x9b?\xde\xbc\xb6\xdeS\x09\xcc\x1c\xec\x07L\x8d\xb6" +
-	"L\xad\xc4\xfep\xe6\xfb\x95\xb5\xf1\xeb\x9b\xd0\x9a/S" +
-	"6/\xc2^l\x80O\x9bZ\xc9;\xeb#_\xae\xac" +
-	"\xbet}\x93\x12S\x8b\xcd,\x993\xb0W5:\xf3" +
-	"\x85\x99\xd6\x99L\x1d\xfb\xee\xbdS\x17\xdf\xfd}\x13\xbc" +
-	"\xc1\xfdCb\x16\xf6\xaf\x09\xcd}#q\x8b\xf6\xc4\xca" +
-	"-\xc9\xb2\xb37\xe2~X|\xbe\xf9\xf3\x98\xebT\x82" +
-	"\xca\xc43~X\xcc\xfa!w\xe7\x8e\x00b;7\x88" +
-	"\x0c\x10Y\xef\xcf\x10\x89\x0b\x1c\xe2\x12\x83\x05\xa4\xa0\x87" +
-	"K\xfb\x88\xc4\x07\x1c\xe2\x13\x06\xb0\x14\x18\x91\xf5\xf1(" +
-	"\x91\xf8\x88C\\f\xb08R\xe0D\xd6\xa7zx\x89" +
-	"C|\xce`\x19,\x05\x83\xc8\xfa,G$.s\x88" +
-	"\xaf\x18,s{\x0a&\x91\xb5\xaa\x87+\x1c\xe2\x0aC" +
-	"\xac\xe4\xf1\x9a\x0c\\ID\xd8F\x0c\xdb\x08i/(" +
-	"\xc8:Lb0\x09C%G\x95\x90$\x86$a\xa8" +
-	"\xe0DN\xeb'\x8e\xbc\xb2T\x91S&TZ\xe88" +
-	"\xac\xc8\xaa\x13y!!@\x82\x18\x12\x84{D\x91\x93" +
-	"*]\x09\x03%u\x1a\xfd\xed4\x1e\x99 \x12\xbb8" +
-	"\xc4\x18C+\x8c=Z\xf9\xa3\x1c\xe2\x10\xc3\xa4\x8a\x9c" +
-	"\xa8\xa6\xc0\x88\x81Q\x97\x11(\x0c\x12\x8ep4\xfc\x0c" +
-	"v\xbdony\xff\xa8\xe3EZ\xc3!'(\xa8\x92" +
-	"3's\x9aE!\xd2Z\x8c\xb6\x96d\x96H\xf4s" +
-	"\x88\x14\xc3\xab\xf3\x05O\xcd=5\x8d\x01b\x18\xe8b" +
-	"7z\xba{\xc2\xf7d\x10\x1d\x96J9\xbc\xd8\xb08" +
-	"\xcc\x8d\x818n\xf0:\xba\xdcg9D\x89a'\xfe" +
-	"\x897l\xca3D\xa2\xc0!*\x0cIv'n\xb6" +
-	"^>O$*\x1c\xe2$C\x92\xff\x1d7k_\x98" +
-	"%\x12u\x0eq\x96!\xfd\xa2\x1f\xbas\x18\xee\xdc:" +
-	"\x01\xc3\x84\xf8XXu\xe5A\xbf\x06U\x9a\x8a\xf2\xf2" +
-	"x\xbb\xea\xf8\x84\xe3EOg\xa7\xf3\xf0\x9dy\x99_" +
-	"\x08\\\xea\x8b\x0b\x9er\xc3 \x90\xc4\xdd\x88\x12\xff\xe3" +
-	"\xafgj]\x0df{4\xa8g\xbb9\xc4\xe3:I" +
-	"YU^\x18\xa0\x9f\x18\xfa\xe9\xbf\x93\xbd\xaf\xdeT%" +
-	"\x0cxs\x89\xba\x8a\x9b\xe8\x147)\xeb\x9e\x8a\x14@" +
-	"\x0c\xb8goS\xc5bU\x16\xf5&\x07D\x9a4\xd5" +
-	"&=5\xda\x89\xbc\xe5\xeb\xb4\x9e\x9d\xe4\x10\xe7\x18," +
-	"\x86fc\x8bz]\xcfr\x88\xb7\xba\xee\xf4\x0d-\xe9" +
-	"\xdc\xc6\x95\xb7\xeeti\xb4s\xe5C\x81S\x96\xad\x0c" +
-	"\x86\x94\xf7\xb2l\xb7\xd5\xe3\xe0&\x1b\x9d\xb7W\xfe\xee" +
-	"\xee\x07\x09C\x95\xaa\x9co_\xec}u\xd9\x95\xe4p" +
-	"\xdb\xb4\x93\xed\xac\xaa\x05ccQ\xb5\x97\x178\x84\xdf" +
-	"\xe5\xda[&\x12>\x87\xa8k\xd7\xbb\x9b\xaekU\"" +
-	"\x11q\x88\xd7\xb6\xf6\xbe\xf9\x92}GE\x07\xfd\x9aB" +
-	"I\x16\xf2z\xb3\xfa\x02W\xde\xb5\xb1\xba J\xe7\xa4" +
-	"SXh\xd5\xf9o\x00\x00\x00\xff\xff\x0cs\xb9y"
+type SubscribeHandshakeRequest struct{ capnp.Struct }
+
+// SubscribeHandshakeRequest_TypeID is the unique identifier for the type SubscribeHandshakeRequest.
+const SubscribeHandshakeRequest_TypeID = 0x8bcd7e8c1ad2a9e7
+
+func NewSubscribeHandshakeRequest(s *capnp.Segment) (SubscribeHandshakeRequest, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	return SubscribeHandshakeRequest{st}, err
+}
+
+func NewRootSubscribeHandshakeRequest(s *capnp.Segment) (SubscribeHandshakeRequest, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	return SubscribeHandshakeRequest{st}, err
+}
+
+func ReadRootSubscribeHandshakeRequest(msg *capnp.Message) (SubscribeHandshakeRequest, error) {
+	root, err := msg.RootPtr()
+	return SubscribeHandshakeRequest{root.Struct()}, err
+}
+
+func (s SubscribeHandshakeRequest) String() string {
+	str, _ := text.Marshal(0x8bcd7e8c1ad2a9e7, s.Struct)
+	return str
+}
+
+func (s SubscribeHandshakeRequest) Version() uint32 {
+	return s.Struct.Uint32(0)
+}
+
+func (s SubscribeHandshakeRequest) SetVersion(v uint32) {
+	s.Struct.SetUint32(0, v)
+}
+
+func (s SubscribeHandshakeRequest) VdiskID() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s SubscribeHandshakeRequest) HasVdiskID() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s SubscribeHandshakeRequest) VdiskIDBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s SubscribeHandshakeRequest) SetVdiskID(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+func (s SubscribeHandshakeRequest) StartSequence() uint64 {
+	return s.Struct.Uint64(8)
+}
+
+func (s SubscribeHandshakeRequest) SetStartSequence(v uint64) {
+	s.Struct.SetUint64(8, v)
+}
+
+func (s SubscribeHandshakeRequest) WithData() bool {
+	return s.Struct.Bit(32)
+}
+
+func (s SubscribeHandshakeRequest) SetWithData(v bool) {
+	s.Struct.SetBit(32, v)
+}
+
+// SubscribeHandshakeRequest_List is a list of SubscribeHandshakeRequest.
+type SubscribeHandshakeRequest_List struct{ capnp.List }
+
+// NewSubscribeHandshakeRequest creates a new list of SubscribeHandshakeRequest.
+func NewSubscribeHandshakeRequest_List(s *capnp.Segment, sz int32) (SubscribeHandshakeRequest_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1}, sz)
+	return SubscribeHandshakeRequest_List{l}, err
+}
+
+func (s SubscribeHandshakeRequest_List) At(i int) SubscribeHandshakeRequest {
+	return SubscribeHandshakeRequest{s.List.Struct(i)}
+}
+
+func (s SubscribeHandshakeRequest_List) Set(i int, v SubscribeHandshakeRequest) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s SubscribeHandshakeRequest_List) String() string {
+	str, _ := text.MarshalList(0x8bcd7e8c1ad2a9e7, s.List)
+	return str
+}
+
+// SubscribeHandshakeRequest_Promise is a wrapper for a SubscribeHandshakeRequest promised by a client call.
+type SubscribeHandshakeRequest_Promise struct{ *capnp.Pipeline }
+
+func (p SubscribeHandshakeRequest_Promise) Struct() (SubscribeHandshakeRequest, error) {
+	s, err := p.Pipeline.Struct()
+	return SubscribeHandshakeRequest{s}, err
+}
+
+type SubscribeHandshakeResponse struct{ capnp.Struct }
+
+// SubscribeHandshakeResponse_TypeID is the unique identifier for the type SubscribeHandshakeResponse.
+const SubscribeHandshakeResponse_TypeID = 0x9c2c89d7c69430cb
+
+func NewSubscribeHandshakeResponse(s *capnp.Segment) (SubscribeHandshakeResponse, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 0})
+	return SubscribeHandshakeResponse{st}, err
+}
+
+func NewRootSubscribeHandshakeResponse(s *capnp.Segment) (SubscribeHandshakeResponse, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 0})
+	return SubscribeHandshakeResponse{st}, err
+}
+
+func ReadRootSubscribeHandshakeResponse(msg *capnp.Message) (SubscribeHandshakeResponse, error) {
+	root, err := msg.RootPtr()
+	return SubscribeHandshakeResponse{root.Struct()}, err
+}
+
+func (s SubscribeHandshakeResponse) String() string {
+	str, _ := text.Marshal(0x9c2c89d7c69430cb, s.Struct)
+	return str
+}
+
+func (s SubscribeHandshakeResponse) Version() uint32 {
+	return s.Struct.Uint32(0)
+}
+
+func (s SubscribeHandshakeResponse) SetVersion(v uint32) {
+	s.Struct.SetUint32(0, v)
+}
+
+func (s SubscribeHandshakeResponse) Status() int8 {
+	return int8(s.Struct.Uint8(4))
+}
+
+func (s SubscribeHandshakeResponse) SetStatus(v int8) {
+	s.Struct.SetUint8(4, uint8(v))
+}
+
+func (s SubscribeHandshakeResponse) LastFlushedSequence() uint64 {
+	return s.Struct.Uint64(8)
+}
+
+func (s SubscribeHandshakeResponse) SetLastFlushedSequence(v uint64) {
+	s.Struct.SetUint64(8, v)
+}
+
+// SubscribeHandshakeResponse_List is a list of SubscribeHandshakeResponse.
+type SubscribeHandshakeResponse_List struct{ capnp.List }
+
+// NewSubscribeHandshakeResponse creates a new list of SubscribeHandshakeResponse.
+func NewSubscribeHandshakeResponse_List(s *capnp.Segment, sz int32) (SubscribeHandshakeResponse_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 0}, sz)
+	return SubscribeHandshakeResponse_List{l}, err
+}
+
+func (s SubscribeHandshakeResponse_List) At(i int) SubscribeHandshakeResponse {
+	return SubscribeHandshakeResponse{s.List.Struct(i)}
+}
+
+func (s SubscribeHandshakeResponse_List) Set(i int, v SubscribeHandshakeResponse) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s SubscribeHandshakeResponse_List) String() string {
+	str, _ := text.MarshalList(0x9c2c89d7c69430cb, s.List)
+	return str
+}
+
+// SubscribeHandshakeResponse_Promise is a wrapper for a SubscribeHandshakeResponse promised by a client call.
+type SubscribeHandshakeResponse_Promise struct{ *capnp.Pipeline }
+
+func (p SubscribeHandshakeResponse_Promise) Struct() (SubscribeHandshakeResponse, error) {
+	s, err := p.Pipeline.Struct()
+	return SubscribeHandshakeResponse{s}, err
+}
+
+type TlogMarker struct{ capnp.Struct }
+
+// TlogMarker_TypeID is the unique identifier for the type TlogMarker.
+const TlogMarker_TypeID = 0xa28b8b8e6a57e5ca
+
+func NewTlogMarker(s *capnp.Segment) (TlogMarker, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	return TlogMarker{st}, err
+}
+
+func NewRootTlogMarker(s *capnp.Segment) (TlogMarker, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	return TlogMarker{st}, err
+}
+
+func ReadRootTlogMarker(msg *capnp.Message) (TlogMarker, error) {
+	root, err := msg.RootPtr()
+	return TlogMarker{root.Struct()}, err
+}
+
+func (s TlogMarker) String() string {
+	str, _ := text.Marshal(0xa28b8b8e6a57e5ca, s.Struct)
+	return str
+}
+
+func (s TlogMarker) Name() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s TlogMarker) HasName() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s TlogMarker) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s TlogMarker) SetName(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+func (s TlogMarker) Sequence() uint64 {
+	return s.Struct.Uint64(0)
+}
+
+func (s TlogMarker) SetSequence(v uint64) {
+	s.Struct.SetUint64(0, v)
+}
+
+func (s TlogMarker) Timestamp() int64 {
+	return int64(s.Struct.Uint64(8))
+}
+
+func (s TlogMarker) SetTimestamp(v int64) {
+	s.Struct.SetUint64(8, uint64(v))
+}
+
+// TlogMarker_List is a list of TlogMarker.
+type TlogMarker_List struct{ capnp.List }
+
+// NewTlogMarker creates a new list of TlogMarker.
+func NewTlogMarker_List(s *capnp.Segment, sz int32) (TlogMarker_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1}, sz)
+	return TlogMarker_List{l}, err
+}
+
+func (s TlogMarker_List) At(i int) TlogMarker { return TlogMarker{s.List.Struct(i)} }
+
+func (s TlogMarker_List) Set(i int, v TlogMarker) error { return s.List.SetStruct(i, v.Struct) }
+
+func (s TlogMarker_List) String() string {
+	str, _ := text.MarshalList(0xa28b8b8e6a57e5ca, s.List)
+	return str
+}
+
+// TlogMarker_Promise is a wrapper for a TlogMarker promised by a client call.
+type TlogMarker_Promise struct{ *capnp.Pipeline }
+
+func (p TlogMarker_Promise) Struct() (TlogMarker, error) {
+	s, err := p.Pipeline.Struct()
+	return TlogMarker{s}, err
+}
+
+const schema_f4533cbae6e08506 = "x\xda\xa4Um\x88TU\x18~\x9fs\xee|(\xfb" +
+	"u\x99\x09\\!\xd6L\xc8\x16\xcb]\xb7\xfe,\xc2\xba" +
+	"\xdbj\xba\xb4\xb1gF\x10D\xa8\xeb\xccq\xe6\xba\xb3" +
+	"w\xc69w\xd6\xb52?\xc8\x1f\xeaR\x10\x1a&\x19" +
+	"\xba\xf8G\x08Bp#\xa2\xc0\xa0\xb2\x0f\xa26\xac\x94" +
+	",V\xd8\xd5~T$h\x18\xb5\xdd83;\x1f\xce" +
+	"\x8e\xa6\xf4o\xef\xcb\xb3\xef<\xcf\xf3>\xef{\xda\x9e" +
+	"\xe4\xabX\xbb\xef!\x83H\xb4\xf9\xfc\xde\xd5S\xdf," +
+	"\x1c}\xf1\xcb\x83$\x9a\xc1<\xff\xbe\xc9+\xef\xad\x8c" +
+	"^'\x1f\x02D\x1d\x7f\xe2(\x08\xa1y\xec*\xc1k" +
+	"?\xbfw\xe5O#\xd7F5\xd2\xa8@2\x8d\x9cf" +
+	"\x0b\x11\xba\x91\xff\xf3\x1a;\x07\x82wq\xc9\x81s\x97" +
+	"\x17N\x1c\xd1pT7\xfe\xd8X\x81\xd0\xb7F\x80(" +
+	"4al'x_\xb4\x1d\xfa\xe4\xfb\xfd\xcb\xde\xa8\xa2" +
+	"\xa1\x11\x1d\xdd\xbe1\xcdB\xf8\xde&x\x9fOo\xd8" +
+	"\xfa\xf2\xc1\x83c5\xf9^\xf3-F\x08~\xddv&" +
+	"\x8f\xee\xedo\x8f\xff0\xbd|\x9c\xcc\xe69\x1c\x8e\xf9" +
+	"_Eh<\x0f>\xed\xef\"xc\x13\x7f\xcc<\xf8" +
+	"\xfc\xaaO\xabZ\xafF\xc0G\xd41\xe1\xdf\x88\xd0\xb4" +
+	"\x86w\\\xf6\xb7h\x85g\xfb\xc6^\xf9\xee\xca\xf9\xc9" +
+	"\x9a\x0a\x11\x8c t_Pw7\x83Za\xe8\xcd\xbe" +
+	"\xaf\xce\\l\x9f\xaaB\xe7\x15\x0e\x05\x8f\"\xb4/\x0f" +
+	"\xde\x13\xd4T\x0eM6\xbfsf|\xebT\xb5\xca<" +
+	"\xfaD\xb0\x0f\xa1q\x8d\xee8\x1d\xdc\xa0\x99to\xf9" +
+	"\xec\xb5\x9dG\x0f\xffV\xcb\xbd\x07\xe6oD\xe8\xf1\xf9" +
+	"\xbaw\xfb\xfc\xabT\xe7\xa9XR\x0eY\xcb]_*" +
+	"\x9dx\xa6\xf0\xf1h\xcc\xca8\x99\xcehn\xb3\x8ae" +
+	"\xed\xcdr\xad\xe5\xc4U\xd2\x1a\x94\x11\xb9-'\xb9r" +
+	"E\x137\x88\x0c\x10\x99V\x0f\x91\xd8\xc4!\x92\x0c@" +
+	"\x18\xba&u\xedY\x0e\x91b0\x19\xc2`D\xa6\x9d" +
+	"%\x12I\x0e\xe12\x98|Q\x18\x9c\xc8\xdc\xd6G$" +
+	"2\x1c\xe2\x05\x86]\xc32\xab\xec\xb4\x83 1\x04\x09" +
+	"\xbb\x86\xe3\xb6\x1a\\\xd7\x8b:b\xa8#x\xca\xb5\xb2" +
+	"nTn\xa3\x96\x9ctb\x12\xf3\x88a\x1e\xc1\xdbn" +
+	"\xbb\xc9^\xcb\xb5\x88\x08 \x06h\xec\xac*>G\xd5" +
+	"\xfaT:\xd1\x93J\xf3\xd8\xe0\x00 \x16\x95\x84Lh" +
+	"*_s\x88K\x0cfQ\xc9\xc5\x15D\xe2<\x87\x98" +
+	"d\x00+\x08\xf9\xb1\x95H\\\xe0\x10SZ\x08\x0aB" +
+	".\xeb\xe2%\x0e\xf13\x83i\xb00\x0c\"s:B" +
+	"$\xa68\xc4\xef\x0c\xa6oA\x18>\"\xf3W]\xfc" +
+	"\x85C\xdcd0\xfd\xcda\xf8\x89\xcc\x1b\xfa\xc7\xafs" +
+	"D\xc0`\x06\x02a\x9d\x19sf1\x91\xb8\xc9\x115" +
+	"\xc0\xe0)\xed\xbd\x13\x93DTT\xdeb;q9\x02" +
+	"\x1f1\xf8\x08\x8dIK%QO\x0c\xf5\x84\xc6\xb8\xe5" +
+	"Z\xc5\x0f\xcf\xb5\x87\xa4r\xad!B\xa6\x88\xf6\xd2\x19" +
+	"\x99\xb5\\;Mp\xe0'\x06?\xc1\x93N,\x1d\xb7" +
+	"\x9d\x04\x11\x15k\x81\xac\xdcR\x9c\xc8\x7f\xb8\x1a\x91\xaa" +
+	"%\x93v\x94\xd4\xc6\x06K\xc6>\xdcI$\x96p\x88" +
+	"\xb6rB\x1e\xd1&,\xe3\x10k\x19\xba\x94k\xb99" +
+	"\x05F\x0c\x8c*\x84B\xa1\x810\xc0\x91\xd7\xdb@\xb8" +
+	"\xc7\xac\xaaL:\xe0()\xeaJTV\xeb`\xae\xe2" +
+	"\x10O\xe9\x19\x1b\x05.\xeb4\xbf^\x0e1P\x91\xd6" +
+	"\xfe1\"1\xc0!6\xcd\x0df5\xe1\x94\xa5\xdc5" +
+	"\xa9\x9cBR\xc6\xa3\x9a|\xa02\x9dw\xf6\xac\xdf\xca" +
+	"\x06\x06eV;\x96\xa7Y\xa0\xb4\xba\xb5\xcc\x13\xc8S" +
+	"_\xa7\x13\xb2\x96C\xac\x9fe\xa9\x03&\"e\x96\x8d" +
+	"\x8e5$\xcb\xbb27-5Sp{G7X\xb6" +
+	"\xab\x19\xde\xba\xfc\x0a\xae\xe6j\x94,\xad\xd7\x96\x069" +
+	"D\x98\xd5\xd8\xd8\xd9\xeeFM\xedO\xa4l\xe9\xb8\xfd" +
+	"R)\x8b'\xf2\xa1\x09s\xa3\xce\xf3\xf2}w\xea\xcd" +
+	"\x1b\xe1\x10/1\xdc\x8f\x7f\xbc\xd9\xe0\xec\xd9K$v" +
+	"s\x88Q\x86z6\xe3\x15\xa6\xb5\xff\x00\x91\x18\xe5\x10" +
+	"G\x18\xea\xf9\xdf^a'\x0fo$\x12\x878\xc4q" +
+	"\x86z\xe3/\xaf\xe0\xd91=\xee#\x1c\xe2$C\xcb" +
+	"\xe6T:6\x88\xa6\xf2cF@\x13\xc1\xdb\x92\xce\xc6" +
+	"\xe4\x9aT\x0e*\xd9\x9d?9\xe5cc\xd9\xee\xd3=" +
+	"\xbdQ\xa4\xaca\x19\xdd\xe1\xc4(\xe0\xc5m\x15K;" +
+	"\x8e$\x1es\xc9\xdf5de\x07e\x16M\xe5\xc7i" +
+	"\xb6\xeb\xed\xed\xa8ir\xc5\x0a\xf5\xd4X!][\xca" +
+	"!\x1e\xbb\x9b\xd3y/cV\x99\xb4\xc3\x95\xac\x9as" +
+	"gy\xce]r\xc4V\xae\x9aslk\x8f\xb9;\x91" +
+	"\xc8\xca\x84>5\x0e\x91n\xba\xa0\xd4\xf4\xf5\xd6\xf2\x84" +
+	"\x8a\xba\x8e\xb5\x96\xe7SZ\xc7\x13:\xe8\xc79\xc4[" +
+	"\x157\xf7\x94\xa6t\x92C\x9c\xad\xb8\xb9\x1f\xe8\x7f\x7f" +
+	"\x97C|\xa4o./\xdc\xdc\x0f\xb5W\xefs\x88\x0b" +
+	"U{\xd2\xa8\xec\xe7\xe4\x9d\x16\xa4+\x9f\x90\xd2!\xba" +
+	"5)\x0d\x84\xc6LV\x0e\x17\xef\xec\xae\xc2\xe4+\xd0" +
+	"\x95\x09h\xb8\xdb\x04T\xf8_\xfb\x9d-\x9d.\xd9Y" +
+	"\xeb\xa1\xd5\xa7+\xc5!F\xb4WK\x0b^\xe5\xf4\xeb" +
+	"\xebr\x88\xdd\xff\xf7\x9em\x9f\x0d\x0c\xb5D\xa4\x15\xdf" +
+	"Q\x0c\xc1\xbf\x03\x00g\xe9\x91B"
 
 func init() {
 	schemas.Register(schema_f4533cbae6e08506,
+		0x8bcd7e8c1ad2a9e7,
 		0x8cf178de3c82d431,
 		0x98d11ae1c78a24d9,
+		0x9c2c89d7c69430cb,
+		0xa28b8b8e6a57e5ca,
 		0xb52fe5db64314d44,
 		0xc8407b23fdf6d1a2,
 		0xe0d4e6d68fa24ac0,
@@ -32,5 +32,7 @@ func CopyBlock(dst, src *TlogBlock) error {
 
 	dst.SetTimestamp(src.Timestamp())
 	dst.SetOperation(src.Operation())
+	dst.SetEncoding(src.Encoding())
+	dst.SetRef(src.Ref())
 	return nil
 }
@@ -28,6 +28,8 @@ func (status BlockStatus) String() string {
 		return "ForceFlushCommandReceived"
 	case BlockStatusReady:
 		return "ReadyToReceiveBlock"
+	case BlockStatusRecvBusy:
+		return "RecvBusy"
 	default:
 		return "Unknown"
 	}
@@ -60,6 +62,10 @@ const (
 	BlockStatusWaitNbdSlaveSyncReceived BlockStatus = 4
 	BlockStatusDisconnected             BlockStatus = 5
 	BlockStatusReady                    BlockStatus = 6
+	// returned when the block wasn't accepted,
+	// because the vdisk has reached its max amount of buffered blocks,
+	// the block has to be resent later
+	BlockStatusRecvBusy BlockStatus = 7
 )
 
 // HandshakeStatus is returned by the Tlog server
@@ -5,6 +5,7 @@ import (
 
 	"zombiezen.com/go/capnproto2"
 
+	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/schema"
 )
 
@@ -17,5 +18,10 @@ func (c *Client) decodeCapnp(data []byte) (*schema.TlogAggregation, error) {
 	}
 
 	agg, err := schema.ReadRootTlogAggregation(msg)
-	return &agg, err
+	if err != nil {
+		return nil, err
+	}
+
+	// decode the (deduplicated and/or delta-encoded) block data
+	return tlog.DecodeAggregation(&agg)
 }
@@ -16,6 +16,11 @@ import (
 var (
 	// ErrNoFlushedBlock returned when there is no flushed block for a vdisk
 	ErrNoFlushedBlock = errors.New("no flushed block")
+
+	// ErrConflictingWriter returned when the tlog of a vdisk
+	// is written to by another client at the same time,
+	// e.g. two tlogservers storing into the same 0-stor cluster
+	ErrConflictingWriter = errors.New("tlog is written by another client")
 )
 
 // Config defines the 0-stor client config
@@ -52,6 +57,11 @@ type Client struct {
 	// we need to store it so we still know it after restart
 	lastMetaEtcdKey  []byte
 	firstMetaEtcdKey []byte
+	// last meta key as we last stored (or loaded) it in the metadata server,
+	// used to detect another client writing the tlog of this vdisk
+	storedLastMetaKey []byte
+	// set once another client writing the tlog of this vdisk was detected
+	conflictingWriter bool
 
 	lastMd *meta.Meta
 
@@ -134,6 +144,9 @@ func (c *Client) ProcessStoreAgg(agg *tlog.Aggregation) ([]byte, error) {
 }
 
 func (c *Client) processStoreData(data []byte, lastSequence uint64, timestamp int64) error {
+	if c.conflictingWriter {
+		return errors.Wrapf(ErrConflictingWriter, "vdisk %s", c.vdiskID)
+	}
 
 	key := c.hasher.Hash(append([]byte(c.vdiskID), data...))
 
@@ -162,7 +175,10 @@ func (c *Client) processStoreData(data []byte, lastSequence uint64, timestamp in
 
 	c.lastMd = lastMd
 	c.lastMetaKey = key
-	c.lastSequence = lastSequence
+	// an aggregation without blocks only contains markers
+	if lastSequence > 0 {
+		c.lastSequence = lastSequence
+	}
 
 	c.storeNum++
 	// we don't store last sequence on each iteration because
@@ -172,7 +188,7 @@ func (c *Client) processStoreData(data []byte, lastSequence uint64, timestamp in
 	// on startup.
 	const lastMetaKeyStoreInterval = 25
 	if c.storeNum%lastMetaKeyStoreInterval == 0 {
-		if err := c.saveLastMetaKey(); err != nil {
+		if err := c.updateLastMetaKey(); err != nil {
 			return err
 		}
 	}
@@ -261,6 +277,7 @@ func (c *Client) LoadLastSequence() (uint64, error) {
 			return 0, err
 		}
 		c.lastMetaKey = lastMetaKey
+		c.storedLastMetaKey = lastMetaKey
 		if c.lastMetaKey == nil {
 			c.lastMetaKey = c.firstMetaKey
 		}
@@ -275,29 +292,47 @@ func (c *Client) LoadLastSequence() (uint64, error) {
 	}
 	c.lastMetaKey = lastMeta.Key
 
-	// get last aggregation
-	data, _, err := c.storClient.Read(c.lastMetaKey)
-	if err != nil {
-		return 0, err
-	}
+	// get the last aggregation which contains blocks,
+	// skipping the aggregations which only contain markers
+	key := c.lastMetaKey
+	for {
+		data, _, err := c.storClient.Read(key)
+		if err != nil {
+			return 0, err
+		}
 
-	// decode aggregation
-	agg, err := c.decodeCapnp(data)
-	if err != nil {
-		return 0, err
-	}
+		// decode aggregation
+		agg, err := c.decodeCapnp(data)
+		if err != nil {
+			return 0, err
+		}
 
-	blocks, err := agg.Blocks()
-	if err != nil {
-		return 0, err
-	}
-	if blocks.Len() == 0 {
-		return 0, errors.New("empty blocks for aggregation")
-	}
+		blocks, size, err := tlog.AggregationBlocks(agg)
+		if err != nil {
+			return 0, err
+		}
+		if size > 0 {
+			c.lastSequence = blocks.At(size - 1).Sequence()
+			return c.lastSequence, nil
+		}
 
-	c.lastSequence = blocks.At(blocks.Len() - 1).Sequence()
+		md, err := c.storClient.GetMeta(key)
+		if err != nil {
+			return 0, err
+		}
+		if md.Previous == nil {
+			return 0, ErrNoFlushedBlock
+		}
+		key = md.Previous
+	}
+}
 
-	return c.lastSequence, nil
+// LastSequence returns the last sequence stored by this client,
+// or loaded by LoadLastSequence.
+func (c *Client) LastSequence() uint64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.lastSequence
 }
 
 // find last metadata of this vdisk
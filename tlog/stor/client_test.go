@@ -1,6 +1,7 @@
 package stor
 
 import (
+	"bytes"
 	"crypto/rand"
 	"testing"
 
@@ -8,6 +9,8 @@ import (
 	"github.com/zero-os/0-stor/client/meta/embedserver"
 	"zombiezen.com/go/capnproto2"
 
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/schema"
 	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
@@ -71,6 +74,365 @@ func TestRoundTrip(t *testing.T) {
 	require.Equal(t, numData, i)
 }
 
+func TestWalkFromSequence(t *testing.T) {
+	const (
+		vdiskID      = "12345678"
+		numData      = 10
+		aggSize      = 2
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	cli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	// nothing to walk yet
+	for wr := range cli.WalkFromSequence(tlog.FirstSequence, tlog.TimeNowTimestamp()) {
+		t.Fatalf("unexpected walk result: %v", wr)
+	}
+
+	// store the data, with aggSize sequences per aggregation
+	seq := tlog.FirstSequence
+	for i := 0; i < numData; i++ {
+		agg, err := tlog.NewAggregation(nil, aggSize)
+		require.NoError(t, err)
+
+		for j := 0; j < aggSize; j++ {
+			val := make([]byte, 1024)
+			rand.Read(val)
+			block := encodeBlock(t, val)
+			block.SetSequence(seq)
+			seq++
+
+			err = agg.AddBlock(block)
+			require.NoError(t, err)
+		}
+
+		_, err = cli.ProcessStoreAgg(agg)
+		require.Nil(t, err)
+	}
+	lastSeq := seq - 1
+
+	testWalk := func(startSeq, expectedFirstSeq uint64) {
+		expectedSeq := expectedFirstSeq
+		for wr := range cli.WalkFromSequence(startSeq, tlog.TimeNowTimestamp()) {
+			require.Nil(t, wr.Err)
+			blocks, err := wr.Agg.Blocks()
+			require.Nil(t, err)
+			for i := 0; i < blocks.Len(); i++ {
+				require.Equal(t, expectedSeq, blocks.At(i).Sequence(), "start sequence %d", startSeq)
+				expectedSeq++
+			}
+		}
+		require.Equal(t, lastSeq+1, expectedSeq, "start sequence %d", startSeq)
+	}
+
+	// the walk starts at the aggregation which contains the given sequence
+	testWalk(0, tlog.FirstSequence)
+	testWalk(tlog.FirstSequence, tlog.FirstSequence)
+	testWalk(tlog.FirstSequence+1, tlog.FirstSequence)
+	testWalk(5, 5)
+	testWalk(6, 5)
+	testWalk(lastSeq, lastSeq-1)
+	// or at the last aggregation, if the sequence wasn't flushed yet
+	testWalk(lastSeq+5, lastSeq-1)
+
+	// or at the first aggregation, if the sequence is too far back in the history
+	originalMaxWalkBack := maxSequenceWalkBack
+	maxSequenceWalkBack = 2
+	testWalk(lastSeq-2, lastSeq-3)
+	testWalk(5, tlog.FirstSequence)
+	maxSequenceWalkBack = originalMaxWalkBack
+
+	// a new client loads its last sequence, and can thus walk back from it as well
+	cli = createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+	testWalk(6, 5)
+}
+
+// the last sequence is loaded from the last aggregation,
+// even when that aggregation was flushed before it was full
+func TestLoadLastSequence(t *testing.T) {
+	const (
+		vdiskID      = "12345678"
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	cli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	_, err = cli.LoadLastSequence()
+	require.Equal(t, ErrNoFlushedBlock, errors.Cause(err))
+
+	// an aggregation with room for 4 blocks, only containing 2 blocks
+	agg, err := tlog.NewAggregation(nil, 4)
+	require.NoError(t, err)
+	for seq := uint64(1); seq <= 2; seq++ {
+		val := make([]byte, 1024)
+		rand.Read(val)
+		block := encodeBlock(t, val)
+		block.SetSequence(seq)
+		err = agg.AddBlock(block)
+		require.NoError(t, err)
+	}
+	_, err = cli.ProcessStoreAgg(agg)
+	require.NoError(t, err)
+
+	// a new client has to load the last sequence from the stored aggregation
+	cli = createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+	lastSeq, err := cli.LoadLastSequence()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), lastSeq)
+}
+
+// Test that two clients writing the tlog of the same vdisk
+// into the same 0-stor cluster are detected.
+func TestConflictingWriter(t *testing.T) {
+	const (
+		vdiskID      = "12345678"
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	cli1 := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+	cli2 := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	storeAgg := func(cli *Client, seq uint64) error {
+		agg, err := tlog.NewAggregation(nil, 1)
+		require.NoError(t, err)
+		val := make([]byte, 1024)
+		rand.Read(val)
+		block := encodeBlock(t, val)
+		block.SetSequence(seq)
+		require.NoError(t, agg.AddBlock(block))
+		_, err = cli.ProcessStoreAgg(agg)
+		return err
+	}
+
+	// the first client stores its last meta key after 25 aggregations
+	for seq := uint64(1); seq <= 25; seq++ {
+		require.NoError(t, storeAgg(cli1, seq))
+	}
+
+	// the second client detects it is not the only writer,
+	// once it wants to store its last meta key
+	for seq := uint64(1); seq < 25; seq++ {
+		require.NoError(t, storeAgg(cli2, seq))
+	}
+	err = storeAgg(cli2, 25)
+	require.Equal(t, ErrConflictingWriter, errors.Cause(err))
+	err = storeAgg(cli2, 26)
+	require.Equal(t, ErrConflictingWriter, errors.Cause(err))
+
+	// while the first client can continue
+	for seq := uint64(26); seq <= 50; seq++ {
+		require.NoError(t, storeAgg(cli1, seq))
+	}
+}
+
+func TestFindMarker(t *testing.T) {
+	const (
+		vdiskID      = "12345678"
+		numData      = 10
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	cli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	_, err = cli.FindMarker("foo")
+	require.Equal(t, ErrMarkerNotFound, errors.Cause(err))
+
+	// store the data, with a marker in some of the aggregations
+	for i := 0; i < numData; i++ {
+		val := make([]byte, 1024)
+		rand.Read(val)
+
+		block := encodeBlock(t, val)
+		block.SetSequence(uint64(i + 1))
+
+		agg, err := tlog.NewAggregation(nil, 1)
+		require.NoError(t, err)
+
+		err = agg.AddBlock(block)
+		require.NoError(t, err)
+
+		switch i {
+		case 2, 6:
+			agg.AddMarker(tlog.Marker{Name: "foo", Sequence: uint64(i + 1), Timestamp: int64(i)})
+		case 4:
+			agg.AddMarker(tlog.Marker{Name: "bar", Sequence: uint64(i + 1), Timestamp: int64(i)})
+		}
+
+		_, err = cli.ProcessStoreAgg(agg)
+		require.Nil(t, err)
+	}
+
+	// the last marker with a given name is returned
+	marker, err := cli.FindMarker("foo")
+	require.NoError(t, err)
+	require.Equal(t, tlog.Marker{Name: "foo", Sequence: 7, Timestamp: 6}, marker)
+
+	marker, err = cli.FindMarker("bar")
+	require.NoError(t, err)
+	require.Equal(t, tlog.Marker{Name: "bar", Sequence: 5, Timestamp: 4}, marker)
+
+	_, err = cli.FindMarker("baz")
+	require.Equal(t, ErrMarkerNotFound, errors.Cause(err))
+}
+
+func TestDataEncodingRoundTrip(t *testing.T) {
+	const (
+		vdiskID      = "12345678"
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	cli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	base := make([]byte, 4096)
+	rand.Read(base)
+	other := make([]byte, 4096)
+	rand.Read(other)
+	changed := make([]byte, 4096)
+	copy(changed, base)
+	copy(changed[100:], []byte("changed"))
+
+	// index 0 is written 3 times with the same data, then changed in-place,
+	// index 1 is written with the same data as index 0, and then deleted.
+	type testBlock struct {
+		index int64
+		op    uint8
+		data  []byte
+	}
+	testBlocks := []testBlock{
+		{0, schema.OpSet, base},
+		{0, schema.OpSet, base},
+		{1, schema.OpSet, base},
+		{2, schema.OpSet, other},
+		{0, schema.OpSet, changed},
+		{1, schema.OpDelete, nil},
+		{0, schema.OpSet, base},
+	}
+
+	// store the data, with all encodings enabled
+	agg, err := tlog.NewAggregation(nil, len(testBlocks))
+	require.NoError(t, err)
+	agg.SetDataEncoding(true, true)
+
+	for i, tb := range testBlocks {
+		block := encodeBlock(t, tb.data)
+		block.SetSequence(uint64(i))
+		block.SetIndex(tb.index)
+		block.SetOperation(tb.op)
+		if tb.data != nil {
+			err = block.SetHash(zerodisk.HashBytes(tb.data))
+			require.NoError(t, err)
+		}
+
+		err = agg.AddBlock(block)
+		require.NoError(t, err)
+	}
+
+	// identical data is deduplicated and small changes are delta-encoded
+	encoded, err := agg.Encode()
+	require.NoError(t, err)
+	msg, err := capnp.NewDecoder(bytes.NewReader(encoded)).Decode()
+	require.NoError(t, err)
+	encodedAgg, err := schema.ReadRootTlogAggregation(msg)
+	require.NoError(t, err)
+	encodedBlocks, err := encodedAgg.Blocks()
+	require.NoError(t, err)
+	expectedEncodings := []uint8{
+		schema.EncodingRaw,
+		schema.EncodingDedup,
+		schema.EncodingDedup,
+		schema.EncodingRaw,
+		schema.EncodingDelta,
+		schema.EncodingRaw,
+		schema.EncodingDedup,
+	}
+	for i, encoding := range expectedEncodings {
+		require.Equal(t, encoding, encodedBlocks.At(i).Encoding(), "block %d", i)
+	}
+
+	_, err = cli.ProcessStoreAgg(agg)
+	require.Nil(t, err)
+
+	// the stored data is decoded transparently
+	var numAggs int
+	for wr := range cli.Walk(0, tlog.TimeNowTimestamp()) {
+		require.Nil(t, wr.Err)
+		numAggs++
+
+		blocks, err := wr.Agg.Blocks()
+		require.Nil(t, err)
+		require.Equal(t, len(testBlocks), blocks.Len())
+
+		for i, tb := range testBlocks {
+			block := blocks.At(i)
+			require.Equal(t, uint8(schema.EncodingRaw), block.Encoding())
+			require.Equal(t, uint64(i), block.Sequence())
+			require.Equal(t, tb.index, block.Index())
+			require.Equal(t, tb.op, block.Operation())
+
+			data, err := block.Data()
+			require.Nil(t, err)
+			require.Equal(t, len(tb.data), len(data))
+			if tb.data != nil {
+				require.Equal(t, tb.data, data)
+			}
+		}
+	}
+	require.Equal(t, 1, numAggs)
+}
+
 func encodeBlock(t *testing.T, data []byte) *schema.TlogBlock {
 	buf := make([]byte, 0, 4096)
 	_, seg, err := capnp.NewMessage(capnp.SingleSegment(buf))
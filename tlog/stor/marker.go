@@ -0,0 +1,39 @@
+package stor
+
+import (
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/tlog"
+)
+
+var (
+	// ErrMarkerNotFound returned when no tlog marker with the given name exists
+	ErrMarkerNotFound = errors.New("tlog marker not found")
+)
+
+// FindMarker walks the entire history of the vdisk,
+// and returns the last marker stored with the given name.
+func (c *Client) FindMarker(name string) (tlog.Marker, error) {
+	var (
+		marker tlog.Marker
+		found  bool
+	)
+	for wr := range c.Walk(0, tlog.TimeNowTimestamp()) {
+		if wr.Err != nil {
+			return tlog.Marker{}, wr.Err
+		}
+
+		markers, err := tlog.AggregationMarkers(wr.Agg)
+		if err != nil {
+			return tlog.Marker{}, errors.Wrap(err, "couldn't get markers of tlog aggregation")
+		}
+		for _, m := range markers {
+			if m.Name == name {
+				marker, found = m, true
+			}
+		}
+	}
+	if !found {
+		return tlog.Marker{}, errors.Wrapf(ErrMarkerNotFound, "marker %q", name)
+	}
+	return marker, nil
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/coreos/etcd/clientv3"
+
+	"github.com/zero-os/0-Disk/errors"
 )
 
 const (
@@ -63,6 +65,31 @@ func (cli *MetaClient) SaveMeta(key, val []byte) error {
 	return err
 }
 
+// compareAndSaveMeta stores metadata to the given metadata server,
+// only if the current metadata equals the old metadata,
+// where nil old metadata means that no metadata may exist yet.
+// It returns false if the metadata wasn't stored, as it was different.
+func (cli *MetaClient) compareAndSaveMeta(key, old, val []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metaOpTimeout)
+	defer cancel()
+
+	var cmp clientv3.Cmp
+	if old == nil {
+		cmp = clientv3.Compare(clientv3.CreateRevision(string(key)), "=", 0)
+	} else {
+		cmp = clientv3.Compare(clientv3.Value(string(key)), "=", string(old))
+	}
+
+	resp, err := cli.cli.Txn(ctx).
+		If(cmp).
+		Then(clientv3.OpPut(string(key), string(val))).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
 func (cli *MetaClient) deleteMeta(key []byte) error {
 	ctx, cancel := context.WithTimeout(context.Background(), metaOpTimeout)
 	defer cancel()
@@ -85,5 +112,26 @@ func (c *Client) getLastMetaKey() ([]byte, error) {
 }
 
 func (c *Client) saveLastMetaKey() error {
-	return c.metaCli.SaveMeta(c.lastMetaEtcdKey, c.lastMetaKey)
+	err := c.metaCli.SaveMeta(c.lastMetaEtcdKey, c.lastMetaKey)
+	if err != nil {
+		return err
+	}
+	c.storedLastMetaKey = c.lastMetaKey
+	return nil
+}
+
+// updateLastMetaKey stores the last meta key, only if the last meta key
+// stored in the metadata server is still the one we stored (or loaded),
+// as it is overwritten otherwise by another client writing the tlog of the same vdisk.
+func (c *Client) updateLastMetaKey() error {
+	ok, err := c.metaCli.compareAndSaveMeta(c.lastMetaEtcdKey, c.storedLastMetaKey, c.lastMetaKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.conflictingWriter = true
+		return errors.Wrapf(ErrConflictingWriter, "vdisk %s", c.vdiskID)
+	}
+	c.storedLastMetaKey = c.lastMetaKey
+	return nil
 }
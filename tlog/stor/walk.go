@@ -1,6 +1,9 @@
 package stor
 
 import (
+	"bytes"
+
+	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/schema"
 	"github.com/zero-os/0-stor/client/meta"
 )
@@ -38,32 +41,117 @@ func (c *Client) Walk(fromEpoch, toEpoch int64) <-chan *WalkResult {
 			return
 		}
 
-		for res := range c.storClient.Walk([]byte(c.firstMetaKey), fromEpoch, toEpoch) {
-			wr := &WalkResult{
-				StorKey: res.Key,
-				Meta:    res.Meta,
-				Data:    res.Data,
-				RefList: res.RefList,
-			}
-
-			// make sure it is not error
-			if res.Error != nil {
-				wr.Err = res.Error
-				wrCh <- wr
-				return
-			}
-
-			// decode capnp
-			agg, err := c.decodeCapnp(res.Data)
-			if err != nil {
-				wr.Err = err
-				wrCh <- wr
-				return
-			}
-
-			wr.Agg = agg
-			wrCh <- wr
+		c.walk(c.firstMetaKey, fromEpoch, toEpoch, wrCh)
+	}()
+	return wrCh
+}
+
+// WalkFromSequence walks the history up to toEpoch,
+// starting from the aggregation which contains the given sequence,
+// or the first aggregation flushed after it.
+// The start of the walk is found by walking back from the last aggregation,
+// such that recent sequences can be found without walking the entire history,
+// while the entire history is walked in case the sequence is older than
+// the last maxSequenceWalkBack aggregations.
+func (c *Client) WalkFromSequence(seq uint64, toEpoch int64) <-chan *WalkResult {
+	wrCh := make(chan *WalkResult, 2)
+	go func() {
+		defer close(wrCh)
+
+		startKey, err := c.findSequenceMetaKey(seq)
+		if err != nil {
+			wrCh <- &WalkResult{Err: err}
+			return
 		}
+		if len(startKey) == 0 {
+			// we have no data yet
+			return
+		}
+
+		c.walk(startKey, 0, toEpoch, wrCh)
 	}()
 	return wrCh
 }
+
+// findSequenceMetaKey returns the key of the last aggregation
+// which starts at or before the given sequence,
+// or the key of the first aggregation if there is no such aggregation
+// within the last maxSequenceWalkBack aggregations.
+func (c *Client) findSequenceMetaKey(seq uint64) ([]byte, error) {
+	c.mux.Lock()
+	firstKey, key := c.firstMetaKey, c.lastMetaKey
+	c.mux.Unlock()
+
+	if len(key) == 0 || seq <= tlog.FirstSequence {
+		return firstKey, nil
+	}
+
+	for steps := 0; !bytes.Equal(key, firstKey); steps++ {
+		if steps == maxSequenceWalkBack {
+			// walk from the first aggregation instead,
+			// rather than reading each aggregation twice
+			// for a sequence which is far back in the history
+			return firstKey, nil
+		}
+		data, _, err := c.storClient.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		agg, err := c.decodeCapnp(data)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := agg.Blocks()
+		if err != nil {
+			return nil, err
+		}
+		if agg.Size() > 0 && blocks.Len() > 0 && blocks.At(0).Sequence() <= seq {
+			return key, nil
+		}
+
+		md, err := c.storClient.GetMeta(key)
+		if err != nil {
+			return nil, err
+		}
+		if md.Previous == nil {
+			break
+		}
+		key = md.Previous
+	}
+	return key, nil
+}
+
+// walk the history from the given key, from fromEpoch to toEpoch,
+// sending all results to the given channel.
+func (c *Client) walk(startKey []byte, fromEpoch, toEpoch int64, wrCh chan<- *WalkResult) {
+	for res := range c.storClient.Walk(startKey, fromEpoch, toEpoch) {
+		wr := &WalkResult{
+			StorKey: res.Key,
+			Meta:    res.Meta,
+			Data:    res.Data,
+			RefList: res.RefList,
+		}
+
+		// make sure it is not error
+		if res.Error != nil {
+			wr.Err = res.Error
+			wrCh <- wr
+			return
+		}
+
+		// decode capnp
+		agg, err := c.decodeCapnp(res.Data)
+		if err != nil {
+			wr.Err = err
+			wrCh <- wr
+			return
+		}
+
+		wr.Agg = agg
+		wrCh <- wr
+	}
+}
+
+// maxSequenceWalkBack defines the maximum amount of aggregations
+// walked back from the last aggregation, to find the aggregation of a sequence.
+var maxSequenceWalkBack = 64
@@ -196,6 +196,27 @@ func (b *Buffer) NeedResend(seq uint64) bool {
 	return ok
 }
 
+// NotReceived returns the blocks, starting from the given sequence,
+// which are not received by the server yet, ordered by sequence.
+func (b *Buffer) NotReceived(fromSeq uint64) []*schema.TlogBlock {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	var seqs []uint64
+	for seq := range b.entries {
+		if seq >= fromSeq {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Sort(Uint64Slice(seqs))
+
+	blocks := make([]*schema.TlogBlock, 0, len(seqs))
+	for _, seq := range seqs {
+		blocks = append(blocks, b.entries[seq].block)
+	}
+	return blocks
+}
+
 // get one timed out block from the buffer
 func (b *Buffer) getOne() *entry {
 	b.lock.Lock()
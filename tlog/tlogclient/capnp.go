@@ -80,6 +80,29 @@ func encodeForceFlushAtSeq(w io.Writer, seq uint64) error {
 	return capnp.NewEncoder(w).Encode(msg)
 }
 
+// encode and send Marker command
+func encodeMarker(w io.Writer, name string, seq uint64, timestamp int64) error {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return err
+	}
+	cmd, err := schema.NewRootTlogClientMessage(seg)
+	if err != nil {
+		return err
+	}
+	marker, err := cmd.NewMarker()
+	if err != nil {
+		return err
+	}
+	if err = marker.SetName(name); err != nil {
+		return err
+	}
+	marker.SetSequence(seq)
+	marker.SetTimestamp(timestamp)
+
+	return capnp.NewEncoder(w).Encode(msg)
+}
+
 // encode and send WaitNBDSlaveSync command
 func encodeWaitNBDSlaveSync(w io.Writer) error {
 	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
@@ -11,12 +11,14 @@ import (
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
 	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
 	"github.com/zero-os/0-Disk/tlog/tlogclient/blockbuffer"
 )
 
 const (
 	readTimeout          = 2 * time.Second
-	resendTimeoutDur     = 2 * time.Second // duration to wait before re-send the tlog.
+	resendTimeoutDur     = 2 * time.Second       // duration to wait before re-send the tlog.
+	busyResendDelay      = 20 * time.Millisecond // duration to wait before re-send the tlog, refused by a busy server.
 	failedFlushSleepTime = 10 * time.Second
 )
 
@@ -30,6 +32,9 @@ var (
 
 	// ErrFlushFailed returned when client failed to do flush
 	ErrFlushFailed = errors.New("tlogserver failed to flush")
+
+	// ErrInvalidMarkerName returned when inserting a marker without a name
+	ErrInvalidMarkerName = errors.New("tlog marker requires a name")
 )
 
 // Response defines a response from tlog server
@@ -62,6 +67,9 @@ type Client struct {
 	retryCommandCh chan command
 	respCh         chan *Result
 
+	// sequence of the first block refused by a busy server
+	busyCh chan uint64
+
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 
@@ -111,6 +119,8 @@ func newClient(servers []string, vdiskID string) (*Client, error) {
 		//	 before needed and then put it to this channel.
 		respCh: make(chan *Result, 3),
 
+		busyCh: make(chan uint64, 1),
+
 		serverReadyCh: make(chan struct{}, 1),
 	}
 
@@ -242,6 +252,14 @@ func (c *Client) runReceiver(ctx context.Context, cancelFunc context.CancelFunc)
 					if len(tr.Sequences) > 0 { // should always be true, but we anticipate.
 						c.blockBuffer.SetSent(tr.Sequences[0])
 					}
+				case tlog.BlockStatusRecvBusy:
+					// the block isn't marked as sent, and the server
+					// dropped the blocks sent after it, resend them all
+					if len(tr.Sequences) > 0 {
+						log.Debugf("tlogserver is busy, block %v of vdisk %v will be resent",
+							tr.Sequences[0], c.vdiskID)
+						c.signalBusy(tr.Sequences[0])
+					}
 				case tlog.BlockStatusReady:
 					if c.Ready() {
 						continue
@@ -393,6 +411,17 @@ func (c *Client) connect() error {
 	return nil
 }
 
+// signalBusy signals the resender that the server refused
+// the block with the given sequence, because it is busy.
+// Signals received while the resender is still busy with a previous one are dropped,
+// as the resender resends all blocks not received by the server.
+func (c *Client) signalBusy(seq uint64) {
+	select {
+	case c.busyCh <- seq:
+	default:
+	}
+}
+
 // goroutine which re-send the block.
 func (c *Client) resender() {
 	timeoutCh := c.blockBuffer.TimedOut(c.ctx)
@@ -401,28 +430,44 @@ func (c *Client) resender() {
 		case <-c.ctx.Done():
 			return
 		case block := <-timeoutCh:
-			seq := block.Sequence()
-
-			// check it once again, make sure this block still
-			// need to be re-send
-			if !c.blockBuffer.NeedResend(seq) {
-				continue
-			}
-
-			data, err := block.Data()
-			if err != nil {
-				log.Errorf("client resender failed to get data block:%v", err)
-				continue
+			c.resend(block)
+		case seq := <-c.busyCh:
+			// the server refused this block, and dropped all blocks sent after it,
+			// resend them right away, giving the server only a moment to make room
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(busyResendDelay):
 			}
-
-			err = c.Send(block.Operation(), seq, block.Index(), block.Timestamp(), data)
-			if err != nil {
-				log.Errorf("client resender failed to send data:%v", err)
+			for _, block := range c.blockBuffer.NotReceived(seq) {
+				c.resend(block)
 			}
 		}
 	}
 }
 
+// resend a block, unless it has been received by the server already.
+func (c *Client) resend(block *schema.TlogBlock) {
+	seq := block.Sequence()
+
+	// check it once again, make sure this block still
+	// need to be re-send
+	if !c.blockBuffer.NeedResend(seq) {
+		return
+	}
+
+	data, err := block.Data()
+	if err != nil {
+		log.Errorf("client resender failed to get data block:%v", err)
+		return
+	}
+
+	err = c.Send(block.Operation(), seq, block.Index(), block.Timestamp(), data)
+	if err != nil {
+		log.Errorf("client resender failed to send data:%v", err)
+	}
+}
+
 // do handshaking process to tlogserver
 // it returns true if tlogserver is ready
 func (c *Client) handshake() (ready bool, err error) {
@@ -503,8 +548,37 @@ func (c *Client) createConn() error {
 
 // ForceFlushAtSeq force flush at given sequence
 func (c *Client) ForceFlushAtSeq(seq uint64) error {
-	c.commandCh <- cmdForceFlushAtSeq{seq: seq}
-	return nil
+	select {
+	case c.commandCh <- cmdForceFlushAtSeq{seq: seq}:
+		return nil
+	case <-c.ctx.Done():
+		return ErrClientClosed
+	}
+}
+
+// InsertMarker inserts a named marker right after the given sequence,
+// and force flushes at that sequence, such that the marker is persisted
+// together with the aggregation containing that sequence.
+// A vdisk can later be restored up to (and including) that sequence,
+// by restoring it to the marker with the given name.
+func (c *Client) InsertMarker(name string, seq uint64) error {
+	if name == "" {
+		return ErrInvalidMarkerName
+	}
+	return c.insertMarker(cmdMarker{
+		name:      name,
+		seq:       seq,
+		timestamp: tlog.TimeNowTimestamp(),
+	})
+}
+
+func (c *Client) insertMarker(cmd cmdMarker) error {
+	select {
+	case c.commandCh <- cmd:
+		return nil
+	case <-c.ctx.Done():
+		return ErrClientClosed
+	}
 }
 
 // WaitNbdSlaveSync commands tlog server to wait
@@ -557,6 +631,7 @@ func (c *Client) signalCond(cond *sync.Cond) {
 // It returns error in these cases:
 // - failed to encode the capnp.
 // - failed to recover from broken network connection.
+// - the client was closed.
 func (c *Client) Send(op uint8, seq uint64, index int64, timestamp int64, data []byte) error {
 	cmd := cmdBlock{
 		op:         op,
@@ -566,8 +641,12 @@ func (c *Client) Send(op uint8, seq uint64, index int64, timestamp int64, data [
 		data:       data,
 		segmentBuf: c.capnpSegmentBuf,
 	}
-	c.commandCh <- cmd
-	return nil
+	select {
+	case c.commandCh <- cmd:
+		return nil
+	case <-c.ctx.Done():
+		return ErrClientClosed
+	}
 }
 
 // LastFlushedSequence returns tlog last flushed sequence
@@ -608,6 +687,15 @@ func (c *Client) WaitReady() {
 	c.mux.Unlock()
 }
 
+// clearReadySignal consumes the pending ready signal of the tlogserver, if any,
+// such that the receiver isn't blocked when nobody uses WaitReady.
+func (c *Client) clearReadySignal() {
+	select {
+	case <-c.serverReadyCh:
+	default:
+	}
+}
+
 // Disconnect disconnects client gracefully
 // It is on progress func which can only be used in unit test
 // See https://github.com/zero-os/0-Disk/issues/426 for the progress
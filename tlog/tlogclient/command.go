@@ -34,6 +34,16 @@ func (cmd cmdForceFlushAtSeq) encodeSend(w io.Writer) (*schema.TlogBlock, error)
 	return nil, encodeForceFlushAtSeq(w, cmd.seq)
 }
 
+type cmdMarker struct {
+	name      string
+	seq       uint64
+	timestamp int64
+}
+
+func (cmd cmdMarker) encodeSend(w io.Writer) (*schema.TlogBlock, error) {
+	return nil, encodeMarker(w, cmd.name, cmd.seq, cmd.timestamp)
+}
+
 type cmdWaitNbdSlaveSync struct {
 }
 
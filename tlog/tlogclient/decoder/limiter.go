@@ -146,3 +146,42 @@ func (lbt LimitBySequence) StartBlock(block schema.TlogBlock) bool {
 	}
 	return block.Sequence() >= lbt.startSeq
 }
+
+// LimitByMarker implements Limiter interface which is limited by
+// a start sequence and a tlog marker, such that all blocks
+// up to (and including) the sequence of that marker are decoded.
+type LimitByMarker struct {
+	LimitBySequence
+	marker tlog.Marker
+}
+
+// NewLimitByMarker creates new LimitByMarker object
+func NewLimitByMarker(startSeq uint64, marker tlog.Marker) LimitByMarker {
+	return LimitByMarker{
+		LimitBySequence: NewLimitBySequence(startSeq, marker.Sequence),
+		marker:          marker,
+	}
+}
+
+// Marker returns the marker this limiter is limited by
+func (lbm LimitByMarker) Marker() tlog.Marker {
+	return lbm.marker
+}
+
+// EndAgg implements Limiter.EndAgg
+func (lbm LimitByMarker) EndAgg(agg *schema.TlogAggregation, blocks schema.TlogBlock_List) bool {
+	if lbm.marker.Sequence == 0 {
+		return true
+	}
+	return lbm.LimitBySequence.EndAgg(agg, blocks)
+}
+
+// EndBlock implementes Limiter.EndBlock
+func (lbm LimitByMarker) EndBlock(block schema.TlogBlock) bool {
+	// a marker inserted before the first sequence,
+	// marks the state of an empty vdisk
+	if lbm.marker.Sequence == 0 {
+		return true
+	}
+	return lbm.LimitBySequence.EndBlock(block)
+}
@@ -0,0 +1,596 @@
+package tlogclient
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
+)
+
+const (
+	// amount of commands which can be queued for a single tlogserver
+	memberQueueSize = 1024
+	// time a command waits for room in the full queue of a tlogserver,
+	// before that tlogserver is considered unable to keep up
+	memberStallTimeout = 3 * time.Second
+)
+
+var (
+	// ErrNoQuorum returned when less tlogservers are available
+	// than required by the write quorum of a MultiClient
+	ErrNoQuorum = errors.New("not enough tlogservers available for write quorum")
+
+	// errMemberStalled is used when a tlogserver can't keep up
+	// with the commands sent to it
+	errMemberStalled = errors.New("tlogserver can't keep up")
+)
+
+// MultiClient defines a Tlog Client,
+// which streams all transactions to multiple tlogservers at once.
+// A sequence is only considered flushed once it is flushed
+// by at least the write quorum of those tlogservers.
+//
+// A tlogserver which is lost or can't keep up is excluded,
+// for as long as the remaining tlogservers still form a quorum,
+// such that the loss of a tlogserver causes at most a short write stall.
+// An excluded tlogserver is never used again by the same client.
+// Once a tlogserver can't be excluded, as it is required for the quorum,
+// the client fails, and all further commands return ErrNoQuorum.
+//
+// Each tlogserver is expected to store its tlog into its own 0-stor cluster,
+// as tlogservers sharing a 0-stor cluster corrupt each other's tlog.
+// Such a shared 0-stor cluster is only detected after the fact,
+// after which the tlogserver which detected it fails to flush.
+// This client is not thread/goroutine safe.
+type MultiClient struct {
+	vdiskID string
+	quorum  int
+	members []*member
+
+	respCh   chan *Result
+	resultCh chan memberResult
+
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	mux         sync.Mutex
+	lastFlushed uint64
+	ready       bool
+	readyCh     chan struct{}
+
+	// closed once the quorum is lost,
+	// quorumErr is protected by the mutex
+	noQuorumCh chan struct{}
+	quorumErr  error
+}
+
+// member is a single tlogserver used by a MultiClient
+type member struct {
+	client *Client
+	queue  chan command
+
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	// protected by the mutex of the MultiClient
+	addr   string
+	failed bool
+	ready  bool
+}
+
+// memberResult is a result received from a member
+type memberResult struct {
+	member *member
+	result *Result
+}
+
+// NewMulti creates a new tlog client for a vdisk,
+// which streams to all given tlogservers at once,
+// considering a sequence flushed once the given quorum of tlogservers flushed it.
+// ErrNoQuorum is returned if less tlogservers than the quorum are available.
+// The client is not goroutine safe.
+func NewMulti(servers []string, vdiskID string, quorum int) (*MultiClient, error) {
+	if len(servers) < 2 {
+		return nil, errors.New("multi tlogclient requires at least 2 tlogservers")
+	}
+	if quorum < 1 || quorum > len(servers) {
+		return nil, errors.Newf(
+			"invalid write quorum %d for %d tlogservers", quorum, len(servers))
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	mc := &MultiClient{
+		vdiskID:    vdiskID,
+		quorum:     quorum,
+		respCh:     make(chan *Result, 3),
+		resultCh:   make(chan memberResult),
+		ctx:        ctx,
+		cancelFunc: cancelFunc,
+		readyCh:    make(chan struct{}),
+		noQuorumCh: make(chan struct{}),
+	}
+
+	// connect to all tlogservers
+	for _, addr := range servers {
+		m := &member{addr: addr}
+		m.ctx, m.cancelFunc = context.WithCancel(ctx)
+		mc.members = append(mc.members, m)
+
+		client, err := New([]string{addr}, vdiskID)
+		if err != nil {
+			log.Errorf("multi tlogclient couldn't connect to tlogserver %s for vdisk %s: %v",
+				addr, vdiskID, err)
+			m.failed = true
+			m.cancelFunc()
+			continue
+		}
+		m.client = client
+		m.queue = make(chan command, memberQueueSize)
+		m.ready = client.Ready()
+	}
+
+	// exclude the ready tlogservers which are behind
+	for _, m := range mc.members {
+		if !m.failed && m.ready {
+			if seq := m.client.LastFlushedSequence(); seq > mc.lastFlushed {
+				mc.lastFlushed = seq
+			}
+		}
+	}
+	noQuorum := false
+	for _, m := range mc.members {
+		if !m.failed && m.ready && m.client.LastFlushedSequence() < mc.lastFlushed {
+			noQuorum = !mc.failMember(m, errors.Newf(
+				"tlogserver is behind (last flushed sequence %d < %d)",
+				m.client.LastFlushedSequence(), mc.lastFlushed)) || noQuorum
+		}
+	}
+	if noQuorum || len(mc.healthyMembers()) < quorum {
+		mc.Close()
+		return nil, errors.Wrapf(ErrNoQuorum, "vdisk %s", vdiskID)
+	}
+	if mc.countReady() >= quorum {
+		mc.ready = true
+		close(mc.readyCh)
+	}
+
+	for _, m := range mc.healthyMembers() {
+		go mc.runMemberSender(m)
+		go mc.runMemberReceiver(m)
+	}
+	go mc.runCollector()
+
+	return mc, nil
+}
+
+// Send sends the transaction tlog to all tlogservers.
+// It returns ErrClientClosed in case the client was closed.
+func (mc *MultiClient) Send(op uint8, seq uint64, index int64, timestamp int64, data []byte) error {
+	return mc.push(cmdBlock{
+		op:        op,
+		seq:       seq,
+		index:     index,
+		timestamp: timestamp,
+		data:      data,
+	})
+}
+
+// ForceFlushAtSeq force flush at given sequence on all tlogservers
+func (mc *MultiClient) ForceFlushAtSeq(seq uint64) error {
+	return mc.push(cmdForceFlushAtSeq{seq: seq})
+}
+
+// InsertMarker inserts a named marker right after the given sequence
+// on all tlogservers, and force flushes at that sequence.
+func (mc *MultiClient) InsertMarker(name string, seq uint64) error {
+	if name == "" {
+		return ErrInvalidMarkerName
+	}
+	return mc.push(cmdMarker{
+		name:      name,
+		seq:       seq,
+		timestamp: tlog.TimeNowTimestamp(),
+	})
+}
+
+// push a command to the queues of all healthy members
+func (mc *MultiClient) push(cmd command) error {
+	if mc.ctx.Err() != nil {
+		return ErrClientClosed
+	}
+	if err := mc.quorumError(); err != nil {
+		return err
+	}
+
+	for _, m := range mc.healthyMembers() {
+		select {
+		case m.queue <- cmd:
+			continue
+		default:
+		}
+
+		// the queue of the member is full, give it some time to catch up
+		timer := time.NewTimer(memberStallTimeout)
+		select {
+		case m.queue <- cmd:
+			timer.Stop()
+			continue
+		case <-m.ctx.Done():
+			timer.Stop()
+			continue
+		case <-mc.ctx.Done():
+			timer.Stop()
+			return ErrClientClosed
+		case <-mc.noQuorumCh:
+			timer.Stop()
+			return mc.quorumError()
+		case <-timer.C:
+		}
+
+		// the member can't keep up,
+		// exclude it, which fails this client if it is required for the quorum
+		if !mc.failMember(m, errMemberStalled) {
+			return mc.quorumError()
+		}
+	}
+
+	return nil
+}
+
+// WaitNbdSlaveSync commands all tlogservers to wait
+// for nbd slave to be fully synced,
+// returning an error if less than the quorum succeeded.
+func (mc *MultiClient) WaitNbdSlaveSync() error {
+	if err := mc.quorumError(); err != nil {
+		return err
+	}
+	members := mc.healthyMembers()
+	errCh := make(chan error, len(members))
+	for _, m := range members {
+		go func(m *member) {
+			errCh <- m.client.WaitNbdSlaveSync()
+		}(m)
+	}
+
+	var synced int
+	var err error
+	for range members {
+		if memberErr := <-errCh; memberErr != nil {
+			err = memberErr
+		} else {
+			synced++
+		}
+	}
+	if synced < mc.quorum {
+		if err == nil {
+			err = ErrNoQuorum
+		}
+		return err
+	}
+	return nil
+}
+
+// ChangeServerAddresses changes the tlogserver of each member
+// to the server at the same position in the given servers.
+func (mc *MultiClient) ChangeServerAddresses(servers []string) {
+	healthy := mc.healthyMembers()
+	for i, m := range mc.members {
+		if i >= len(servers) || !containsMember(healthy, m) {
+			continue
+		}
+
+		mc.mux.Lock()
+		addr := m.addr
+		m.addr = servers[i]
+		mc.mux.Unlock()
+		if addr == servers[i] {
+			continue
+		}
+
+		log.Infof("multi tlogclient vdisk '%v' change server addr '%v' to '%v'",
+			mc.vdiskID, addr, servers[i])
+		m.client.ChangeServerAddresses([]string{servers[i]})
+	}
+}
+
+// Recv get channel of responses and errors (Result)
+func (mc *MultiClient) Recv() <-chan *Result {
+	return mc.respCh
+}
+
+// LastFlushedSequence returns the last sequence
+// flushed by the quorum of tlogservers
+func (mc *MultiClient) LastFlushedSequence() uint64 {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	return mc.lastFlushed
+}
+
+// Ready returns true if the quorum of tlogservers is ready
+// and thus this client is ready to be used
+func (mc *MultiClient) Ready() bool {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	return mc.ready
+}
+
+// WaitReady waits until the quorum of tlogservers is ready
+func (mc *MultiClient) WaitReady() {
+	select {
+	case <-mc.readyCh:
+	case <-mc.ctx.Done():
+	}
+}
+
+// Close the connections to all tlogservers, making this client invalid.
+// It is user responsibility to call this function.
+func (mc *MultiClient) Close() error {
+	mc.cancelFunc()
+
+	var errs errors.ErrorSlice
+	for _, m := range mc.members {
+		if m.client != nil {
+			errs.Add(m.client.Close())
+		}
+	}
+	return errs.AsError()
+}
+
+// sends all queued commands of a member to its tlogserver
+func (mc *MultiClient) runMemberSender(m *member) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case cmd := <-m.queue:
+			var err error
+			switch cmd := cmd.(type) {
+			case cmdBlock:
+				err = m.client.Send(cmd.op, cmd.seq, cmd.index, cmd.timestamp, cmd.data)
+			case cmdForceFlushAtSeq:
+				err = m.client.ForceFlushAtSeq(cmd.seq)
+			case cmdMarker:
+				err = m.client.insertMarker(cmd)
+			}
+			if err != nil {
+				mc.failMember(m, errors.Wrap(err, "failed to send to tlogserver"))
+				return
+			}
+		}
+	}
+}
+
+// forwards all results of a member to the collector
+func (mc *MultiClient) runMemberReceiver(m *member) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case res := <-m.client.Recv():
+			if res.Resp != nil && res.Resp.Status == tlog.BlockStatusReady {
+				// nobody waits on the member itself
+				m.client.clearReadySignal()
+			}
+			select {
+			case mc.resultCh <- memberResult{member: m, result: res}:
+			case <-m.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// collects the results of all members,
+// turning them into the results of this client
+func (mc *MultiClient) runCollector() {
+	for {
+		select {
+		case <-mc.ctx.Done():
+			return
+		case mr := <-mc.resultCh:
+			res := mc.handleMemberResult(mr.member, mr.result)
+			if res == nil {
+				continue
+			}
+			select {
+			case mc.respCh <- res:
+			case <-mc.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// handle a result of a member,
+// returning the result to be forwarded to the user, if any
+func (mc *MultiClient) handleMemberResult(m *member, res *Result) *Result {
+	if res.Err != nil {
+		if mc.failMember(m, res.Err) {
+			return nil
+		}
+		return res
+	}
+	if res.Resp == nil {
+		return nil
+	}
+
+	switch res.Resp.Status {
+	case tlog.BlockStatusFlushOK:
+		return mc.updateFlushed()
+
+	case tlog.BlockStatusReady:
+		return mc.memberReady(m)
+
+	case tlog.BlockStatusFlushFailed:
+		log.Errorf("multi tlogclient: tlogserver %s failed to flush for vdisk: %v",
+			mc.memberAddr(m), mc.vdiskID)
+	}
+	return nil
+}
+
+// update the sequence flushed by the quorum,
+// returning a FlushOK result for the newly flushed sequences, if any
+func (mc *MultiClient) updateFlushed() *Result {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+
+	var seqs []uint64
+	for _, m := range mc.members {
+		if !m.failed && m.ready {
+			seqs = append(seqs, m.client.LastFlushedSequence())
+		}
+	}
+	seq := quorumSequence(seqs, mc.quorum)
+	if seq <= mc.lastFlushed {
+		return nil
+	}
+
+	flushed := make([]uint64, 0, seq-mc.lastFlushed)
+	for s := mc.lastFlushed + 1; s <= seq; s++ {
+		flushed = append(flushed, s)
+	}
+	mc.lastFlushed = seq
+
+	return &Result{
+		Resp: &Response{
+			Status:    tlog.BlockStatusFlushOK,
+			Sequences: flushed,
+		},
+	}
+}
+
+// mark a member as ready, excluding it if it's behind,
+// returning a Ready result if the quorum became ready because of it
+func (mc *MultiClient) memberReady(m *member) *Result {
+	if seq := m.client.LastFlushedSequence(); seq < mc.LastFlushedSequence() {
+		mc.failMember(m, errors.Newf(
+			"tlogserver is behind (last flushed sequence %d < %d)",
+			seq, mc.LastFlushedSequence()))
+	}
+
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+
+	m.ready = true
+	if mc.ready || mc.countReady() < mc.quorum {
+		return nil
+	}
+
+	if seq := m.client.LastFlushedSequence(); seq > mc.lastFlushed {
+		mc.lastFlushed = seq
+	}
+	mc.ready = true
+	close(mc.readyCh)
+	log.Infof("quorum of tlogservers is ready for vdisk %s", mc.vdiskID)
+
+	return &Result{
+		Resp: &Response{
+			Status:    tlog.BlockStatusReady,
+			Sequences: []uint64{mc.lastFlushed},
+		},
+	}
+}
+
+// exclude a member because of the given reason,
+// returning false if it can't be excluded,
+// as the remaining members wouldn't form a quorum,
+// in which case this client fails with ErrNoQuorum.
+func (mc *MultiClient) failMember(m *member, reason error) bool {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+
+	if m.failed {
+		return true
+	}
+
+	var healthy int
+	for _, other := range mc.members {
+		if !other.failed {
+			healthy++
+		}
+	}
+	if healthy <= mc.quorum {
+		log.Errorf("multi tlogclient can't exclude tlogserver %s for vdisk %s: %v",
+			m.addr, mc.vdiskID, reason)
+		if mc.quorumErr == nil {
+			mc.quorumErr = errors.Wrapf(ErrNoQuorum,
+				"tlogserver %s for vdisk %s failed: %v", m.addr, mc.vdiskID, reason)
+			close(mc.noQuorumCh)
+		}
+		return false
+	}
+
+	log.Errorf("multi tlogclient excludes tlogserver %s for vdisk %s: %v",
+		m.addr, mc.vdiskID, reason)
+	m.failed = true
+	m.cancelFunc()
+	go m.client.Close()
+	return true
+}
+
+// quorumError returns the error which caused the quorum to be lost,
+// or nil if the quorum wasn't lost.
+func (mc *MultiClient) quorumError() error {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	return mc.quorumErr
+}
+
+// memberAddr returns the address of the tlogserver of the given member
+func (mc *MultiClient) memberAddr(m *member) string {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	return m.addr
+}
+
+// healthyMembers returns all members which weren't excluded
+func (mc *MultiClient) healthyMembers() []*member {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+
+	var members []*member
+	for _, m := range mc.members {
+		if !m.failed {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// containsMember returns true if the given member is part of the given members
+func containsMember(members []*member, m *member) bool {
+	for _, other := range members {
+		if other == m {
+			return true
+		}
+	}
+	return false
+}
+
+// countReady returns the amount of healthy members which are ready,
+// the mutex has to be locked by the caller
+func (mc *MultiClient) countReady() int {
+	var n int
+	for _, m := range mc.members {
+		if !m.failed && m.ready {
+			n++
+		}
+	}
+	return n
+}
+
+// quorumSequence returns the highest sequence flushed by
+// at least quorum of the given last flushed sequences.
+func quorumSequence(seqs []uint64, quorum int) uint64 {
+	if quorum < 1 || len(seqs) < quorum {
+		return 0
+	}
+	sorted := make([]uint64, len(seqs))
+	copy(sorted, seqs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+	return sorted[quorum-1]
+}
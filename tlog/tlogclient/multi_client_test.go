@@ -0,0 +1,311 @@
+package tlogclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/tlogserver/server"
+)
+
+// Test a multi client streaming to two tlogservers,
+// where the second tlogserver is lost while writing.
+// - send log 1 - 100, wait until flushed by both servers
+// - lose the second tlogserver
+// - send log 101 - 2000 without stalling, wait until flushed
+func TestMultiClientLoseServer(t *testing.T) {
+	const (
+		vdiskID = "myimg"
+	)
+	data := make([]byte, 4096)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	// each tlogserver stores into its own 0-stor cluster
+	t1 := createTestMultiTlogServer(ctx, t, vdiskID)
+	t2 := createTestMultiTlogServer(ctx, t, vdiskID)
+	proxy := newTestTCPProxy(t, t2.ListenAddr())
+	defer proxy.Close()
+
+	client, err := NewMulti([]string{t1.ListenAddr(), proxy.Addr()}, vdiskID, 1)
+	require.NoError(t, err)
+	defer client.Close()
+	require.True(t, client.Ready())
+
+	require.NoError(t, testMultiClientSend(client, 1, 100, data))
+	testMultiClientWaitFlushed(t, client, 1, 100)
+	for _, m := range client.members {
+		require.False(t, m.failed)
+		waitForSequenceFlushed(t, m.client, 100)
+	}
+
+	// lose the second tlogserver
+	proxy.Close()
+
+	// errors are reported over a channel,
+	// as the test can't be failed from another goroutine
+	sendErrCh := make(chan error, 1)
+	go func() {
+		sendErrCh <- testMultiClientSend(client, 101, 2000, data)
+	}()
+	testMultiClientWaitFlushed(t, client, 101, 2000)
+	select {
+	case err := <-sendErrCh:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("sending stalled")
+	}
+
+	assert.Equal(t, uint64(2000), client.LastFlushedSequence())
+	assert.False(t, client.members[0].failed)
+	assert.True(t, client.members[1].failed)
+}
+
+// Test a multi client streaming to two tlogservers with a quorum of both,
+// where the second tlogserver is lost while writing.
+// - send log 1 - 100, wait until flushed by both servers
+// - lose the second tlogserver
+// - sending fails with ErrNoQuorum, rather than blocking forever
+func TestMultiClientLoseQuorum(t *testing.T) {
+	const (
+		vdiskID = "myimg"
+	)
+	data := make([]byte, 4096)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	t1 := createTestMultiTlogServer(ctx, t, vdiskID)
+	t2 := createTestMultiTlogServer(ctx, t, vdiskID)
+	proxy := newTestTCPProxy(t, t2.ListenAddr())
+	defer proxy.Close()
+
+	client, err := NewMulti([]string{t1.ListenAddr(), proxy.Addr()}, vdiskID, 2)
+	require.NoError(t, err)
+	defer client.Close()
+	require.True(t, client.Ready())
+
+	require.NoError(t, testMultiClientSend(client, 1, 100, data))
+	testMultiClientWaitFlushed(t, client, 1, 100)
+
+	// lose the second tlogserver
+	proxy.Close()
+
+	// keep receiving results, as a user of the client would
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-client.Recv():
+			}
+		}
+	}()
+
+	// errors are reported over a channel,
+	// as the test can't be failed from another goroutine
+	sendErrCh := make(chan error, 1)
+	go func() {
+		sendErrCh <- testMultiClientSend(client, 101, 100000, data)
+	}()
+	select {
+	case err := <-sendErrCh:
+		assert.Equal(t, ErrNoQuorum, errors.Cause(err))
+	case <-time.After(30 * time.Second):
+		t.Fatal("sending stalled")
+	}
+
+	// the client stays failed
+	assert.Equal(t, ErrNoQuorum, errors.Cause(client.Send(schema.OpSet, 100001, 0, 0, data)))
+	assert.Equal(t, ErrNoQuorum, errors.Cause(client.ForceFlushAtSeq(100001)))
+	assert.Equal(t, ErrNoQuorum, errors.Cause(client.WaitNbdSlaveSync()))
+}
+
+func TestMultiClientQuorum(t *testing.T) {
+	const (
+		vdiskID = "myimg"
+	)
+	data := make([]byte, 4096)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	t1 := createTestMultiTlogServer(ctx, t, vdiskID)
+	t2 := createTestMultiTlogServer(ctx, t, vdiskID)
+	servers := []string{t1.ListenAddr(), t2.ListenAddr()}
+
+	// a quorum of both tlogservers
+	client, err := NewMulti(servers, vdiskID, 2)
+	require.NoError(t, err)
+	require.NoError(t, testMultiClientSend(client, 1, 100, data))
+	testMultiClientWaitFlushed(t, client, 1, 100)
+	for _, m := range client.members {
+		assert.True(t, m.client.LastFlushedSequence() >= 100)
+	}
+	require.NoError(t, client.Close())
+	assert.Equal(t, ErrClientClosed, client.Send(schema.OpSet, 101, 101, 101, data))
+
+	// unreachable tlogserver
+	unusedAddr := newTestTCPProxy(t, t2.ListenAddr())
+	unusedAddr.Close()
+	_, err = NewMulti([]string{t1.ListenAddr(), unusedAddr.Addr()}, vdiskID, 2)
+	assert.Equal(t, ErrNoQuorum, errors.Cause(err))
+
+	// invalid quorum
+	_, err = NewMulti(servers, vdiskID, 3)
+	assert.Error(t, err)
+	_, err = NewMulti(servers[:1], vdiskID, 1)
+	assert.Error(t, err)
+}
+
+func TestQuorumSequence(t *testing.T) {
+	testCases := []struct {
+		seqs     []uint64
+		quorum   int
+		expected uint64
+	}{
+		{nil, 1, 0},
+		{[]uint64{5}, 2, 0},
+		{[]uint64{5, 10}, 1, 10},
+		{[]uint64{5, 10}, 2, 5},
+		{[]uint64{10, 5}, 2, 5},
+		{[]uint64{3, 10, 7}, 2, 7},
+		{[]uint64{3, 10, 7}, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, quorumSequence(tc.seqs, tc.quorum), "%v", tc)
+	}
+}
+
+func createTestMultiTlogServer(ctx context.Context, t *testing.T, vdiskID string) *server.Server {
+	cleanFunc, configSource, _ := newZeroStorDefaultConfig(t, vdiskID)
+	go func() {
+		<-ctx.Done()
+		cleanFunc()
+	}()
+
+	conf := *testConf
+	conf.FlushSize = 25
+	s, err := server.NewServer(&conf, configSource)
+	require.NoError(t, err)
+	go s.Listen(ctx)
+	return s
+}
+
+func testMultiClientSend(client *MultiClient, startSeq, endSeq uint64, data []byte) error {
+	for seq := startSeq; seq <= endSeq; seq++ {
+		err := client.Send(schema.OpSet, seq, int64(seq), int64(seq), data)
+		if err != nil {
+			return err
+		}
+	}
+	return client.ForceFlushAtSeq(endSeq)
+}
+
+// wait until the given sequences are reported as flushed, in order
+func testMultiClientWaitFlushed(t *testing.T, client *MultiClient, startSeq, endSeq uint64) {
+	next := startSeq
+	for next <= endSeq {
+		select {
+		case <-time.After(20 * time.Second):
+			t.Fatalf("timed out waiting for sequence %d to be flushed", next)
+		case res := <-client.Recv():
+			require.NoError(t, res.Err)
+			if res.Resp.Status != tlog.BlockStatusFlushOK {
+				continue
+			}
+			for _, seq := range res.Resp.Sequences {
+				require.Equal(t, next, seq)
+				next++
+			}
+		}
+	}
+}
+
+// wait until the tlogserver of a member flushed the given sequence
+func waitForSequenceFlushed(t *testing.T, client *Client, seq uint64) {
+	deadline := time.Now().Add(20 * time.Second)
+	for client.LastFlushedSequence() < seq {
+		require.True(t, time.Now().Before(deadline), "sequence %d wasn't flushed", seq)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// testTCPProxy forwards all connections to a given address,
+// such that the loss of that address can be simulated
+type testTCPProxy struct {
+	listener net.Listener
+	target   string
+	mux      sync.Mutex
+	conns    []net.Conn
+	closed   bool
+}
+
+func newTestTCPProxy(t *testing.T, target string) *testTCPProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &testTCPProxy{listener: listener, target: target}
+	go p.serve()
+	return p
+}
+
+func (p *testTCPProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *testTCPProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		targetConn, err := net.Dial("tcp", p.target)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		if !p.track(conn, targetConn) {
+			return
+		}
+		go io.Copy(targetConn, conn)
+		go io.Copy(conn, targetConn)
+	}
+}
+
+func (p *testTCPProxy) track(conns ...net.Conn) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+	p.conns = append(p.conns, conns...)
+	return true
+}
+
+// Close the proxy and all its connections
+func (p *testTCPProxy) Close() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	p.listener.Close()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+}
@@ -0,0 +1,210 @@
+package player
+
+import (
+	"context"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+)
+
+// CoalesceConfig defines the configuration used
+// to replay the tlog in (coalesced) windows of aggregations.
+type CoalesceConfig struct {
+	// WindowSize defines the maximum amount of aggregations
+	// coalesced into a single window, by default it equals DefaultWindowSize.
+	WindowSize int
+	// JobCount defines the amount of jobs (goroutines) applying a window in parallel,
+	// by default it equals the amount of CPUs available.
+	JobCount int
+}
+
+// DefaultWindowSize is the default amount of aggregations
+// coalesced into a single window.
+const DefaultWindowSize = 64
+
+// ReplayCoalesced replays the tlog by decoding data from the tlog blockchains,
+// coalescing the aggregations into windows, see ReplayCoalescedWithCallback.
+func (p *Player) ReplayCoalesced(lmt decoder.Limiter, cfg CoalesceConfig) (uint64, error) {
+	return p.ReplayCoalescedWithCallback(lmt, cfg, nil)
+}
+
+// ReplayCoalescedWithCallback replays the tlog in windows of aggregations.
+// Only the last operation of each block index is kept within a window,
+// after which the remaining operations are applied by multiple jobs in parallel,
+// sharded by block index. The block storage requires to be safe for concurrent use.
+//
+// The callback is executed after each window was applied and flushed,
+// with the last sequence of that window.
+// It returns the last sequence number it replayed,
+// which is always the last sequence of a window that was applied completely.
+func (p *Player) ReplayCoalescedWithCallback(lmt decoder.Limiter, cfg CoalesceConfig, onReplayCb OnReplayCb) (uint64, error) {
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = DefaultWindowSize
+	}
+	if cfg.JobCount <= 0 {
+		cfg.JobCount = runtime.NumCPU()
+	}
+
+	var lastSeq uint64
+	window := newReplayWindow()
+
+	// apply the collected window, if it contains anything
+	applyWindow := func() error {
+		if window.Empty() {
+			return nil
+		}
+		err := p.applyWindow(window, cfg.JobCount)
+		if err != nil {
+			return err
+		}
+		log.Debugf(
+			"replayed window of %d aggregations (%d blocks) for vdisk %s, up to sequence %d",
+			window.aggregations, len(window.blocks), p.vdiskID, window.lastSeq)
+
+		lastSeq = window.lastSeq
+		window = newReplayWindow()
+		if onReplayCb != nil {
+			return onReplayCb(lastSeq)
+		}
+		return nil
+	}
+
+	walkCh := p.storCli.Walk(lmt.FromEpoch(), lmt.ToEpoch())
+	for wr := range walkCh {
+		if wr.Err != nil {
+			return lastSeq, wr.Err
+		}
+
+		ended, err := window.Add(wr.Agg, lmt)
+		if err != nil {
+			return lastSeq, err
+		}
+		if ended {
+			// no more blocks are to be replayed,
+			// drain the remaining aggregations in the background,
+			// as the walk can't be cancelled
+			go func() {
+				for range walkCh {
+				}
+			}()
+			break
+		}
+
+		if window.aggregations >= cfg.WindowSize {
+			if err = applyWindow(); err != nil {
+				return lastSeq, err
+			}
+		}
+	}
+
+	return lastSeq, applyWindow()
+}
+
+// applyWindow applies all operations of a window in parallel,
+// sharded by block index, and flushes the block storage afterwards.
+func (p *Player) applyWindow(window *replayWindow, jobCount int) error {
+	shards := make([][]schema.TlogBlock, jobCount)
+	for index, block := range window.blocks {
+		shard := index % int64(jobCount)
+		shards[shard] = append(shards[shard], block)
+	}
+
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, shard := range shards {
+		shard := shard
+		group.Go(func() error {
+			for _, block := range shard {
+				select {
+				case <-groupCtx.Done():
+					return nil
+				default:
+				}
+				if err := p.replayBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return err
+	}
+	// the window is only partially applied, if the player's context was cancelled
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	return p.blockStorage.Flush()
+}
+
+// newReplayWindow creates a new (empty) replay window.
+func newReplayWindow() *replayWindow {
+	return &replayWindow{
+		blocks: make(map[int64]schema.TlogBlock),
+	}
+}
+
+// replayWindow collects the last operation of each block index,
+// for a window of consecutive aggregations.
+type replayWindow struct {
+	blocks       map[int64]schema.TlogBlock
+	lastSeq      uint64
+	aggregations int
+}
+
+// Add all blocks of an aggregation, which are within the limits of the given limiter.
+// It returns true in case the end of the limiter was reached.
+func (w *replayWindow) Add(agg *schema.TlogAggregation, lmt decoder.Limiter) (bool, error) {
+	// decode the (deduplicated and/or delta-encoded) block data,
+	// as the blocks are applied outside of the context of their aggregation
+	agg, err := tlog.DecodeAggregation(agg)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to decode aggregation")
+	}
+
+	blocks, err := agg.Blocks()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get blocks of aggregation")
+	}
+
+	for i := 0; i < int(agg.Size()); i++ {
+		block := blocks.At(i)
+
+		if !lmt.StartBlock(block) {
+			continue
+		}
+		if lmt.EndBlock(block) {
+			return true, nil
+		}
+
+		// only keep the last operation of each index
+		seq, index := block.Sequence(), block.Index()
+		if prev, ok := w.blocks[index]; !ok || prev.Sequence() < seq {
+			w.blocks[index] = block
+		}
+		if seq > w.lastSeq {
+			w.lastSeq = seq
+		}
+	}
+
+	w.aggregations++
+	return false, nil
+}
+
+// Empty returns true in case the window has no operations to apply.
+func (w *replayWindow) Empty() bool {
+	return len(w.blocks) == 0
+}
@@ -0,0 +1,105 @@
+package player
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+)
+
+func TestReplayCoalesced(t *testing.T) {
+	const (
+		vdiskID          = "vdisk"
+		blockSize        = 4096
+		blockCount       = 16
+		transactionCount = 500
+		endSequence      = 420
+		privKey          = "12345678901234567890123456789012"
+	)
+
+	confSource, cluster, cleanup := newTestConfigSource(t, vdiskID, blockSize)
+	defer cleanup()
+
+	// 1. generate tlog data, overwriting and deleting the same blocks over and over,
+	//    and keep track of the expected content of each block at the end sequence
+	f, err := flusher.New(confSource, 8, vdiskID, privKey)
+	require.NoError(t, err)
+
+	expected := make(map[int64][]byte)
+	timestamp := tlog.TimeNowTimestamp()
+	for seq := uint64(tlog.FirstSequence); seq <= transactionCount; seq++ {
+		index := rand.Int63n(blockCount)
+		transaction := tlog.Transaction{
+			Operation: schema.OpDelete,
+			Sequence:  seq,
+			Index:     index,
+			Timestamp: timestamp,
+		}
+		if rand.Intn(5) != 0 {
+			content := make([]byte, blockSize)
+			rand.Read(content)
+			transaction.Operation = schema.OpSet
+			transaction.Content = content
+			transaction.Hash = zerodisk.Hash(content)
+		}
+
+		require.NoError(t, f.AddTransaction(transaction))
+		if f.Full() {
+			_, _, err = f.Flush()
+			require.NoError(t, err)
+		}
+
+		if seq <= endSequence {
+			expected[index] = transaction.Content
+		}
+	}
+	_, _, err = f.Flush()
+	require.NoError(t, err)
+
+	// 2. replay the tlog up to the end sequence, in small windows
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	player, err := NewPlayer(ctx, confSource, vdiskID, privKey)
+	require.NoError(t, err)
+	defer player.Close()
+
+	var windowSeqs []uint64
+	lastSeq, err := player.ReplayCoalescedWithCallback(
+		decoder.NewLimitBySequence(0, endSequence),
+		CoalesceConfig{WindowSize: 4, JobCount: 3},
+		func(seq uint64) error {
+			windowSeqs = append(windowSeqs, seq)
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(endSequence), lastSeq)
+
+	// the callback is called for each window (of 4 aggregations of 8 blocks),
+	// with the last sequence of that window
+	if assert.NotEmpty(t, windowSeqs) {
+		assert.Equal(t, uint64(32), windowSeqs[0])
+		assert.Equal(t, uint64(endSequence), windowSeqs[len(windowSeqs)-1])
+	}
+
+	// 3. check the replayed data
+	blockStorage, err := storage.Deduped(
+		vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	require.NoError(t, err)
+	defer blockStorage.Close()
+
+	for index := int64(0); index < blockCount; index++ {
+		content, err := blockStorage.GetBlock(index)
+		require.NoError(t, err)
+		assert.Equal(t, expected[index], content, "block %d", index)
+	}
+}
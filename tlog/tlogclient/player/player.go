@@ -7,6 +7,7 @@ import (
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/nbd/ardb"
 	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/schema"
 	"github.com/zero-os/0-Disk/tlog/stor"
 	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
@@ -83,6 +84,12 @@ func (p *Player) Close() error {
 	return p.blockStorage.Close()
 }
 
+// FindMarker returns the last tlog marker stored with the given name,
+// which can be used to create a decoder.LimitByMarker limiter.
+func (p *Player) FindMarker(name string) (tlog.Marker, error) {
+	return p.storCli.FindMarker(name)
+}
+
 // Replay replays the tlog by decoding data from the tlog blockchains.
 func (p *Player) Replay(lmt decoder.Limiter) (uint64, error) {
 	return p.ReplayWithCallback(lmt, nil)
@@ -93,14 +100,22 @@ func (p *Player) Replay(lmt decoder.Limiter) (uint64, error) {
 // It returns last sequence number it replayed.
 func (p *Player) ReplayWithCallback(lmt decoder.Limiter, onReplayCb OnReplayCb) (uint64, error) {
 	var lastSeq uint64
-	var err error
 
 	for wr := range p.storCli.Walk(lmt.FromEpoch(), lmt.ToEpoch()) {
 		if wr.Err != nil {
 			return lastSeq, wr.Err
 		}
 
-		if lastSeq, err = p.ReplayAggregationWithCallback(wr.Agg, lmt, onReplayCb); err != nil {
+		// aggregations which only contain markers have nothing to replay
+		if wr.Agg.Size() == 0 {
+			continue
+		}
+
+		seq, err := p.ReplayAggregationWithCallback(wr.Agg, lmt, onReplayCb)
+		if seq > lastSeq {
+			lastSeq = seq
+		}
+		if err != nil {
 			return lastSeq, err
 		}
 	}
@@ -125,8 +140,12 @@ func (p *Player) ReplayAggregationWithCallback(agg *schema.TlogAggregation, lmt
 	onReplayCb OnReplayCb) (uint64, error) {
 
 	var seq uint64
-	var index int64
-	var data []byte
+
+	// decode the (deduplicated and/or delta-encoded) block data
+	agg, err := tlog.DecodeAggregation(agg)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to decode aggregation")
+	}
 
 	// replay all the blocks
 	blocks, err := agg.Blocks()
@@ -141,21 +160,10 @@ func (p *Player) ReplayAggregationWithCallback(agg *schema.TlogAggregation, lmt
 			return seq, nil
 		}
 
-		seq, index = block.Sequence(), block.Index()
-
-		switch block.Operation() {
-		case schema.OpSet:
-			data, err = block.Data()
-			if err != nil {
-				return seq - 1, errors.Wrapf(err, "failed to get data block %v", index)
-			}
-			if err = p.blockStorage.SetBlock(index, data); err != nil {
-				return seq - 1, errors.Wrapf(err, "failed to set block %v", index)
-			}
-		case schema.OpDelete:
-			if err = p.blockStorage.DeleteBlock(index); err != nil {
-				return seq - 1, errors.Wrapf(err, "failed to delete block %v", index)
-			}
+		seq = block.Sequence()
+
+		if err = p.replayBlock(block); err != nil {
+			return seq - 1, err
 		}
 
 		if onReplayCb == nil {
@@ -176,3 +184,25 @@ func (p *Player) ReplayAggregationWithCallback(agg *schema.TlogAggregation, lmt
 	}
 	return seq, nil
 }
+
+// replayBlock applies the operation of a single block to the block storage.
+func (p *Player) replayBlock(block schema.TlogBlock) error {
+	index := block.Index()
+
+	switch block.Operation() {
+	case schema.OpSet:
+		data, err := block.Data()
+		if err != nil {
+			return errors.Wrapf(err, "failed to get data block %v", index)
+		}
+		if err = p.blockStorage.SetBlock(index, data); err != nil {
+			return errors.Wrapf(err, "failed to set block %v", index)
+		}
+	case schema.OpDelete:
+		if err := p.blockStorage.DeleteBlock(index); err != nil {
+			return errors.Wrapf(err, "failed to delete block %v", index)
+		}
+	}
+
+	return nil
+}
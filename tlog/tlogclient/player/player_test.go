@@ -0,0 +1,152 @@
+package player
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+)
+
+// a tlog which ends with an aggregation only containing markers,
+// still returns the last sequence of the aggregation before it
+func TestReplayMarkerOnlyAggregation(t *testing.T) {
+	const (
+		vdiskID   = "vdisk"
+		blockSize = 4096
+		flushSize = 8
+		privKey   = "12345678901234567890123456789012"
+	)
+
+	confSource, cluster, cleanup := newTestConfigSource(t, vdiskID, blockSize)
+	defer cleanup()
+
+	// 1. generate one aggregation of blocks,
+	//    followed by an aggregation which only contains a marker
+	f, err := flusher.New(confSource, flushSize, vdiskID, privKey)
+	require.NoError(t, err)
+
+	timestamp := tlog.TimeNowTimestamp()
+	for seq := uint64(tlog.FirstSequence); seq <= flushSize; seq++ {
+		content := make([]byte, blockSize)
+		content[0] = byte(seq)
+		require.NoError(t, f.AddTransaction(tlog.Transaction{
+			Operation: schema.OpSet,
+			Sequence:  seq,
+			Index:     int64(seq),
+			Content:   content,
+			Hash:      zerodisk.Hash(content),
+			Timestamp: timestamp,
+		}))
+	}
+	_, seqs, err := f.Flush()
+	require.NoError(t, err)
+	require.Len(t, seqs, flushSize)
+
+	f.AddMarker(tlog.Marker{Name: "flush", Sequence: flushSize, Timestamp: timestamp})
+	_, seqs, err = f.Flush()
+	require.NoError(t, err)
+	require.Empty(t, seqs)
+
+	// 2. replay the entire tlog
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	player, err := NewPlayer(ctx, confSource, vdiskID, privKey)
+	require.NoError(t, err)
+	defer player.Close()
+
+	lastSeq, err := player.Replay(decoder.NewLimitBySequence(0, 0))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(flushSize), lastSeq)
+
+	// the marker can still be found
+	marker, err := player.FindMarker("flush")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(flushSize), marker.Sequence)
+
+	// 3. check the replayed data
+	blockStorage, err := storage.Deduped(
+		vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	require.NoError(t, err)
+	defer blockStorage.Close()
+
+	for seq := uint64(tlog.FirstSequence); seq <= flushSize; seq++ {
+		content, err := blockStorage.GetBlock(int64(seq))
+		require.NoError(t, err)
+		if assert.Len(t, content, blockSize) {
+			assert.Equal(t, byte(seq), content[0], "block %d", seq)
+		}
+	}
+}
+
+// newTestConfigSource creates a config source for a boot vdisk,
+// with its tlog stored in an embedded 0-stor cluster,
+// and its data stored in the returned in-memory storage cluster.
+func newTestConfigSource(t *testing.T, vdiskID string, blockSize int64) (*config.StubSource, *redisstub.UniCluster, func()) {
+	const (
+		dataShards        = 4
+		parityShards      = 2
+		zeroStorClusterID = "zero_stor_cluster_id"
+		nbdClusterID      = "nbd_cluster_id"
+	)
+
+	// 0-stor servers
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.NoError(t, err)
+
+	mdServer, err := embedserver.New()
+	if err != nil {
+		storCluster.Close()
+		t.Fatal(err)
+	}
+
+	cluster := redisstub.NewUniCluster(true)
+
+	// config source
+	confSource := config.NewStubSource()
+
+	confSource.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: uint64(blockSize),
+		Size:      2,
+		Type:      config.VdiskTypeBoot,
+	})
+	confSource.SetPrimaryStorageCluster(vdiskID, nbdClusterID, &config.StorageClusterConfig{
+		Servers: []config.StorageServerConfig{cluster.StorageServerConfig()},
+	})
+
+	var serverConf []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		serverConf = append(serverConf, config.ServerConfig{Address: addr})
+	}
+	confSource.SetTlogZeroStorCluster(vdiskID, zeroStorClusterID, &config.ZeroStorClusterConfig{
+		IYO: config.IYOCredentials{
+			Org:       "testorg",
+			Namespace: "thedisk",
+		},
+		MetadataServers: []config.ServerConfig{
+			config.ServerConfig{Address: mdServer.ListenAddr()},
+		},
+		DataServers:  serverConf,
+		DataShards:   dataShards,
+		ParityShards: parityShards,
+	})
+
+	return confSource, cluster, func() {
+		confSource.Close()
+		cluster.Close()
+		mdServer.Stop()
+		storCluster.Close()
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"zombiezen.com/go/capnproto2"
 
 	"github.com/zero-os/0-Disk/log"
@@ -197,3 +198,98 @@ func TestResendTimeout(t *testing.T) {
 
 	wg.Wait()
 }
+
+// run this dummy server, which behaves like a busy tlogserver:
+// it refuses each of the busy sequences once, with the RecvBusy status,
+// and drops all blocks received out of order.
+func (ds *dummyServer) runBusy(busy map[uint64]struct{}) error {
+	capnpEnc := capnp.NewEncoder(ds.respPipeWriter)
+	var expectedSequence uint64
+	for {
+		// read client command
+		cmd, err := readDecodeClientMessage(ds.reqPipeReader)
+		if err != nil {
+			return err
+		}
+
+		block, err := cmd.Block()
+		if err != nil {
+			return err
+		}
+		seq := block.Sequence()
+
+		status := tlog.BlockStatusRecvOK
+		switch {
+		case seq > expectedSequence:
+			continue // out of order
+		case seq == expectedSequence:
+			if _, ok := busy[seq]; ok {
+				delete(busy, seq)
+				status = tlog.BlockStatusRecvBusy
+			} else {
+				expectedSequence++
+			}
+		}
+
+		// send resp
+		resp := server.BlockResponse{
+			Status:    status.Int8(),
+			Sequences: []uint64{seq},
+		}
+		resp.Write(capnpEnc, nil)
+	}
+}
+
+// TestResendBusy test client resend in case the server is busy,
+// which has to happen right away, rather than once the resend timeout expires
+func TestResendBusy(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	const (
+		vdisk   = "12345"
+		numLogs = 100
+	)
+
+	clean, configSource, _ := newZeroStorDefaultConfig(t, vdisk)
+	defer clean()
+	// only used in client.connect
+	unusedServer, err := server.NewServer(testConf, configSource)
+	require.NoError(t, err)
+	go unusedServer.Listen(ctx)
+
+	busy := map[uint64]struct{}{}
+	for i := 0; i < numLogs; i += 10 {
+		busy[uint64(i)] = struct{}{}
+	}
+
+	ds := newDummyServer(unusedServer)
+	go ds.runBusy(busy)
+
+	client, err := newClient([]string{unusedServer.ListenAddr()}, vdisk)
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.bw = ds.reqPipeWriter  // fake client writer
+	client.rd = ds.respPipeReader // fake the reader
+
+	go client.run(client.ctx)
+
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		waitForBlockReceivedResponse(t, client, 0, numLogs-1)
+	}()
+
+	data := make([]byte, 4096)
+	for i := 0; i < numLogs; i++ {
+		x := uint64(i)
+		require.NoError(t, client.Send(schema.OpSet, x, int64(x), int64(x), data))
+	}
+
+	select {
+	case <-doneCh:
+	case <-time.After(resendTimeoutDur):
+		t.Fatal("blocks refused by a busy server weren't resent right away")
+	}
+}
@@ -0,0 +1,265 @@
+// Package subscriber provides a client which subscribes
+// to the flushed tlog aggregations of a vdisk, as streamed by a tlogserver.
+package subscriber
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"zombiezen.com/go/capnproto2"
+)
+
+const (
+	dialTimeout = time.Second
+
+	// amount of times a subscriber tries to resubscribe
+	// when the connection to the tlogserver was lost
+	resubscribeAttempts = 3
+	// time to wait in between resubscribe attempts
+	resubscribeDelay = time.Second
+)
+
+var (
+	// ErrClosed is returned when receiving from a closed subscriber.
+	ErrClosed = errors.New("subscriber is closed")
+)
+
+// Config used to create a subscriber.
+type Config struct {
+	// VdiskID of the vdisk to subscribe to.
+	VdiskID string
+	// StartSequence is the first sequence to receive,
+	// by default all flushed sequences are received.
+	StartSequence uint64
+	// WithData defines whether or not the data of set operations is received,
+	// when false only the hashes of that data are received.
+	WithData bool
+}
+
+// Subscriber receives the flushed aggregations of a vdisk from a tlogserver.
+// It first receives all aggregations which were already flushed,
+// starting from the configured sequence, followed by all newly flushed aggregations.
+type Subscriber struct {
+	addr string
+	cfg  Config
+
+	// used to receive one aggregation at a time
+	dec     *capnp.Decoder
+	recvMux sync.Mutex
+
+	conn           net.Conn
+	closed         bool
+	nextSeq        uint64
+	lastFlushedSeq uint64
+	mux            sync.Mutex
+}
+
+// New creates a new subscriber,
+// subscribing to the tlogserver listening for subscribers on the given address.
+func New(addr string, cfg Config) (*Subscriber, error) {
+	if cfg.VdiskID == "" {
+		return nil, errors.New("subscriber requires a vdiskID")
+	}
+	if cfg.StartSequence < tlog.FirstSequence {
+		cfg.StartSequence = tlog.FirstSequence
+	}
+
+	s := &Subscriber{
+		addr:    addr,
+		cfg:     cfg,
+		nextSeq: cfg.StartSequence,
+	}
+	err := s.subscribe()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Recv receives the next flushed aggregation, blocking until one is available.
+// The returned aggregation only contains blocks which weren't received yet,
+// and are ordered by sequence.
+//
+// In case the connection to the tlogserver is lost,
+// it will resubscribe, resuming from the next sequence to be received.
+// This happens for example when this subscriber couldn't keep up.
+func (s *Subscriber) Recv() (*schema.TlogAggregation, error) {
+	s.recvMux.Lock()
+	defer s.recvMux.Unlock()
+
+	for {
+		if s.isClosed() {
+			return nil, ErrClosed
+		}
+
+		agg, err := s.recv()
+		if err == nil {
+			return agg, nil
+		}
+		if s.isClosed() {
+			return nil, ErrClosed
+		}
+
+		log.Infof("subscriber of vdisk %s lost connection to %s: %v",
+			s.cfg.VdiskID, s.addr, err)
+		err = s.resubscribe()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// NextSequence returns the next sequence to be received,
+// which can be used as the start sequence to resume a subscription later.
+func (s *Subscriber) NextSequence() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.nextSeq
+}
+
+// LastFlushedSequence returns the last sequence flushed by the tlogserver
+// at the time this subscriber (re)subscribed.
+func (s *Subscriber) LastFlushedSequence() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.lastFlushedSeq
+}
+
+// Close this subscriber, unblocking any pending Recv call.
+func (s *Subscriber) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.conn.Close()
+}
+
+func (s *Subscriber) isClosed() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.closed
+}
+
+// receive a single aggregation from the current connection
+func (s *Subscriber) recv() (*schema.TlogAggregation, error) {
+	msg, err := s.dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+	agg, err := schema.ReadRootTlogAggregation(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if size := agg.Size(); size > 0 {
+		blocks, err := agg.Blocks()
+		if err != nil {
+			return nil, err
+		}
+		s.mux.Lock()
+		s.nextSeq = blocks.At(int(size-1)).Sequence() + 1
+		s.mux.Unlock()
+	}
+	return &agg, nil
+}
+
+// resubscribe to the tlogserver, resuming from the next sequence
+func (s *Subscriber) resubscribe() (err error) {
+	s.mux.Lock()
+	s.conn.Close()
+	s.mux.Unlock()
+
+	for attempt := 1; attempt <= resubscribeAttempts; attempt++ {
+		time.Sleep(resubscribeDelay)
+		if s.isClosed() {
+			return ErrClosed
+		}
+
+		err = s.subscribe()
+		if err == nil {
+			return nil
+		}
+		log.Infof("subscriber of vdisk %s failed to resubscribe to %s (attempt %d): %v",
+			s.cfg.VdiskID, s.addr, attempt, err)
+	}
+
+	return errors.Wrapf(err, "couldn't resubscribe to %s", s.addr)
+}
+
+// subscribe to the tlogserver, starting from the next sequence
+func (s *Subscriber) subscribe() error {
+	conn, err := net.DialTimeout("tcp", s.addr, dialTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't connect to %s", s.addr)
+	}
+
+	br := bufio.NewReader(conn)
+	lastFlushedSeq, err := s.handshake(conn, br)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "subscribe handshake failed")
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		conn.Close()
+		return ErrClosed
+	}
+
+	s.conn = conn
+	s.dec = capnp.NewDecoder(br)
+	s.lastFlushedSeq = lastFlushedSeq
+	return nil
+}
+
+// do the subscribe handshake,
+// returning the last flushed sequence of the tlogserver
+func (s *Subscriber) handshake(w io.Writer, r io.Reader) (uint64, error) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build (subscribe handshake) capnp")
+	}
+	req, err := schema.NewRootSubscribeHandshakeRequest(seg)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't create subscribe handshake")
+	}
+	req.SetVersion(zerodisk.CurrentVersion.UInt32())
+	err = req.SetVdiskID(s.cfg.VdiskID)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't set subscribe handshake vdiskID")
+	}
+	req.SetStartSequence(s.nextSeq)
+	req.SetWithData(s.cfg.WithData)
+
+	err = capnp.NewEncoder(w).Encode(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	msg, err = capnp.NewDecoder(r).Decode()
+	if err != nil {
+		return 0, err
+	}
+	resp, err := schema.ReadRootSubscribeHandshakeResponse(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tlog.HandshakeStatus(resp.Status()).Error()
+	if err != nil {
+		return 0, err
+	}
+	return resp.LastFlushedSequence(), nil
+}
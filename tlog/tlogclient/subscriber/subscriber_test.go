@@ -0,0 +1,138 @@
+package subscriber
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-Disk/tlog/tlogclient"
+	"github.com/zero-os/0-Disk/tlog/tlogserver/server"
+)
+
+func TestSubscriber(t *testing.T) {
+	const (
+		vdiskID  = "vdisk"
+		numFlush = 4
+		dataLen  = 4096
+	)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	// 0-stor servers
+	storCluster, err := embeddedserver.NewZeroStorCluster(2)
+	require.NoError(t, err)
+	defer storCluster.Close()
+
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+	defer mdServer.Stop()
+
+	var servers []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		servers = append(servers, config.ServerConfig{Address: addr})
+	}
+	configSource := config.NewStubSource()
+	configSource.SetTlogZeroStorCluster(vdiskID, "zero_stor_cluster_id", &config.ZeroStorClusterConfig{
+		IYO: config.IYOCredentials{
+			Org:       "testorg",
+			Namespace: "thedisk",
+		},
+		MetadataServers: []config.ServerConfig{
+			config.ServerConfig{Address: mdServer.ListenAddr()},
+		},
+		DataServers:  servers,
+		DataShards:   1,
+		ParityShards: 1,
+	})
+
+	// tlogserver, accepting subscribers
+	conf := server.DefaultConfig()
+	conf.ListenAddr = "127.0.0.1:0"
+	conf.SubscribeListenAddr = "127.0.0.1:0"
+	s, err := server.NewServer(conf, configSource)
+	require.NoError(t, err)
+	go s.Listen(ctx)
+
+	// subscribe prior to any sequence being flushed
+	liveSub, err := New(s.SubscribeListenAddr(), Config{VdiskID: vdiskID, WithData: true})
+	require.NoError(t, err)
+	defer liveSub.Close()
+	assert.Equal(t, uint64(0), liveSub.LastFlushedSequence())
+
+	// send and flush some transactions
+	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID)
+	require.NoError(t, err)
+	defer client.Close()
+
+	lastSeq := uint64(conf.FlushSize * numFlush)
+	for seq := tlog.FirstSequence; seq <= lastSeq; seq++ {
+		data := make([]byte, dataLen)
+		data[0] = byte(seq)
+		err := client.Send(schema.OpSet, seq, int64(seq), int64(seq), data)
+		require.NoError(t, err)
+	}
+	for result := range client.Recv() {
+		require.NoError(t, result.Err)
+		if result.Resp.Status != tlog.BlockStatusFlushOK {
+			continue
+		}
+		seqs := result.Resp.Sequences
+		if seqs[len(seqs)-1] == lastSeq {
+			break
+		}
+	}
+
+	// the live subscriber receives all sequences, with their data
+	testSubscriberRecv(t, liveSub, tlog.FirstSequence, lastSeq, true)
+
+	// a new subscriber receives all stored sequences from the given start sequence,
+	// without data
+	const startSeq = 30
+	historySub, err := New(s.SubscribeListenAddr(), Config{VdiskID: vdiskID, StartSequence: startSeq})
+	require.NoError(t, err)
+	defer historySub.Close()
+	assert.Equal(t, lastSeq, historySub.LastFlushedSequence())
+
+	testSubscriberRecv(t, historySub, startSeq, lastSeq, false)
+}
+
+// receive all sequences in the given range, in order, without gaps or duplicates
+func testSubscriberRecv(t *testing.T, sub *Subscriber, startSeq, endSeq uint64, withData bool) {
+	expectedSeq := startSeq
+	for expectedSeq <= endSeq {
+		agg, err := sub.Recv()
+		require.NoError(t, err)
+
+		blocks, err := agg.Blocks()
+		require.NoError(t, err)
+		require.Equal(t, int(agg.Size()), blocks.Len())
+
+		for i := 0; i < blocks.Len(); i++ {
+			block := blocks.At(i)
+			require.Equal(t, expectedSeq, block.Sequence())
+			assert.Equal(t, int64(expectedSeq), block.Index())
+
+			data, err := block.Data()
+			require.NoError(t, err)
+			if withData {
+				if assert.Len(t, data, 4096) {
+					assert.Equal(t, byte(expectedSeq), data[0])
+				}
+			} else {
+				assert.Empty(t, data)
+			}
+
+			expectedSeq++
+		}
+	}
+
+	assert.Equal(t, endSeq+1, sub.NextSequence())
+}
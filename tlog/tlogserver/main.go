@@ -29,9 +29,17 @@ func main() {
 	flag.StringVar(&conf.ListenAddr, "address", conf.ListenAddr, "Address to listen on")
 	flag.IntVar(&conf.FlushSize, "flush-size", conf.FlushSize, "flush size")
 	flag.IntVar(&conf.FlushTime, "flush-time", conf.FlushTime, "flush time (seconds)")
+	flag.BoolVar(&conf.AdaptiveFlush, "adaptive-flush", conf.AdaptiveFlush, "adapt the flush size of each vdisk to its write rate, targeting the flush latency")
+	flag.IntVar(&conf.FlushLatency, "flush-latency", conf.FlushLatency, "target max flush latency (milliseconds), only used for adaptive flushing")
+	flag.IntVar(&conf.MinFlushSize, "min-flush-size", conf.MinFlushSize, "min flush size, only used for adaptive flushing")
+	flag.BoolVar(&conf.DedupEncoding, "dedup-encoding", conf.DedupEncoding, "store data identical to the data of a previous block in the same aggregation only once")
+	flag.BoolVar(&conf.DeltaEncoding, "delta-encoding", conf.DeltaEncoding, "delta-encode the data of a block against its previous version in the same aggregation")
+	flag.IntVar(&conf.MaxBufferedBlocks, "max-buffered-blocks", conf.MaxBufferedBlocks, "max amount of blocks buffered per vdisk, waiting to be flushed (default: 5 x flush-size)")
+	flag.IntVar(&conf.MaxFlushBandwidth, "max-flush-bandwidth", conf.MaxFlushBandwidth, "max bandwidth (KiB/s) used per vdisk to flush to 0-stor (default: unlimited)")
 	flag.IntVar(&conf.BlockSize, "block-size", conf.BlockSize, "block size (bytes)")
 	flag.StringVar(&conf.WaitListenAddr, "wait-listen-addr", conf.WaitListenAddr, "wait listen addr")
 	flag.StringVar(&conf.WaitConnectAddr, "wait-connect-addr", conf.WaitConnectAddr, "wait connect addr")
+	flag.StringVar(&conf.SubscribeListenAddr, "subscribe-address", conf.SubscribeListenAddr, "Address to listen on for subscribers of flushed aggregations (disabled if empty)")
 	flag.StringVar(&conf.PrivKey, "priv-key", conf.PrivKey, "private key")
 	flag.StringVar(&profileAddr, "profile-address", "", "Enables profiling of this server as an http service")
 	flag.Var(&sourceConfig, "config", "config resource: dialstrings (etcd cluster) or path (yaml file)")
@@ -66,10 +74,17 @@ func main() {
 
 	zerodisk.LogVersion()
 
-	log.Debugf("flags parsed: address=%q flush-size=%d flush-time=%d block-size=%d priv-key=%q profile-address=%q config=%q storage-addresses=%q logfile=%q id=%q accept-address=%q",
+	log.Debugf("flags parsed: address=%q flush-size=%d flush-time=%d adaptive-flush=%t flush-latency=%d min-flush-size=%d dedup-encoding=%t delta-encoding=%t max-buffered-blocks=%d max-flush-bandwidth=%d block-size=%d priv-key=%q profile-address=%q config=%q storage-addresses=%q logfile=%q id=%q accept-address=%q subscribe-address=%q",
 		conf.ListenAddr,
 		conf.FlushSize,
 		conf.FlushTime,
+		conf.AdaptiveFlush,
+		conf.FlushLatency,
+		conf.MinFlushSize,
+		conf.DedupEncoding,
+		conf.DeltaEncoding,
+		conf.MaxBufferedBlocks,
+		conf.MaxFlushBandwidth,
 		conf.BlockSize,
 		conf.PrivKey,
 		profileAddr,
@@ -78,6 +93,7 @@ func main() {
 		logPath,
 		serverID,
 		conf.AcceptAddr,
+		conf.SubscribeListenAddr,
 	)
 
 	// let's create the source and defer close it
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/flusher"
 )
@@ -19,6 +20,9 @@ func DefaultConfig() *Config {
 		FlushTime:  25,
 		BlockSize:  4096,
 		PrivKey:    "12345678901234567890123456789012",
+
+		FlushLatency: 1000,
+		MinFlushSize: 1,
 	}
 }
 
@@ -33,6 +37,60 @@ type Config struct {
 	SlaveSyncerMgr  tlog.SlaveSyncerManager
 	WaitListenAddr  string
 	WaitConnectAddr string
+	// address to listen on for subscribers of flushed aggregations,
+	// no subscribers are accepted if empty
+	SubscribeListenAddr string
+
+	// when true, the aggregation size of each vdisk is adapted
+	// to its block arrival rate, instead of only flushing
+	// when an aggregation is full or the flush time has passed
+	AdaptiveFlush bool
+	FlushLatency  int // target max acknowledgement latency (milliseconds) used by adaptive flushing
+	MinFlushSize  int // min aggregation size used by adaptive flushing
+
+	// when true, data identical to the data of a previous block
+	// in the same aggregation is only stored once
+	DedupEncoding bool
+	// when true, the data of a block is delta-encoded against
+	// the previous version of that block in the same aggregation
+	DeltaEncoding bool
+
+	// resource limits of a single vdisk, 0 means the default (buffered blocks)
+	// or unlimited (flush bandwidth)
+	MaxBufferedBlocks int // max amount of received blocks buffered per vdisk, waiting to be flushed
+	MaxFlushBandwidth int // max bandwidth (KiB/s) used per vdisk to flush to 0-stor
+}
+
+// validateLimits validates the resource limits
+func (conf *Config) validateLimits() error {
+	if conf.MaxBufferedBlocks < 0 {
+		return errors.Newf("invalid max buffered blocks %d, can't be negative", conf.MaxBufferedBlocks)
+	}
+	if conf.MaxFlushBandwidth < 0 {
+		return errors.Newf("invalid max flush bandwidth %dKiB/s, can't be negative", conf.MaxFlushBandwidth)
+	}
+	return nil
+}
+
+// validateAdaptiveFlush validates the adaptive flush properties,
+// which are only used (and thus validated) when adaptive flushing is enabled
+func (conf *Config) validateAdaptiveFlush() error {
+	if !conf.AdaptiveFlush {
+		return nil
+	}
+	if conf.FlushLatency <= 0 {
+		return errors.Newf("invalid flush latency %dms, has to be positive", conf.FlushLatency)
+	}
+	flushSize := conf.FlushSize
+	if flushSize == 0 {
+		flushSize = flusher.DefaultFlushSize
+	}
+	if conf.MinFlushSize <= 0 || conf.MinFlushSize > flushSize {
+		return errors.Newf(
+			"invalid min flush size %d, has to be within the range [1, %d]",
+			conf.MinFlushSize, flushSize)
+	}
+	return nil
 }
 
 // flusherConfig is used by the server to create a flusher
@@ -41,4 +99,14 @@ type flusherConfig struct {
 	FlushSize int
 	FlushTime int
 	PrivKey   string
+
+	AdaptiveFlush bool
+	FlushLatency  int
+	MinFlushSize  int
+
+	DedupEncoding bool
+	DeltaEncoding bool
+
+	MaxBufferedBlocks int
+	MaxFlushBandwidth int
 }
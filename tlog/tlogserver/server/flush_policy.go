@@ -0,0 +1,274 @@
+package server
+
+import (
+	"time"
+
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+)
+
+// flushReason defines why the flusher of a vdisk flushed
+type flushReason uint8
+
+// all reasons for which the flusher of a vdisk can flush
+const (
+	// the aggregation reached its max size (flush size)
+	flushReasonFull flushReason = iota
+	// the aggregation reached the target size of the adaptive flush policy
+	flushReasonSize
+	// the flush timer expired, either the flush time
+	// or the target latency of the adaptive flush policy
+	flushReasonTimer
+	// a force flush was requested by the client
+	flushReasonForce
+	// a command (e.g. wait slave sync) required a flush
+	flushReasonCommand
+
+	flushReasonCount
+)
+
+// String implements Stringer.String
+func (reason flushReason) String() string {
+	switch reason {
+	case flushReasonFull:
+		return "full"
+	case flushReasonSize:
+		return "size"
+	case flushReasonTimer:
+		return "timer"
+	case flushReasonForce:
+		return "force"
+	case flushReasonCommand:
+		return "command"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// weight of the last measured block interval,
+	// used to compute the (exponential moving) average block interval
+	flushPolicyIntervalWeight = 0.2
+
+	// flushStatisticsInterval defines the minimum interval
+	// in which the flush statistics of a vdisk are broadcasted.
+	flushStatisticsInterval = time.Second * 30
+)
+
+// flushPolicy decides when the flusher of a vdisk flushes.
+//
+// By default it only flushes when the aggregation is full,
+// or when the flush time has passed since the last flush.
+//
+// When adaptive flushing is enabled, it targets a max acknowledgement latency
+// instead: the target size of an aggregation is the amount of blocks
+// expected to arrive within that latency, based on the observed block arrival rate,
+// and is kept within the range [min flush size, flush size].
+// The aggregation is flushed as soon as it reaches that target size,
+// or at the latest when its oldest block has waited for the target latency.
+type flushPolicy struct {
+	adaptive bool
+	flushDur time.Duration // fixed flush time
+	latency  time.Duration // target max latency
+	minSize  int
+	maxSize  int
+
+	// (exponential moving) average interval between 2 blocks
+	avgInterval time.Duration
+	lastArrival time.Time
+	// arrival time of the oldest block which isn't flushed yet
+	firstPending time.Time
+	targetSize   int
+
+	stats flushStatistics
+}
+
+// newFlushPolicy creates a new flush policy for the given vdisk
+func newFlushPolicy(vdiskID string, conf *flusherConfig) *flushPolicy {
+	maxSize := conf.FlushSize
+	if maxSize == 0 {
+		maxSize = flusher.DefaultFlushSize
+	}
+	fp := &flushPolicy{
+		adaptive: conf.AdaptiveFlush,
+		flushDur: time.Duration(conf.FlushTime) * time.Second,
+		latency:  time.Duration(conf.FlushLatency) * time.Millisecond,
+		minSize:  conf.MinFlushSize,
+		maxSize:  maxSize,
+		stats:    newFlushStatistics(vdiskID),
+	}
+	if fp.minSize <= 0 {
+		fp.minSize = 1
+	}
+	fp.targetSize = fp.maxSize
+	if fp.adaptive {
+		// start with the min size, until we know the arrival rate
+		fp.targetSize = fp.minSize
+	}
+	return fp
+}
+
+// timeout returns the duration of the flush timer,
+// when (re)started after a flush or an expired timer.
+func (fp *flushPolicy) timeout() time.Duration {
+	if fp.adaptive {
+		return fp.latency
+	}
+	return fp.flushDur
+}
+
+// addBlock registers a block arriving at the given time,
+// with pending the amount of blocks in the aggregation, this block included.
+// It returns true if the aggregation has to be flushed because of its target size,
+// as well as the duration to which the flush timer has to be reset,
+// 0 if the timer doesn't have to be reset.
+func (fp *flushPolicy) addBlock(now time.Time, pending int) (bool, time.Duration) {
+	if !fp.lastArrival.IsZero() {
+		interval := now.Sub(fp.lastArrival)
+		if fp.avgInterval == 0 {
+			fp.avgInterval = interval
+		} else {
+			fp.avgInterval = time.Duration(flushPolicyIntervalWeight*float64(interval) +
+				(1-flushPolicyIntervalWeight)*float64(fp.avgInterval))
+		}
+	}
+	fp.lastArrival = now
+
+	if pending == 1 || fp.firstPending.IsZero() {
+		fp.firstPending = now
+	}
+
+	if !fp.adaptive {
+		// only flushed when full or when the flush time has passed
+		return false, 0
+	}
+
+	fp.targetSize = fp.computeTargetSize()
+	if pending >= fp.targetSize {
+		return true, 0
+	}
+	if pending == 1 {
+		// the first pending block has to be acknowledged within the target latency
+		return false, fp.latency
+	}
+	return false, 0
+}
+
+// computeTargetSize computes the target size of an aggregation,
+// being the amount of blocks expected to arrive within the target latency.
+func (fp *flushPolicy) computeTargetSize() int {
+	if fp.avgInterval <= 0 {
+		return fp.minSize
+	}
+	size := int(fp.latency / fp.avgInterval)
+	if size < fp.minSize {
+		return fp.minSize
+	}
+	if size > fp.maxSize {
+		return fp.maxSize
+	}
+	return size
+}
+
+// flushed registers a flush of the given amount of blocks, finished at the given time.
+func (fp *flushPolicy) flushed(now time.Time, reason flushReason, size int) {
+	var latency time.Duration
+	if !fp.firstPending.IsZero() {
+		latency = now.Sub(fp.firstPending)
+	}
+	fp.firstPending = time.Time{}
+
+	log.Debugf("vdisk `%s` flushed %d blocks (reason: %s, target size: %d, latency: %v)",
+		fp.stats.vdiskID, size, reason, fp.targetSize, latency)
+
+	fp.stats.track(reason, size, fp.targetSize, latency)
+	if fp.adaptive {
+		fp.stats.blockRate = fp.blockRate()
+	}
+	fp.stats.broadcastIfNeeded(now, fp.adaptive)
+}
+
+// blockRate returns the observed block arrival rate, in blocks per second
+func (fp *flushPolicy) blockRate() float64 {
+	if fp.avgInterval <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(fp.avgInterval)
+}
+
+// flushStatistics aggregates the flush decisions of a vdisk,
+// broadcasting them at most once per flushStatisticsInterval.
+type flushStatistics struct {
+	vdiskID string
+
+	// precomputed keys for this vdisk,
+	// used to broadcast the statistics linked to these keys
+	sizeKey, targetSizeKey, latencyKey, rateKey string
+	reasonKeys                                  [flushReasonCount]string
+
+	// values aggregated since the last broadcast
+	flushes    int64
+	blocks     int64
+	targetSize int64
+	latency    time.Duration
+	blockRate  float64
+
+	// total amount of flushes per reason
+	reasons [flushReasonCount]int64
+
+	lastBroadcast time.Time
+}
+
+func newFlushStatistics(vdiskID string) flushStatistics {
+	stats := flushStatistics{
+		vdiskID:       vdiskID,
+		sizeKey:       "vdisk.tlog.flush.size@virt." + vdiskID,
+		targetSizeKey: "vdisk.tlog.flush.target@virt." + vdiskID,
+		latencyKey:    "vdisk.tlog.flush.latency@virt." + vdiskID,
+		rateKey:       "vdisk.tlog.flush.rate@virt." + vdiskID,
+		lastBroadcast: time.Now(),
+	}
+	for reason := flushReason(0); reason < flushReasonCount; reason++ {
+		stats.reasonKeys[reason] = "vdisk.tlog.flush.reason." + reason.String() + "@virt." + vdiskID
+	}
+	return stats
+}
+
+// track a single flush
+func (stats *flushStatistics) track(reason flushReason, size, targetSize int, latency time.Duration) {
+	stats.flushes++
+	stats.blocks += int64(size)
+	stats.targetSize += int64(targetSize)
+	stats.latency += latency
+	stats.reasons[reason]++
+}
+
+// broadcast the aggregated statistics,
+// in case the interval since the last broadcast has passed.
+func (stats *flushStatistics) broadcastIfNeeded(now time.Time, adaptive bool) {
+	if stats.flushes == 0 || now.Sub(stats.lastBroadcast) < flushStatisticsInterval {
+		return
+	}
+	stats.lastBroadcast = now
+
+	flushes := float64(stats.flushes)
+	broadcastFlushStatistic(stats.sizeKey, float64(stats.blocks)/flushes, log.AggregationAverages)
+	broadcastFlushStatistic(stats.latencyKey,
+		float64(stats.latency/time.Millisecond)/flushes, log.AggregationAverages)
+	if adaptive {
+		broadcastFlushStatistic(stats.targetSizeKey, float64(stats.targetSize)/flushes, log.AggregationAverages)
+		broadcastFlushStatistic(stats.rateKey, stats.blockRate, log.AggregationAverages)
+	}
+	for reason, count := range stats.reasons {
+		broadcastFlushStatistic(stats.reasonKeys[reason], float64(count), log.AggregationDifferentiates)
+	}
+
+	stats.flushes, stats.blocks, stats.targetSize, stats.latency = 0, 0, 0, 0
+}
+
+// broadcastFlushStatistic is the broadcast function used for production,
+// using the zero-os/0-log lib wrapped in our log module.
+// It is a variable, such that it can be overwritten for testing purposes.
+var broadcastFlushStatistic = func(key string, value float64, op log.AggregationType) {
+	log.BroadcastStatistics(key, value, op, nil)
+}
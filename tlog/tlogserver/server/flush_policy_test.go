@@ -0,0 +1,129 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zero-os/0-Disk/log"
+)
+
+func TestFlushPolicyFixed(t *testing.T) {
+	policy := newFlushPolicy("a", &flusherConfig{
+		FlushSize: 25,
+		FlushTime: 10,
+	})
+	require.Equal(t, 10*time.Second, policy.timeout())
+
+	// a fixed policy only flushes when full or when the flush time has passed,
+	// which isn't decided by the policy itself
+	now := time.Now()
+	for pending := 1; pending <= 25; pending++ {
+		now = now.Add(time.Millisecond)
+		flush, timeout := policy.addBlock(now, pending)
+		assert.False(t, flush)
+		assert.Equal(t, time.Duration(0), timeout)
+	}
+}
+
+func TestFlushPolicyAdaptive(t *testing.T) {
+	policy := newFlushPolicy("a", &flusherConfig{
+		FlushSize:     25,
+		FlushTime:     10,
+		AdaptiveFlush: true,
+		FlushLatency:  100,
+		MinFlushSize:  2,
+	})
+	require.Equal(t, 100*time.Millisecond, policy.timeout())
+
+	now := time.Now()
+
+	// the first block resets the flush timer to the target latency,
+	// such that it is acknowledged in time
+	flush, timeout := policy.addBlock(now, 1)
+	require.False(t, flush)
+	require.Equal(t, 100*time.Millisecond, timeout)
+
+	// a block every 10ms gives a target size of 10 blocks
+	var pending int
+	for pending = 2; pending <= 10; pending++ {
+		now = now.Add(10 * time.Millisecond)
+		flush, timeout = policy.addBlock(now, pending)
+		require.Equal(t, time.Duration(0), timeout)
+		if flush {
+			break
+		}
+	}
+	require.True(t, flush)
+	require.Equal(t, 10, pending)
+	require.Equal(t, 10, policy.targetSize)
+	policy.flushed(now, flushReasonSize, pending)
+
+	// a block every 1ms is limited by the flush size
+	for pending = 1; pending <= 25; pending++ {
+		now = now.Add(time.Millisecond)
+		flush, _ = policy.addBlock(now, pending)
+		if flush {
+			break
+		}
+	}
+	require.True(t, flush)
+	require.Equal(t, 25, policy.targetSize)
+	policy.flushed(now, flushReasonSize, pending)
+
+	// a block every second is limited by the min flush size
+	for i := 0; i < 50; i++ {
+		now = now.Add(time.Second)
+		policy.addBlock(now, 1)
+		policy.flushed(now, flushReasonTimer, 1)
+	}
+	require.Equal(t, 2, policy.targetSize)
+	require.InDelta(t, 1.0, policy.blockRate(), 0.01)
+}
+
+func TestFlushPolicyStatistics(t *testing.T) {
+	type statistic struct {
+		value float64
+		op    log.AggregationType
+	}
+	statistics := make(map[string]statistic)
+
+	broadcast := broadcastFlushStatistic
+	defer func() {
+		broadcastFlushStatistic = broadcast
+	}()
+	broadcastFlushStatistic = func(key string, value float64, op log.AggregationType) {
+		statistics[key] = statistic{value, op}
+	}
+
+	policy := newFlushPolicy("a", &flusherConfig{
+		FlushSize:     25,
+		FlushTime:     10,
+		AdaptiveFlush: true,
+		FlushLatency:  100,
+		MinFlushSize:  1,
+	})
+
+	now := time.Now()
+	policy.addBlock(now, 1)
+	policy.addBlock(now.Add(20*time.Millisecond), 2)
+	now = now.Add(40 * time.Millisecond)
+	policy.flushed(now, flushReasonTimer, 2)
+
+	// nothing is broadcasted until the statistics interval has passed
+	require.Empty(t, statistics)
+
+	policy.addBlock(now, 1)
+	now = now.Add(flushStatisticsInterval)
+	policy.flushed(now, flushReasonForce, 4)
+
+	require.Equal(t, statistic{3, log.AggregationAverages}, statistics["vdisk.tlog.flush.size@virt.a"])
+	require.Contains(t, statistics, "vdisk.tlog.flush.latency@virt.a")
+	require.Contains(t, statistics, "vdisk.tlog.flush.target@virt.a")
+	require.Equal(t, statistic{50, log.AggregationAverages}, statistics["vdisk.tlog.flush.rate@virt.a"])
+	require.Equal(t, statistic{1, log.AggregationDifferentiates}, statistics["vdisk.tlog.flush.reason.timer@virt.a"])
+	require.Equal(t, statistic{1, log.AggregationDifferentiates}, statistics["vdisk.tlog.flush.reason.force@virt.a"])
+	require.Equal(t, statistic{0, log.AggregationDifferentiates}, statistics["vdisk.tlog.flush.reason.size@virt.a"])
+}
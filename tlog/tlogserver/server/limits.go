@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// bandwidthLimiter limits the bandwidth used by a vdisk to flush to 0-stor,
+// by delaying the next flush until the previous ones fit within that bandwidth.
+type bandwidthLimiter struct {
+	rate float64   // bytes per second, 0 if unlimited
+	next time.Time // earliest time of the next flush
+}
+
+// newBandwidthLimiter creates a bandwidth limiter
+// for the given bandwidth (KiB/s), 0 meaning unlimited.
+func newBandwidthLimiter(kibps int) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: float64(kibps) * 1024}
+}
+
+// delay returns the duration to wait at the given time,
+// before a flush is allowed.
+func (bl *bandwidthLimiter) delay(now time.Time) time.Duration {
+	if bl.rate <= 0 || !bl.next.After(now) {
+		return 0
+	}
+	return bl.next.Sub(now)
+}
+
+// flushed registers a flush of the given amount of bytes, started at the given time.
+func (bl *bandwidthLimiter) flushed(start time.Time, n int) {
+	if bl.rate <= 0 {
+		return
+	}
+	if bl.next.Before(start) {
+		bl.next = start
+	}
+	bl.next = bl.next.Add(time.Duration(float64(n) / bl.rate * float64(time.Second)))
+}
+
+// vdiskLimits enforces the resource limits of a single vdisk
+// when flushing to 0-stor. While the vdisk waits to flush,
+// it keeps buffering received blocks, up to its max amount of buffered blocks,
+// after which the client is asked to resend its blocks later.
+type vdiskLimits struct {
+	bandwidth *bandwidthLimiter
+}
+
+func newVdiskLimits(conf *flusherConfig) *vdiskLimits {
+	return &vdiskLimits{
+		bandwidth: newBandwidthLimiter(conf.MaxFlushBandwidth),
+	}
+}
+
+// acquireFlush blocks until the vdisk is allowed to flush.
+// It returns false in case the context was done first.
+func (vl *vdiskLimits) acquireFlush(ctx context.Context) bool {
+	if delay := vl.bandwidth.delay(time.Now()); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+	return true
+}
+
+// releaseFlush releases a flush, acquired earlier,
+// which started at the given time and flushed the given amount of bytes.
+func (vl *vdiskLimits) releaseFlush(start time.Time, n int) {
+	vl.bandwidth.flushed(start, n)
+}
+
+// maxBufferedBlocks returns the max amount of blocks
+// a vdisk buffers, waiting to be flushed.
+func maxBufferedBlocks(conf *flusherConfig) int {
+	if conf.MaxBufferedBlocks > 0 {
+		return conf.MaxBufferedBlocks
+	}
+	return conf.FlushSize * tlogBlockFactorSize
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"zombiezen.com/go/capnproto2"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+)
+
+func TestBandwidthLimiter(t *testing.T) {
+	now := time.Now()
+
+	// unlimited
+	bl := newBandwidthLimiter(0)
+	bl.flushed(now, 1024*1024)
+	require.Equal(t, time.Duration(0), bl.delay(now))
+
+	// 1 MiB/s
+	bl = newBandwidthLimiter(1024)
+	require.Equal(t, time.Duration(0), bl.delay(now))
+
+	// flushing 512 KiB takes half a second of bandwidth
+	bl.flushed(now, 512*1024)
+	assert.Equal(t, 500*time.Millisecond, bl.delay(now))
+	assert.Equal(t, 250*time.Millisecond, bl.delay(now.Add(250*time.Millisecond)))
+	assert.Equal(t, time.Duration(0), bl.delay(now.Add(time.Second)))
+
+	// flushes started before the previous ones fit within the bandwidth add up
+	bl.flushed(now.Add(250*time.Millisecond), 512*1024)
+	assert.Equal(t, time.Second, bl.delay(now))
+
+	// unused bandwidth can't be saved up
+	later := now.Add(time.Minute)
+	bl.flushed(later, 256*1024)
+	assert.Equal(t, 250*time.Millisecond, bl.delay(later))
+}
+
+// blocks received when the max amount of blocks is buffered,
+// are not accepted, and have to be resent by the client
+func TestVdiskMaxBufferedBlocks(t *testing.T) {
+	const maxBuffered = 2
+
+	vd := &vdisk{
+		id:               "a",
+		respChan:         make(chan *BlockResponse, maxBuffered+2),
+		orderedBlockChan: make(chan *schema.TlogBlock, maxBuffered),
+		expectedSequence: tlog.FirstSequence,
+	}
+
+	seq := tlog.FirstSequence
+	for i := 0; i < maxBuffered; i++ {
+		require.NoError(t, vd.handleBlock(newTestBlock(t, seq)))
+		resp := <-vd.respChan
+		require.Equal(t, tlog.BlockStatusRecvOK.Int8(), resp.Status)
+		require.Equal(t, []uint64{seq}, resp.Sequences)
+		seq++
+	}
+
+	// the buffer is full
+	require.NoError(t, vd.handleBlock(newTestBlock(t, seq)))
+	resp := <-vd.respChan
+	require.Equal(t, tlog.BlockStatusRecvBusy.Int8(), resp.Status)
+	require.Equal(t, []uint64{seq}, resp.Sequences)
+	require.Equal(t, seq, vd.expectedSequence)
+
+	// once a block is flushed, the resent block is accepted
+	<-vd.orderedBlockChan
+	require.NoError(t, vd.handleBlock(newTestBlock(t, seq)))
+	resp = <-vd.respChan
+	require.Equal(t, tlog.BlockStatusRecvOK.Int8(), resp.Status)
+	require.Equal(t, []uint64{seq}, resp.Sequences)
+	require.Equal(t, seq+1, vd.expectedSequence)
+}
+
+func newTestBlock(t *testing.T, seq uint64) *schema.TlogBlock {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	require.NoError(t, err)
+	block, err := schema.NewRootTlogBlock(seg)
+	require.NoError(t, err)
+
+	data := make([]byte, 16)
+	block.SetSequence(seq)
+	block.SetOperation(schema.OpSet)
+	require.NoError(t, block.SetData(data))
+	require.NoError(t, block.SetHash(zerodisk.HashBytes(data)))
+	return &block
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/tlog/tlogclient"
+)
+
+// Test server's marker feature
+// Steps:
+// 1. set flushTime to very high value to avoid flush by timeout
+// 2. sequence of the marker must not be multiple of FlushSize
+// 3. client inserts the marker at that sequence
+// 4. create goroutine to wait for the marker's sequence to be flushed
+// 5. client send the logs
+// 6. the marker can be found in the flushed aggregations
+func TestInsertMarker(t *testing.T) {
+	const (
+		vdiskID    = "1234567890"
+		markerName = "fsfreeze"
+	)
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	// Step #1
+	// create and start server
+	conf := testConf
+	conf.FlushTime = 1000
+	cleanFunc, stubSource, storConf := newZeroStorConfig(t, vdiskID, conf.PrivKey)
+	defer cleanFunc()
+
+	// start the server
+	s, err := NewServer(conf, stubSource)
+	require.Nil(t, err)
+
+	go s.Listen(ctx)
+
+	// #Step 2
+	numLogs := conf.FlushSize + 10
+	markerSeq := uint64(numLogs - 5)
+
+	// create tlog client
+	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID)
+	require.Nil(t, err)
+	defer client.Close()
+
+	// Step 3
+	err = client.InsertMarker("", markerSeq)
+	require.Equal(t, tlogclient.ErrInvalidMarkerName, err)
+	err = client.InsertMarker(markerName, markerSeq)
+	require.Nil(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	respChan := client.Recv()
+
+	// Step #4
+	go func() {
+		defer wg.Done()
+		testClientWaitSeqFlushed(ctx, t, respChan, cancelFunc, markerSeq, true)
+	}()
+
+	// Step #5
+	go func() {
+		defer wg.Done()
+		data := make([]byte, 4096)
+		testClientSendLog(ctx, t, client, cancelFunc, 0, numLogs, data)
+	}()
+
+	wg.Wait()
+
+	// Step #6
+	storCli, err := stor.NewClient(storConf)
+	require.Nil(t, err)
+	defer storCli.Close()
+
+	marker, err := storCli.FindMarker(markerName)
+	require.Nil(t, err)
+	require.Equal(t, markerName, marker.Name)
+	require.Equal(t, markerSeq, marker.Sequence)
+}
+
+// Test that a marker is persisted,
+// when it is inserted after its sequence has been flushed,
+// even though no blocks are written afterwards.
+func TestInsertMarkerAfterFlush(t *testing.T) {
+	const (
+		vdiskID    = "1234567890"
+		markerName = "snapshot"
+	)
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	// create and start server, flushing only when an aggregation is full
+	conf := testConf
+	conf.FlushTime = 1000
+	cleanFunc, stubSource, storConf := newZeroStorConfig(t, vdiskID, conf.PrivKey)
+	defer cleanFunc()
+
+	s, err := NewServer(conf, stubSource)
+	require.Nil(t, err)
+
+	go s.Listen(ctx)
+
+	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID)
+	require.Nil(t, err)
+	defer client.Close()
+
+	// send and flush a full aggregation
+	lastSeq := uint64(conf.FlushSize)
+	data := make([]byte, 4096)
+	testClientSendWaitFlushResp(t, client, client.Recv(), conf.FlushSize+1, 1, lastSeq, data)
+
+	// insert a marker at the last flushed sequence
+	err = client.InsertMarker(markerName, lastSeq)
+	require.Nil(t, err)
+
+	storCli, err := stor.NewClient(storConf)
+	require.Nil(t, err)
+	defer storCli.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		marker, err := storCli.FindMarker(markerName)
+		if err == nil {
+			require.Equal(t, markerName, marker.Name)
+			require.Equal(t, lastSeq, marker.Sequence)
+			break
+		}
+		require.Equal(t, stor.ErrMarkerNotFound, errors.Cause(err))
+		require.True(t, time.Now().Before(deadline), "marker wasn't flushed")
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// the aggregation which only contains the marker
+	// doesn't change the last flushed sequence
+	storCli, err = stor.NewClient(storConf)
+	require.Nil(t, err)
+	defer storCli.Close()
+	seq, err := storCli.LoadLastSequence()
+	require.Nil(t, err)
+	require.Equal(t, lastSeq, seq)
+}
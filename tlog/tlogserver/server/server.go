@@ -24,6 +24,7 @@ type Server struct {
 	maxRespSegmentBufLen int // max len of response capnp segment buffer
 	listener             net.Listener
 	coordListener        net.Listener
+	subscribeListener    net.Listener
 	waitConnectAddr      string
 	flusherConf          *flusherConfig
 	vdiskMgr             *vdiskManager
@@ -35,10 +36,16 @@ func NewServer(conf *Config, configSource config.Source) (*Server, error) {
 	if conf == nil {
 		return nil, errors.New("tlogserver requires a non-nil config")
 	}
+	if err := conf.validateAdaptiveFlush(); err != nil {
+		return nil, err
+	}
+	if err := conf.validateLimits(); err != nil {
+		return nil, err
+	}
 
 	var (
-		err                     error
-		coordListener, listener net.Listener
+		err                                        error
+		coordListener, subscribeListener, listener net.Listener
 	)
 
 	// tlog main listen addr
@@ -70,11 +77,27 @@ func NewServer(conf *Config, configSource config.Source) (*Server, error) {
 		}
 	}
 
+	// tlog subscribe listen address
+	if conf.SubscribeListenAddr != "" {
+		subscribeListener, err = net.Listen("tcp", conf.SubscribeListenAddr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to listen to %v", conf.SubscribeListenAddr)
+		}
+	}
+
 	// used to created a flusher on rumtime
 	flusherConf := &flusherConfig{
-		FlushSize: conf.FlushSize,
-		FlushTime: conf.FlushTime,
-		PrivKey:   conf.PrivKey,
+		FlushSize:     conf.FlushSize,
+		FlushTime:     conf.FlushTime,
+		PrivKey:       conf.PrivKey,
+		AdaptiveFlush: conf.AdaptiveFlush,
+		FlushLatency:  conf.FlushLatency,
+		MinFlushSize:  conf.MinFlushSize,
+		DedupEncoding: conf.DedupEncoding,
+		DeltaEncoding: conf.DeltaEncoding,
+
+		MaxBufferedBlocks: conf.MaxBufferedBlocks,
+		MaxFlushBandwidth: conf.MaxFlushBandwidth,
 	}
 
 	vdiskManager := newVdiskManager(conf.SlaveSyncerMgr, conf.FlushSize, configSource)
@@ -82,6 +105,7 @@ func NewServer(conf *Config, configSource config.Source) (*Server, error) {
 		listener:             listener,
 		acceptAddr:           conf.AcceptAddr,
 		coordListener:        coordListener,
+		subscribeListener:    subscribeListener,
 		waitConnectAddr:      conf.WaitConnectAddr,
 		flusherConf:          flusherConf,
 		maxRespSegmentBufLen: schema.RawTlogRespLen(conf.FlushSize),
@@ -97,6 +121,10 @@ func (s *Server) Listen(ctx context.Context) {
 	if s.coordListener != nil {
 		go s.listenTlogWait()
 	}
+	if s.subscribeListener != nil {
+		defer s.subscribeListener.Close()
+		go s.listenSubscribe()
+	}
 
 	for {
 		select {
@@ -152,6 +180,11 @@ func (s *Server) WaitListenAddr() string {
 	return s.coordListener.Addr().String()
 }
 
+// SubscribeListenAddr returns the address the tlog server is listening on for subscribers
+func (s *Server) SubscribeListenAddr() string {
+	return s.subscribeListener.Addr().String()
+}
+
 // handshake stage, required prior to receiving blocks
 func (s *Server) handshake(r io.Reader, w io.Writer, conn *net.TCPConn) (vd *vdisk, err error) {
 	status := tlog.HandshakeStatusInternalServerError
@@ -0,0 +1,466 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"zombiezen.com/go/capnproto2"
+)
+
+const (
+	// amount of flushed aggregations buffered for a single subscriber,
+	// a subscriber which falls further behind gets disconnected,
+	// such that it can resume its subscription from the last sequence it received
+	subscriberBufferSize = 32
+)
+
+var (
+	// errSubscriberDropped is returned when a subscriber
+	// couldn't keep up with the flushed aggregations of its vdisk
+	errSubscriberDropped = errors.New("subscriber couldn't keep up with the flushed aggregations")
+)
+
+// newSubscriberHub creates a new (empty) subscriber hub.
+func newSubscriberHub() *subscriberHub {
+	return &subscriberHub{
+		subscribers: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// subscriberHub broadcasts the flushed aggregations
+// of all vdisks to their subscribers.
+type subscriberHub struct {
+	subscribers map[string]map[*subscriber]struct{}
+	mux         sync.Mutex
+}
+
+// subscriber receives the raw flushed aggregations of a single vdisk,
+// until it is dropped because it couldn't keep up.
+type subscriber struct {
+	aggCh   chan []byte
+	dropped chan struct{}
+}
+
+// subscribe to all aggregations flushed for the given vdisk from now on.
+func (hub *subscriberHub) subscribe(vdiskID string) *subscriber {
+	sub := &subscriber{
+		aggCh:   make(chan []byte, subscriberBufferSize),
+		dropped: make(chan struct{}),
+	}
+
+	hub.mux.Lock()
+	defer hub.mux.Unlock()
+
+	subs, ok := hub.subscribers[vdiskID]
+	if !ok {
+		subs = make(map[*subscriber]struct{})
+		hub.subscribers[vdiskID] = subs
+	}
+	subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe a subscriber, if it wasn't dropped already.
+func (hub *subscriberHub) unsubscribe(vdiskID string, sub *subscriber) {
+	hub.mux.Lock()
+	defer hub.mux.Unlock()
+
+	subs, ok := hub.subscribers[vdiskID]
+	if !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(hub.subscribers, vdiskID)
+	}
+}
+
+// publish a flushed aggregation to all subscribers of the given vdisk.
+// Publishing never blocks, subscribers which can't keep up are dropped instead.
+func (hub *subscriberHub) publish(vdiskID string, rawAgg []byte) {
+	hub.mux.Lock()
+	defer hub.mux.Unlock()
+
+	subs, ok := hub.subscribers[vdiskID]
+	if !ok {
+		return
+	}
+	for sub := range subs {
+		select {
+		case sub.aggCh <- rawAgg:
+		default:
+			log.Errorf("dropping subscriber of vdisk %s as it can't keep up", vdiskID)
+			close(sub.dropped)
+			delete(subs, sub)
+		}
+	}
+	if len(subs) == 0 {
+		delete(hub.subscribers, vdiskID)
+	}
+}
+
+// listen for subscriber connections
+func (s *Server) listenSubscribe() {
+	log.Infof("listening for subscribers at: %v", s.subscribeListener.Addr().String())
+	for {
+		conn, err := s.subscribeListener.Accept()
+		if err != nil {
+			select {
+			case <-s.ctx.Done():
+				return
+			default:
+			}
+			log.Errorf("couldn't accept subscriber connection: %v", err)
+			continue
+		}
+
+		remoteAddr := conn.RemoteAddr().String()
+		log.Infof("accepted subscriber connection from %s", remoteAddr)
+
+		go func() {
+			err := s.handleSubscribe(conn)
+			if err == nil {
+				log.Infof("subscriber connection from %s dropped", remoteAddr)
+			} else {
+				log.Errorf("subscriber connection from %s dropped with an error: %v", remoteAddr, err)
+			}
+		}()
+	}
+}
+
+// handle subscriber connection
+// - handshake
+// - send all flushed aggregations, starting from the requested sequence
+func (s *Server) handleSubscribe(conn net.Conn) error {
+	defer conn.Close()
+
+	ctx, cancelFunc := context.WithCancel(s.ctx)
+	defer cancelFunc()
+
+	vs, err := s.subscribeHandshake(conn)
+	if err != nil {
+		return errors.Wrap(err, "subscribe handshake failed")
+	}
+	defer vs.close()
+
+	// a subscriber isn't supposed to send anything after the handshake,
+	// we only read from the connection to know when it is closed
+	go func() {
+		io.Copy(ioutil.Discard, conn)
+		cancelFunc()
+	}()
+
+	return vs.run(ctx)
+}
+
+// subscribe handshake stage, required prior to sending aggregations
+func (s *Server) subscribeHandshake(conn net.Conn) (vs *vdiskSubscription, err error) {
+	status := tlog.HandshakeStatusInternalServerError
+	var lastSeq uint64
+
+	// always return response, even in case of a panic,
+	// but normally this is triggered because of a(n early) return
+	defer func() {
+		err := writeSubscribeHandshakeResponse(conn, status, lastSeq)
+		if err != nil {
+			log.Infof("couldn't write server %s subscribe response: %s",
+				status.String(), err.Error())
+		}
+	}()
+
+	msg, err := capnp.NewDecoder(conn).Decode()
+	if err != nil {
+		status = tlog.HandshakeStatusInvalidRequest
+		err = errors.Wrap(err, "couldn't decode SubscribeHandshakeRequest")
+		return
+	}
+	req, err := schema.ReadRootSubscribeHandshakeRequest(msg)
+	if err != nil {
+		status = tlog.HandshakeStatusInvalidRequest
+		err = errors.Wrap(err, "couldn't decode SubscribeHandshakeRequest")
+		return
+	}
+
+	clientVersion := zerodisk.VersionFromUInt32(req.Version())
+	if clientVersion.Compare(tlog.MinSupportedVersion) < 0 {
+		status = tlog.HandshakeStatusInvalidVersion
+		err = errors.Newf("subscriber version (%s) is not supported by this server", clientVersion)
+		return
+	}
+
+	vdiskID, err := req.VdiskID()
+	if err != nil {
+		status = tlog.HandshakeStatusInvalidVdiskID
+		err = errors.Wrap(err, "couldn't get vdiskID from SubscribeHandshakeRequest")
+		return
+	}
+	if vdiskID == "" {
+		status = tlog.HandshakeStatusInvalidVdiskID
+		err = errors.New("no vdiskID given in SubscribeHandshakeRequest")
+		return
+	}
+
+	// only a vdisk without a (valid) tlog config is an invalid vdisk,
+	// any other failure is reported as an internal server error
+	storConf, err := stor.ConfigFromConfigSource(s.vdiskMgr.configSource, vdiskID, s.flusherConf.PrivKey)
+	if err != nil {
+		status = tlog.HandshakeStatusInvalidVdiskID
+		err = errors.Wrapf(err, "couldn't read 0-stor config for vdisk %s", vdiskID)
+		return
+	}
+	storClient, err := stor.NewClient(storConf)
+	if err != nil {
+		err = errors.Wrapf(err, "couldn't create 0-stor client for vdisk %s", vdiskID)
+		return
+	}
+
+	// subscribe prior to loading the last flushed sequence,
+	// such that no aggregation can get lost in between
+	sub := s.vdiskMgr.subscribers.subscribe(vdiskID)
+
+	lastSeq, err = storClient.LoadLastSequence()
+	if errors.Cause(err) == stor.ErrNoFlushedBlock {
+		lastSeq, err = 0, nil
+	}
+	if err != nil {
+		s.vdiskMgr.subscribers.unsubscribe(vdiskID, sub)
+		storClient.Close()
+		err = errors.Wrapf(err, "couldn't load last flushed sequence of vdisk %s", vdiskID)
+		return
+	}
+
+	nextSeq := req.StartSequence()
+	if nextSeq < tlog.FirstSequence {
+		nextSeq = tlog.FirstSequence
+	}
+
+	log.Infof("subscriber subscribed to vdisk %s, starting from sequence %d", vdiskID, nextSeq)
+	status = tlog.HandshakeStatusOK
+	vs = &vdiskSubscription{
+		vdiskID:        vdiskID,
+		hub:            s.vdiskMgr.subscribers,
+		sub:            sub,
+		storClient:     storClient,
+		enc:            capnp.NewEncoder(conn),
+		nextSeq:        nextSeq,
+		lastFlushedSeq: lastSeq,
+		withData:       req.WithData(),
+	}
+	return
+}
+
+func writeSubscribeHandshakeResponse(w io.Writer, status tlog.HandshakeStatus, lastFlushedSeq uint64) error {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return err
+	}
+
+	resp, err := schema.NewRootSubscribeHandshakeResponse(seg)
+	if err != nil {
+		return err
+	}
+
+	resp.SetVersion(zerodisk.CurrentVersion.UInt32())
+	resp.SetLastFlushedSequence(lastFlushedSeq)
+	resp.SetStatus(status.Int8())
+
+	return capnp.NewEncoder(w).Encode(msg)
+}
+
+// vdiskSubscription sends the flushed aggregations of a vdisk to a single subscriber,
+// first the aggregations already stored in 0-stor, and the newly flushed aggregations after that.
+// Blocks are sent only once and in order, starting from the requested sequence.
+type vdiskSubscription struct {
+	vdiskID        string
+	hub            *subscriberHub
+	sub            *subscriber
+	storClient     *stor.Client
+	enc            *capnp.Encoder
+	nextSeq        uint64 // next sequence to send
+	lastFlushedSeq uint64 // last flushed sequence at subscribe time
+	withData       bool
+}
+
+// run the subscription until the context is done,
+// or until the subscriber couldn't keep up.
+func (vs *vdiskSubscription) run(ctx context.Context) error {
+	// aggregations flushed while the history is sent, are buffered,
+	// and sent once all stored aggregations have been sent
+	live, err := vs.sendHistory(ctx)
+	if err != nil || ctx.Err() != nil {
+		return err
+	}
+	for _, rawAgg := range live {
+		err = vs.sendRaw(rawAgg)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-vs.sub.dropped:
+			return errSubscriberDropped
+
+		case rawAgg := <-vs.sub.aggCh:
+			err = vs.sendRaw(rawAgg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send all aggregations stored in 0-stor,
+// which contain sequences the subscriber hasn't received yet,
+// starting from the aggregation which contains the next sequence to send.
+// The aggregations flushed in the meantime are returned,
+// such that the subscriber can't fall behind while the history is sent.
+func (vs *vdiskSubscription) sendHistory(ctx context.Context) ([][]byte, error) {
+	if vs.nextSeq > vs.lastFlushedSeq {
+		return nil, nil // subscriber doesn't need any stored aggregation
+	}
+
+	walkCh := vs.storClient.WalkFromSequence(vs.nextSeq, tlog.TimeNowTimestamp())
+	// drain the remaining aggregations in the background,
+	// as the walk can't be cancelled
+	defer func() {
+		go func() {
+			for range walkCh {
+			}
+		}()
+	}()
+
+	var live [][]byte
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+
+		case <-vs.sub.dropped:
+			return nil, errSubscriberDropped
+
+		case rawAgg := <-vs.sub.aggCh:
+			live = append(live, rawAgg)
+
+		case wr, ok := <-walkCh:
+			if !ok {
+				return live, nil
+			}
+			if wr.Err != nil {
+				return nil, errors.Wrapf(wr.Err, "couldn't walk the aggregations of vdisk %s", vs.vdiskID)
+			}
+			err := vs.send(wr.Agg)
+			if err != nil {
+				return nil, err
+			}
+			if vs.nextSeq > vs.lastFlushedSeq {
+				return live, nil // all stored sequences have been sent
+			}
+		}
+	}
+}
+
+// sendRaw decodes a raw flushed aggregation, and sends it.
+func (vs *vdiskSubscription) sendRaw(rawAgg []byte) error {
+	msg, err := capnp.NewDecoder(bytes.NewReader(rawAgg)).Decode()
+	if err != nil {
+		return errors.Wrap(err, "couldn't decode flushed aggregation")
+	}
+	agg, err := schema.ReadRootTlogAggregation(msg)
+	if err != nil {
+		return errors.Wrap(err, "couldn't decode flushed aggregation")
+	}
+	return vs.send(&agg)
+}
+
+// send the blocks of the given aggregation,
+// which the subscriber hasn't received yet.
+func (vs *vdiskSubscription) send(agg *schema.TlogAggregation) error {
+	// decode the (deduplicated and/or delta-encoded) block data,
+	// as only some of the blocks might be sent
+	agg, err := tlog.DecodeAggregation(agg)
+	if err != nil {
+		return errors.Wrap(err, "couldn't decode aggregation")
+	}
+
+	blocks, err := agg.Blocks()
+	if err != nil {
+		return errors.Wrap(err, "couldn't get blocks of aggregation")
+	}
+
+	size := int(agg.Size())
+	start := 0
+	for start < size && blocks.At(start).Sequence() < vs.nextSeq {
+		start++
+	}
+	if start == size {
+		return nil // all blocks were already sent
+	}
+
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return err
+	}
+	out, err := schema.NewRootTlogAggregation(seg)
+	if err != nil {
+		return err
+	}
+	out.SetSize(uint64(size - start))
+	out.SetTimestamp(agg.Timestamp())
+	prev, err := agg.Prev()
+	if err != nil {
+		return err
+	}
+	err = out.SetPrev(prev)
+	if err != nil {
+		return err
+	}
+
+	outBlocks, err := out.NewBlocks(int32(size - start))
+	if err != nil {
+		return err
+	}
+	for i := start; i < size; i++ {
+		src, dst := blocks.At(i), outBlocks.At(i-start)
+		err = schema.CopyBlock(&dst, &src)
+		if err != nil {
+			return err
+		}
+		if !vs.withData {
+			err = dst.SetData(nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = vs.enc.Encode(msg)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't send aggregation to subscriber of vdisk %s", vs.vdiskID)
+	}
+
+	vs.nextSeq = blocks.At(size-1).Sequence() + 1
+	return nil
+}
+
+// close the subscription
+func (vs *vdiskSubscription) close() {
+	vs.hub.unsubscribe(vs.vdiskID, vs.sub)
+	if err := vs.storClient.Close(); err != nil {
+		log.Errorf("subscriber of vdisk %s failed to close 0-stor client: %v", vs.vdiskID, err)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriberHub(t *testing.T) {
+	hub := newSubscriberHub()
+
+	subA := hub.subscribe("a")
+	subB := hub.subscribe("a")
+	subC := hub.subscribe("c")
+
+	// aggregations are only published to the subscribers of that vdisk
+	hub.publish("a", []byte{1})
+	require.Len(t, subA.aggCh, 1)
+	require.Len(t, subB.aggCh, 1)
+	require.Len(t, subC.aggCh, 0)
+	assert.Equal(t, []byte{1}, <-subA.aggCh)
+
+	// unsubscribed subscribers no longer receive aggregations
+	hub.unsubscribe("c", subC)
+	hub.publish("c", []byte{2})
+	require.Len(t, subC.aggCh, 0)
+
+	// a subscriber which can't keep up is dropped,
+	// without blocking the other subscribers
+	for i := 0; i < subscriberBufferSize; i++ {
+		hub.publish("a", []byte{3})
+		<-subA.aggCh
+	}
+	select {
+	case <-subB.dropped:
+	default:
+		t.Fatal("subscriber b should have been dropped")
+	}
+	select {
+	case <-subA.dropped:
+		t.Fatal("subscriber a shouldn't have been dropped")
+	default:
+	}
+	assert.Len(t, subB.aggCh, subscriberBufferSize)
+
+	// unsubscribing a subscriber which was already dropped is fine
+	hub.unsubscribe("a", subB)
+	hub.unsubscribe("a", subA)
+	assert.Empty(t, hub.subscribers)
+}
@@ -19,7 +19,7 @@ import (
 const (
 	respChanSize = 10
 
-	// tlogblock buffer size = flusher.flushSize * tlogBlockFactorSize
+	// default tlogblock buffer size = flusher.flushSize * tlogBlockFactorSize
 	// With buffer size that bigger than flushSize:
 	// - we don't always block when flushing
 	// - our RAM won't exploded because we still have upper limit
@@ -37,12 +37,17 @@ const (
 	// ignore all sequences before
 	// the sequence provided in the command
 	vdiskCmdIgnoreSeqBefore
+
+	// add a marker and force flush
+	// at the sequence of that marker
+	vdiskCmdMarker
 )
 
 // command for vdisk flusher
 type vdiskFlusherCmd struct {
 	cmdType  int8
 	sequence uint64
+	marker   tlog.Marker
 	respCh   chan error
 }
 
@@ -63,6 +68,7 @@ type vdisk struct {
 	mux              sync.Mutex
 
 	flusherConf *flusherConfig
+	limits      *vdiskLimits
 
 	// connected clients table
 	clientConn     *net.TCPConn
@@ -72,6 +78,8 @@ type vdisk struct {
 	slaveSyncer  tlog.SlaveSyncer
 	ssMux        sync.Mutex
 
+	subscribers *subscriberHub
+
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 
@@ -96,12 +104,13 @@ func (vd *vdisk) ResponseChan() <-chan *BlockResponse {
 }
 
 // creates vdisk with given vdiskID
-func newVdisk(parentCtx context.Context, vdiskID string, slaveSyncMgr tlog.SlaveSyncerManager, configSource config.Source,
+func newVdisk(parentCtx context.Context, vdiskID string, slaveSyncMgr tlog.SlaveSyncerManager,
+	subscribers *subscriberHub, configSource config.Source,
 	flusherConf *flusherConfig, cleanup vdiskCleanupFunc, coordConnectAddr string) (*vdisk, error) {
 
 	ctx, cancelFunc := context.WithCancel(parentCtx)
 
-	maxTlbInBuffer := flusherConf.FlushSize * tlogBlockFactorSize
+	maxTlbInBuffer := maxBufferedBlocks(flusherConf)
 
 	vd := &vdisk{
 		id:           vdiskID,
@@ -113,10 +122,13 @@ func newVdisk(parentCtx context.Context, vdiskID string, slaveSyncMgr tlog.Slave
 		flusherCmdChan:     make(chan vdiskFlusherCmd, 1),
 		flusherCmdRespChan: make(chan struct{}, 1),
 		flusherConf:        flusherConf,
+		limits:             newVdiskLimits(flusherConf),
 
 		// slave syncer
 		slaveSyncMgr: slaveSyncMgr,
 
+		subscribers: subscribers,
+
 		ctx:              ctx,
 		cancelFunc:       cancelFunc,
 		coordConnectAddr: coordConnectAddr,
@@ -210,6 +222,7 @@ func (vd *vdisk) createFlusher() error {
 
 	// creates flusher
 	vd.flusher = flusher.NewWithStorClient(storClient, vd.flusherConf.FlushSize, int(vdiskConf.BlockSize))
+	vd.flusher.SetDataEncoding(vd.flusherConf.DedupEncoding, vd.flusherConf.DeltaEncoding)
 	return nil
 }
 
@@ -236,6 +249,16 @@ func (vd *vdisk) forceFlushAtSeq(seq uint64) {
 	}
 }
 
+// add the given marker, and force flush
+// when vdisk receives the sequence of that marker
+func (vd *vdisk) insertMarker(marker tlog.Marker) {
+	vd.flusherCmdChan <- vdiskFlusherCmd{
+		cmdType:  vdiskCmdMarker,
+		sequence: marker.Sequence,
+		marker:   marker,
+	}
+}
+
 // connects the given connection to this vdisk
 func (vd *vdisk) connect(conn *net.TCPConn) (uint64, error) {
 	if err := vd.attachConn(conn); err != nil {
@@ -298,15 +321,18 @@ func (vd *vdisk) runFlusher() {
 		// last sequence flushed by this flusher
 		lastSeqFlushed uint64
 
-		// periodic flush interval
-		pfDur = time.Duration(vd.flusherConf.FlushTime) * time.Second
+		// decides when to flush
+		policy = newFlushPolicy(vd.id, vd.flusherConf)
 
 		// periodic flush timer
-		pfTimer = time.NewTimer(pfDur)
+		pfTimer = time.NewTimer(policy.timeout())
 
 		flusherCmd vdiskFlusherCmd
 		cmdType    int8
 
+		// reason of the flush
+		reason flushReason
+
 		// sequence to be force flushed
 		seqToForceFlush uint64
 
@@ -327,31 +353,48 @@ func (vd *vdisk) runFlusher() {
 			}
 
 			maxSeq = tlb.Sequence()
+			targetReached, timeout := policy.addBlock(time.Now(), vd.flusher.Size())
 
 			// check if we need to flush
 			if needForceFlushSeq && tlb.Sequence() >= seqToForceFlush {
 				// reset the flag and flush right now
 				needForceFlushSeq = false
-			} else if !vd.flusher.Full() {
-				// only flush if full
+				reason = flushReasonForce
+			} else if vd.flusher.Full() {
+				reason = flushReasonFull
+			} else if targetReached {
+				reason = flushReasonSize
+			} else {
+				// only flush if full or the target size is reached
+				if timeout > 0 {
+					pfTimer.Stop()
+					pfTimer.Reset(timeout)
+				}
 				continue
 			}
 
 			pfTimer.Stop()
-			pfTimer.Reset(pfDur)
+			pfTimer.Reset(policy.timeout())
 
 		case <-pfTimer.C:
 			// flush by timeout timer
-			pfTimer.Reset(pfDur)
+			pfTimer.Reset(policy.timeout())
 			if vd.flusher.Empty() {
 				continue
 			}
+			reason = flushReasonTimer
 
 		case flusherCmd = <-vd.flusherCmdChan:
 			// got command
 			cmdType = flusherCmd.cmdType
 
 			switch cmdType {
+			case vdiskCmdMarker: // add marker and force flush at its sequence
+				// a marker which sequence is already flushed,
+				// is stored without any blocks
+				vd.flusher.AddMarker(flusherCmd.marker)
+				fallthrough
+
 			case vdiskCmdForceFlushAtSeq: // force flush at sequence
 				seqToForceFlush = flusherCmd.sequence
 				if maxSeq < seqToForceFlush { // we don't have it yet
@@ -361,6 +404,7 @@ func (vd *vdisk) runFlusher() {
 				// we already have the wanted sequence
 				// flush right now if possible
 				needForceFlushSeq = false
+				reason = flushReasonForce
 
 			case vdiskCmdIgnoreSeqBefore:
 				if err := vd.flusher.IgnoreSeqBefore(flusherCmd.sequence); err != nil {
@@ -373,34 +417,50 @@ func (vd *vdisk) runFlusher() {
 
 			case vdiskCmdWaitSlaveSync: // wait for slave sync
 				vd.doWaitSlaveSync(flusherCmd.respCh, lastSeqFlushed)
+				reason = flushReasonCommand
 			default:
 				log.Errorf("invalid command to runFlusher: %v", flusherCmd)
 				continue
 			}
 
-			if vd.flusher.Empty() {
+			// markers are flushed even without blocks,
+			// in case their sequence was already flushed
+			if vd.flusher.Empty() && !vd.flusher.HasFlushedMarkers() {
 				continue
 			}
 
 			pfTimer.Stop()
-			pfTimer.Reset(pfDur)
+			pfTimer.Reset(policy.timeout())
+		}
+
+		// wait until the resource limits of this vdisk allow to flush,
+		// received blocks are buffered in the meantime
+		if !vd.limits.acquireFlush(vd.ctx) {
+			return
 		}
 
 		// get the blocks
 		status := tlog.BlockStatusFlushOK
 
 		// flush to 0-stor
+		flushStart := time.Now()
 		rawAgg, seqs, err := vd.flusher.Flush()
+		vd.limits.releaseFlush(flushStart, len(rawAgg))
 		if err != nil {
 			log.Errorf("flush %v failed: %v", vd.id, err)
 			notifyFlushError(err)
 			status = tlog.BlockStatusFlushFailed
+		} else {
+			policy.flushed(time.Now(), reason, len(seqs))
 		}
 
-		// send response
-		vd.respChan <- &BlockResponse{
-			Status:    status.Int8(),
-			Sequences: seqs,
+		// send response,
+		// unless only markers were flushed, which are already acknowledged
+		if status != tlog.BlockStatusFlushOK || len(seqs) > 0 {
+			vd.respChan <- &BlockResponse{
+				Status:    status.Int8(),
+				Sequences: seqs,
+			}
 		}
 
 		if status != tlog.BlockStatusFlushOK {
@@ -414,8 +474,13 @@ func (vd *vdisk) runFlusher() {
 		if len(seqs) > 0 {
 			lastSeqFlushed = seqs[len(seqs)-1]
 		}
-		// send aggregation to slave syncer
-		vd.sendAggToSlaveSync(rawAgg)
+		// send aggregation to slave syncer,
+		// unless it only contains markers, which have nothing to sync
+		if len(seqs) > 0 {
+			vd.sendAggToSlaveSync(rawAgg)
+		}
+		// send aggregation to all subscribers
+		vd.subscribers.publish(vd.id, rawAgg)
 	}
 }
 
@@ -72,6 +72,14 @@ func (vd *vdisk) handle(conn *net.TCPConn, br *bufio.Reader, respSegmentBufLen i
 		case schema.TlogClientMessage_Which_forceFlushAtSeq:
 			err = vd.handleForceFlushAtSeq(cmd.ForceFlushAtSeq())
 
+		case schema.TlogClientMessage_Which_marker:
+			marker, mErr := cmd.Marker()
+			if mErr != nil {
+				err = mErr
+			} else {
+				err = vd.handleMarker(marker)
+			}
+
 		case schema.TlogClientMessage_Which_waitNBDSlaveSync:
 			err = vd.handleWaitNBDSlaveSync()
 		case schema.TlogClientMessage_Which_disconnect:
@@ -144,10 +152,19 @@ func (vd *vdisk) handleBlock(block *schema.TlogBlock) error {
 		return nil
 	}
 
-	vd.expectedSequence++
+	// store, unless the max amount of blocks is already buffered,
+	// in which case the client has to resend this block later
+	select {
+	case vd.orderedBlockChan <- block:
+	default:
+		vd.respChan <- &BlockResponse{
+			Status:    tlog.BlockStatusRecvBusy.Int8(),
+			Sequences: []uint64{block.Sequence()},
+		}
+		return nil
+	}
 
-	// store
-	vd.orderedBlockChan <- block
+	vd.expectedSequence++
 	vd.respChan <- &BlockResponse{
 		Status:    tlog.BlockStatusRecvOK.Int8(),
 		Sequences: []uint64{block.Sequence()},
@@ -163,6 +180,25 @@ func (vd *vdisk) handleForceFlushAtSeq(sequence uint64) error {
 	return nil
 }
 
+func (vd *vdisk) handleMarker(marker schema.TlogMarker) error {
+	m, err := tlog.MarkerFromSchema(marker)
+	if err != nil {
+		return err
+	}
+	if m.Name == "" {
+		return errors.New("tlog marker requires a name")
+	}
+	log.Debugf("vdisk `%v` received marker %q at sequence %d", vd.id, m.Name, m.Sequence)
+
+	// a marker is force flushed at its sequence,
+	// and is thus acknowledged as such
+	vd.insertMarker(m)
+	vd.respChan <- &BlockResponse{
+		Status: tlog.BlockStatusForceFlushReceived.Int8(),
+	}
+	return nil
+}
+
 func (vd *vdisk) handleWaitNBDSlaveSync() error {
 	vd.waitSlaveSync()
 	log.Debugf("sending BlockStatusWaitNbdSlaveSyncReceived to vdisk: %v", vd.id)
@@ -15,6 +15,7 @@ type vdiskManager struct {
 	lock         sync.Mutex
 	configSource config.Source
 	slaveSyncMgr tlog.SlaveSyncerManager
+	subscribers  *subscriberHub
 }
 
 func newVdiskManager(slaveSyncMgr tlog.SlaveSyncerManager, flushSize int, configSource config.Source) *vdiskManager {
@@ -22,6 +23,7 @@ func newVdiskManager(slaveSyncMgr tlog.SlaveSyncerManager, flushSize int, config
 		slaveSyncMgr: slaveSyncMgr,
 		vdisks:       map[string]*vdisk{},
 		configSource: configSource,
+		subscribers:  newSubscriberHub(),
 	}
 }
 
@@ -39,7 +41,7 @@ func (vt *vdiskManager) Get(ctx context.Context, vdiskID string,
 	}
 
 	// create vdisk
-	vd, err = newVdisk(ctx, vdiskID, vt.slaveSyncMgr, vt.configSource,
+	vd, err = newVdisk(ctx, vdiskID, vt.slaveSyncMgr, vt.subscribers, vt.configSource,
 		flusherConf, vt.remove, coordConnectAddr)
 	if err != nil {
 		return
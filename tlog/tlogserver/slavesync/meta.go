@@ -3,22 +3,39 @@ package slavesync
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/tlog/stor"
 )
 
-func (ss *slaveSyncer) getLastSyncedSeq() (uint64, error) {
-	b, err := ss.metaCli.GetMeta(ss.lastSeqSyncedKey)
+// LoadLastSyncedSequence loads the last tlog sequence
+// which was synced to the slave cluster of the given vdisk.
+// 0 is returned in case no sequence was synced yet.
+func LoadLastSyncedSequence(configSource config.Source, vdiskID string) (uint64, error) {
+	storConf, err := stor.ConfigFromConfigSource(configSource, vdiskID, "")
 	if err != nil {
 		return 0, err
 	}
 
-	if len(b) == 0 {
-		return 0, nil
+	metaCli, err := stor.NewMetaClient(storConf.MetaShards)
+	if err != nil {
+		return 0, err
 	}
+	defer metaCli.Close()
 
-	var lastSeq uint64
+	b, err := metaCli.GetMeta(lastSeqSyncedKey(vdiskID))
+	if err != nil {
+		return 0, err
+	}
+	return decodeLastSyncedSeq(b)
+}
 
-	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&lastSeq)
-	return lastSeq, err
+func (ss *slaveSyncer) getLastSyncedSeq() (uint64, error) {
+	b, err := ss.metaCli.GetMeta(ss.lastSeqSyncedKey)
+	if err != nil {
+		return 0, err
+	}
+	return decodeLastSyncedSeq(b)
 }
 
 func (ss *slaveSyncer) setLastSyncedSeq(seq uint64) error {
@@ -29,3 +46,20 @@ func (ss *slaveSyncer) setLastSyncedSeq(seq uint64) error {
 	}
 	return ss.metaCli.SaveMeta(ss.lastSeqSyncedKey, buf.Bytes())
 }
+
+func decodeLastSyncedSeq(b []byte) (uint64, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var lastSeq uint64
+
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&lastSeq)
+	return lastSeq, err
+}
+
+// lastSeqSyncedKey returns the (metadata) key
+// of the last sequence synced for the given vdisk.
+func lastSeqSyncedKey(vdiskID string) []byte {
+	return []byte("tlog:last_slave_sync_seq:" + vdiskID)
+}
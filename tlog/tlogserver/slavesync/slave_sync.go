@@ -90,7 +90,7 @@ func newSlaveSyncer(ctx context.Context, configSource config.Source, vdiskID, pr
 		aggCh:            make(chan []byte, 1000),
 		cmdCh:            make(chan command, 1),
 		lastSyncedCh:     make(chan syncResult),
-		lastSeqSyncedKey: []byte("tlog:last_slave_sync_seq:" + vdiskID),
+		lastSeqSyncedKey: lastSeqSyncedKey(vdiskID),
 	}
 	return ss, ss.init()
 }
@@ -308,7 +308,10 @@ func (ss *slaveSyncer) run() {
 				needToExit = true
 				return
 			}
-			ss.lastSyncedSeq = seq
+			// an aggregation which only contains markers doesn't replay any sequence
+			if seq > ss.lastSyncedSeq {
+				ss.lastSyncedSeq = seq
+			}
 
 			finishWaitForSync(nil)
 
@@ -0,0 +1,217 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+	tlogserver "github.com/zero-os/0-Disk/tlog/tlogserver/server"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// ImportImageCmd represents the image import subcommand
+var ImportImageCmd = &cobra.Command{
+	Use:   "image vdiskid file",
+	Short: "import a vdisk from a raw or qcow2 image",
+	RunE:  importImage,
+}
+
+// ExportImageCmd represents the image export subcommand
+var ExportImageCmd = &cobra.Command{
+	Use:   "image vdiskid file",
+	Short: "export a vdisk to a raw or qcow2 image",
+	RunE:  exportImage,
+}
+
+// image only configuration
+// see `init` for more information
+// about the meaning of each config property.
+var imageCmdCfg struct {
+	Path   string
+	Format backup.ImageFormat
+}
+
+func importImage(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse the position arguments
+	err := parseImagePosArguments(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	err = checkVdiskExists(vdiskCmdCfg.VdiskID, configSource)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log.Infof("importing %s image %s", imageCmdCfg.Format.String(), imageCmdCfg.Path)
+	err = backup.ImportImage(ctx, backup.ImageConfig{
+		VdiskID:      vdiskCmdCfg.VdiskID,
+		Path:         imageCmdCfg.Path,
+		Format:       imageCmdCfg.Format,
+		ConfigSource: configSource,
+		JobCount:     vdiskCmdCfg.JobCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	return generateTlogData(ctx, vdiskCmdCfg.VdiskID, configSource)
+}
+
+func exportImage(cmd *cobra.Command, args []string) error {
+	logLevel := log.ErrorLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse the position arguments
+	err := parseImagePosArguments(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	// only overwrite an existing image when forced to
+	if !vdiskCmdCfg.Force {
+		_, err = os.Stat(imageCmdCfg.Path)
+		if err == nil {
+			return errors.Newf("cannot export to image %s as it already exists", imageCmdCfg.Path)
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return backup.ExportImage(ctx, backup.ImageConfig{
+		VdiskID:      vdiskCmdCfg.VdiskID,
+		Path:         imageCmdCfg.Path,
+		Format:       imageCmdCfg.Format,
+		ConfigSource: configSource,
+		JobCount:     vdiskCmdCfg.JobCount,
+	})
+}
+
+func parseImagePosArguments(cmd *cobra.Command, args []string) error {
+	// validate pos arg length
+	argn := len(args)
+	if argn < 2 {
+		return errors.New("not enough arguments")
+	} else if argn > 2 {
+		return errors.New("too many arguments")
+	}
+
+	vdiskCmdCfg.VdiskID = args[0]
+	imageCmdCfg.Path = args[1]
+
+	// infer the image format from the file extension, if not explicitly given
+	if !cmd.Flags().Changed("format") {
+		switch strings.ToLower(filepath.Ext(imageCmdCfg.Path)) {
+		case ".qcow2", ".qcow":
+			imageCmdCfg.Format = backup.QCOW2Image
+		default:
+			imageCmdCfg.Format = backup.RawImage
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	ImportImageCmd.Long = ImportImageCmd.Short + `
+
+The image is read directly from the given file,
+and its blocks are written directly into the block storage of the vdisk.
+Blocks which only contain zeroes are not stored.
+Tlog data will be generated if the vdisk has configured tlog cluster.
+
+The format of the image is inferred from its file extension
+(.qcow2 and .qcow for qcow2, raw otherwise),
+unless it is explicitly specified using the --format flag.
+Only unencrypted qcow2 images, without a backing file
+and without compressed clusters, are supported.
+
+  If an error occured during the import process,
+blocks might already have been written to the block storage.
+These blocks won't be deleted in case of an error,
+so note that you might end up with some "garbage" in such a scenario.
+Deleting the vdisk in such a scenario will help with this problem.
+`
+	ExportImageCmd.Long = ExportImageCmd.Short + `
+
+The blocks of the vdisk are read directly from its block storage,
+and written directly into the given file.
+Blocks which aren't stored by the vdisk remain holes in the image,
+meaning that raw images are written as sparse files,
+and no clusters are allocated for them in qcow2 images.
+
+The format of the image is inferred from its file extension
+(.qcow2 and .qcow for qcow2, raw otherwise),
+unless it is explicitly specified using the --format flag.
+`
+
+	for _, cmd := range []*cobra.Command{ImportImageCmd, ExportImageCmd} {
+		cmd.Flags().Var(
+			&vdiskCmdCfg.SourceConfig, "config",
+			"config resource: dialstrings (etcd cluster) or path (yaml file)")
+		cmd.Flags().Var(
+			&imageCmdCfg.Format, "format",
+			"the format of the image, options { raw, qcow2 } (inferred from the file extension by default)")
+		cmd.Flags().IntVarP(
+			&vdiskCmdCfg.JobCount, "jobs", "j", runtime.NumCPU(),
+			"the amount of parallel jobs to run")
+	}
+
+	ImportImageCmd.Flags().BoolVarP(
+		&vdiskCmdCfg.Force,
+		"force", "f", false,
+		"when given, delete the vdisk if it already existed")
+	ImportImageCmd.Flags().StringVar(
+		&importVdiskCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+	ImportImageCmd.Flags().IntVar(
+		&importVdiskCmdCfg.FlushSize,
+		"flush-size", tlogserver.DefaultConfig().FlushSize,
+		"number of tlog blocks in one flush")
+
+	ExportImageCmd.Flags().BoolVarP(
+		&vdiskCmdCfg.Force,
+		"force", "f", false,
+		"when given, overwrite the image if it already existed")
+}
@@ -80,29 +80,35 @@ func importVdisk(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	return generateTlogData(ctx, vdiskCmdCfg.VdiskID, configSource)
+}
+
+// generateTlogData generates the tlog data of an imported vdisk,
+// in case that vdisk has a tlog cluster configured.
+func generateTlogData(ctx context.Context, vdiskID string, configSource config.Source) error {
 	// check if this vdisk has tlog cluster
-	hasTlogCluster, err := tlog.HasTlogCluster(configSource, vdiskCmdCfg.VdiskID)
+	hasTlogCluster, err := tlog.HasTlogCluster(configSource, vdiskID)
 	if err != nil || !hasTlogCluster {
 		return err
 	}
 
 	log.Infof("generate tlog data")
 
-	vdiskNbdConf, err := config.ReadVdiskNBDConfig(configSource, vdiskCmdCfg.VdiskID)
+	vdiskNbdConf, err := config.ReadVdiskNBDConfig(configSource, vdiskID)
 	if err != nil {
-		log.Errorf("failed to read vdisk nbd config of `%v`: %v", vdiskCmdCfg.VdiskID, err)
+		log.Errorf("failed to read vdisk nbd config of `%v`: %v", vdiskID, err)
 		return err
 	}
 
 	clusterConf, err := config.ReadStorageClusterConfig(configSource, vdiskNbdConf.StorageClusterID)
 	if err != nil {
-		log.Errorf("failed to read storage cluster config of `%v`: %v", vdiskCmdCfg.VdiskID, err)
+		log.Errorf("failed to read storage cluster config of `%v`: %v", vdiskID, err)
 		return err
 	}
 
 	generator, err := copy.NewGenerator(configSource, copy.Config{
-		SourceVdiskID: vdiskCmdCfg.VdiskID,
-		TargetVdiskID: vdiskCmdCfg.VdiskID,
+		SourceVdiskID: vdiskID,
+		TargetVdiskID: vdiskID,
 		FlushSize:     importVdiskCmdCfg.FlushSize,
 		PrivKey:       importVdiskCmdCfg.TlogPrivKey,
 		JobCount:      vdiskCmdCfg.JobCount,
@@ -122,7 +128,7 @@ func importVdisk(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	return storage.StoreTlogMetadata(vdiskCmdCfg.VdiskID, cluster, tlogMetadata)
+	return storage.StoreTlogMetadata(vdiskID, cluster, tlogMetadata)
 }
 
 // checkVdiskExists checks if the vdisk in question already/still exists,
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/clonevdisk"
+)
+
+// CloneCmd represents the clone subcommand
+var CloneCmd = &cobra.Command{
+	Use:   "clone",
+	Short: "Clone a zero-os resource",
+}
+
+func init() {
+	CloneCmd.AddCommand(
+		clonevdisk.VdiskCmd,
+	)
+}
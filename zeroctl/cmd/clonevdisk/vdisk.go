@@ -0,0 +1,194 @@
+package clonevdisk
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	tlogcopy "github.com/zero-os/0-Disk/tlog/copy"
+	tlogserver "github.com/zero-os/0-Disk/tlog/tlogserver/server"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var vdiskCmdCfg struct {
+	SourceConfig config.SourceConfig
+	TlogPrivKey  string
+	FlushSize    int
+	JobCount     int
+	Force        bool
+}
+
+// VdiskCmd represents the vdisk clone subcommand
+var VdiskCmd = &cobra.Command{
+	Use:   "vdisk parent_vdiskid clone_vdiskid",
+	Short: "Clone a vdisk",
+	RunE:  cloneVdisk,
+}
+
+func cloneVdisk(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	log.Debug("parsing positional arguments...")
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 2 {
+		return errors.New("not enough arguments")
+	} else if argn > 2 {
+		return errors.New("too many arguments")
+	}
+
+	// store pos arguments in named variables
+	parentVdiskID, cloneVdiskID := args[0], args[1]
+
+	// try to read the configs of the parent vdisk
+	parentStaticCfg, err := config.ReadVdiskStaticConfig(configSource, parentVdiskID)
+	if err != nil {
+		return err
+	}
+	parentNBDConfig, err := config.ReadVdiskNBDConfig(configSource, parentVdiskID)
+	if err != nil {
+		return err
+	}
+
+	// try to read the configs of the clone vdisk
+	cloneStaticCfg, err := config.ReadVdiskStaticConfig(configSource, cloneVdiskID)
+	if err != nil {
+		return err
+	}
+	cloneNBDConfig, err := config.ReadVdiskNBDConfig(configSource, cloneVdiskID)
+	if err != nil {
+		return err
+	}
+
+	// a clone reads through to its parent, and thus has to be stored in the same cluster
+	if parentNBDConfig.StorageClusterID != cloneNBDConfig.StorageClusterID {
+		return errors.Newf(
+			"cannot clone vdisk %s as vdisk %s is stored in a different cluster (%s != %s)",
+			parentVdiskID, cloneVdiskID,
+			parentNBDConfig.StorageClusterID, cloneNBDConfig.StorageClusterID)
+	}
+	if !parentStaticCfg.ReadOnly {
+		if !vdiskCmdCfg.Force {
+			return errors.Newf(
+				"cannot clone vdisk %s as it isn't configured as read-only, "+
+					"use --force to clone it anyhow", parentVdiskID)
+		}
+		log.Errorf(
+			"parent vdisk %s isn't configured as read-only, "+
+				"any modifications to it will corrupt clone %s",
+			parentVdiskID, cloneVdiskID)
+	}
+
+	clusterConfig, err := config.ReadStorageClusterConfig(configSource, parentNBDConfig.StorageClusterID)
+	if err != nil {
+		return err
+	}
+	cluster, err := ardb.NewCluster(*clusterConfig, nil)
+	if err != nil {
+		return err
+	}
+
+	// ensure the clone doesn't exist yet
+	exists, err := storage.VdiskExistsInCluster(cloneVdiskID, cloneStaticCfg.Type, cluster)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't check if vdisk %s already exists", cloneVdiskID)
+	}
+	if exists {
+		return errors.Newf("cannot clone to vdisk %s as it already exists", cloneVdiskID)
+	}
+
+	// 1. link the clone to its parent
+
+	parentConfig := storage.CopyVdiskConfig{
+		VdiskID:   parentVdiskID,
+		Type:      parentStaticCfg.Type,
+		BlockSize: int64(parentStaticCfg.BlockSize),
+	}
+	cloneConfig := storage.CopyVdiskConfig{
+		VdiskID:   cloneVdiskID,
+		Type:      cloneStaticCfg.Type,
+		BlockSize: int64(cloneStaticCfg.BlockSize),
+	}
+
+	err = storage.CloneVdisk(parentConfig, cloneConfig, cluster)
+	if err != nil || !cloneStaticCfg.Type.TlogSupport() {
+		return err // return early if an error occured, or if clone has no tlog support
+	}
+
+	// 2. copy the tlog data if it is needed
+
+	err = tlogcopy.Copy(context.Background(), configSource, tlogcopy.Config{
+		SourceVdiskID: parentVdiskID,
+		TargetVdiskID: cloneVdiskID,
+		PrivKey:       vdiskCmdCfg.TlogPrivKey,
+		FlushSize:     vdiskCmdCfg.FlushSize,
+		JobCount:      vdiskCmdCfg.JobCount,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy/generate tlog data for vdisk `%v`: %v", cloneVdiskID, err)
+	}
+
+	return nil
+}
+
+func init() {
+	VdiskCmd.Long = `Clone a parent vdisk as a new clone vdisk, both configured in the config.
+
+A clone is created instantly, as no data is copied.
+Instead the clone reads through to its parent,
+until a block is written to the clone, at which point it is copied up.
+
+The parent vdisk cannot be deleted as long as it has clones,
+and should no longer be modified, as these modifications
+would otherwise corrupt its clones.
+The parent vdisk therefore has to be configured as read-only,
+unless the '--force' flag is given.
+
+NOTE: the storage types and block sizes of parent and clone vdisk
+  need to be equal, and they need to be stored in the same storage cluster,
+  else an error is returned. Semi-deduped vdisks cannot be cloned.
+`
+
+	VdiskCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+
+	VdiskCmd.Flags().StringVar(
+		&vdiskCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+
+	VdiskCmd.Flags().IntVarP(
+		&vdiskCmdCfg.JobCount,
+		"jobs", "j", runtime.NumCPU(),
+		"the amount of parallel jobs to run the tlog generator")
+
+	VdiskCmd.Flags().IntVar(
+		&vdiskCmdCfg.FlushSize,
+		"flush-size", tlogserver.DefaultConfig().FlushSize,
+		"number of tlog blocks in one flush")
+
+	VdiskCmd.Flags().BoolVarP(
+		&vdiskCmdCfg.Force,
+		"force", "f", false,
+		"when given, clone the parent vdisk even if it isn't configured as read-only")
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/configsource"
+)
+
+// ConfigCmd represents the config subcommand
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get, set, validate or diff a zero-os config",
+}
+
+func init() {
+	ConfigCmd.AddCommand(
+		configsource.GetCmd,
+		configsource.SetCmd,
+		configsource.ValidateCmd,
+		configsource.DiffCmd,
+	)
+}
@@ -0,0 +1,84 @@
+package configsource
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// all config kinds supported by the config subcommands,
+// mapped to the config key type they represent
+var configKinds = map[string]config.KeyType{
+	"vdisk":            config.KeyVdiskStatic,
+	"vdisk-nbd":        config.KeyVdiskNBD,
+	"vdisk-tlog":       config.KeyVdiskTlog,
+	"storage-cluster":  config.KeyClusterStorage,
+	"zerostor-cluster": config.KeyClusterZeroStor,
+	"tlog-cluster":     config.KeyClusterTlog,
+	"nbdserver-vdisks": config.KeyNBDServerVdisks,
+}
+
+// kindsDescription lists all supported config kinds,
+// used in the long description of the config subcommands.
+func kindsDescription() string {
+	kinds := make([]string, 0, len(configKinds))
+	for kind := range configKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return "Supported config kinds: " + strings.Join(kinds, ", ") + "."
+}
+
+// parseKeyType returns the config key type of a given config kind.
+func parseKeyType(kind string) (config.KeyType, error) {
+	keyType, ok := configKinds[strings.ToLower(kind)]
+	if !ok {
+		return 0, errors.Newf("%q is not a supported config kind", kind)
+	}
+	return keyType, nil
+}
+
+// setLogLevel sets the log level, depending on the verbose flag.
+func setLogLevel() {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+}
+
+// readSourceConfig reads the raw config of a given key from a given source,
+// returning nil in case no config exists yet for that key.
+func readSourceConfig(source config.Source, key config.Key) ([]byte, error) {
+	data, err := source.Get(key)
+	if err != nil {
+		if errors.Cause(err) == config.ErrConfigUnavailable {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// readFileConfig reads the raw config from a given path,
+// or from the STDIN in case the path equals "-".
+func readFileConfig(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// addSourceFlag adds the config source flag to a given command.
+func addSourceFlag(cmd *cobra.Command, cfg *config.SourceConfig) {
+	cmd.Flags().Var(
+		cfg, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+}
@@ -0,0 +1,113 @@
+package configsource
+
+import (
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var diffCmdCfg struct {
+	SourceConfig config.SourceConfig
+}
+
+// DiffCmd represents the config diff subcommand
+var DiffCmd = &cobra.Command{
+	Use:   "diff kind id [path|-]",
+	Short: "Show the differences between a config stored in the config source and a file",
+	RunE:  diffConfig,
+}
+
+func diffConfig(cmd *cobra.Command, args []string) error {
+	setLogLevel()
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 2 {
+		return errors.New("not enough arguments")
+	} else if argn > 3 {
+		return errors.New("too many arguments")
+	}
+	keyType, err := parseKeyType(args[0])
+	if err != nil {
+		return err
+	}
+	key := config.Key{ID: args[1], Type: keyType}
+	path := "-"
+	if argn == 3 {
+		path = args[2]
+	}
+
+	// read the new config
+	data, err := readFileConfig(path)
+	if err != nil {
+		return err
+	}
+	newConfig, err := normalizeConfig(keyType, data)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s config in %s", args[0], path)
+	}
+
+	// read the stored config
+	configSource, err := config.NewSource(diffCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer configSource.Close()
+	data, err = readSourceConfig(configSource, key)
+	if err != nil {
+		return err
+	}
+	var oldConfig string
+	if data != nil {
+		oldConfig, err = normalizeConfig(keyType, data)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s config stored for %s", args[0], key.ID)
+		}
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(oldConfig),
+		B:        difflib.SplitLines(newConfig),
+		FromFile: fmt.Sprintf("%s (%s)", key.ID, configSource.Type()),
+		ToFile:   path,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+// normalizeConfig parses and reserializes a given config,
+// such that formatting differences aren't part of the diff.
+func normalizeConfig(keyType config.KeyType, data []byte) (string, error) {
+	cfg, err := config.ParseConfig(keyType, data)
+	if err != nil {
+		return "", err
+	}
+	data, err = yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func init() {
+	DiffCmd.Long = DiffCmd.Short + `
+
+The differences are printed in the unified diff format,
+nothing is printed if both configs are equal.
+The config is read in YAML format from the given file,
+or from the STDIN in case no path (or "-") is given.
+Both configs are validated and normalized prior to comparing them,
+such that formatting differences are ignored.
+
+` + kindsDescription()
+
+	addSourceFlag(DiffCmd, &diffCmdCfg.SourceConfig)
+}
@@ -0,0 +1,65 @@
+package configsource
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+)
+
+var getCmdCfg struct {
+	SourceConfig config.SourceConfig
+}
+
+// GetCmd represents the config get subcommand
+var GetCmd = &cobra.Command{
+	Use:   "get kind id",
+	Short: "Print a config stored in the config source",
+	RunE:  getConfig,
+}
+
+func getConfig(cmd *cobra.Command, args []string) error {
+	setLogLevel()
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 2 {
+		return errors.New("not enough arguments")
+	} else if argn > 2 {
+		return errors.New("too many arguments")
+	}
+	keyType, err := parseKeyType(args[0])
+	if err != nil {
+		return err
+	}
+	key := config.Key{ID: args[1], Type: keyType}
+
+	// create config source
+	configSource, err := config.NewSource(getCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer configSource.Close()
+
+	data, err := readSourceConfig(configSource, key)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return errors.Newf("no %s config found for %s", args[0], key.ID)
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func init() {
+	GetCmd.Long = GetCmd.Short + `
+
+The config is printed in YAML format, exactly as stored in the config source.
+
+` + kindsDescription()
+
+	addSourceFlag(GetCmd, &getCmdCfg.SourceConfig)
+}
@@ -0,0 +1,103 @@
+package configsource
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var setCmdCfg struct {
+	SourceConfig config.SourceConfig
+	Create       bool
+}
+
+// SetCmd represents the config set subcommand
+var SetCmd = &cobra.Command{
+	Use:   "set kind id [path|-]",
+	Short: "Store a config in the config source",
+	RunE:  setConfig,
+}
+
+func setConfig(cmd *cobra.Command, args []string) error {
+	setLogLevel()
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 2 {
+		return errors.New("not enough arguments")
+	} else if argn > 3 {
+		return errors.New("too many arguments")
+	}
+	keyType, err := parseKeyType(args[0])
+	if err != nil {
+		return err
+	}
+	key := config.Key{ID: args[1], Type: keyType}
+	path := "-"
+	if argn == 3 {
+		path = args[2]
+	}
+
+	// read and validate the new config
+	data, err := readFileConfig(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.ParseConfig(keyType, data)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s config", args[0])
+	}
+	data, err = yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	// create config source
+	configSource, err := config.NewSource(setCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer configSource.Close()
+	writer, ok := configSource.(config.SourceWriter)
+	if !ok {
+		return errors.Wrapf(config.ErrSourceReadOnly,
+			"can't set config using a %s config source", configSource.Type())
+	}
+
+	// only swap the config if it wasn't modified since we read it
+	old, err := readSourceConfig(configSource, key)
+	if err != nil {
+		return err
+	}
+	if old != nil && setCmdCfg.Create {
+		return errors.Newf("%s config already exists for %s", args[0], key.ID)
+	}
+	err = writer.CompareAndSwap(key, old, data)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't set %s config for %s", args[0], key.ID)
+	}
+
+	log.Infof("stored %s config for %s", args[0], key.ID)
+	return nil
+}
+
+func init() {
+	SetCmd.Long = SetCmd.Short + `
+
+The config is read in YAML format from the given file,
+or from the STDIN in case no path (or "-") is given.
+The config is validated prior to storing it,
+and is only stored in case it wasn't modified by anyone else in the meantime.
+
+When using a file config source, all comments and custom formatting
+of the config file are lost when storing a config.
+
+` + kindsDescription()
+
+	addSourceFlag(SetCmd, &setCmdCfg.SourceConfig)
+	SetCmd.Flags().BoolVar(
+		&setCmdCfg.Create, "create", false,
+		"only store the config if it doesn't exist yet")
+}
@@ -0,0 +1,104 @@
+package configsource
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+)
+
+var validateCmdCfg struct {
+	SourceConfig config.SourceConfig
+	Path         string
+}
+
+// ValidateCmd represents the config validate subcommand
+var ValidateCmd = &cobra.Command{
+	Use:   "validate kind (id|--file path)",
+	Short: "Validate a config stored in the config source or a file",
+	RunE:  validateConfig,
+}
+
+func validateConfig(cmd *cobra.Command, args []string) error {
+	setLogLevel()
+
+	// validate pos arg length
+	expectedArgn := 2
+	if validateCmdCfg.Path != "" {
+		expectedArgn = 1
+	}
+	argn := len(args)
+	if argn < expectedArgn {
+		return errors.New("not enough arguments")
+	} else if argn > expectedArgn {
+		return errors.New("too many arguments")
+	}
+	keyType, err := parseKeyType(args[0])
+	if err != nil {
+		return err
+	}
+
+	// validate a config file
+	if validateCmdCfg.Path != "" {
+		data, err := readFileConfig(validateCmdCfg.Path)
+		if err != nil {
+			return err
+		}
+		_, err = config.ParseConfig(keyType, data)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s config", args[0])
+		}
+		log.Infof("%s config in %s is valid", args[0], validateCmdCfg.Path)
+		return nil
+	}
+
+	// validate a config stored in the config source
+	key := config.Key{ID: args[1], Type: keyType}
+	configSource, err := config.NewSource(validateCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer configSource.Close()
+
+	data, err := readSourceConfig(configSource, key)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return errors.Newf("no %s config found for %s", args[0], key.ID)
+	}
+	_, err = config.ParseConfig(keyType, data)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s config for %s", args[0], key.ID)
+	}
+
+	// also validate the clusters referenced by a vdisk config
+	switch keyType {
+	case config.KeyVdiskNBD:
+		_, err = config.ReadNBDStorageConfig(configSource, key.ID)
+	case config.KeyVdiskTlog:
+		_, err = config.ReadTlogStorageConfig(configSource, key.ID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "invalid cluster referenced by %s config for %s", args[0], key.ID)
+	}
+
+	log.Infof("%s config for %s is valid", args[0], key.ID)
+	return nil
+}
+
+func init() {
+	ValidateCmd.Long = ValidateCmd.Short + `
+
+The config is validated in the same way as it is
+when it is read by the nbdserver or tlogserver.
+For the nbd and tlog vdisk configs stored in the config source,
+the storage clusters they reference are validated as well.
+
+` + kindsDescription()
+
+	addSourceFlag(ValidateCmd, &validateCmdCfg.SourceConfig)
+	ValidateCmd.Flags().StringVar(
+		&validateCmdCfg.Path, "file", "",
+		"validate the config in the given file (or STDIN if '-') instead")
+}
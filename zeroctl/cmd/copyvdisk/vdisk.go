@@ -10,7 +10,9 @@ import (
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
 	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
 	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog"
 	tlogcopy "github.com/zero-os/0-Disk/tlog/copy"
 	tlogdelete "github.com/zero-os/0-Disk/tlog/delete"
 	tlogserver "github.com/zero-os/0-Disk/tlog/tlogserver/server"
@@ -80,6 +82,18 @@ func copyVdisk(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	// content of the source vdisk might only be available in its template cluster
+	var sourceTemplateCluster ardb.StorageCluster
+	if srcStaticCfg.Type.TemplateSupport() && srcNBDConfig.TemplateStorageClusterID != "" {
+		templateClusterConfig, err := config.ReadStorageClusterConfig(configSource, srcNBDConfig.TemplateStorageClusterID)
+		if err != nil {
+			return err
+		}
+		sourceTemplateCluster, err = ardb.NewCluster(*templateClusterConfig, nil)
+		if err != nil {
+			return err
+		}
+	}
 
 	// try to read the configs of target vdisk
 	dstStaticConfig, err := config.ReadVdiskStaticConfig(configSource, targetVdiskID)
@@ -126,7 +140,13 @@ func copyVdisk(cmd *cobra.Command, args []string) error {
 		BlockSize: int64(dstStaticConfig.BlockSize),
 	}
 
-	err = storage.CopyVdisk(sourceConfig, targetConfig, sourceCluster, targetCluster)
+	if backup.ConversionRequired(sourceConfig, targetConfig) {
+		return convertVdisk(
+			sourceConfig, targetConfig,
+			sourceCluster, sourceTemplateCluster, targetCluster, configSource)
+	}
+
+	err = storage.CopyVdisk(sourceConfig, targetConfig, sourceCluster, sourceTemplateCluster, targetCluster)
 	if err != nil || !dstStaticConfig.Type.TlogSupport() {
 		return err // return early if an error occured, or if dst no tlog support
 	}
@@ -147,6 +167,52 @@ func copyVdisk(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// convertVdisk copies the source vdisk into a target vdisk
+// which has a different storage type and/or block size,
+// regenerating the tlog data of the target vdisk if it is needed.
+func convertVdisk(source, target storage.CopyVdiskConfig, sourceCluster, sourceTemplateCluster, targetCluster ardb.StorageCluster, cs config.Source) error {
+	ctx := context.Background()
+
+	// 1. convert the ARDB data
+
+	err := backup.ConvertVdisk(ctx, source, target, sourceCluster, sourceTemplateCluster, targetCluster)
+	if err != nil {
+		return err
+	}
+
+	// 2. generate the tlog data if it is needed,
+	//    as the tlog data of the source vdisk doesn't match the converted target vdisk
+
+	hasTlogCluster, err := tlog.HasTlogCluster(cs, target.VdiskID)
+	if err != nil || !hasTlogCluster {
+		return err
+	}
+
+	log.Infof("generate tlog data for vdisk %s", target.VdiskID)
+	generator, err := tlogcopy.NewGenerator(cs, tlogcopy.Config{
+		SourceVdiskID: target.VdiskID,
+		TargetVdiskID: target.VdiskID,
+		PrivKey:       vdiskCmdCfg.TlogPrivKey,
+		FlushSize:     vdiskCmdCfg.FlushSize,
+		JobCount:      vdiskCmdCfg.JobCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	var tlogMetadata storage.TlogMetadata
+	tlogMetadata.LastFlushedSequence, err = generator.GenerateFromStorage(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to generate tlog data for vdisk `%v`: %v", target.VdiskID, err)
+	}
+
+	// store nbd's tlog metadata
+	if targetCluster == nil {
+		targetCluster = sourceCluster
+	}
+	return storage.StoreTlogMetadata(target.VdiskID, targetCluster, tlogMetadata)
+}
+
 // checkVdiskExists checks if the vdisk in question already/still exists,
 // and if so, and the force flag is specified, delete the vdisk.
 func checkVdiskExists(id string, t config.VdiskType, cluster ardb.StorageCluster, cs config.Source) error {
@@ -189,8 +255,13 @@ NOTE: by design,
   the data will be copied the first time the vdisk spins up,
   on the condition that the templateStorageCluster has been configured.
 
-NOTE: the storage types and block sizes of source and target vdisk
-  need to be equal, else an error is returned.
+NOTE: in case the storage types and/or block sizes of source and target vdisk
+  are different, the vdisk is converted, by streaming all its blocks
+  from the source to the target vdisk, inflating or deflating them if needed.
+  This is a lot slower than a regular copy, and requires that the
+  block size of one vdisk is a multiple of the block size of the other vdisk.
+  The tlog data of a converted vdisk is regenerated (if needed),
+  rather than being copied from the source vdisk.
 `
 
 	VdiskCmd.Flags().Var(
@@ -5,6 +5,7 @@ import (
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
 	"github.com/zero-os/0-Disk/nbd/ardb/storage"
 	tlogdelete "github.com/zero-os/0-Disk/tlog/delete"
 	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
@@ -46,6 +47,11 @@ func deleteVdisk(cmd *cobra.Command, args []string) error {
 	defer cs.Close()
 	configSource := config.NewOnceSource(cs)
 
+	// delete a snapshot of a vdisk, rather than the vdisk itself
+	if parentID, snapshotID, ok := storage.ParseSnapshotVdiskID(vdiskID); ok {
+		return deleteSnapshot(parentID, snapshotID, configSource)
+	}
+
 	_, err = storage.DeleteVdisk(vdiskID, configSource)
 	if err != nil {
 		return err
@@ -62,9 +68,42 @@ func deleteVdisk(cmd *cobra.Command, args []string) error {
 	return tlogdelete.Delete(configSource, vdiskID, vdiskCmdCfg.TlogPrivKey)
 }
 
+// deleteSnapshot deletes a snapshot of a vdisk,
+// which is stored in the primary cluster of that vdisk.
+func deleteSnapshot(vdiskID, snapshotID string, configSource config.Source) error {
+	staticConfig, err := config.ReadVdiskStaticConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	nbdConfig, err := config.ReadVdiskNBDConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	clusterConfig, err := config.ReadStorageClusterConfig(configSource, nbdConfig.StorageClusterID)
+	if err != nil {
+		return err
+	}
+	cluster, err := ardb.NewCluster(*clusterConfig, nil)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := storage.DeleteSnapshot(vdiskID, snapshotID, staticConfig.Type, cluster)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.Newf("snapshot %s of vdisk %s does not exist", snapshotID, vdiskID)
+	}
+	return nil
+}
+
 func init() {
 	VdiskCmd.Long = VdiskCmd.Short + `
 
+A snapshot of a vdisk can be deleted by using 'vdiskid@snapshotid' as the identifier.
+A vdisk cannot be deleted as long as it has snapshots or clones.
+
 WARNING: until issue #88 has been resolved,
   only the metadata of deduped vdisks can be deleted by this command.
   Nondeduped vdisks have no metadata, and thus are not affected by this issue.
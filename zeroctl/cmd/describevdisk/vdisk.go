@@ -0,0 +1,242 @@
+package describevdisk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog/tlogserver/slavesync"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var vdiskCmdCfg struct {
+	SourceConfig config.SourceConfig
+	PrettyPrint  bool
+}
+
+// VdiskCmd represents the vdisk describe subcommand
+var VdiskCmd = &cobra.Command{
+	Use:   "vdisk vdiskid",
+	Short: "Describe a vdisk",
+	RunE:  describeVdisk,
+}
+
+func describeVdisk(cmd *cobra.Command, args []string) error {
+	logLevel := log.ErrorLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+	vdiskID := args[0]
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+
+	info, err := collectVdiskInfo(config.NewOnceSource(cs), vdiskID)
+	if err != nil {
+		return err
+	}
+
+	var bytes []byte
+	if vdiskCmdCfg.PrettyPrint {
+		bytes, err = json.MarshalIndent(info, "", "  \t")
+	} else {
+		bytes, err = json.Marshal(info)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(bytes))
+	return nil
+}
+
+// collectVdiskInfo collects the info of a vdisk,
+// from its configuration and from the live state of its storage.
+func collectVdiskInfo(configSource config.Source, vdiskID string) (*VdiskInfo, error) {
+	// read the configs of the vdisk
+	staticConfig, err := config.ReadVdiskStaticConfig(configSource, vdiskID)
+	if err != nil {
+		return nil, err
+	}
+	nbdConfig, err := config.ReadVdiskNBDConfig(configSource, vdiskID)
+	if err != nil {
+		return nil, err
+	}
+	clusterConfig, err := config.ReadStorageClusterConfig(configSource, nbdConfig.StorageClusterID)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := ardb.NewCluster(*clusterConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &VdiskInfo{
+		VdiskID:         vdiskID,
+		Type:            staticConfig.Type.String(),
+		BlockSize:       staticConfig.BlockSize,
+		Size:            staticConfig.Size * uint64(ardb.GibibyteAsBytes),
+		ReadOnly:        staticConfig.ReadOnly,
+		TemplateVdiskID: staticConfig.TemplateVdiskID,
+		Clusters: VdiskClustersInfo{
+			Primary:    nbdConfig.StorageClusterID,
+			Slave:      nbdConfig.SlaveStorageClusterID,
+			Template:   nbdConfig.TemplateStorageClusterID,
+			TlogServer: nbdConfig.TlogServerClusterID,
+		},
+	}
+
+	// collect the (allocated) storage usage of the vdisk
+	indices, err := storage.ListBlockIndicesInCluster(vdiskID, staticConfig.Type, cluster)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't list block indices of vdisk %s", vdiskID)
+	}
+	info.Usage.BlockCount = int64(len(indices))
+	info.Usage.Bytes = info.Usage.BlockCount * int64(staticConfig.BlockSize)
+
+	counts, err := storage.CountBlocksPerServer(vdiskID, staticConfig.Type, *clusterConfig)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		info.Usage.Servers = append(info.Usage.Servers, VdiskServerUsageInfo{
+			Address:    count.Server.Address,
+			Database:   count.Server.Database,
+			State:      count.Server.State.String(),
+			BlockCount: count.BlockCount,
+		})
+	}
+
+	// collect the tlog state of the vdisk, if it has any
+	if staticConfig.Type.TlogSupport() && nbdConfig.TlogServerClusterID != "" {
+		metadata, err := storage.LoadTlogMetadata(vdiskID, cluster)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't load tlog metadata of vdisk %s", vdiskID)
+		}
+		info.Tlog = &VdiskTlogInfo{LastFlushedSequence: metadata.LastFlushedSequence}
+
+		if nbdConfig.SlaveStorageClusterID != "" {
+			info.Tlog.SlaveSync = &VdiskSlaveSyncInfo{}
+			// the last synced sequence is stored in the 0-stor metadata cluster of the tlog,
+			// which might not be configured or reachable, this is reported rather than fatal
+			lastSyncedSeq, err := slavesync.LoadLastSyncedSequence(configSource, vdiskID)
+			if err != nil {
+				log.Errorf("couldn't load last slave-synced sequence of vdisk %s: %v", vdiskID, err)
+				info.Tlog.SlaveSync.Error = err.Error()
+			} else {
+				var lag uint64
+				if metadata.LastFlushedSequence > lastSyncedSeq {
+					lag = metadata.LastFlushedSequence - lastSyncedSeq
+				}
+				info.Tlog.SlaveSync.LastSyncedSequence = &lastSyncedSeq
+				info.Tlog.SlaveSync.Lag = &lag
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// VdiskInfo describes a vdisk,
+// combining its configuration with its live storage state.
+type VdiskInfo struct {
+	VdiskID         string            `json:"vdiskID"`
+	Type            string            `json:"type"`
+	BlockSize       uint64            `json:"blockSize"`
+	Size            uint64            `json:"size"`
+	ReadOnly        bool              `json:"readOnly"`
+	TemplateVdiskID string            `json:"templateVdiskID,omitempty"`
+	Clusters        VdiskClustersInfo `json:"clusters"`
+	Usage           VdiskUsageInfo    `json:"usage"`
+	Tlog            *VdiskTlogInfo    `json:"tlog,omitempty"`
+}
+
+// VdiskClustersInfo describes the clusters a vdisk is placed on.
+type VdiskClustersInfo struct {
+	Primary    string `json:"primary"`
+	Slave      string `json:"slave,omitempty"`
+	Template   string `json:"template,omitempty"`
+	TlogServer string `json:"tlogServer,omitempty"`
+}
+
+// VdiskUsageInfo describes the storage allocated by a vdisk.
+type VdiskUsageInfo struct {
+	BlockCount int64                  `json:"blockCount"`
+	Bytes      int64                  `json:"bytes"`
+	Servers    []VdiskServerUsageInfo `json:"servers"`
+}
+
+// VdiskServerUsageInfo describes the blocks
+// a vdisk stores on a single primary storage server.
+type VdiskServerUsageInfo struct {
+	Address    string `json:"address"`
+	Database   int    `json:"db"`
+	State      string `json:"state"`
+	BlockCount int64  `json:"blockCount"`
+}
+
+// VdiskTlogInfo describes the tlog state of a vdisk.
+type VdiskTlogInfo struct {
+	LastFlushedSequence uint64              `json:"lastFlushedSequence"`
+	SlaveSync           *VdiskSlaveSyncInfo `json:"slaveSync,omitempty"`
+}
+
+// VdiskSlaveSyncInfo describes the state of
+// the tlog-based synchronization to the slave cluster of a vdisk.
+// The last synced sequence (and lag) is nil in case it is unavailable,
+// in which case the error explains why.
+type VdiskSlaveSyncInfo struct {
+	LastSyncedSequence *uint64 `json:"lastSyncedSequence"`
+	Lag                *uint64 `json:"lag"`
+	Error              string  `json:"error,omitempty"`
+}
+
+func init() {
+	VdiskCmd.Long = VdiskCmd.Short + `
+
+A vdisk will be described in JSON format and written to the STDOUT.
+The printed JSON object can have following properties:
+
++ "vdiskID": the identifier of the vdisk;
++ "type": the type of the vdisk;
++ "blockSize": the size (in bytes) of each block of the vdisk;
++ "size": the (configured) size (in bytes) of the vdisk;
++ "readOnly": true if the vdisk is configured as read-only;
++ "templateVdiskID": the identifier of the template vdisk, if it has one;
++ "clusters": the IDs of the primary, slave, template and tlog clusters of the vdisk;
++ "usage": the amount of blocks (and bytes) allocated by the vdisk,
+  as well as the amount of blocks stored on each server of the primary cluster;
++ "tlog": the last sequence flushed to the primary cluster,
+  and the last sequence synced to the slave cluster (and the lag in between), if any,
+  which are null (with an "error" explaining why) in case they are unavailable;
+
+The allocated blocks of a cloned vdisk include the blocks read through from its parent(s),
+the blocks per server only include the blocks stored by the vdisk itself.
+For deduped vdisks the blocks per server are the blocks whose metadata is stored on that server.
+`
+
+	VdiskCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	VdiskCmd.Flags().BoolVar(
+		&vdiskCmdCfg.PrettyPrint, "pretty", false,
+		"pretty print output when this flag is specified")
+}
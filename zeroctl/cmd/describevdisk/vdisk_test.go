@@ -0,0 +1,81 @@
+package describevdisk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestCollectVdiskInfo(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	cluster := redisstub.NewCluster(2, false)
+	defer cluster.Close()
+	slave := redisstub.NewMemoryRedis()
+	defer slave.Close()
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeDB,
+	})
+	clusterCfg := cluster.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, "mycluster", &clusterCfg)
+
+	// write some blocks into the vdisk
+	blockStorage, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:   vdiskID,
+		VdiskType: config.VdiskTypeDB,
+		BlockSize: blockSize,
+	}, cluster, nil)
+	require.NoError(t, err)
+	for index := int64(0); index < 3; index++ {
+		content := make([]byte, blockSize)
+		content[0] = byte(index + 1)
+		require.NoError(t, blockStorage.SetBlock(index, content))
+	}
+	require.NoError(t, blockStorage.Flush())
+	require.NoError(t, blockStorage.Close())
+
+	info, err := collectVdiskInfo(source, vdiskID)
+	require.NoError(t, err)
+	assert.Equal(t, vdiskID, info.VdiskID)
+	assert.Equal(t, config.VdiskTypeDB.String(), info.Type)
+	assert.Equal(t, uint64(blockSize), info.BlockSize)
+	assert.Equal(t, "mycluster", info.Clusters.Primary)
+	assert.Equal(t, int64(3), info.Usage.BlockCount)
+	assert.Equal(t, int64(3*blockSize), info.Usage.Bytes)
+	if assert.Len(t, info.Usage.Servers, 2) {
+		assert.Equal(t, int64(3),
+			info.Usage.Servers[0].BlockCount+info.Usage.Servers[1].BlockCount)
+	}
+	assert.Nil(t, info.Tlog)
+
+	// a vdisk with a slave cluster, but without a 0-stor cluster for its tlog,
+	// is still described, its last synced sequence is reported as unavailable
+	source.SetTlogServerCluster(vdiskID, "mytlogcluster", &config.TlogClusterConfig{
+		Servers: []string{"localhost:11211"},
+	})
+	slaveCfg := config.StorageClusterConfig{
+		Servers: []config.StorageServerConfig{slave.StorageServerConfig()},
+	}
+	source.SetSlaveStorageCluster(vdiskID, "myslavecluster", &slaveCfg)
+
+	info, err = collectVdiskInfo(source, vdiskID)
+	require.NoError(t, err)
+	require.NotNil(t, info.Tlog)
+	assert.Equal(t, uint64(0), info.Tlog.LastFlushedSequence)
+	require.NotNil(t, info.Tlog.SlaveSync)
+	assert.Nil(t, info.Tlog.SlaveSync.LastSyncedSequence)
+	assert.Nil(t, info.Tlog.SlaveSync.Lag)
+	assert.NotEmpty(t, info.Tlog.SlaveSync.Error)
+}
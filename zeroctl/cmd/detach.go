@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/detachvdisk"
+)
+
+// DetachCmd represents the detach subcommand
+var DetachCmd = &cobra.Command{
+	Use:   "detach",
+	Short: "Detach a zero-os resource",
+}
+
+func init() {
+	DetachCmd.AddCommand(
+		detachvdisk.VdiskCmd,
+	)
+}
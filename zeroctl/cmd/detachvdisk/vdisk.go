@@ -0,0 +1,84 @@
+package detachvdisk
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var vdiskCmdCfg struct {
+	SourceConfig config.SourceConfig
+	Rate         int64
+	JobCount     int
+}
+
+// VdiskCmd represents the vdisk detach subcommand
+var VdiskCmd = &cobra.Command{
+	Use:   "vdisk vdiskid",
+	Short: "Detach a vdisk from its template",
+	RunE:  detachVdisk,
+}
+
+func detachVdisk(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+	vdiskID := args[0]
+
+	// create config source,
+	// which isn't cached, as the config of the vdisk is modified
+	configSource, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer configSource.Close()
+
+	_, err = storage.DetachVdisk(context.Background(), storage.DetachConfig{
+		VdiskID:      vdiskID,
+		ConfigSource: configSource,
+		Rate:         vdiskCmdCfg.Rate,
+		JobCount:     vdiskCmdCfg.JobCount,
+	})
+	return err
+}
+
+func init() {
+	VdiskCmd.Long = VdiskCmd.Short + `
+
+All content referenced by the vdisk, which isn't available yet
+in its primary storage cluster, is copied from its template storage cluster.
+Once every referenced block is available in the primary storage cluster,
+the template storage cluster and template vdisk are cleared
+from the vdisk's configuration, such that the template storage cluster
+is no longer required by the vdisk, and can be retired.
+
+The vdisk can't be detached while it is in use (mounted by an nbdserver).
+The config source is modified, and has to be an etcd cluster, a redis server or a YAML file.
+`
+
+	VdiskCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	VdiskCmd.Flags().Int64Var(
+		&vdiskCmdCfg.Rate, "rate", 0,
+		"max amount of content blocks to check per second, 0 means unlimited")
+	VdiskCmd.Flags().IntVarP(
+		&vdiskCmdCfg.JobCount, "jobs", "j", runtime.NumCPU(),
+		"the amount of parallel jobs to run")
+}
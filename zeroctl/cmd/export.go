@@ -14,5 +14,6 @@ var ExportCmd = &cobra.Command{
 func init() {
 	ExportCmd.AddCommand(
 		backup.ExportVdiskCmd,
+		backup.ExportImageCmd,
 	)
 }
@@ -3,6 +3,7 @@ package list
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/spf13/cobra"
 	zerodiskcfg "github.com/zero-os/0-Disk/config"
@@ -16,6 +17,8 @@ import (
 var vdisksCmdCfg struct {
 	SourceConfig zerodiskcfg.SourceConfig
 	NameRegexp   string
+	Lineage      bool
+	RebuildIndex bool
 }
 
 // VdisksCmd represents the list vdisk subcommand
@@ -57,6 +60,15 @@ func listVdisks(cmd *cobra.Command, args []string) error {
 		pred = regexp.MatchString
 	}
 
+	// rebuild the vdisk registry first, if requested
+	if vdisksCmdCfg.RebuildIndex {
+		log.Infof("rebuilding the vdisk registry of %s, this might take a while...", args[0])
+		_, err = storage.RebuildVdiskRegistry(cluster)
+		if err != nil {
+			return errors.Wrap(err, "couldn't rebuild the vdisk registry")
+		}
+	}
+
 	// list vdisks
 	vdiskIDs, err := storage.ListVdisks(cluster, pred)
 	if err != nil {
@@ -79,7 +91,15 @@ func listVdisks(cmd *cobra.Command, args []string) error {
 
 	// print at least 1 vdisk found from the specified storage
 	for _, vdiskID := range vdiskIDs {
-		fmt.Println(vdiskID)
+		if !vdisksCmdCfg.Lineage {
+			fmt.Println(vdiskID)
+			continue
+		}
+		lineage, err := storage.LoadVdiskLineage(vdiskID, cluster)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.Join(append([]string{vdiskID}, lineage...), " <- "))
 	}
 	return nil
 }
@@ -120,7 +140,15 @@ as wel as on a single storage server. Some examples:
   	zeroctl vdisk list localhost:2000
   	zeroctl vdisk list 127.0.0.1:16379@5
 
-WARNING: This command is very slow, and might take a while to finish!
+The vdisks are listed using the vdisk registry of the cluster,
+in which vdisks are registered when they are first mounted,
+copied, cloned or imported, and from which they are removed when deleted.
+
+Vdisks created before the registry existed (or by other tools)
+can be added to the registry using the --rebuild-index flag,
+which scans the entire cluster for vdisks prior to listing them.
+
+WARNING: Rebuilding the registry is very slow, and might take a while to finish!
   It might also decrease the performance of the ardb server
   in question, by locking the server down for each operation.
 `
@@ -132,4 +160,12 @@ WARNING: This command is very slow, and might take a while to finish!
 	VdisksCmd.Flags().StringVar(
 		&vdisksCmdCfg.NameRegexp, "name", "",
 		"list only vdisks which match the given name (supports regexp)")
+
+	VdisksCmd.Flags().BoolVar(
+		&vdisksCmdCfg.Lineage, "lineage", false,
+		"list each vdisk together with the parent vdisk(s) it was cloned from")
+
+	VdisksCmd.Flags().BoolVar(
+		&vdisksCmdCfg.RebuildIndex, "rebuild-index", false,
+		"rebuild the vdisk registry by scanning the cluster, prior to listing the vdisks (very slow)")
 }
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/promotevdisk"
+)
+
+// PromoteCmd represents the promote subcommand
+var PromoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Promote a standby zero-os resource",
+}
+
+func init() {
+	PromoteCmd.AddCommand(
+		promotevdisk.VdiskCmd,
+	)
+}
@@ -0,0 +1,78 @@
+package promotevdisk
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/replication"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var vdiskCmdCfg struct {
+	SourceConfig config.SourceConfig
+	TlogPrivKey  string
+}
+
+// VdiskCmd represents the vdisk promote subcommand
+var VdiskCmd = &cobra.Command{
+	Use:   "vdisk vdiskid",
+	Short: "Promote a standby vdisk into a writable vdisk",
+	RunE:  promoteVdisk,
+}
+
+func promoteVdisk(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+	vdiskID := args[0]
+
+	// create config source,
+	// which isn't cached, as the config of the vdisk is modified
+	configSource, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer configSource.Close()
+
+	lastSeq, err := replication.Promote(
+		context.Background(), configSource, vdiskID, vdiskCmdCfg.TlogPrivKey)
+	if err != nil {
+		return err
+	}
+	log.Infof("promoted vdisk %s with last sequence = %d", vdiskID, lastSeq)
+	return nil
+}
+
+func init() {
+	VdiskCmd.Long = VdiskCmd.Short + `
+
+All replicated sequences which weren't replayed yet,
+are replayed into the storage cluster of the standby vdisk,
+after which the vdisk is no longer configured as read-only,
+such that it can be mounted as a writable vdisk.
+
+Replication to the standby vdisk has to be stopped prior to promoting it.
+The config source is modified, and has to be an etcd cluster, a redis server or a YAML file.
+`
+
+	VdiskCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	VdiskCmd.Flags().StringVar(
+		&vdiskCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+}
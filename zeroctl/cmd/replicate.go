@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/replicatevdisk"
+)
+
+// ReplicateCmd represents the replicate subcommand
+var ReplicateCmd = &cobra.Command{
+	Use:   "replicate",
+	Short: "Replicate a zero-os resource to a standby site",
+}
+
+func init() {
+	ReplicateCmd.AddCommand(
+		replicatevdisk.VdiskCmd,
+	)
+}
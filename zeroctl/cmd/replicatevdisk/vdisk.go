@@ -0,0 +1,171 @@
+package replicatevdisk
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/replication"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var vdiskCmdCfg struct {
+	SourceConfig   config.SourceConfig
+	SourceAddress  string
+	StandbyVdiskID string
+	TlogServers    []string
+	TlogPrivKey    string
+	MaxLag         uint64
+	ReportInterval time.Duration
+}
+
+// VdiskCmd represents the vdisk replicate subcommand
+var VdiskCmd = &cobra.Command{
+	Use:   "vdisk vdiskid",
+	Short: "Replicate a vdisk to a standby vdisk on a remote site",
+	RunE:  replicateVdisk,
+}
+
+func replicateVdisk(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+	vdiskID := args[0]
+
+	if vdiskCmdCfg.SourceAddress == "" {
+		return errors.New("no source address given for the primary tlogserver")
+	}
+	standbyVdiskID := vdiskCmdCfg.StandbyVdiskID
+	if standbyVdiskID == "" {
+		standbyVdiskID = vdiskID
+	}
+
+	// create config source of the standby site
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	err = replication.CheckStandby(configSource, standbyVdiskID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// create the target, applying the replicated aggregations to the standby vdisk
+	var target replication.Target
+	if len(vdiskCmdCfg.TlogServers) > 0 {
+		target, err = replication.NewTlogServerTarget(vdiskCmdCfg.TlogServers, standbyVdiskID)
+	} else {
+		target, err = replication.NewPlayerTarget(ctx, configSource, standbyVdiskID, vdiskCmdCfg.TlogPrivKey)
+	}
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	replicator, err := replication.New(replication.Config{
+		SourceAddress: vdiskCmdCfg.SourceAddress,
+		VdiskID:       vdiskID,
+		MaxLag:        vdiskCmdCfg.MaxLag,
+	}, target)
+	if err != nil {
+		return err
+	}
+
+	// replicate until interrupted
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+	if vdiskCmdCfg.ReportInterval > 0 {
+		go reportStatus(ctx, replicator, vdiskCmdCfg.ReportInterval)
+	}
+
+	replicator.Run(ctx)
+	status := replicator.Status()
+	log.Infof("replication stopped with last acknowledged sequence = %d",
+		status.LastAckedSequence)
+	return nil
+}
+
+// report the status of the replicator on a given interval
+func reportStatus(ctx context.Context, replicator *replication.Replicator, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			status := replicator.Status()
+			log.Infof("replication status: last flushed = %d, last acknowledged = %d, lag = %d",
+				status.LastFlushedSequence, status.LastAckedSequence, status.Lag)
+		}
+	}
+}
+
+func init() {
+	VdiskCmd.Long = VdiskCmd.Short + `
+
+All aggregations flushed by the tlogserver of the primary vdisk
+are shipped to the standby site, where they are applied to the standby vdisk.
+The standby vdisk has to be configured as read-only in the config of the standby site,
+and can be turned into a writable vdisk using the promote command.
+
+By default the aggregations are stored in the tlog of the standby vdisk,
+and replayed into its storage cluster, as they arrive.
+When the tlogserver(s) of the standby site are given,
+the aggregations are sent to those tlogserver(s) instead,
+in which case the standby vdisk's storage is only updated once it is promoted.
+
+Replication continues until interrupted,
+and can be resumed at any time from the last sequence acknowledged by the standby site.
+`
+
+	VdiskCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource of the standby site: dialstrings (etcd cluster) or path (yaml file)")
+	VdiskCmd.Flags().StringVar(
+		&vdiskCmdCfg.SourceAddress, "source-address", "",
+		"address of the primary tlogserver, on which it accepts subscribers (required)")
+	VdiskCmd.Flags().StringVar(
+		&vdiskCmdCfg.StandbyVdiskID, "standby", "",
+		"id of the standby vdisk (default: the id of the primary vdisk)")
+	VdiskCmd.Flags().StringSliceVar(
+		&vdiskCmdCfg.TlogServers, "tlogserver", nil,
+		"address(es) of the tlogserver(s) of the standby site, if not given the aggregations are replayed directly")
+	VdiskCmd.Flags().StringVar(
+		&vdiskCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+	VdiskCmd.Flags().Uint64Var(
+		&vdiskCmdCfg.MaxLag, "max-lag", replication.DefaultMaxLag,
+		"amount of sequences the standby vdisk can lag behind, before it is reported as lagging")
+	VdiskCmd.Flags().DurationVar(
+		&vdiskCmdCfg.ReportInterval, "report-interval", 10*time.Second,
+		"interval in which the replication status is logged (0: never)")
+}
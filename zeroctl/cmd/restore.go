@@ -14,5 +14,6 @@ var RestoreCmd = &cobra.Command{
 func init() {
 	RestoreCmd.AddCommand(
 		restore.VdiskCmd,
+		restore.ImageCmd,
 	)
 }
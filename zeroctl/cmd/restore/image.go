@@ -0,0 +1,173 @@
+package restore
+
+import (
+	"context"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	tlogplayer "github.com/zero-os/0-Disk/tlog/tlogclient/player"
+	cmdConf "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// imageCmdCfg is the configuration used for the restore image command
+var imageCmdCfg struct {
+	SourceConfig config.SourceConfig
+	TlogPrivKey  string
+	StartTs      int64 // start timestamp
+	At           int64 // end timestamp
+	Marker       string
+	Force        bool
+	WindowSize   int
+	JobCount     int
+}
+
+// ImageCmd represents the restore image subcommand
+var ImageCmd = &cobra.Command{
+	Use:   "image id file",
+	Short: "Restore a vdisk into a local raw image file using its tlog",
+	Long: `Restore a vdisk into a local (sparse) raw image file,
+by replaying its tlog up to a given timestamp.
+
+No ARDB storage cluster is used, only the 0-stor cluster of the vdisk's tlog,
+such that the state of a vdisk at a given time can be inspected,
+for example by loop-mounting the resulting image.`,
+	RunE: restoreImage,
+}
+
+func restoreImage(cmd *cobra.Command, args []string) error {
+	// create config source
+	cs, err := config.NewSource(imageCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	// parse positional args
+	argn := len(args)
+	if argn < 2 {
+		return errors.New("not enough arguments")
+	}
+	if argn > 2 {
+		return errors.New("too many arguments")
+	}
+
+	vdiskID, path := args[0], args[1]
+
+	logLevel := log.InfoLevel
+	if cmdConf.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	staticConfig, err := config.ReadVdiskStaticConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	if !staticConfig.Type.TlogSupport() {
+		return errors.Newf("cannot restore vdisk %s as it has no tlog support", vdiskID)
+	}
+
+	err = checkImageExists(path)
+	if err != nil {
+		return err
+	}
+
+	blockStorage, err := storage.NewFileStorage(
+		vdiskID, path,
+		int64(staticConfig.BlockSize),
+		int64(staticConfig.Size)*ardb.GibibyteAsBytes)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	player, err := tlogplayer.NewPlayerWithStorage(
+		ctx, configSource, nil, blockStorage, vdiskID, imageCmdCfg.TlogPrivKey)
+	if err != nil {
+		blockStorage.Close()
+		return err
+	}
+	defer player.Close()
+
+	lmt, err := createLimiter(player, imageCmdCfg.StartTs, imageCmdCfg.At, imageCmdCfg.Marker)
+	if err != nil {
+		return err
+	}
+	log.Infof("restoring vdisk %s into image %s using %s", vdiskID, path, limiterString(lmt))
+
+	var lastSeq uint64
+	if imageCmdCfg.WindowSize > 0 {
+		lastSeq, err = player.ReplayCoalesced(lmt, tlogplayer.CoalesceConfig{
+			WindowSize: imageCmdCfg.WindowSize,
+			JobCount:   imageCmdCfg.JobCount,
+		})
+	} else {
+		lastSeq, err = player.Replay(lmt)
+	}
+	log.Infof("restore finished with last sequence = %v", lastSeq)
+	return err
+}
+
+// checkImageExists checks if the image in question already exists,
+// and if so, and the force flag is specified, delete the image.
+func checkImageExists(path string) error {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil // image doesn't exist, so nothing to do
+	}
+	if err != nil {
+		return errors.Wrapf(err, "couldn't check if image %s already exists", path)
+	}
+	if !imageCmdCfg.Force {
+		return errors.Newf("cannot restore image %s as it already exists", path)
+	}
+
+	// delete image, as it exists and `--force` is specified
+	err = os.Remove(path)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't delete image %s", path)
+	}
+	return nil
+}
+
+func init() {
+	ImageCmd.Flags().Var(
+		&imageCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	ImageCmd.Flags().StringVar(
+		&imageCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+	ImageCmd.Flags().Int64Var(
+		&imageCmdCfg.StartTs,
+		"start-timestamp", 0,
+		"start UTC timestamp in nanosecond(default 0: since beginning)")
+	ImageCmd.Flags().Int64Var(
+		&imageCmdCfg.At,
+		"at", 0,
+		"UTC timestamp in nanosecond of the vdisk state to restore(default 0: until the end)")
+	ImageCmd.Flags().StringVar(
+		&imageCmdCfg.Marker,
+		"marker", "",
+		"restore the image up to the last tlog marker with this name, can't be combined with timestamps")
+	ImageCmd.Flags().BoolVarP(
+		&imageCmdCfg.Force,
+		"force", "f", false,
+		"when given, delete the image if it already existed")
+	ImageCmd.Flags().IntVar(
+		&imageCmdCfg.WindowSize,
+		"window", 0,
+		"amount of tlog aggregations coalesced and replayed at once (0: replay all transactions one by one)")
+	ImageCmd.Flags().IntVarP(
+		&imageCmdCfg.JobCount,
+		"jobs", "j", runtime.NumCPU(),
+		"amount of parallel jobs used to replay a window of coalesced aggregations")
+}
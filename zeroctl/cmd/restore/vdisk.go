@@ -2,6 +2,8 @@ package restore
 
 import (
 	"context"
+	"fmt"
+	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/zero-os/0-Disk/config"
@@ -10,7 +12,7 @@ import (
 	"github.com/zero-os/0-Disk/nbd/ardb/storage"
 	tlogdelete "github.com/zero-os/0-Disk/tlog/delete"
 	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
-	"github.com/zero-os/0-Disk/tlog/tlogclient/player"
+	tlogplayer "github.com/zero-os/0-Disk/tlog/tlogclient/player"
 	cmdConf "github.com/zero-os/0-Disk/zeroctl/cmd/config"
 )
 
@@ -20,7 +22,10 @@ var vdiskCmdCfg struct {
 	TlogPrivKey  string
 	StartTs      int64 // start timestamp
 	EndTs        int64 // end timestamp
+	Marker       string
 	Force        bool
+	WindowSize   int
+	JobCount     int
 }
 
 // VdiskCmd represents the restore vdisk subcommand
@@ -63,18 +68,59 @@ func restoreVdisk(cmd *cobra.Command, args []string) error {
 
 	ctx := context.Background()
 
-	player, err := player.NewPlayer(ctx, configSource, vdiskID, vdiskCmdCfg.TlogPrivKey)
+	player, err := tlogplayer.NewPlayer(ctx, configSource, vdiskID, vdiskCmdCfg.TlogPrivKey)
 	if err != nil {
 		return err
 	}
 
-	log.Infof("restoring vdisk with start timestamp=%v end timestamp=%v",
-		vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs)
-	lastSeq, err := player.Replay(decoder.NewLimitByTimestamp(vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs))
+	lmt, err := createLimiter(player, vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs, vdiskCmdCfg.Marker)
+	if err != nil {
+		player.Close()
+		return err
+	}
+	log.Infof("restoring vdisk %s using %s", vdiskID, limiterString(lmt))
+
+	var lastSeq uint64
+	if vdiskCmdCfg.WindowSize > 0 {
+		lastSeq, err = player.ReplayCoalesced(lmt, tlogplayer.CoalesceConfig{
+			WindowSize: vdiskCmdCfg.WindowSize,
+			JobCount:   vdiskCmdCfg.JobCount,
+		})
+	} else {
+		lastSeq, err = player.Replay(lmt)
+	}
 	log.Infof("restore finished with last sequence = %v", lastSeq)
 	return err
 }
 
+// createLimiter creates the limiter used to replay the tlog,
+// limiting it up to the given marker if one is specified,
+// or by the given timestamps otherwise.
+func createLimiter(player *tlogplayer.Player, startTs, endTs int64, markerName string) (decoder.Limiter, error) {
+	if markerName == "" {
+		return decoder.NewLimitByTimestamp(startTs, endTs), nil
+	}
+	if startTs != 0 || endTs != 0 {
+		return nil, errors.New("can't limit the tlog replay by both a marker and a timestamp")
+	}
+
+	marker, err := player.FindMarker(markerName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't find tlog marker %q", markerName)
+	}
+	return decoder.NewLimitByMarker(0, marker), nil
+}
+
+// limiterString returns a human readable description of the given limiter
+func limiterString(lmt decoder.Limiter) string {
+	if lbm, ok := lmt.(decoder.LimitByMarker); ok {
+		marker := lbm.Marker()
+		return fmt.Sprintf("marker %q (sequence=%v timestamp=%v)",
+			marker.Name, marker.Sequence, marker.Timestamp)
+	}
+	return fmt.Sprintf("start timestamp=%v end timestamp=%v", lmt.FromEpoch(), lmt.ToEpoch())
+}
+
 // checkVdiskExists checks if the vdisk in question already/still exists,
 // and if so, and the force flag is specified, delete the vdisk.
 func checkVdiskExists(vdiskID string, configSource config.Source) error {
@@ -126,8 +172,20 @@ func init() {
 		&vdiskCmdCfg.EndTs,
 		"end-timestamp", 0,
 		"end UTC timestamp in nanosecond(default 0: until the end)")
+	VdiskCmd.Flags().StringVar(
+		&vdiskCmdCfg.Marker,
+		"marker", "",
+		"restore the vdisk up to the last tlog marker with this name, can't be combined with timestamps")
 	VdiskCmd.Flags().BoolVarP(
 		&vdiskCmdCfg.Force,
 		"force", "f", false,
 		"when given, delete the vdisk if it already existed")
+	VdiskCmd.Flags().IntVar(
+		&vdiskCmdCfg.WindowSize,
+		"window", 0,
+		"amount of tlog aggregations coalesced and replayed at once (0: replay all transactions one by one)")
+	VdiskCmd.Flags().IntVarP(
+		&vdiskCmdCfg.JobCount,
+		"jobs", "j", runtime.NumCPU(),
+		"amount of parallel jobs used to replay a window of coalesced aggregations")
 }
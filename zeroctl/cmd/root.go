@@ -29,12 +29,20 @@ func init() {
 	RootCmd.AddCommand(
 		VersionCmd,
 		CopyCmd,
+		CloneCmd,
+		SnapshotCmd,
+		WarmCmd,
+		DetachCmd,
 		DeleteCmd,
 		RestoreCmd,
+		ReplicateCmd,
+		PromoteCmd,
 		ExportCmd,
 		ImportCmd,
 		ListCmd,
 		DescribeCmd,
+		TlogCmd,
+		ConfigCmd,
 	)
 
 	RootCmd.PersistentFlags().BoolVarP(
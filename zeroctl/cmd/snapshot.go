@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/snapshotvdisk"
+)
+
+// SnapshotCmd represents the snapshot subcommand
+var SnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Snapshot a zero-os resource",
+}
+
+func init() {
+	SnapshotCmd.AddCommand(
+		snapshotvdisk.VdiskCmd,
+	)
+}
@@ -0,0 +1,120 @@
+package snapshotvdisk
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var vdiskCmdCfg struct {
+	SourceConfig config.SourceConfig
+	List         bool
+}
+
+// VdiskCmd represents the vdisk snapshot subcommand
+var VdiskCmd = &cobra.Command{
+	Use:   "vdisk vdiskid [snapshotid]",
+	Short: "Create a read-only snapshot of a vdisk",
+	RunE:  snapshotVdisk,
+}
+
+func snapshotVdisk(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("no vdisk identifier given")
+	}
+	if vdiskCmdCfg.List && argn > 1 {
+		return errors.New("too many arguments")
+	}
+	if !vdiskCmdCfg.List && argn != 2 {
+		return errors.New("both a vdisk and snapshot identifier are required")
+	}
+	vdiskID := args[0]
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	// read the configs of the vdisk
+	staticConfig, err := config.ReadVdiskStaticConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	nbdConfig, err := config.ReadVdiskNBDConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	clusterConfig, err := config.ReadStorageClusterConfig(configSource, nbdConfig.StorageClusterID)
+	if err != nil {
+		return err
+	}
+	cluster, err := ardb.NewCluster(*clusterConfig, nil)
+	if err != nil {
+		return err
+	}
+
+	if vdiskCmdCfg.List {
+		snapshots, err := storage.ListSnapshots(vdiskID, cluster)
+		if err != nil {
+			return err
+		}
+		for _, snapshotID := range snapshots {
+			fmt.Println(storage.SnapshotVdiskID(vdiskID, snapshotID))
+		}
+		return nil
+	}
+
+	snapshotID := args[1]
+	err = storage.CreateSnapshot(vdiskID, snapshotID, staticConfig.Type, cluster)
+	if err != nil {
+		return err
+	}
+	log.Infof(
+		"created snapshot %s of vdisk %s, mountable (read-only) as `%s`",
+		snapshotID, vdiskID, storage.SnapshotVdiskID(vdiskID, snapshotID))
+	return nil
+}
+
+func init() {
+	VdiskCmd.Long = VdiskCmd.Short + `
+
+The snapshot is stored within the same storage cluster as the vdisk,
+and can be mounted as a read-only NBD export using the name
+'vdiskid@snapshotid', or deleted using 'zeroctl delete vdisk vdiskid@snapshotid'.
+
+The LBA sectors of a deduped vdisk are frozen when the snapshot is taken,
+while the blocks of a nondeduped vdisk are copied to the snapshot,
+only when they are modified for the first time afterwards (copy-on-write).
+A vdisk cannot be deleted as long as it has snapshots.
+
+NOTE: only data that has been flushed by the nbdserver is part of the snapshot,
+  so it is recommended to freeze (or flush) the filesystem of the vdisk first.
+  Semi-deduped vdisks don't support snapshots.
+`
+
+	VdiskCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+
+	VdiskCmd.Flags().BoolVarP(
+		&vdiskCmdCfg.List,
+		"list", "l", false,
+		"list all snapshots of the vdisk, rather than creating one")
+}
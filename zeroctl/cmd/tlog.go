@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/tloglog"
+)
+
+// TlogCmd represents the tlog subcommand
+var TlogCmd = &cobra.Command{
+	Use:   "tlog",
+	Short: "Inspect the tlog of a zero-os resource",
+}
+
+func init() {
+	TlogCmd.AddCommand(
+		tloglog.LogCmd,
+	)
+}
@@ -0,0 +1,342 @@
+package tloglog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var logCmdCfg struct {
+	SourceConfig config.SourceConfig
+	TlogPrivKey  string
+	StartTs      int64
+	EndTs        int64
+	StartSeq     uint64
+	EndSeq       uint64
+	BlockIndex   int64
+	PrettyPrint  bool
+}
+
+// LogCmd represents the tlog log subcommand
+var LogCmd = &cobra.Command{
+	Use:   "log vdiskid",
+	Short: "Inspect the tlog history of a vdisk",
+	RunE:  inspectTlog,
+}
+
+func inspectTlog(cmd *cobra.Command, args []string) error {
+	logLevel := log.ErrorLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+	vdiskID := args[0]
+
+	lmt, err := createLimiter()
+	if err != nil {
+		return err
+	}
+
+	// create config source
+	cs, err := config.NewSource(logCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	storCli, err := stor.NewClientFromConfigSource(configSource, vdiskID, logCmdCfg.TlogPrivKey)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't create tlog stor client for vdisk %s", vdiskID)
+	}
+	defer storCli.Close()
+
+	var info interface{}
+	if cmd.Flags().Changed("block") {
+		info, err = collectBlockTimeline(storCli, lmt, vdiskID, logCmdCfg.BlockIndex)
+	} else {
+		info, err = collectAggregations(storCli, lmt, vdiskID)
+	}
+	if err != nil {
+		return err
+	}
+
+	var bytes []byte
+	if logCmdCfg.PrettyPrint {
+		bytes, err = json.MarshalIndent(info, "", "  \t")
+	} else {
+		bytes, err = json.Marshal(info)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(bytes))
+	return nil
+}
+
+// createLimiter creates the limiter based on the given flags,
+// limiting by either timestamp or sequence.
+func createLimiter() (decoder.Limiter, error) {
+	bySequence := logCmdCfg.StartSeq != 0 || logCmdCfg.EndSeq != 0
+	byTimestamp := logCmdCfg.StartTs != 0 || logCmdCfg.EndTs != 0
+	if bySequence && byTimestamp {
+		return nil, errors.New("can't limit the tlog history by both sequence and timestamp")
+	}
+	if bySequence {
+		return decoder.NewLimitBySequence(logCmdCfg.StartSeq, logCmdCfg.EndSeq), nil
+	}
+	return decoder.NewLimitByTimestamp(logCmdCfg.StartTs, logCmdCfg.EndTs), nil
+}
+
+// AggregationsInfo lists the tlog aggregations of a vdisk.
+type AggregationsInfo struct {
+	VdiskID      string            `json:"vdiskID"`
+	Aggregations []AggregationInfo `json:"aggregations"`
+}
+
+// AggregationInfo describes a single tlog aggregation,
+// only taking into account the blocks within the requested limits.
+type AggregationInfo struct {
+	Timestamp     int64        `json:"timestamp"`
+	Size          uint64       `json:"size"`
+	FirstSequence uint64       `json:"firstSequence"`
+	LastSequence  uint64       `json:"lastSequence"`
+	Markers       []MarkerInfo `json:"markers,omitempty"`
+}
+
+// MarkerInfo describes a single tlog marker stored in an aggregation.
+type MarkerInfo struct {
+	Name      string `json:"name"`
+	Sequence  uint64 `json:"sequence"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// BlockTimelineInfo lists all operations applied on a single block of a vdisk.
+type BlockTimelineInfo struct {
+	VdiskID    string               `json:"vdiskID"`
+	BlockIndex int64                `json:"blockIndex"`
+	Timeline   []BlockOperationInfo `json:"timeline"`
+}
+
+// BlockOperationInfo describes a single operation applied on a block.
+type BlockOperationInfo struct {
+	Sequence  uint64 `json:"sequence"`
+	Timestamp int64  `json:"timestamp"`
+	Operation string `json:"operation"`
+	Hash      string `json:"hash,omitempty"`
+}
+
+// collectAggregations collects the info of all aggregations within the given limits.
+func collectAggregations(storCli *stor.Client, lmt decoder.Limiter, vdiskID string) (*AggregationsInfo, error) {
+	info := &AggregationsInfo{
+		VdiskID:      vdiskID,
+		Aggregations: []AggregationInfo{},
+	}
+	err := walkBlocks(storCli, lmt, func(agg *schema.TlogAggregation, blocks []schema.TlogBlock) error {
+		markers, err := tlog.AggregationMarkers(agg)
+		if err != nil {
+			return errors.Wrap(err, "couldn't get markers of tlog aggregation")
+		}
+		aggInfo := AggregationInfo{
+			Timestamp:     agg.Timestamp(),
+			Size:          uint64(len(blocks)),
+			FirstSequence: blocks[0].Sequence(),
+			LastSequence:  blocks[len(blocks)-1].Sequence(),
+		}
+		for _, marker := range markers {
+			aggInfo.Markers = append(aggInfo.Markers, MarkerInfo{
+				Name:      marker.Name,
+				Sequence:  marker.Sequence,
+				Timestamp: marker.Timestamp,
+			})
+		}
+		info.Aggregations = append(info.Aggregations, aggInfo)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// collectBlockTimeline collects all operations applied on the given block,
+// within the given limits.
+func collectBlockTimeline(storCli *stor.Client, lmt decoder.Limiter, vdiskID string, blockIndex int64) (*BlockTimelineInfo, error) {
+	info := &BlockTimelineInfo{
+		VdiskID:    vdiskID,
+		BlockIndex: blockIndex,
+		Timeline:   []BlockOperationInfo{},
+	}
+	err := walkBlocks(storCli, lmt, func(_ *schema.TlogAggregation, blocks []schema.TlogBlock) error {
+		for _, block := range blocks {
+			if block.Index() != blockIndex {
+				continue
+			}
+			op, err := newBlockOperationInfo(block)
+			if err != nil {
+				return err
+			}
+			info.Timeline = append(info.Timeline, op)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// newBlockOperationInfo creates the info of the operation stored in a block,
+// using the hash of its data, which is computed if the block has none stored.
+func newBlockOperationInfo(block schema.TlogBlock) (BlockOperationInfo, error) {
+	op := BlockOperationInfo{
+		Sequence:  block.Sequence(),
+		Timestamp: block.Timestamp(),
+	}
+
+	switch block.Operation() {
+	case schema.OpSet:
+		op.Operation = "set"
+	case schema.OpDelete:
+		op.Operation = "delete"
+		return op, nil
+	default:
+		op.Operation = fmt.Sprintf("unknown(%d)", block.Operation())
+		return op, nil
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		return op, errors.Wrapf(err, "couldn't get hash of sequence %d", op.Sequence)
+	}
+	if len(hash) == 0 {
+		data, err := block.Data()
+		if err != nil {
+			return op, errors.Wrapf(err, "couldn't get data of sequence %d", op.Sequence)
+		}
+		hash = zerodisk.HashBytes(data)
+	}
+	op.Hash = hex.EncodeToString(hash)
+	return op, nil
+}
+
+// walkBlocks walks the tlog history, calling the given callback
+// for each aggregation which has blocks within the given limits,
+// with only those blocks.
+func walkBlocks(storCli *stor.Client, lmt decoder.Limiter, cb func(*schema.TlogAggregation, []schema.TlogBlock) error) error {
+	for wr := range storCli.Walk(lmt.FromEpoch(), lmt.ToEpoch()) {
+		if wr.Err != nil {
+			return wr.Err
+		}
+
+		blockList, size, err := tlog.AggregationBlocks(wr.Agg)
+		if err != nil {
+			return errors.Wrap(err, "couldn't get blocks of tlog aggregation")
+		}
+
+		var blocks []schema.TlogBlock
+		var end bool
+		for i := 0; i < size; i++ {
+			block := blockList.At(i)
+			if !lmt.StartBlock(block) {
+				continue
+			}
+			if lmt.EndBlock(block) {
+				end = true
+				break
+			}
+			blocks = append(blocks, block)
+		}
+
+		if len(blocks) > 0 {
+			err = cb(wr.Agg, blocks)
+			if err != nil {
+				return err
+			}
+		}
+		if end {
+			return nil
+		}
+	}
+	return nil
+}
+
+func init() {
+	LogCmd.Long = LogCmd.Short + `
+
+All tlog aggregations of a vdisk are listed in JSON format and written to the STDOUT.
+The printed JSON object can have following properties:
+
++ "vdiskID": the identifier of the vdisk;
++ "aggregations": a list of all aggregations, with for each aggregation:
+  + "timestamp": the UTC timestamp (in nanoseconds) of the aggregation;
+  + "size": the amount of blocks (transactions) in the aggregation;
+  + "firstSequence": the sequence of the first block in the aggregation;
+  + "lastSequence": the sequence of the last block in the aggregation;
+  + "markers": the tlog markers stored in the aggregation (if any),
+    each with a "name", "sequence" and "timestamp";
+
+When the --block flag is given, the timeline of that block is printed instead:
+
++ "vdiskID": the identifier of the vdisk;
++ "blockIndex": the index of the block;
++ "timeline": a list of all operations applied on the block, with for each operation:
+  + "sequence": the sequence of the operation;
+  + "timestamp": the UTC timestamp (in nanoseconds) of the operation;
+  + "operation": the operation applied on the block, "set" or "delete";
+  + "hash": the hex-encoded hash of the data set (only for "set" operations);
+
+The history can be limited by either timestamp or sequence.
+`
+
+	LogCmd.Flags().Var(
+		&logCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	LogCmd.Flags().StringVar(
+		&logCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+	LogCmd.Flags().Int64Var(
+		&logCmdCfg.StartTs,
+		"start-timestamp", 0,
+		"start UTC timestamp in nanosecond(default 0: since beginning)")
+	LogCmd.Flags().Int64Var(
+		&logCmdCfg.EndTs,
+		"end-timestamp", 0,
+		"end UTC timestamp in nanosecond(default 0: until the end)")
+	LogCmd.Flags().Uint64Var(
+		&logCmdCfg.StartSeq,
+		"start-seq", 0,
+		"start sequence(default 0: since beginning)")
+	LogCmd.Flags().Uint64Var(
+		&logCmdCfg.EndSeq,
+		"end-seq", 0,
+		"end sequence(default 0: until the end)")
+	LogCmd.Flags().Int64Var(
+		&logCmdCfg.BlockIndex,
+		"block", 0,
+		"when given, print the timeline of the block at this index, instead of the aggregations")
+	LogCmd.Flags().BoolVar(
+		&logCmdCfg.PrettyPrint, "pretty", false,
+		"pretty print output when this flag is specified")
+}
@@ -0,0 +1,156 @@
+package tloglog
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+)
+
+func TestWalkBlocks(t *testing.T) {
+	storCli, cleanup := newTestStorClient(t)
+	defer cleanup()
+
+	storeTestAggregations(t, storCli)
+
+	var sequences [][]uint64
+	collect := func(agg *schema.TlogAggregation, blocks []schema.TlogBlock) error {
+		var seqs []uint64
+		for _, block := range blocks {
+			seqs = append(seqs, block.Sequence())
+		}
+		sequences = append(sequences, seqs)
+		return nil
+	}
+
+	// all blocks, not including the unused room of the partially filled aggregation
+	err := walkBlocks(storCli, decoder.NewLimitBySequence(0, 0), collect)
+	require.NoError(t, err)
+	require.Equal(t, [][]uint64{{1, 2, 3, 4}, {5, 6}}, sequences)
+
+	// only the blocks within the limits
+	sequences = nil
+	err = walkBlocks(storCli, decoder.NewLimitBySequence(3, 5), collect)
+	require.NoError(t, err)
+	require.Equal(t, [][]uint64{{3, 4}, {5}}, sequences)
+
+	// aggregations without blocks within the limits are skipped
+	sequences = nil
+	err = walkBlocks(storCli, decoder.NewLimitBySequence(5, 0), collect)
+	require.NoError(t, err)
+	require.Equal(t, [][]uint64{{5, 6}}, sequences)
+}
+
+func TestCollectBlockTimeline(t *testing.T) {
+	storCli, cleanup := newTestStorClient(t)
+	defer cleanup()
+
+	storeTestAggregations(t, storCli)
+
+	info, err := collectBlockTimeline(storCli, decoder.NewLimitBySequence(0, 0), "vdisk", 0)
+	require.NoError(t, err)
+	require.Equal(t, "vdisk", info.VdiskID)
+	require.Equal(t, int64(0), info.BlockIndex)
+	require.Equal(t, []BlockOperationInfo{
+		{Sequence: 1, Timestamp: 1, Operation: "set", Hash: testBlockHash(1)},
+		{Sequence: 3, Timestamp: 3, Operation: "set", Hash: testBlockHash(3)},
+		{Sequence: 5, Timestamp: 5, Operation: "delete"},
+	}, info.Timeline)
+
+	// only the operations within the limits
+	info, err = collectBlockTimeline(storCli, decoder.NewLimitBySequence(2, 4), "vdisk", 0)
+	require.NoError(t, err)
+	require.Equal(t, []BlockOperationInfo{
+		{Sequence: 3, Timestamp: 3, Operation: "set", Hash: testBlockHash(3)},
+	}, info.Timeline)
+
+	// a block which was never written has an empty timeline
+	info, err = collectBlockTimeline(storCli, decoder.NewLimitBySequence(0, 0), "vdisk", 42)
+	require.NoError(t, err)
+	require.Empty(t, info.Timeline)
+}
+
+// stores an aggregation with sequences 1-4 (block indices 0, 1, 0, 2),
+// followed by a partially filled aggregation with sequences 5-6 (block indices 0, 1),
+// where sequence 5 deletes block 0
+func storeTestAggregations(t *testing.T, storCli *stor.Client) {
+	indices := []int64{0, 1, 0, 2, 0, 1}
+	for _, seqs := range [][]uint64{{1, 2, 3, 4}, {5, 6}} {
+		agg, err := tlog.NewAggregation(nil, 4)
+		require.NoError(t, err)
+		for _, seq := range seqs {
+			tx := tlog.Transaction{
+				Operation: schema.OpSet,
+				Sequence:  seq,
+				Content:   testBlockData(seq),
+				Index:     indices[seq-1],
+				Timestamp: int64(seq),
+			}
+			if seq == 5 {
+				tx.Operation = schema.OpDelete
+				tx.Content = nil
+			}
+			require.NoError(t, agg.AddTransaction(tx))
+		}
+		_, err = storCli.ProcessStoreAgg(agg)
+		require.NoError(t, err)
+	}
+}
+
+func testBlockData(seq uint64) []byte {
+	data := make([]byte, 512)
+	for i := range data {
+		data[i] = byte(seq)
+	}
+	return data
+}
+
+func testBlockHash(seq uint64) string {
+	return hex.EncodeToString(zerodisk.HashBytes(testBlockData(seq)))
+}
+
+func newTestStorClient(t *testing.T) (*stor.Client, func()) {
+	const (
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	if err != nil {
+		mdServer.Stop()
+		t.Fatal(err)
+	}
+
+	storCli, err := stor.NewClient(stor.Config{
+		VdiskID:         "vdisk",
+		Organization:    "testorg",
+		Namespace:       "thedisk",
+		ZeroStorShards:  storCluster.Addrs(),
+		MetaShards:      []string{mdServer.ListenAddr()},
+		DataShardsNum:   dataShards,
+		ParityShardsNum: parityShards,
+		EncryptPrivKey:  "12345678901234567890123456789012",
+	})
+	if err != nil {
+		storCluster.Close()
+		mdServer.Stop()
+		t.Fatal(err)
+	}
+
+	return storCli, func() {
+		storCli.Close()
+		storCluster.Close()
+		mdServer.Stop()
+	}
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/warmvdisk"
+)
+
+// WarmCmd represents the warm subcommand
+var WarmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Warm up a zero-os resource",
+}
+
+func init() {
+	WarmCmd.AddCommand(
+		warmvdisk.VdiskCmd,
+	)
+}
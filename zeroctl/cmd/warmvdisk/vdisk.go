@@ -0,0 +1,123 @@
+package warmvdisk
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var vdiskCmdCfg struct {
+	SourceConfig config.SourceConfig
+	Rate         int64
+	JobCount     int
+}
+
+// VdiskCmd represents the vdisk warm subcommand
+var VdiskCmd = &cobra.Command{
+	Use:   "vdisk vdiskid",
+	Short: "Fetch all missing content of a vdisk from its template cluster",
+	RunE:  warmVdisk,
+}
+
+func warmVdisk(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+	vdiskID := args[0]
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	// read the configs of the vdisk
+	staticConfig, err := config.ReadVdiskStaticConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	if !staticConfig.Type.TemplateSupport() {
+		return errors.Newf(
+			"vdisk %s can't be warmed up, as its type %s has no template support",
+			vdiskID, staticConfig.Type)
+	}
+	nbdStorageConfig, err := config.ReadNBDStorageConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	if nbdStorageConfig.TemplateStorageCluster == nil {
+		return errors.Newf(
+			"vdisk %s can't be warmed up, as it has no template cluster configured", vdiskID)
+	}
+
+	// create the primary and template cluster
+	cluster, err := ardb.NewCluster(nbdStorageConfig.StorageCluster, nil)
+	if err != nil {
+		return err
+	}
+	templateCluster, err := ardb.NewCluster(*nbdStorageConfig.TemplateStorageCluster, nil)
+	if err != nil {
+		return err
+	}
+
+	// load the lineage, in case the vdisk is a clone
+	lineage, err := storage.LoadVdiskLineage(vdiskID, cluster)
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.WarmVdisk(context.Background(), storage.WarmConfig{
+		VdiskID:         vdiskID,
+		TemplateVdiskID: staticConfig.TemplateVdiskID,
+		Type:            staticConfig.Type,
+		Lineage:         lineage,
+		Rate:            vdiskCmdCfg.Rate,
+		JobCount:        vdiskCmdCfg.JobCount,
+	}, cluster, templateCluster)
+	return err
+}
+
+func init() {
+	VdiskCmd.Long = VdiskCmd.Short + `
+
+Content which isn't available in the primary storage cluster of a vdisk
+is normally fetched lazily from its template storage cluster,
+when it is read for the first time. Warming up a vdisk fetches all
+that content upfront, such that the vdisk no longer depends on the
+template storage cluster once it is read.
+
+For (semi) deduped vdisks the LBA of the vdisk is walked,
+while for nondeduped vdisks the blocks of the template vdisk are listed.
+Blocks which are already available in the primary storage cluster
+are never overwritten, so a vdisk can be warmed up while it is mounted.
+`
+
+	VdiskCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	VdiskCmd.Flags().Int64Var(
+		&vdiskCmdCfg.Rate, "rate", 0,
+		"max amount of content blocks to check per second, 0 means unlimited")
+	VdiskCmd.Flags().IntVarP(
+		&vdiskCmdCfg.JobCount, "jobs", "j", runtime.NumCPU(),
+		"the amount of parallel jobs to run")
+}